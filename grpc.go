@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+
+	"github.com/goccy/go-json"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/jasonlovesdoggo/abacus/middleware"
+	"github.com/jasonlovesdoggo/abacus/pb"
+
+	"github.com/gin-gonic/gin/binding"
+)
+
+// counterServer serves the Counters gRPC service by running every call through the REST handler of the same
+// endpoint in process, asking for its protobuf response. That way both interfaces validate, authorize, rate limit,
+// expire and count hits the same way without the logic having to be kept in sync.
+type counterServer struct {
+	pb.UnimplementedCountersServer
+	router http.Handler
+}
+
+// NewGRPCServer returns a gRPC server serving the Counters service through router.
+func NewGRPCServer(router http.Handler) *grpc.Server {
+	server := grpc.NewServer()
+	pb.RegisterCountersServer(server, &counterServer{router: router})
+	return server
+}
+
+func (s *counterServer) Get(ctx context.Context, request *pb.CounterRequest) (*pb.Counter, error) {
+	response := &pb.Counter{}
+	return response, s.call(ctx, http.MethodGet, "/get", request.Namespace, request.Key, nil, response)
+}
+
+func (s *counterServer) Hit(ctx context.Context, request *pb.HitRequest) (*pb.Counter, error) {
+	query := url.Values{}
+	switch step := request.Step.(type) {
+	case *pb.HitRequest_IntStep:
+		query.Set("step", strconv.FormatInt(step.IntStep, 10))
+	case *pb.HitRequest_FloatStep:
+		query.Set("step", strconv.FormatFloat(step.FloatStep, 'f', -1, 64))
+	}
+	response := &pb.Counter{}
+	return response, s.call(ctx, http.MethodGet, "/hit", request.Namespace, request.Key, query, response)
+}
+
+func (s *counterServer) Set(ctx context.Context, request *pb.SetRequest) (*pb.Counter, error) {
+	query := url.Values{"value": {strconv.FormatInt(request.Value, 10)}}
+	response := &pb.Counter{}
+	return response, s.call(ctx, http.MethodPost, "/set", request.Namespace, request.Key, query, response)
+}
+
+func (s *counterServer) Info(ctx context.Context, request *pb.CounterRequest) (*pb.CounterInfo, error) {
+	response := &pb.CounterInfo{}
+	return response, s.call(ctx, http.MethodGet, "/info", request.Namespace, request.Key, nil, response)
+}
+
+// forwardedMetadata are the headers a call passes on to the REST handler as metadata of the same (lower case) name:
+// its token and, for namespaces that require signed requests, the signature of the request it is forwarded as.
+var forwardedMetadata = []string{"Authorization", middleware.SignatureHeader, middleware.TimestampHeader}
+
+// call sends a request for the counter namespace/key to the endpoint at path and decodes its response into message,
+// translating failures into the gRPC status closest to their HTTP status.
+func (s *counterServer) call(ctx context.Context, method, path, namespace, key string, query url.Values, message proto.Message) error {
+	if namespace != "" {
+		path += "/" + url.PathEscape(namespace)
+	}
+	path += "/" + url.PathEscape(key)
+	if len(query) > 0 {
+		path += "?" + query.Encode()
+	}
+	request, err := http.NewRequestWithContext(ctx, method, path, nil)
+	if err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+	request.Header.Set("Accept", binding.MIMEPROTOBUF)
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		for _, header := range forwardedMetadata {
+			if values := md.Get(header); len(values) > 0 {
+				request.Header.Set(header, values[0])
+			}
+		}
+	}
+	if p, ok := peer.FromContext(ctx); ok {
+		request.RemoteAddr = p.Addr.String() // rate limits and unique visitors go by the IP of the caller
+	}
+
+	recorder := httptest.NewRecorder()
+	s.router.ServeHTTP(recorder, request)
+	if recorder.Code >= 200 && recorder.Code < 300 {
+		if err := proto.Unmarshal(recorder.Body.Bytes(), message); err != nil {
+			return status.Error(codes.Internal, "Failed to get data. Try again later.")
+		}
+		return nil
+	}
+	var failure struct {
+		Error string `json:"error"`
+	}
+	if json.Unmarshal(recorder.Body.Bytes(), &failure) != nil || failure.Error == "" {
+		failure.Error = http.StatusText(recorder.Code)
+	}
+	return status.Error(grpcCode(recorder.Code), failure.Error)
+}
+
+// grpcCode maps the HTTP status of a failed request to the gRPC code with the same meaning.
+func grpcCode(httpStatus int) codes.Code {
+	switch httpStatus {
+	case http.StatusBadRequest:
+		return codes.InvalidArgument
+	case http.StatusUnauthorized:
+		return codes.Unauthenticated
+	case http.StatusForbidden:
+		return codes.PermissionDenied
+	case http.StatusNotFound:
+		return codes.NotFound
+	case http.StatusConflict:
+		return codes.FailedPrecondition
+	case http.StatusTooManyRequests:
+		return codes.ResourceExhausted
+	case http.StatusServiceUnavailable:
+		return codes.Unavailable
+	case http.StatusInternalServerError:
+		return codes.Internal
+	}
+	return codes.Unknown
+}
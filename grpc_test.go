@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/goccy/go-json"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	"github.com/jasonlovesdoggo/abacus/pb"
+	"github.com/jasonlovesdoggo/abacus/utils"
+)
+
+// grpcClient serves the Counters service through r over an in-memory connection for the duration of the test.
+func grpcClient(t *testing.T, r http.Handler) pb.CountersClient {
+	listener := bufconn.Listen(1 << 20)
+	server := NewGRPCServer(r)
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+	conn, err := grpc.NewClient("passthrough:///bufconn", grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return listener.DialContext(ctx) }))
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	return pb.NewCountersClient(conn)
+}
+
+func TestGRPCServer(t *testing.T) {
+	r := setupTestRouter()
+	client := grpcClient(t, r)
+	ctx := context.Background()
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/create/grpc_ns/visits?initializer=5", nil)
+	r.ServeHTTP(w, req)
+	require.Equal(t, http.StatusCreated, w.Code)
+	var created map[string]interface{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &created))
+
+	counter, err := client.Get(ctx, &pb.CounterRequest{Namespace: "grpc_ns", Key: "visits"})
+	require.NoError(t, err)
+	assert.Equal(t, int64(5), counter.GetIntValue())
+
+	counter, err = client.Hit(ctx, &pb.HitRequest{Namespace: "grpc_ns", Key: "visits", Step: &pb.HitRequest_IntStep{IntStep: 3}})
+	require.NoError(t, err)
+	assert.Equal(t, int64(8), counter.GetIntValue())
+
+	info, err := client.Info(ctx, &pb.CounterRequest{Namespace: "grpc_ns", Key: "visits"})
+	require.NoError(t, err)
+	assert.Equal(t, int64(8), info.GetIntValue())
+	assert.True(t, info.Exists)
+
+	t.Run("Errors", func(t *testing.T) {
+		_, err := client.Get(ctx, &pb.CounterRequest{Namespace: "grpc_ns", Key: "missing"})
+		assert.Equal(t, codes.NotFound, status.Code(err))
+		_, err = client.Hit(ctx, &pb.HitRequest{Namespace: "grpc_ns", Key: "visits", Step: &pb.HitRequest_FloatStep{FloatStep: 1.5}})
+		assert.Equal(t, codes.FailedPrecondition, status.Code(err)) // a 409 over REST, the counter is an int
+		_, err = client.Set(ctx, &pb.SetRequest{Namespace: "grpc_ns", Key: "visits", Value: 1})
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+		assert.Contains(t, status.Convert(err).Message(), "Token is required")
+		_, err = client.Set(metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer wrong"),
+			&pb.SetRequest{Namespace: "grpc_ns", Key: "visits", Value: 1})
+		assert.Equal(t, codes.Unauthenticated, status.Code(err))
+	})
+
+	t.Run("Set with the admin token", func(t *testing.T) {
+		authorized := metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+created["admin_key"].(string))
+		counter, err := client.Set(authorized, &pb.SetRequest{Namespace: "grpc_ns", Key: "visits", Value: 42})
+		require.NoError(t, err)
+		assert.Equal(t, int64(42), counter.GetIntValue())
+	})
+}
+
+func TestGRPCSignedRequests(t *testing.T) {
+	RequestSigning = true
+	defer func() { RequestSigning = false }()
+	r := setupTestRouter()
+	client := grpcClient(t, r)
+	ctx := context.Background()
+
+	request := func(path, token string) map[string]interface{} {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", path, nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		r.ServeHTTP(w, req)
+		require.Equal(t, http.StatusCreated, w.Code, path)
+		var response map[string]interface{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		return response
+	}
+	token := request("/namespace/grpc_signed_ns/token", "")["admin_key"].(string)
+	request("/create/grpc_signed_ns/visits", "")
+	secret := request("/namespace/grpc_signed_ns/secret", token)["signing_secret"].(string)
+
+	set := func(value int64, signature, timestamp string) (*pb.Counter, error) {
+		md := metadata.Pairs("authorization", "Bearer "+token)
+		if signature != "" {
+			md.Append("x-signature", signature)
+			md.Append("x-timestamp", timestamp)
+		}
+		return client.Set(metadata.NewOutgoingContext(ctx, md), &pb.SetRequest{Namespace: "grpc_signed_ns", Key: "visits", Value: value})
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+
+	t.Run("Signatures are forwarded", func(t *testing.T) {
+		counter, err := set(42, utils.SignRequest(secret, timestamp, "POST", "/set/grpc_signed_ns/visits?value=42", nil), timestamp)
+		require.NoError(t, err)
+		assert.Equal(t, int64(42), counter.GetIntValue())
+	})
+
+	t.Run("Unsigned or wrongly signed calls are rejected", func(t *testing.T) {
+		_, err := set(7, "", "")
+		assert.Equal(t, codes.Unauthenticated, status.Code(err))
+		_, err = set(7, utils.SignRequest(secret, timestamp, "POST", "/set/grpc_signed_ns/visits?value=42", nil), timestamp)
+		assert.Equal(t, codes.Unauthenticated, status.Code(err))
+	})
+}
@@ -3,11 +3,14 @@ package main
 import (
 	"context"
 	"errors"
-	"fmt"
 	"log"
+	"net"
 	"net/http"
+	"net/url"
 	"os"
 	"os/signal"
+	"runtime/debug"
+	"slices"
 	"strconv"
 	"strings"
 	"syscall"
@@ -18,6 +21,7 @@ import (
 	"github.com/anandvarma/namegen"
 
 	"github.com/redis/go-redis/v9"
+	"google.golang.org/grpc"
 
 	"github.com/jasonlovesdoggo/abacus/middleware"
 
@@ -30,23 +34,59 @@ import (
 )
 
 const (
-	DocsUrl string = "http://localhost:8080/abacus/"
-	Version string = "1.3.3"
+	DefaultDocsUrl string = "http://localhost:8080/abacus/"
+	DefaultPort    string = "8080" // port the server listens on unless PORT says otherwise
+	Version        string = "1.3.3"
 )
 
 var (
-	Client          *redis.Client
+	Client          redis.UniversalClient
 	RateLimitClient *redis.Client
+	ReplicaClient   *redis.Client
 	DbNum           = 0 // 0-16
 	StartTime       time.Time
 	Shard           string
+	GitCommit       string                // commit the binary was built from, set with -ldflags "-X main.GitCommit=..."
+	BuildTime       string                // when the binary was built, set with -ldflags "-X main.BuildTime=..."
+	MaxTTL          = utils.BaseTTLPeriod // longest custom ttl a counter can be created with
+	CORSOrigins     []string              // origins allowed to call the API from a browser, nil allows all of them
+	CORSCredentials bool                  // lets browsers send cookies along with the cross-origin requests of CORSOrigins
+	CORSMaxAge      = 12 * time.Hour      // how long browsers may cache the answers to preflight requests
+	CreatorIPSalt   string                // when set, counters record a hash of the IP they were created from
+	CensusInterval  = 5 * time.Minute     // how long the counter counts in /stats are cached for
+	MaxCounters     int64                 // most counters a namespace can hold unless its N: hash overrides it, 0 for no limit
+	RedisTimeout    = 5 * time.Second     // how long the Redis calls of a request may take, 0 for no limit
+	RedisBreaker    *utils.Breaker        // stops calling Redis for a while after consecutive failures, nil if disabled
+	ReplicaBreaker  *utils.Breaker        // RedisBreaker of the replica, reads go to the primary while it is open
+	PublicURL       string                // address the server is reached at, used in links such as admin_url
+	DocsUrl         = DefaultDocsUrl      // where unknown routes and /docs redirect to
+	TrustedProxies  []string              // proxies (IPs or CIDRs) whose X-Forwarded-For is believed, nil trusts none
+	OperatorToken   string                // lets the operator toggle the read-only mode with /maintenance, unset disables it
+	RequestSigning  bool                  // lets namespaces require their admin requests to be signed, see middleware.Signature
+	MaxBodyBytes    = int64(10 << 20)     // largest request body accepted, 0 for no limit
+	ShardClients    []*redis.Client       // the other shards /get?shards=true sums a counter across, nil if not sharded
+	ExpiryEvents    bool                  // streams the expirations of counters at /expired/:namespace
+	GRPCPort        string                // port the gRPC service listens on, unset disables it
+	RedisCluster    bool                  // connects to a Redis Cluster, keeping the keys of each namespace in one hash slot
+	CreateOnHit     = true                // hits create missing counters unless ?create=false, CREATE_ON_HIT=false inverts it
+	SubdomainBase   string                // domain whose subdomains name the namespace of the counters, unset disables it
+	EventsBackend   string                // where the events of writes are published, unset disables them
+	EventStream     string                // redis stream the events are added to
+	EventBuffer     int                   // events waiting to be published before new ones are dropped
+	Census          *utils.CensusCache
+	// shutdownCtx is cancelled when the server starts shutting down, telling the open streams to close.
+	shutdownCtx, shutdown = context.WithCancel(context.Background())
 )
 
 func init() {
+	loadBuildInfo()
 	utils.LoadEnv()
+	loadConfig()
 	// Use miniredis for testing
 	if strings.ToLower(os.Getenv("TESTING")) == "true" {
 		setupMockRedis()
+		setupEvents()
+		setupExpirations()
 		return
 	}
 
@@ -63,20 +103,430 @@ func init() {
 	log.Println("Listening to redis on: " + ADDR)
 	DbNum, _ = strconv.Atoi(os.Getenv("REDIS_DB"))
 
-	Client = redis.NewClient(&redis.Options{
-		Addr:     ADDR, // Redis server address
-		Username: os.Getenv("REDIS_USERNAME"),
-		Password: os.Getenv("REDIS_PASSWORD"),
-		DB:       DbNum,
+	if RedisCluster {
+		setupCluster(ADDR)
+	} else {
+		Client = redis.NewClient(&redis.Options{
+			Addr:     ADDR, // Redis server address
+			Username: os.Getenv("REDIS_USERNAME"),
+			Password: os.Getenv("REDIS_PASSWORD"),
+			DB:       DbNum,
+			// lets the deadlines of requests interrupt calls waiting on a slow server, not just those waiting for a connection
+			ContextTimeoutEnabled: true,
+		})
+		RateLimitClient = redis.NewClient(&redis.Options{
+			Addr:     ADDR, // Redis server address
+			Username: os.Getenv("REDIS_USERNAME"),
+			Password: os.Getenv("REDIS_PASSWORD"),
+			DB:       DbNum + 1,
+		})
+	}
+	Client.AddHook(utils.RedisErrorHook{})
+	if RedisBreaker != nil {
+		Client.AddHook(RedisBreaker)
+	}
+	if replicaHost := os.Getenv("REDIS_REPLICA_HOST"); replicaHost != "" {
+		replicaPort := os.Getenv("REDIS_REPLICA_PORT")
+		if replicaPort == "" {
+			replicaPort = os.Getenv("REDIS_PORT")
+		}
+		log.Println("Reading from the redis replica on: " + replicaHost + ":" + replicaPort)
+		ReplicaClient = redis.NewClient(&redis.Options{
+			Addr:                  replicaHost + ":" + replicaPort,
+			Username:              os.Getenv("REDIS_USERNAME"),
+			Password:              os.Getenv("REDIS_PASSWORD"),
+			DB:                    DbNum,
+			ContextTimeoutEnabled: true,
+		})
+		ReplicaClient.AddHook(utils.RedisErrorHook{})
+		if ReplicaBreaker != nil {
+			ReplicaClient.AddHook(ReplicaBreaker)
+		}
+	}
+	if rawShards := os.Getenv("REDIS_SHARDS"); rawShards != "" {
+		for _, addr := range strings.Split(rawShards, ",") {
+			addr = strings.TrimSpace(addr)
+			if _, _, err := net.SplitHostPort(addr); err != nil {
+				log.Fatalf("Invalid REDIS_SHARDS address %q, please provide a comma separated list of host:port", addr)
+			}
+			shard := redis.NewClient(&redis.Options{
+				Addr:                  addr,
+				Username:              os.Getenv("REDIS_USERNAME"),
+				Password:              os.Getenv("REDIS_PASSWORD"),
+				DB:                    DbNum,
+				ContextTimeoutEnabled: true,
+			})
+			shard.AddHook(utils.RedisErrorHook{})
+			ShardClients = append(ShardClients, shard)
+		}
+		log.Printf("Summing counters across %d other shards for /get?shards=true", len(ShardClients))
+	}
+	setupEvents()
+	setupExpirations()
+}
+
+// setupCluster connects to the Redis Cluster addr is a node of, the other nodes are discovered from it. A cluster only
+// has database 0 and the rate limiter can't talk to one, so the rate limits are kept on the standalone server at
+// RATE_LIMIT_REDIS_ADDR instead.
+func setupCluster(addr string) {
+	rateLimitAddr := os.Getenv("RATE_LIMIT_REDIS_ADDR")
+	if _, _, err := net.SplitHostPort(rateLimitAddr); err != nil {
+		log.Fatalf("Invalid RATE_LIMIT_REDIS_ADDR %q, REDIS_CLUSTER requires the host:port of a standalone redis to keep the rate limits on", rateLimitAddr)
+	}
+	if DbNum != 0 {
+		log.Fatalf("Invalid REDIS_DB %d, a redis cluster only has database 0", DbNum)
+	}
+	if os.Getenv("REDIS_REPLICA_HOST") != "" {
+		log.Fatalf("REDIS_REPLICA_HOST can't be used with REDIS_CLUSTER, the cluster serves reads from its own masters")
+	}
+	log.Println("Listening to the redis cluster of the node " + addr + ", rate limiting on " + rateLimitAddr)
+	Client = redis.NewClusterClient(&redis.ClusterOptions{
+		Addrs:                 []string{addr},
+		Username:              os.Getenv("REDIS_USERNAME"),
+		Password:              os.Getenv("REDIS_PASSWORD"),
+		ContextTimeoutEnabled: true,
 	})
 	RateLimitClient = redis.NewClient(&redis.Options{
-		Addr:     ADDR, // Redis server address
+		Addr:     rateLimitAddr,
 		Username: os.Getenv("REDIS_USERNAME"),
 		Password: os.Getenv("REDIS_PASSWORD"),
-		DB:       DbNum + 1,
 	})
 }
 
+// setupExpirations starts watching for expired counters if ExpiryEvents is enabled. Redis only reports them with
+// notify-keyspace-events including "Ex", which is up to the operator (managed servers often don't allow CONFIG).
+func setupExpirations() {
+	if !ExpiryEvents {
+		return
+	}
+	if config, err := Client.ConfigGet(context.Background(), "notify-keyspace-events").Result(); err == nil {
+		if flags := config["notify-keyspace-events"]; !strings.Contains(flags, "E") || !strings.ContainsAny(flags, "xA") {
+			log.Printf("EXPIRY_EVENTS is enabled but redis has notify-keyspace-events %q, set it to include Ex to get them", flags)
+		}
+	}
+	utils.Expirations = utils.NewExpiryWatcher()
+	go utils.Expirations.Watch(shutdownCtx, Client, DbNum)
+}
+
+// setupEvents starts publishing the events of writes to EventsBackend, if one is configured.
+func setupEvents() {
+	switch EventsBackend {
+	case "":
+		return
+	case "redis":
+		utils.Events = utils.NewEventQueue(utils.RedisStreamPublisher{
+			Client: Client,
+			Stream: EventStream,
+			MaxLen: utils.MaxEventStreamLength,
+		}, EventBuffer)
+		log.Printf("Publishing the events of writes to the redis stream %s", EventStream)
+	}
+}
+
+// loadBuildInfo falls back to the version control info the go tool embeds in the binary for the GitCommit and
+// BuildTime that weren't set with -ldflags, which is the commit time rather than the build time.
+func loadBuildInfo() {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return
+	}
+	for _, setting := range info.Settings {
+		switch {
+		case setting.Key == "vcs.revision" && GitCommit == "":
+			GitCommit = setting.Value
+		case setting.Key == "vcs.time" && BuildTime == "":
+			BuildTime = setting.Value
+		}
+	}
+}
+
+// loadConfig reads the optional settings from the environment, exiting if any of them are malformed.
+func loadConfig() {
+	logFormat := os.Getenv("LOG_FORMAT")
+	if logFormat == "" {
+		logFormat = "text"
+	}
+	if err := utils.SetupLogging(logFormat); err != nil {
+		log.Fatalf("Invalid LOG_FORMAT %q: %v", logFormat, err)
+	}
+	if rawDefaultTTL := os.Getenv("DEFAULT_TTL"); rawDefaultTTL != "" {
+		defaultTTL, err := time.ParseDuration(rawDefaultTTL)
+		if err != nil || defaultTTL < time.Second {
+			log.Fatalf("Invalid DEFAULT_TTL %q, please provide a duration of at least a second such as 720h", rawDefaultTTL)
+		}
+		utils.BaseTTLPeriod = defaultTTL
+	}
+	log.Printf("Counters without a custom ttl expire after %s without being used", utils.BaseTTLPeriod)
+	if rawMaxPathLength := os.Getenv("MAX_KEY_PATH_LENGTH"); rawMaxPathLength != "" {
+		// checked on its own, on top of the lengths of the namespace and key, so it may also be lowered below them
+		maxPathLength, err := strconv.Atoi(rawMaxPathLength)
+		if err != nil || maxPathLength < 1 {
+			log.Fatalf("Invalid MAX_KEY_PATH_LENGTH %q, please provide a positive number", rawMaxPathLength)
+		}
+		utils.MaxKeyPathLength = maxPathLength
+	}
+	if rawMaxDepth := os.Getenv("MAX_KEY_DEPTH"); rawMaxDepth != "" {
+		maxDepth, err := strconv.Atoi(rawMaxDepth)
+		if err != nil || maxDepth < 1 || maxDepth > utils.DefaultMaxKeyDepth {
+			log.Fatalf("Invalid MAX_KEY_DEPTH %q, please provide 1 or %d", rawMaxDepth, utils.DefaultMaxKeyDepth)
+		}
+		utils.MaxKeyDepth = maxDepth
+	}
+	if rawMaxTTL := os.Getenv("MAX_TTL"); rawMaxTTL != "" {
+		maxTTL, err := time.ParseDuration(rawMaxTTL)
+		if err != nil || maxTTL <= 0 {
+			log.Fatalf("Invalid MAX_TTL %q, please provide a positive duration such as 720h", rawMaxTTL)
+		}
+		MaxTTL = maxTTL
+	}
+	if rawInterval := os.Getenv("STATS_CENSUS_INTERVAL"); rawInterval != "" {
+		interval, err := time.ParseDuration(rawInterval)
+		if err != nil || interval <= 0 {
+			log.Fatalf("Invalid STATS_CENSUS_INTERVAL %q, please provide a positive duration such as 5m", rawInterval)
+		}
+		CensusInterval = interval
+	}
+	if rawBodyBytes := os.Getenv("MAX_BODY_BYTES"); rawBodyBytes != "" {
+		maxBodyBytes, err := strconv.ParseInt(rawBodyBytes, 10, 64)
+		if err != nil || maxBodyBytes < 0 {
+			log.Fatalf("Invalid MAX_BODY_BYTES %q, please provide a number of bytes such as 1048576, or 0 for no limit", rawBodyBytes)
+		}
+		MaxBodyBytes = maxBodyBytes
+	}
+	if rawTimeout := os.Getenv("REDIS_TIMEOUT"); rawTimeout != "" {
+		timeout, err := time.ParseDuration(rawTimeout)
+		if err != nil || timeout < 0 {
+			log.Fatalf("Invalid REDIS_TIMEOUT %q, please provide a duration such as 2s, or 0 for no limit", rawTimeout)
+		}
+		RedisTimeout = timeout
+	}
+	threshold, cooldown := 5, 10*time.Second
+	if rawThreshold := os.Getenv("REDIS_BREAKER_THRESHOLD"); rawThreshold != "" {
+		var err error
+		if threshold, err = strconv.Atoi(rawThreshold); err != nil || threshold < 0 {
+			log.Fatalf("Invalid REDIS_BREAKER_THRESHOLD %q, please provide a number of failures, or 0 to disable the breaker", rawThreshold)
+		}
+	}
+	if rawCooldown := os.Getenv("REDIS_BREAKER_COOLDOWN"); rawCooldown != "" {
+		var err error
+		if cooldown, err = time.ParseDuration(rawCooldown); err != nil || cooldown <= 0 {
+			log.Fatalf("Invalid REDIS_BREAKER_COOLDOWN %q, please provide a positive duration such as 10s", rawCooldown)
+		}
+	}
+	if threshold > 0 {
+		RedisBreaker = utils.NewBreaker(threshold, cooldown)
+		ReplicaBreaker = utils.NewBreaker(threshold, cooldown) // only used if there is a replica
+	}
+	if rawURL := os.Getenv("PUBLIC_URL"); rawURL != "" {
+		publicURL, err := url.Parse(rawURL)
+		if err != nil || (publicURL.Scheme != "http" && publicURL.Scheme != "https") || publicURL.Host == "" {
+			log.Fatalf("Invalid PUBLIC_URL %q, please provide an address such as https://abacus.example.com", rawURL)
+		}
+		PublicURL = strings.TrimSuffix(rawURL, "/")
+	}
+	if rawURL := os.Getenv("DOCS_URL"); rawURL != "" {
+		docsURL, err := url.Parse(rawURL)
+		if err != nil || (docsURL.Scheme != "http" && docsURL.Scheme != "https") || docsURL.Host == "" {
+			log.Fatalf("Invalid DOCS_URL %q, please provide an absolute url such as https://abacus.example.com/docs/", rawURL)
+		}
+		DocsUrl = rawURL
+	}
+	log.Printf("Redirecting unknown routes and /docs to %s", DocsUrl)
+	if rawMax := os.Getenv("NAMESPACE_MAX_COUNTERS"); rawMax != "" {
+		maxCounters, err := strconv.ParseInt(rawMax, 10, 64)
+		if err != nil || maxCounters < 0 {
+			log.Fatalf("Invalid NAMESPACE_MAX_COUNTERS %q, please provide a number of counters, or 0 for no limit", rawMax)
+		}
+		MaxCounters = maxCounters
+	}
+	if rawSize := os.Getenv("COUNTER_CACHE_SIZE"); rawSize != "" {
+		size, err := strconv.Atoi(rawSize)
+		if err != nil || size <= 0 {
+			log.Fatalf("Invalid COUNTER_CACHE_SIZE %q, please provide a positive number of counters", rawSize)
+		}
+		ttl := 5 * time.Second
+		if rawTTL := os.Getenv("COUNTER_CACHE_TTL"); rawTTL != "" {
+			if ttl, err = time.ParseDuration(rawTTL); err != nil || ttl <= 0 {
+				log.Fatalf("Invalid COUNTER_CACHE_TTL %q, please provide a positive duration such as 5s", rawTTL)
+			}
+		}
+		utils.Counters = utils.NewCounterCache(size, ttl)
+		log.Printf("Caching up to %d counters for %s", size, ttl)
+	}
+	if rawJWTKey := os.Getenv("JWT_PUBLIC_KEY"); rawJWTKey != "" {
+		jwtKey, err := middleware.ParseJWTKey(rawJWTKey)
+		if err != nil {
+			log.Fatalf("Invalid JWT_PUBLIC_KEY: %v", err)
+		}
+		middleware.JWTKey = jwtKey
+		log.Println("JWT authentication enabled")
+	}
+	if CreatorIPSalt = os.Getenv("CREATOR_IP_SALT"); CreatorIPSalt != "" {
+		log.Println("Recording the hashed IPs counters are created from")
+	}
+	if rawSeparator := os.Getenv("KEY_SEPARATOR"); rawSeparator != "" {
+		separator, err := utils.ParseKeySeparator(rawSeparator)
+		if err != nil {
+			log.Fatalf("Invalid KEY_SEPARATOR %q: %v", rawSeparator, err)
+		}
+		utils.KeySeparator = separator
+	}
+	if rawProxies := os.Getenv("TRUSTED_PROXIES"); rawProxies != "" {
+		for _, proxy := range strings.Split(rawProxies, ",") {
+			proxy = strings.TrimSpace(proxy)
+			if _, _, err := net.ParseCIDR(proxy); err != nil && net.ParseIP(proxy) == nil {
+				log.Fatalf("Invalid TRUSTED_PROXIES entry %q, please provide IPs or CIDRs such as 10.0.0.0/8", proxy)
+			}
+			TrustedProxies = append(TrustedProxies, proxy)
+		}
+		log.Printf("Taking client IPs from the X-Forwarded-For of %s", strings.Join(TrustedProxies, ", "))
+	}
+	switch rateLimitKey := os.Getenv("RATE_LIMIT_KEY"); rateLimitKey {
+	case "":
+	case middleware.RateLimitByIP, middleware.RateLimitByAPIKey:
+		middleware.RateLimitKey = rateLimitKey
+	default:
+		log.Fatalf("Invalid RATE_LIMIT_KEY %q, please provide %s or %s", rateLimitKey, middleware.RateLimitByIP, middleware.RateLimitByAPIKey)
+	}
+	if rawPrefix := os.Getenv("RATE_LIMIT_IPV4_PREFIX"); rawPrefix != "" {
+		bits, err := strconv.Atoi(rawPrefix)
+		if err != nil || bits < 1 || bits > 32 {
+			log.Fatalf("Invalid RATE_LIMIT_IPV4_PREFIX %q, please provide a prefix length between 1 and 32", rawPrefix)
+		}
+		middleware.RateLimitIPv4Prefix = bits
+	}
+	if rawPrefix := os.Getenv("RATE_LIMIT_IPV6_PREFIX"); rawPrefix != "" {
+		bits, err := strconv.Atoi(rawPrefix)
+		if err != nil || bits < 1 || bits > 128 {
+			log.Fatalf("Invalid RATE_LIMIT_IPV6_PREFIX %q, please provide a prefix length between 1 and 128", rawPrefix)
+		}
+		middleware.RateLimitIPv6Prefix = bits
+	}
+	if rawReadOnly := os.Getenv("READ_ONLY"); rawReadOnly != "" {
+		enabled, err := strconv.ParseBool(rawReadOnly)
+		if err != nil {
+			log.Fatalf("Invalid READ_ONLY %q, please provide true or false", rawReadOnly)
+		}
+		middleware.SetReadOnly(enabled, "READ_ONLY")
+	}
+	OperatorToken = os.Getenv("OPERATOR_TOKEN")
+	if rawSigning := os.Getenv("REQUEST_SIGNING"); rawSigning != "" {
+		enabled, err := strconv.ParseBool(rawSigning)
+		if err != nil {
+			log.Fatalf("Invalid REQUEST_SIGNING %q, please provide true or false", rawSigning)
+		}
+		RequestSigning = enabled
+	}
+	rawTimezone, timezoneVar := os.Getenv("DEFAULT_TIMEZONE"), "DEFAULT_TIMEZONE"
+	if rawTimezone == "" {
+		rawTimezone, timezoneVar = strings.TrimPrefix(os.Getenv("TZ"), ":"), "TZ"
+	}
+	if rawTimezone != "" {
+		location, err := time.LoadLocation(rawTimezone)
+		if err != nil {
+			log.Fatalf("Invalid %s %q, please provide an IANA timezone such as Europe/Berlin", timezoneVar, rawTimezone)
+		}
+		utils.Location = location
+		log.Printf("Using the %s timezone for date-based operations", location)
+	}
+	utils.RollLocation = utils.Location
+	if rawZone := os.Getenv("ROLL_TIMEZONE"); rawZone != "" {
+		location, err := time.LoadLocation(rawZone)
+		if err != nil {
+			log.Fatalf("Invalid ROLL_TIMEZONE %q, please provide an IANA timezone such as Europe/Berlin", rawZone)
+		}
+		utils.RollLocation = location
+	}
+	if rawKeep := os.Getenv("ROLL_KEEP_PERIODS"); rawKeep != "" {
+		keep, err := strconv.Atoi(rawKeep)
+		if err != nil || keep < 0 {
+			log.Fatalf("Invalid ROLL_KEEP_PERIODS %q, please provide a number of periods such as 30", rawKeep)
+		}
+		utils.RollKeep = keep
+	}
+	if GRPCPort = os.Getenv("GRPC_PORT"); GRPCPort != "" {
+		if port, err := strconv.Atoi(GRPCPort); err != nil || port < 1 || port > 65535 {
+			log.Fatalf("Invalid GRPC_PORT %q, please provide a port such as 9090", GRPCPort)
+		}
+	}
+	if rawCluster := os.Getenv("REDIS_CLUSTER"); rawCluster != "" {
+		enabled, err := strconv.ParseBool(rawCluster)
+		if err != nil {
+			log.Fatalf("Invalid REDIS_CLUSTER %q, please provide true or false", rawCluster)
+		}
+		RedisCluster, utils.HashTags = enabled, enabled
+	}
+	if rawCreate := os.Getenv("CREATE_ON_HIT"); rawCreate != "" {
+		enabled, err := strconv.ParseBool(rawCreate)
+		if err != nil {
+			log.Fatalf("Invalid CREATE_ON_HIT %q, please provide true or false", rawCreate)
+		}
+		CreateOnHit = enabled
+	}
+	if SubdomainBase = strings.Trim(os.Getenv("NAMESPACE_FROM_SUBDOMAIN"), "."); strings.ContainsAny(SubdomainBase, ":/ ") {
+		log.Fatalf("Invalid NAMESPACE_FROM_SUBDOMAIN %q, please provide the domain whose subdomains are namespaces, such as counters.example.com", SubdomainBase)
+	}
+	if rawExpiry := os.Getenv("EXPIRY_EVENTS"); rawExpiry != "" {
+		enabled, err := strconv.ParseBool(rawExpiry)
+		if err != nil {
+			log.Fatalf("Invalid EXPIRY_EVENTS %q, please provide true or false", rawExpiry)
+		}
+		ExpiryEvents = enabled
+	}
+	if ExpiryEvents && RedisCluster {
+		log.Fatalf("EXPIRY_EVENTS can't be used with REDIS_CLUSTER, as each node only reports the expirations of its own keys")
+	}
+	switch EventsBackend = strings.ToLower(os.Getenv("EVENTS_BACKEND")); EventsBackend {
+	case "", "redis":
+	default:
+		log.Fatalf("Invalid EVENTS_BACKEND %q, only redis (streams) is supported, or leave it unset to disable events", EventsBackend)
+	}
+	EventStream, EventBuffer = os.Getenv("EVENTS_STREAM"), utils.DefaultEventBuffer
+	if EventStream == "" {
+		EventStream = utils.DefaultEventStream
+	}
+	if rawBuffer := os.Getenv("EVENTS_BUFFER"); rawBuffer != "" {
+		buffer, err := strconv.Atoi(rawBuffer)
+		if err != nil || buffer < 1 {
+			log.Fatalf("Invalid EVENTS_BUFFER %q, please provide a positive number of events such as 1000", rawBuffer)
+		}
+		EventBuffer = buffer
+	}
+	if rawPrivate := os.Getenv("WEBHOOK_ALLOW_PRIVATE"); rawPrivate != "" {
+		enabled, err := strconv.ParseBool(rawPrivate)
+		if err != nil {
+			log.Fatalf("Invalid WEBHOOK_ALLOW_PRIVATE %q, please provide true or false", rawPrivate)
+		}
+		utils.AllowPrivateWebhooks = enabled
+	}
+	if rawOrigins := os.Getenv("CORS_ALLOWED_ORIGINS"); rawOrigins != "" {
+		origins, err := utils.ParseOrigins(rawOrigins)
+		if err != nil {
+			log.Fatalf("Invalid CORS_ALLOWED_ORIGINS: %v", err)
+		}
+		CORSOrigins = origins
+	}
+	if rawCredentials := os.Getenv("CORS_ALLOW_CREDENTIALS"); rawCredentials != "" {
+		enabled, err := strconv.ParseBool(rawCredentials)
+		if err != nil {
+			log.Fatalf("Invalid CORS_ALLOW_CREDENTIALS %q, please provide true or false", rawCredentials)
+		}
+		CORSCredentials = enabled
+	}
+	// browsers refuse credentialed responses allowing any origin, and echoing every origin back would let any site
+	// make requests with the cookies of its visitors
+	if CORSCredentials && (CORSOrigins == nil || slices.Contains(CORSOrigins, "*")) {
+		log.Fatalf("CORS_ALLOW_CREDENTIALS can't be used with all origins allowed, please list them in CORS_ALLOWED_ORIGINS")
+	}
+	if rawMaxAge := os.Getenv("CORS_MAX_AGE"); rawMaxAge != "" {
+		maxAge, err := time.ParseDuration(rawMaxAge)
+		if err != nil || maxAge < 0 {
+			log.Fatalf("Invalid CORS_MAX_AGE %q, please provide a duration such as 12h, or 0 to not cache preflights", rawMaxAge)
+		}
+		CORSMaxAge = maxAge
+	}
+}
+
 func setupMockRedis() {
 	// Used for testing, "miniredis" is a mock Redis server that runs in-memory for testing purposes only (no persistence)
 	mr, err := miniredis.Run()
@@ -88,78 +538,170 @@ func setupMockRedis() {
 
 	// Connect clients to miniredis
 	Client = redis.NewClient(&redis.Options{
-		Addr: mr.Addr(),
+		Addr:                  mr.Addr(),
+		ContextTimeoutEnabled: true,
 	})
 	RateLimitClient = redis.NewClient(&redis.Options{
 		Addr: mr.Addr(),
 	})
+	Client.AddHook(utils.RedisErrorHook{})
+	if RedisBreaker != nil {
+		Client.AddHook(RedisBreaker)
+	}
 }
 
 func CreateRouter() *gin.Engine {
 	utils.InitializeStatsManager(Client)
-	r := gin.Default()
+	Census = utils.NewCensusCache(Client, CensusInterval)
+	r := gin.New()
+	// without trusted proxies the client IP is the address of the connection, X-Forwarded-For is easily forged
+	if err := r.SetTrustedProxies(TrustedProxies); err != nil {
+		log.Fatalf("Invalid TRUSTED_PROXIES: %v", err)
+	}
+	r.Use(middleware.RequestLogger()) // replaces gin's logger, so every line is structured and carries the request id
+	if MaxBodyBytes > 0 {
+		r.Use(middleware.BodyLimit(MaxBodyBytes))
+	}
+	if RedisTimeout > 0 {
+		r.Use(middleware.Deadline(RedisTimeout))
+	}
+	if RedisBreaker != nil {
+		r.Use(middleware.Breaker(RedisBreaker))
+	}
 	// Cors
 	corsConfig := cors.Config{
 		AllowMethods:     []string{"GET", "POST", "PUT", "PATCH", "DELETE", "HEAD", "OPTIONS"},
-		AllowHeaders:     []string{"Origin", "Content-Length", "Content-Type", "Authorization"},
-		AllowCredentials: false,
-		AllowAllOrigins:  true,
-		MaxAge:           12 * time.Hour,
+		AllowHeaders:     []string{"Origin", "Content-Length", "Content-Type", "Authorization", middleware.APIKeyHeader, middleware.RequestIDHeader, middleware.SignatureHeader, middleware.TimestampHeader},
+		AllowCredentials: CORSCredentials,
+		MaxAge:           CORSMaxAge,
+	}
+	if CORSOrigins != nil {
+		corsConfig.AllowOriginFunc = func(origin string) bool { return utils.OriginAllowed(CORSOrigins, origin) }
+	} else {
+		corsConfig.AllowAllOrigins = true
 	}
 	r.Use(cors.New(corsConfig))
 	r.Use(gin.Recovery()) // recover from panics and returns a 500 error
+	if os.Getenv("COMPRESSION_ENABLED") == "true" {
+		r.Use(middleware.Compress("/list/", "/export/", "/stats", "/history/", "/openapi.json", "/docs"))
+		log.Println("Compression enabled")
+	}
 	if os.Getenv("API_ANALYTICS_ENABLED") == "true" {
 		r.Use(analytics.Analytics(os.Getenv("API_ANALYTICS_KEY"))) // Add middleware
 		log.Println("Analytics enabled")
 	}
+	if os.Getenv("METRICS_ENABLED") == "true" {
+		r.GET("/metrics", MetricsView(Client))
+		log.Println("Metrics enabled")
+	}
 	route := r.Group("")
 	route.Use(middleware.Stats())
 	if os.Getenv("RATE_LIMIT_ENABLED") == "true" {
 		route.Use(middleware.RateLimit(RateLimitClient))
 		log.Println("Rate limiting enabled")
 	}
+	if SubdomainBase != "" {
+		route.Use(middleware.SubdomainNamespace(SubdomainBase))
+		log.Printf("Namespaces from subdomains of %s enabled", SubdomainBase)
+	}
 	// Define routes
-	r.NoRoute(func(c *gin.Context) {
-		c.Redirect(http.StatusPermanentRedirect, DocsUrl)
-	})
+	r.NoRoute(NoRouteView(r))
 	// heath check
 	r.StaticFile("/favicon.svg", "./assets/favicon.svg")
 	r.StaticFile("/favicon.ico", "./assets/favicon.ico")
+	// liveness only, so it stays out of the stats and rate limits; readiness is checked by /healthcheck
+	r.GET("/livez", LivezView)
 
 	{ // Stats Routes
-		route.GET("/healthcheck", func(context *gin.Context) {
-			context.JSON(http.StatusOK, gin.H{
-				"status": "ok", "uptime": time.Since(StartTime).String()})
-		})
+		route.GET("/healthcheck", HealthCheckView)
 
-		route.GET("/docs", func(context *gin.Context) {
-			context.Redirect(http.StatusPermanentRedirect, DocsUrl)
-		})
+		route.GET("/docs", DocsView)
 
 		route.GET("/stats", StatsView)
+		route.GET("/version", VersionView)
+		route.GET("/openapi.json", OpenAPIView(r))
+		route.GET("/swagger", SwaggerView)
+		if OperatorToken != "" {
+			route.POST("/maintenance", MaintenanceView) // authenticated by the view
+		}
 	}
+	// the routes that change counters, which are rejected while in read-only mode
+	writable := route.Group("")
+	writable.Use(middleware.ReadOnly())
 	{ // Public Routes
 		route.GET("/get/:namespace/*key", GetView)
-
-		route.GET("/hit/:namespace/*key", HitView)
+		route.HEAD("/get/:namespace/*key", GetView) // the server leaves out the body, so probes don't transfer it
+		route.GET("/exists/:namespace/*key", ExistsView)
+		route.GET("/badge/:namespace/*key", BadgeView)
+
+		writable.GET("/hit/:namespace/*key", HitView)
+		writable.GET("/dec/:namespace/*key", DecView)
+		writable.POST("/dec/:namespace/*key", DecView)
+		writable.POST("/hit-batch", HitBatchView)
+		writable.POST("/transaction", TransactionView)
+		writable.POST("/reserve/:namespace/*key", ReserveView)
 		route.GET("/stream/:namespace/*key", middleware.SSEMiddleware(), StreamValueView)
+		route.GET("/ws/:namespace/*key", WebSocketView)
+
+		writable.POST("/create/:namespace/*key", CreateView)
+		writable.GET("/create/:namespace/*key", CreateView)
 
-		route.POST("/create/:namespace/*key", CreateView)
-		route.GET("/create/:namespace/*key", CreateView)
+		writable.GET("/upsert/:namespace/*key", UpsertView)
+		writable.POST("/upsert/:namespace/*key", UpsertView)
 
-		route.GET("/create/", CreateRandomView)
-		route.POST("/create/", CreateRandomView)
+		writable.GET("/create/", CreateRandomView)
+		writable.POST("/create/", CreateRandomView)
 
 		route.GET("/info/:namespace/*key", InfoView)
+		route.HEAD("/info/:namespace/*key", InfoView)
+		route.POST("/info-batch", InfoBatchView)
+		route.GET("/history/:namespace/*key", HistoryView)
+		route.GET("/snapshot/:namespace/*key", SnapshotView)
+		route.GET("/list/:namespace", ListView)
+		route.GET("/sum/:namespace", SumView)
+
+		writable.POST("/namespace/:namespace/token", NamespaceTokenView) // authenticated by the view
 	}
-	authorized := route.Group("")
+	authorized := writable.Group("")
 	authorized.Use(middleware.Auth(Client))
+	if RequestSigning {
+		authorized.Use(middleware.Signature(Client))
+	}
 	{ // Authorized Routes
 		authorized.POST("/delete/:namespace/*key", DeleteView)
+		authorized.DELETE("/delete/:namespace/*key", DeleteView)
+		authorized.DELETE("/:namespace/*key", DeleteView)
 
 		authorized.POST("/set/:namespace/*key", SetView)
+		authorized.POST("/max/:namespace/*key", MaxView)
+		authorized.POST("/min/:namespace/*key", MinView)
 		authorized.POST("/reset/:namespace/*key", ResetView)
 		authorized.POST("/update/:namespace/*key", UpdateByView)
+		authorized.POST("/rename/:namespace/*key", RenameView)
+		authorized.POST("/merge/:namespace/*key", MergeView)
+		authorized.POST("/webhook/:namespace/*key", WebhookView)
+		authorized.POST("/description/:namespace/*key", DescriptionView)
+		authorized.POST("/snapshot/:namespace/*key", TakeSnapshotView)
+		authorized.POST("/share/:namespace/*key", ShareView)
+	}
+	namespaceAuthorized := route.Group("")
+	namespaceAuthorized.Use(middleware.NamespaceAuth(Client))
+	if RequestSigning { // including the routes that rotate and remove the secret, which would otherwise bypass it
+		namespaceAuthorized.Use(middleware.Signature(Client))
+	}
+	{ // Routes that need the namespace admin token
+		namespaceAuthorized.GET("/export/:namespace", ExportView)
+		namespaceAuthorized.POST("/import/:namespace", middleware.ReadOnly(), ImportView)
+		namespaceAuthorized.POST("/purge/:namespace", middleware.ReadOnly(), PurgeView)
+		namespaceAuthorized.POST("/reset-all/:namespace", middleware.ReadOnly(), ResetAllView)
+		namespaceAuthorized.DELETE("/share/:namespace", middleware.ReadOnly(), RevokeShareView)
+		if ExpiryEvents {
+			namespaceAuthorized.GET("/expired/:namespace", middleware.SSEMiddleware(), ExpiredStreamView)
+		}
+		if RequestSigning {
+			namespaceAuthorized.POST("/namespace/:namespace/secret", middleware.ReadOnly(), SigningSecretView)
+			namespaceAuthorized.DELETE("/namespace/:namespace/secret", middleware.ReadOnly(), SigningSecretView)
+		}
 	}
 	return r
 }
@@ -174,11 +716,33 @@ func main() {
 	StartTime = time.Now()
 	// Initialize the Gin router
 	r := CreateRouter()
+	// the server speaks plain HTTP, TLS is left to the proxy or ingress in front of it
+	port := os.Getenv("PORT")
+	if port == "" {
+		port = DefaultPort
+	}
+	// BIND_ADDRESS limits the server to one interface, e.g. 127.0.0.1 behind a local proxy, all of them if unset
+	addr := net.JoinHostPort(os.Getenv("BIND_ADDRESS"), port)
 	srv := &http.Server{ // #nosec G112 -- Due to the use of SSE endpoints, we cannot close the server early
-		Addr:    ":" + os.Getenv("PORT"),
+		Addr:    addr,
 		Handler: r,
 	}
-	fmt.Println("Listening on port " + os.Getenv("PORT"))
+	log.Println("Listening on " + addr)
+	var grpcServer *grpc.Server
+	if GRPCPort != "" {
+		grpcAddr := net.JoinHostPort(os.Getenv("BIND_ADDRESS"), GRPCPort)
+		listener, err := net.Listen("tcp", grpcAddr)
+		if err != nil {
+			log.Fatalf("listen: %s\n", err)
+		}
+		grpcServer = NewGRPCServer(r)
+		log.Println("Serving gRPC on " + grpcAddr)
+		go func() {
+			if err := grpcServer.Serve(listener); err != nil {
+				log.Fatalf("grpc: %s\n", err)
+			}
+		}()
+	}
 
 	go func() {
 		// service connections
@@ -196,12 +760,17 @@ func main() {
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	<-quit
 	close(utils.ServerClose)
+	shutdown() // streams never go idle, so srv.Shutdown would wait for them until the timeout
 
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 	if err := srv.Shutdown(ctx); err != nil {
 		log.Fatal("Server Shutdown:", err)
 	}
+	if grpcServer != nil {
+		grpcServer.GracefulStop() // calls are unary, so this only waits for those in flight
+	}
+	utils.Events.Close() // publishes the events still buffered
 	select {
 	case <-ctx.Done():
 		log.Println("timeout of 5 seconds.")
@@ -0,0 +1,40 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// BodyLimit rejects requests with a body of more than limit bytes with a 413. Bodies that announce their size are
+// refused before anything is read, others are cut off by http.MaxBytesReader once they pass the limit, which the views
+// reading them report with BodyTooLarge.
+func BodyLimit(limit int64) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.Request.ContentLength > limit {
+			c.AbortWithStatusJSON(http.StatusRequestEntityTooLarge, gin.H{"error": bodyTooLargeError(limit)})
+			return
+		}
+		if c.Request.Body != nil {
+			c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, limit)
+		}
+		c.Next()
+	}
+}
+
+// BodyTooLarge responds with a 413 if err comes from reading a body past the limit of BodyLimit, reporting whether it
+// did. Other errors are left to the caller.
+func BodyTooLarge(c *gin.Context, err error) bool {
+	var tooLarge *http.MaxBytesError
+	if !errors.As(err, &tooLarge) {
+		return false
+	}
+	c.AbortWithStatusJSON(http.StatusRequestEntityTooLarge, gin.H{"error": bodyTooLargeError(tooLarge.Limit)})
+	return true
+}
+
+func bodyTooLargeError(limit int64) string {
+	return "Request body is too large, it can be at most " + strconv.FormatInt(limit, 10) + " bytes"
+}
@@ -0,0 +1,36 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/jasonlovesdoggo/abacus/utils"
+)
+
+// Breaker turns the 500 of a request that failed because breaker is open into a 503, with a Retry-After header
+// telling clients when redis will be tried again.
+func Breaker(breaker *utils.Breaker) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Writer = &breakerWriter{ResponseWriter: c.Writer, breaker: breaker}
+		c.Next()
+	}
+}
+
+type breakerWriter struct {
+	gin.ResponseWriter
+	breaker *utils.Breaker
+}
+
+func (w *breakerWriter) WriteHeader(code int) {
+	if code == http.StatusInternalServerError && w.breaker.Open() {
+		code = http.StatusServiceUnavailable
+		retryIn := w.breaker.Status().RetryIn
+		if retryIn < 1 {
+			retryIn = 1
+		}
+		w.Header().Set("Retry-After", strconv.FormatInt(retryIn, 10))
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
@@ -0,0 +1,23 @@
+package middleware
+
+import (
+	"strings"
+
+	"github.com/gin-contrib/gzip"
+	"github.com/gin-gonic/gin"
+)
+
+// Compress gzip compresses the responses of the routes whose path starts with one of prefixes for clients that accept
+// it. Only bulk responses, such as listings and exports, are worth compressing, those of a single counter are smaller
+// than the overhead. Event streams and websockets are left alone by gzip.Gzip itself.
+func Compress(prefixes ...string) gin.HandlerFunc {
+	compress := gzip.Gzip(gzip.DefaultCompression)
+	return func(c *gin.Context) {
+		for _, prefix := range prefixes {
+			if strings.HasPrefix(c.FullPath(), prefix) {
+				compress(c)
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// deadlineKey is the key of the gin context holding the context Redis calls are made with.
+const deadlineKey = "redis_context"
+
+// Deadline gives the Redis calls of each request timeout to finish, see Context. Requests that failed because they
+// ran out of time respond with a 503 instead of a 500, telling clients that trying again later may work.
+func Deadline(timeout time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(c.Request.Context(), timeout)
+		defer cancel()
+		c.Set(deadlineKey, ctx)
+		c.Writer = &deadlineWriter{ResponseWriter: c.Writer, ctx: ctx}
+		c.Next()
+	}
+}
+
+// Context returns the context the Redis calls of a request are made with, which is cancelled once the request's
+// deadline passed. Calls that outlive the request, such as those of streams or goroutines, must not use it.
+func Context(c *gin.Context) context.Context {
+	if ctx, ok := c.Get(deadlineKey); ok {
+		return ctx.(context.Context)
+	}
+	return context.Background()
+}
+
+// deadlineWriter turns the 500 of a request whose deadline passed into a 503.
+type deadlineWriter struct {
+	gin.ResponseWriter
+	ctx context.Context
+}
+
+func (w *deadlineWriter) WriteHeader(code int) {
+	if code == http.StatusInternalServerError && errors.Is(w.ctx.Err(), context.DeadlineExceeded) {
+		code = http.StatusServiceUnavailable
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
@@ -0,0 +1,102 @@
+package middleware
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// JWTKey is the public key JWTs are verified with, JWT authentication is disabled if it is nil.
+var JWTKey crypto.PublicKey
+
+type jwtClaims struct {
+	// Scope is a space separated list of the namespaces the token may modify, "*" meaning all of them. If it is
+	// empty, the token may only modify the namespace named by its subject.
+	Scope string `json:"scope"`
+	jwt.RegisteredClaims
+}
+
+// ParseJWTKey parses a PEM encoded RSA, ECDSA or Ed25519 public key. Escaped newlines are accepted, so the key
+// fits in a single line env variable.
+func ParseJWTKey(rawPEM string) (crypto.PublicKey, error) {
+	block, _ := pem.Decode([]byte(strings.ReplaceAll(rawPEM, `\n`, "\n")))
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	if len(signingMethods(key)) == 0 {
+		return nil, fmt.Errorf("unsupported key type %T", key)
+	}
+	return key, nil
+}
+
+func signingMethods(key crypto.PublicKey) []string {
+	switch key.(type) {
+	case *rsa.PublicKey:
+		return []string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512"}
+	case *ecdsa.PublicKey:
+		return []string{"ES256", "ES384", "ES512"}
+	case ed25519.PublicKey:
+		return []string{"EdDSA"}
+	}
+	return nil
+}
+
+// isJWT reports whether an auth token is a JWT rather than an admin key, which never contain dots.
+func isJWT(token string) bool {
+	return strings.Count(token, ".") == 2
+}
+
+// jwtNamespaces verifies a JWT against JWTKey, returning the namespaces it may modify.
+func jwtNamespaces(token string) ([]string, error) {
+	claims := &jwtClaims{}
+	_, err := jwt.ParseWithClaims(token, claims, func(*jwt.Token) (interface{}, error) {
+		return JWTKey, nil
+	}, jwt.WithValidMethods(signingMethods(JWTKey)), jwt.WithExpirationRequired())
+	if err != nil {
+		return nil, err
+	}
+	if claims.Scope != "" {
+		return strings.Fields(claims.Scope), nil
+	}
+	if claims.Subject != "" {
+		return []string{claims.Subject}, nil
+	}
+	return nil, errors.New("token has neither a scope nor a sub claim")
+}
+
+func jwtAllows(namespaces []string, namespace string) bool {
+	for _, allowed := range namespaces {
+		if allowed == "*" || allowed == namespace {
+			return true
+		}
+	}
+	return false
+}
+
+// authorizeJWT lets a request carrying a JWT through if it covers namespace, responding with an error otherwise.
+func authorizeJWT(c *gin.Context, token, namespace string) {
+	namespaces, err := jwtNamespaces(token)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "token is invalid: " + err.Error()})
+		c.Abort()
+	} else if !jwtAllows(namespaces, namespace) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "token is not allowed to modify the namespace " + namespace})
+		c.Abort()
+	} else {
+		c.Next()
+	}
+}
@@ -0,0 +1,45 @@
+package middleware
+
+import (
+	"log/slog"
+	"regexp"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// RequestIDHeader carries the ID of a request. Clients may send their own to correlate their logs with ours, it is
+// echoed in the response either way.
+const RequestIDHeader = "X-Request-ID"
+
+const requestIDContextKey = "requestID"
+
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9_\-.]{1,64}$`)
+
+// RequestLogger assigns every request an ID and logs the request once it has been handled.
+func RequestLogger() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		requestID := c.GetHeader(RequestIDHeader)
+		if !validRequestID.MatchString(requestID) {
+			requestID = uuid.New().String()
+		}
+		c.Set(requestIDContextKey, requestID)
+		c.Header(RequestIDHeader, requestID)
+
+		start := time.Now()
+		c.Next()
+		// only the path is logged, the query may contain admin tokens
+		attrs := []any{"method", c.Request.Method, "path", c.Request.URL.Path, "status", c.Writer.Status(),
+			"latency", time.Since(start), "client_ip", c.ClientIP(), "size", c.Writer.Size()}
+		if len(c.Errors) > 0 {
+			attrs = append(attrs, "errors", c.Errors.String())
+		}
+		Log(c).Info("request", attrs...)
+	}
+}
+
+// Log returns the logger for the request being handled, which tags every line with the ID of the request.
+func Log(c *gin.Context) *slog.Logger {
+	return slog.Default().With("request_id", c.GetString(requestIDContextKey))
+}
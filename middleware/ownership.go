@@ -4,7 +4,6 @@ import (
 	"context"
 	"errors"
 	"net/http"
-	"strings"
 
 	"github.com/redis/go-redis/v9"
 
@@ -12,38 +11,113 @@ import (
 	"github.com/jasonlovesdoggo/abacus/utils"
 )
 
-func Auth(Client *redis.Client) gin.HandlerFunc {
+// Auth only lets requests through if they carry the admin token of the counter or of its namespace, or a JWT that
+// covers the namespace (if JWTKey is set).
+func Auth(Client redis.UniversalClient) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		authToken := ""
-		authTokenHeader := c.Request.Header.Get("Authorization")
-		if !strings.HasPrefix(authTokenHeader, "Bearer ") {
-			authTokenQuery := c.DefaultQuery("token", "")
-			if authTokenQuery == "" {
-				c.JSON(http.StatusBadRequest, gin.H{"error": "Token is required, " +
-					"please provide a token in the format of a Bearer token header or ?token=ADMIN_TOKEN"})
-				c.Abort() // Abort further processing
-				return
-			}
-			authToken = authTokenQuery
-		} else {
-			authToken = strings.TrimPrefix(authTokenHeader, "Bearer ")
+		authToken := utils.GetAuthToken(c)
+		if authToken == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Token is required, " +
+				"please provide a token in the format of a Bearer token header or ?token=ADMIN_TOKEN"})
+			c.Abort() // Abort further processing
+			return
 		}
 
-		adminDBKey := utils.CreateRawAdminKey(c)
-		//if adminDBKey == "" {
-		//	c.JSON(http.StatusInternalServerError, gin.H{"error": "There is no"})
-		//	c.Abort() // Abort further processing
-		//	return
-		//}
-		adminKey, err := Client.Get(context.Background(), adminDBKey).Result()
-		if errors.Is(err, redis.Nil) {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "This entry is genuine and does not have an admin key. You cannot delete it. If you wanted to delete it, you should have created it with the /create endpoint."})
+		namespace, key := utils.ResolveNamespaceKey(c)
+		if namespace == "" || key == "" { // error is handled in ResolveNamespaceKey
+			c.Abort()
+			return
+		}
+		if JWTKey != nil && isJWT(authToken) {
+			authorizeJWT(c, authToken, namespace)
+			return
+		}
+
+		adminKey, err := Client.Get(Context(c), utils.CreateAdminKey(utils.BuildDBKey(namespace, key))).Result()
+		if err != nil && !errors.Is(err, redis.Nil) {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get data. Try again later."})
+			c.Abort()
+			return
+		}
+		if err == nil && adminKey == authToken { // token is valid.
+			c.Next()
+			return
+		}
 
-		} else if adminKey != authToken {
+		// fall back to the namespace token, which authorizes every counter in the namespace
+		valid, nsErr := utils.CheckNamespaceToken(Context(c), Client, namespace, authToken)
+		if nsErr != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get data. Try again later."})
+			c.Abort()
+			return
+		}
+		if valid {
+			c.Next()
+		} else if errors.Is(err, redis.Nil) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "This entry is genuine and does not have an admin key. You cannot delete it. If you wanted to delete it, you should have created it with the /create endpoint."})
+			c.Abort()
+		} else {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "token is invalid"})
 			c.Abort() // Abort further processing
-		} else { // token is valid.
-			c.Next()
 		}
 	}
 }
+
+// NamespaceAuth only lets requests through if they carry the admin token of the :namespace they are for, or a JWT
+// that covers it (if JWTKey is set). The admin tokens of single counters are not enough.
+func NamespaceAuth(Client redis.UniversalClient) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		authToken := utils.GetAuthToken(c)
+		if authToken == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Token is required, " +
+				"please provide a token in the format of a Bearer token header or ?token=ADMIN_TOKEN"})
+			c.Abort()
+			return
+		}
+
+		namespace := c.Param("namespace")
+		if err := utils.ValidateNamespace(namespace); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			c.Abort()
+			return
+		}
+		if JWTKey != nil && isJWT(authToken) {
+			authorizeJWT(c, authToken, namespace)
+			return
+		}
+
+		valid, err := utils.CheckNamespaceToken(Context(c), Client, namespace, authToken)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get data. Try again later."})
+			c.Abort()
+			return
+		}
+		if !valid {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "token is invalid, the namespace admin token is required"})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// CanModify reports whether token authorizes changes to the counter key in namespace, accepting the same tokens as
+// Auth. It is meant for views that touch a second counter besides the one Auth already checked. ctx is the Context
+// of the request.
+func CanModify(ctx context.Context, Client redis.UniversalClient, token, namespace, key string) (bool, error) {
+	if token == "" {
+		return false, nil
+	}
+	if JWTKey != nil && isJWT(token) {
+		namespaces, err := jwtNamespaces(token)
+		return err == nil && jwtAllows(namespaces, namespace), nil
+	}
+	adminKey, err := Client.Get(ctx, utils.CreateAdminKey(utils.BuildDBKey(namespace, key))).Result()
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return false, err
+	}
+	if err == nil && adminKey == token {
+		return true, nil
+	}
+	return utils.CheckNamespaceToken(ctx, Client, namespace, token)
+}
@@ -2,12 +2,14 @@ package middleware
 
 import (
 	"fmt"
+	"math"
 	"net/http"
 	"strconv"
 	"time"
 
 	ratelimit "github.com/JGLTechnologies/gin-rate-limit"
 	"github.com/gin-gonic/gin"
+	"github.com/jasonlovesdoggo/abacus/utils"
 	"github.com/redis/go-redis/v9"
 )
 
@@ -16,25 +18,67 @@ var rateLimitPolicy string
 const rate = 3
 const limit = 30
 
+// What requests are rate limited by, see RateLimitKey.
+const (
+	RateLimitByIP     = "ip"      // the client IP
+	RateLimitByAPIKey = "api_key" // the X-API-Key header if it is registered, see tieredStore, or the client IP
+)
+
+// RateLimitKey picks what the budget of a request is keyed on, one of RateLimitByIP and RateLimitByAPIKey.
+var RateLimitKey = RateLimitByIP
+
+// The prefix lengths of the networks that share a budget when keying on the client IP, see utils.IPPrefix. IPv6
+// clients are usually handed a whole /64, so they can't get a fresh budget by rotating addresses within it.
+var (
+	RateLimitIPv4Prefix = 32
+	RateLimitIPv6Prefix = 64
+)
+
+// keyFunc builds the rate limit key of a request in REDIS (R: distinguishes them from other keys). The client IP is
+// the address of the connection, unless it comes from one of the trusted proxies of the engine, whose X-Forwarded-For
+// is used instead. A forged X-Forwarded-For from anyone else is ignored. Clients are keyed by the network of their IP.
+// Registered API keys are keyed by tieredStore, as only it knows which are.
 func keyFunc(c *gin.Context) string {
-	return "R:" + c.ClientIP() // rate limit key in REDIS (add R: to the beginning to distinguish from other keys)
-	// return c.ClientIP() + c.FullPath()
+	return "R:" + utils.IPPrefix(c.ClientIP(), RateLimitIPv4Prefix, RateLimitIPv6Prefix)
+}
 
+// apiKeyKey builds the rate limit key of the registered API key apiKey, hashed like the tier keys as the raw key is
+// a secret.
+func apiKeyKey(apiKey string) string {
+	return "R:K:" + utils.HashToken(apiKey)
 }
 func errorHandler(c *gin.Context, info ratelimit.Info) {
+	utils.RateLimited.Add(1)
+	// the budget headers were already set by beforeResponse, headers have to be set before the body is written
+	retryAfter := int64(math.Ceil(time.Until(info.ResetTime).Seconds()))
+	if retryAfter < 1 {
+		retryAfter = 1
+	}
+	c.Header("Retry-After", strconv.FormatInt(retryAfter, 10))
 	c.JSON(http.StatusTooManyRequests, gin.H{
 		"error": "Too many requests. Try again in " + time.Until(info.ResetTime).String(),
 	})
-	c.Header("Retry-After", time.Until(info.ResetTime).String())
-	c.Header("RateLimit-Reset", fmt.Sprintf("%d", info.ResetTime.Unix()))
-	c.Header("RateLimit-Remaining", "0")
-	c.Header("RateLimit-Policy", rateLimitPolicy)
 
 }
+
+// beforeResponse exposes the rate limit budget of the client on every response that passes through the limiter,
+// both in the IETF draft format (RateLimit-*) and the widespread X-RateLimit-* one.
 func beforeResponse(c *gin.Context, info ratelimit.Info) {
-	c.Header("RateLimit-Remaining", fmt.Sprintf("%v", info.RemainingHits))
-	c.Header("RateLimit-Reset", fmt.Sprintf("%d", info.ResetTime.Unix()))
-	c.Header("RateLimit-Policy", rateLimitPolicy)
+	remaining := strconv.FormatUint(uint64(info.RemainingHits), 10)
+	if info.RateLimited {
+		remaining = "0"
+	}
+	reset := fmt.Sprintf("%d", info.ResetTime.Unix())
+	policy := rateLimitPolicy
+	if tierPolicy := c.GetString(policyContextKey); tierPolicy != "" {
+		policy = tierPolicy
+	}
+	c.Header("RateLimit-Remaining", remaining)
+	c.Header("RateLimit-Reset", reset)
+	c.Header("RateLimit-Policy", policy)
+	c.Header("X-RateLimit-Limit", strconv.FormatUint(uint64(info.Limit), 10))
+	c.Header("X-RateLimit-Remaining", remaining)
+	c.Header("X-RateLimit-Reset", reset)
 }
 
 func RateLimit(client *redis.Client) gin.HandlerFunc {
@@ -45,7 +89,7 @@ func RateLimit(client *redis.Client) gin.HandlerFunc {
 		Rate:        time.Second * rate,
 		Limit:       limit,
 	})
-	mw := RateLimiter(store, &ratelimit.Options{
+	mw := RateLimiter(&tieredStore{client: client, fallback: store}, &ratelimit.Options{
 		ErrorHandler:   errorHandler,
 		KeyFunc:        keyFunc,
 		BeforeResponse: beforeResponse,
@@ -0,0 +1,41 @@
+package middleware
+
+import (
+	"log"
+	"net/http"
+	"sync/atomic"
+
+	"github.com/gin-gonic/gin"
+)
+
+// readOnly is set while the server rejects changes, e.g. during a migration. It only applies to this instance.
+var readOnly atomic.Bool
+
+// SetReadOnly turns the read-only mode on or off, logging who did so if that changed anything.
+func SetReadOnly(enabled bool, by string) {
+	if readOnly.Swap(enabled) == enabled {
+		return
+	}
+	if enabled {
+		log.Printf("Read-only mode enabled by %s, changes are rejected", by)
+	} else {
+		log.Printf("Read-only mode disabled by %s", by)
+	}
+}
+
+// IsReadOnly reports whether the server is in read-only mode.
+func IsReadOnly() bool {
+	return readOnly.Load()
+}
+
+// ReadOnly rejects the requests of the routes that change counters with a 503 while the server is in read-only mode.
+func ReadOnly() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if readOnly.Load() {
+			c.Header("Retry-After", "60")
+			c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"error": "The server is in read-only mode for maintenance, changes are rejected until it is over. Reading counters still works."})
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,86 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jasonlovesdoggo/abacus/utils"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	SignatureHeader = "X-Signature"
+	TimestampHeader = "X-Timestamp"
+)
+
+// SignatureWindow is how far the timestamp of a signed request may be from the server's clock, which limits how long
+// a captured request can be replayed for.
+const SignatureWindow = 5 * time.Minute
+
+// Signature only lets the requests for namespaces with a signing secret through if they carry a valid X-Signature
+// (see utils.SignRequest) made within SignatureWindow of now, as given by X-Timestamp in unix seconds. Namespaces
+// without a secret are left to the other checks, so signing stays opt-in for every namespace. It applies to the
+// routes of single counters as well as to those of whole namespaces.
+func Signature(Client redis.UniversalClient) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		namespace := c.Param("namespace")
+		if _, ok := c.Params.Get("key"); ok {
+			namespace, _ = utils.ResolveNamespaceKey(c)
+		}
+		if namespace == "" { // error is handled in ResolveNamespaceKey
+			c.Abort()
+			return
+		}
+		secret, err := utils.GetSigningSecret(Context(c), Client, namespace)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get data. Try again later."})
+			c.Abort()
+			return
+		}
+		if secret == "" {
+			c.Next()
+			return
+		}
+
+		signature, timestamp := c.GetHeader(SignatureHeader), c.GetHeader(TimestampHeader)
+		signedAt, err := strconv.ParseInt(timestamp, 10, 64)
+		if signature == "" || err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "This namespace requires signed requests, please provide the " +
+				SignatureHeader + " and " + TimestampHeader + " headers"})
+			c.Abort()
+			return
+		}
+		if age := time.Since(time.Unix(signedAt, 0)); age > SignatureWindow || age < -SignatureWindow {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "The signature has expired, requests have to be signed within " +
+				SignatureWindow.String() + " of being sent"})
+			c.Abort()
+			return
+		}
+
+		var body []byte
+		if c.Request.Body != nil {
+			if body, err = io.ReadAll(c.Request.Body); err != nil {
+				if BodyTooLarge(c, err) {
+					return
+				}
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read the request body"})
+				c.Abort()
+				return
+			}
+			c.Request.Body = io.NopCloser(bytes.NewReader(body)) // the view reads it again
+		}
+		expected := utils.SignRequest(secret, timestamp, c.Request.Method, c.Request.URL.RequestURI(), body)
+		if !hmac.Equal([]byte(strings.ToLower(signature)), []byte(expected)) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "signature is invalid"})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
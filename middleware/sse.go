@@ -2,12 +2,15 @@ package middleware
 
 import "github.com/gin-gonic/gin"
 
+// SSEMiddleware sets the headers of an event stream, which proxies and caches must pass on unbuffered for the events
+// to arrive as they happen rather than in bursts.
 func SSEMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		c.Writer.Header().Set("Content-Type", "text/event-stream")
 		c.Writer.Header().Set("Cache-Control", "no-cache")
 		c.Writer.Header().Set("Connection", "keep-alive")
 		c.Writer.Header().Set("Transfer-Encoding", "chunked")
+		c.Writer.Header().Set("X-Accel-Buffering", "no") // nginx buffers responses otherwise
 		c.Next()
 	}
 }
@@ -33,6 +33,7 @@ func Stats() gin.HandlerFunc {
 		}
 
 		atomic.AddInt64(&utils.Total, 1)
+		utils.RequestsServed.Add(1)
 		utils.StatsManager.RecordStat(path, 1)
 
 		c.Next()
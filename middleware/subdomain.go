@@ -0,0 +1,54 @@
+package middleware
+
+import (
+	"net"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SubdomainNamespace lets tenants keep the namespace out of their URLs: requests to a subdomain of base, e.g.
+// tenant.counters.example.com/hit/key for counters.example.com, count the counter key of the namespace tenant. It only
+// applies to routes naming a single counter whose path has just the key, a path that names a namespace as well
+// (/hit/namespace/key) is left as is, as are requests to base itself or to other hosts.
+func SubdomainNamespace(base string) gin.HandlerFunc {
+	suffix := "." + strings.ToLower(base)
+	return func(c *gin.Context) {
+		if namespace := subdomainOf(c.Request.Host, suffix); namespace != "" {
+			injectNamespace(c, namespace)
+		}
+		c.Next()
+	}
+}
+
+// subdomainOf returns the label host has in front of suffix, empty if it isn't a direct subdomain of it. Hosts are
+// case-insensitive, so it is always lower case.
+func subdomainOf(host, suffix string) string {
+	if hostname, _, err := net.SplitHostPort(host); err == nil {
+		host = hostname
+	}
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	label, ok := strings.CutSuffix(host, suffix)
+	if !ok || label == "" || strings.Contains(label, ".") {
+		return ""
+	}
+	return label
+}
+
+// injectNamespace rewrites the :namespace and *key of a route whose path has a single segment, which would otherwise
+// name a key of the default namespace, so the views find the counter namespace/key instead.
+func injectNamespace(c *gin.Context, namespace string) {
+	rawNamespace, hasNamespace := c.Params.Get("namespace")
+	rawKey, hasKey := c.Params.Get("key")
+	if !hasNamespace || !hasKey || strings.Trim(rawKey, "/") != "" || strings.Trim(rawNamespace, "/") == "" {
+		return
+	}
+	for i := range c.Params {
+		switch c.Params[i].Key {
+		case "namespace":
+			c.Params[i].Value = namespace
+		case "key":
+			c.Params[i].Value = "/" + rawNamespace
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package middleware
+
+import (
+	"strconv"
+	"time"
+
+	ratelimit "github.com/JGLTechnologies/gin-rate-limit"
+	"github.com/gin-gonic/gin"
+	"github.com/jasonlovesdoggo/abacus/utils"
+	"github.com/redis/go-redis/v9"
+)
+
+// APIKeyHeader is the header clients with a rate limit tier identify themselves with.
+const APIKeyHeader = "X-API-Key"
+
+const policyContextKey = "rateLimitPolicy"
+
+// fixedWindow counts a hit in the window KEYS[1], which lasts ARGV[1] seconds. Returns {hits, seconds until reset}.
+var fixedWindow = redis.NewScript(`
+local hits = redis.call('INCR', KEYS[1])
+if hits == 1 then
+	redis.call('EXPIRE', KEYS[1], ARGV[1])
+end
+return {hits, redis.call('TTL', KEYS[1])}
+`)
+
+// tieredStore applies the rate limit tier of the API key a request carries, falling back to the default store for
+// requests without a (known) API key. Tiers are stored in the rate limit database, see DB.md. An API key with an
+// incomplete tier is registered without limits of its own: it gets the default ones, keyed on the API key rather than
+// the client IP with RateLimitByAPIKey. Unknown API keys are limited by their IP, so making up a new one for every
+// request doesn't get a fresh budget.
+type tieredStore struct {
+	client   *redis.Client
+	fallback ratelimit.Store
+}
+
+// CreateTierKey builds the key of the rate limit tier of an API key, which is stored hashed.
+func CreateTierKey(apiKey string) string {
+	return "RT:" + utils.HashToken(apiKey)
+}
+
+func (s *tieredStore) Limit(key string, c *gin.Context) ratelimit.Info {
+	apiKey := c.GetHeader(APIKeyHeader)
+	if apiKey == "" {
+		return s.fallback.Limit(key, c)
+	}
+	ctx := Context(c)
+	tierKey := CreateTierKey(apiKey)
+	tier, err := s.client.HGetAll(ctx, tierKey).Result()
+	if err != nil || len(tier) == 0 { // unknown API key
+		return s.fallback.Limit(key, c)
+	}
+	tierLimit, limitErr := strconv.ParseUint(tier["limit"], 10, 64)
+	window, windowErr := strconv.ParseInt(tier["window"], 10, 64)
+	if limitErr != nil || windowErr != nil || window <= 0 { // registered without a tier of its own
+		if RateLimitKey == RateLimitByAPIKey {
+			key = apiKeyKey(apiKey)
+		}
+		return s.fallback.Limit(key, c)
+	}
+
+	result, err := fixedWindow.Run(ctx, s.client, []string{"R:" + tierKey}, window).Int64Slice()
+	if err != nil {
+		return s.fallback.Limit(key, c)
+	}
+	hits, resetIn := uint64(result[0]), time.Duration(result[1])*time.Second
+	remaining := uint64(0)
+	if hits < tierLimit {
+		remaining = tierLimit - hits
+	}
+	c.Set(policyContextKey, strconv.FormatUint(tierLimit, 10)+";w="+strconv.FormatInt(window, 10))
+	return ratelimit.Info{
+		Limit:         uint(tierLimit),
+		RateLimited:   hits > tierLimit,
+		ResetTime:     time.Now().Add(resetIn),
+		RemainingHits: uint(remaining),
+	}
+}
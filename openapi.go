@@ -0,0 +1,311 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+// apiParam documents a query parameter of an operation.
+type apiParam struct {
+	Name        string
+	Type        string // a JSON schema type, string if empty
+	Description string
+	Array       bool // the parameter may be repeated
+}
+
+// apiOperation documents a view for the OpenAPI spec. The spec is built from the routes the router actually has, so a
+// route only needs its view documented here to show up, with the path parameters taken from the route itself.
+type apiOperation struct {
+	Summary  string
+	Tag      string
+	Auth     string // "counter" for a counter admin key, "namespace" for a namespace admin key, "" for none
+	Query    []apiParam
+	Body     string // component schema of the JSON body, if any
+	Status   int    // status of a successful response, 200 if 0
+	Response string // component schema of a successful response, if it is JSON
+}
+
+var (
+	formatParams = []apiParam{
+		{Name: "format", Description: "Response format: json (default), text or protobuf"},
+		{Name: "callback", Description: "Wraps a JSON response in a JSONP callback"},
+		{Name: "base", Type: "integer", Description: "Also returns the value of an integer counter in this base (2 to 36) as value_in_base"},
+		{Name: "case", Description: "camel for camelCase field names, snake_case being the default"},
+	}
+	hitParams = append([]apiParam{
+		{Name: "step", Type: "number", Description: "Amount to change the counter by, 1 if not given"},
+		{Name: "roll", Description: "hourly, daily or monthly to count in a counter of the current period, see the key of the response"},
+		{Name: "dry_run", Type: "boolean", Description: "Returns the value the change would lead to without making it"},
+		{Name: "create", Type: "boolean", Description: "false to answer 404 rather than create a missing counter, defaults to CREATE_ON_HIT"},
+		{Name: "return", Description: "previous to return the value before the change as well"},
+	}, formatParams...)
+)
+
+// apiOperations documents the views by name, the paths and methods they are served at come from the router.
+// TestOpenAPI makes sure every route registered on the router is in the spec.
+var apiOperations = map[string]apiOperation{
+	"LivezView":          {Summary: "Check the server is up, without checking its database", Tag: "Server", Response: "Status"},
+	"HealthCheckView":    {Summary: "Check the server and its database are up", Tag: "Server", Response: "Health"},
+	"DocsView":           {Summary: "Redirect to the documentation", Tag: "Server", Status: http.StatusPermanentRedirect},
+	"OpenAPIView":        {Summary: "This spec", Tag: "Server", Response: "Object"},
+	"MetricsView":        {Summary: "Prometheus metrics, if METRICS_ENABLED", Tag: "Server"},
+	"VersionView":        {Summary: "Which build of the server is running", Tag: "Server", Response: "Version"},
+	"StatsView":          {Summary: "Server and database statistics", Tag: "Server", Query: []apiParam{{Name: "sort", Description: "Order of the namespaces, count or name"}, {Name: "limit", Type: "integer"}, {Name: "offset", Type: "integer"}}, Response: "Object"},
+	"SwaggerView":        {Summary: "Swagger UI for this spec", Tag: "Server"},
+	"MaintenanceView":    {Summary: "Toggle the read-only mode, requires the operator token", Tag: "Server", Query: []apiParam{{Name: "read_only", Type: "boolean", Description: "Whether to reject changes to counters"}}, Response: "Object"},
+	"GetView":            {Summary: "Get the value of a counter", Tag: "Counters", Query: append([]apiParam{{Name: "shards", Type: "boolean", Description: "Sum the counter across the shards in REDIS_SHARDS"}}, formatParams...), Response: "Value"},
+	"ExistsView":         {Summary: "Check whether a counter exists without creating it", Tag: "Counters", Response: "Exists"},
+	"BadgeView":          {Summary: "An SVG badge showing the value of a counter", Tag: "Counters", Query: []apiParam{{Name: "label"}, {Name: "color"}, {Name: "style"}}},
+	"HitView":            {Summary: "Increment a counter, creating it unless ?create=false", Tag: "Counters", Query: hitParams, Response: "Value"},
+	"DecView":            {Summary: "Decrement a counter", Tag: "Counters", Query: hitParams, Response: "Value"},
+	"HitBatchView":       {Summary: "Change several counters at once, each by its own step", Tag: "Counters", Body: "HitBatch", Response: "Results"},
+	"TransactionView":    {Summary: "Change several counters atomically", Tag: "Counters", Body: "Transaction", Response: "Results"},
+	"ReserveView":        {Summary: "Reserve a range of consecutive values", Tag: "Counters", Query: []apiParam{{Name: "count", Type: "integer", Description: "Number of values to reserve, 1 if not given"}}, Response: "Range"},
+	"StreamValueView":    {Summary: "Stream the value of a counter as server-sent events", Tag: "Counters"},
+	"WebSocketView":      {Summary: "Stream the value of a counter over a WebSocket", Tag: "Counters"},
+	"ExpiredStreamView":  {Summary: "Stream the counters of a namespace as they expire as server-sent events", Tag: "Namespaces", Auth: "namespace"},
+	"CreateView":         {Summary: "Create a counter", Tag: "Counters", Status: http.StatusCreated, Response: "Created", Query: append(createParams, apiParam{Name: "overwrite", Type: "boolean"})},
+	"CreateRandomView":   {Summary: "Create a counter with a random key", Tag: "Counters", Status: http.StatusCreated, Response: "Created", Query: append(createParams, apiParam{Name: "overwrite", Type: "boolean"})},
+	"UpsertView":         {Summary: "Change a counter, creating it first if it doesn't exist", Tag: "Counters", Response: "Created", Query: append([]apiParam{{Name: "step", Type: "number", Description: "Amount to change the counter by, 1 if not given"}}, createParams...)},
+	"InfoView":           {Summary: "Get a counter along with its metadata", Tag: "Counters", Response: "Info"},
+	"InfoBatchView":      {Summary: "Get several counters along with their metadata at once", Tag: "Counters", Body: "InfoBatch", Response: "InfoResults"},
+	"HistoryView":        {Summary: "Changes made to a counter over time", Tag: "Counters", Query: []apiParam{{Name: "from", Description: "Start, as RFC 3339, a unix timestamp or a date in the server's timezone"}, {Name: "to", Description: "End, as RFC 3339, a unix timestamp or a date in the server's timezone"}, {Name: "bucket", Description: "Bucket width, e.g. 1h"}}, Response: "History"},
+	"SnapshotView":       {Summary: "Get a snapshot of a counter, or all of them without a name", Tag: "Counters", Query: []apiParam{{Name: "name"}}, Response: "Snapshot"},
+	"ListView":           {Summary: "List the counters of a namespace", Tag: "Namespaces", Query: []apiParam{{Name: "prefix"}, {Name: "cursor"}, {Name: "tag", Array: true}}, Response: "List"},
+	"SumView":            {Summary: "Sum the counters of a namespace", Tag: "Namespaces", Query: []apiParam{{Name: "prefix"}}, Response: "Sum"},
+	"NamespaceTokenView": {Summary: "Claim a namespace or rotate its admin key", Tag: "Namespaces", Status: http.StatusCreated, Response: "Token"},
+	"DeleteView":         {Summary: "Delete a counter", Tag: "Admin", Auth: "counter", Response: "Status"},
+	"SetView":            {Summary: "Set the value of a counter", Tag: "Admin", Auth: "counter", Query: []apiParam{{Name: "value", Type: "number"}, {Name: "expected", Type: "number", Description: "Only set the value if the counter holds this one"}}, Response: "Value"},
+	"MaxView":            {Summary: "Set a counter to a value only if it is greater than the current one", Tag: "Admin", Auth: "counter", Query: []apiParam{{Name: "value", Type: "integer"}}, Response: "Value"},
+	"MinView":            {Summary: "Set a counter to a value only if it is less than the current one", Tag: "Admin", Auth: "counter", Query: []apiParam{{Name: "value", Type: "integer"}}, Response: "Value"},
+	"ResetView":          {Summary: "Reset a counter", Tag: "Admin", Auth: "counter", Query: []apiParam{{Name: "value", Type: "number", Description: "Value to reset to, 0 if not given"}}, Response: "Value"},
+	"UpdateByView":       {Summary: "Change a counter by an amount", Tag: "Admin", Auth: "counter", Query: []apiParam{{Name: "value", Type: "number"}}, Body: "Update", Response: "Value"},
+	"RenameView":         {Summary: "Rename a counter", Tag: "Admin", Auth: "counter", Query: []apiParam{{Name: "to", Description: "New key"}}, Response: "Status"},
+	"MergeView":          {Summary: "Add another counter into this one", Tag: "Admin", Auth: "counter", Query: []apiParam{{Name: "from", Description: "Key of the counter to merge"}, {Name: "delete", Type: "boolean"}}, Response: "Value"},
+	"WebhookView":        {Summary: "Call a URL whenever a counter reaches a multiple of a threshold", Tag: "Admin", Auth: "counter", Body: "Webhook", Response: "Webhook"},
+	"DescriptionView":    {Summary: "Describe what a counter tracks", Tag: "Admin", Auth: "counter", Query: []apiParam{{Name: "description", Description: "Up to 280 characters, empty to remove it"}}, Response: "Description"},
+	"TakeSnapshotView":   {Summary: "Freeze the current value of a counter into a named snapshot", Tag: "Admin", Auth: "counter", Query: []apiParam{{Name: "name"}}, Status: http.StatusCreated, Response: "Snapshot"},
+	"ShareView":          {Summary: "Mint a token that grants read access to a counter for a while", Tag: "Admin", Auth: "counter", Query: []apiParam{{Name: "ttl", Type: "integer", Description: "Seconds the token is valid for, 3600 if not given"}}, Status: http.StatusCreated, Response: "Share"},
+	"ExportView":         {Summary: "Export every counter of a namespace", Tag: "Namespaces", Auth: "namespace", Response: "Export"},
+	"ImportView":         {Summary: "Import counters from an export", Tag: "Namespaces", Auth: "namespace", Query: []apiParam{{Name: "overwrite", Type: "boolean"}}, Body: "Export", Response: "Import"},
+	"PurgeView":          {Summary: "Delete every counter of a namespace", Tag: "Namespaces", Auth: "namespace", Query: []apiParam{{Name: "confirm", Type: "boolean", Description: "Has to be true"}}, Response: "Purge"},
+	"ResetAllView":       {Summary: "Reset every counter of a namespace", Tag: "Namespaces", Auth: "namespace", Query: []apiParam{{Name: "confirm", Type: "boolean", Description: "Has to be true"}, {Name: "value", Type: "integer", Description: "Value to reset to, 0 if not given"}}, Response: "ResetAll"},
+	"RevokeShareView":    {Summary: "Revoke every share token of a namespace", Tag: "Namespaces", Auth: "namespace", Response: "Object"},
+	"SigningSecretView":  {Summary: "Require signed admin requests for a namespace, or stop requiring them with DELETE", Tag: "Namespaces", Auth: "namespace", Status: http.StatusCreated, Response: "Secret"},
+}
+
+var createParams = []apiParam{
+	{Name: "initializer", Type: "number", Description: "Initial value, 0 if not given"},
+	{Name: "type", Description: "int (default) or float"},
+	{Name: "ttl", Type: "integer", Description: "Seconds until the counter expires"},
+	{Name: "refresh_ttl", Type: "boolean"},
+	{Name: "max", Type: "number"},
+	{Name: "min", Type: "number"},
+	{Name: "min_mode", Description: "reject (default) or clamp"},
+	{Name: "visibility", Description: "public (default) or private"},
+	{Name: "tag", Array: true},
+	{Name: "description", Description: "What the counter tracks, up to 280 characters"},
+	{Name: "history", Type: "boolean"},
+	{Name: "delete_at_zero", Type: "boolean"},
+	{Name: "unique", Type: "boolean", Description: "Count each visitor once per window"},
+	{Name: "unique_window", Type: "integer", Description: "Seconds each visitor is counted once for, a day if not given"},
+}
+
+// apiObject is the JSON schema of an object with the given property types.
+func apiObject(properties map[string]string) gin.H {
+	schema := gin.H{}
+	for name, kind := range properties {
+		schema[name] = gin.H{"type": kind}
+	}
+	return gin.H{"type": "object", "properties": schema}
+}
+
+// apiSchemas are the component schemas the operations refer to.
+var apiSchemas = gin.H{
+	"Object":  gin.H{"type": "object"},
+	"Error":   apiObject(map[string]string{"error": "string"}),
+	"Value":   apiObject(map[string]string{"value": "number", "previous": "number", "value_in_base": "string", "clamped": "boolean", "deleted": "boolean", "counted": "boolean", "changed": "boolean"}),
+	"Version": apiObject(map[string]string{"version": "string", "git_commit": "string", "build_time": "string", "go_version": "string"}),
+	"Health":  apiObject(map[string]string{"status": "string", "read_only": "boolean"}),
+	"Exists":  apiObject(map[string]string{"exists": "boolean"}),
+	"Range":   apiObject(map[string]string{"start": "integer", "end": "integer"}),
+	"Status":  apiObject(map[string]string{"status": "string", "message": "string"}),
+	"Created": apiObject(map[string]string{"namespace": "string", "key": "string", "admin_key": "string", "admin_url": "string", "value": "number", "created": "boolean"}),
+	"Info": apiObject(map[string]string{"value": "number", "type": "string", "tags": "array", "created_at": "integer", "last_updated": "integer", "ttl": "number", "refresh_ttl": "boolean",
+		"max": "number", "min": "number", "visibility": "string", "description": "string", "full_key": "string", "is_genuine": "boolean", "expires_in": "number", "expires_str": "string", "exists": "boolean",
+		"history": "boolean", "delete_at_zero": "boolean", "unique_window": "number", "hits_last_minute": "integer"}),
+	"History":     apiObject(map[string]string{"namespace": "string", "key": "string", "from": "string", "to": "string", "bucket": "integer", "timezone": "string", "buckets": "array"}),
+	"List":        apiObject(map[string]string{"namespace": "string", "keys": "array", "cursor": "string"}),
+	"Sum":         apiObject(map[string]string{"namespace": "string", "total": "number", "count": "integer"}),
+	"Token":       apiObject(map[string]string{"namespace": "string", "admin_key": "string"}),
+	"Update":      apiObject(map[string]string{"delta": "number"}),
+	"Webhook":     apiObject(map[string]string{"url": "string", "every": "integer"}),
+	"Import":      apiObject(map[string]string{"imported": "integer", "skipped": "integer", "errors": "array"}),
+	"Purge":       apiObject(map[string]string{"namespace": "string", "deleted": "integer"}),
+	"Snapshot":    apiObject(map[string]string{"name": "string", "value": "number", "taken_at": "integer", "snapshots": "array"}),
+	"ResetAll":    apiObject(map[string]string{"namespace": "string", "reset": "integer", "skipped": "integer"}),
+	"Description": apiObject(map[string]string{"namespace": "string", "key": "string", "description": "string"}),
+	"Share":       apiObject(map[string]string{"namespace": "string", "key": "string", "token": "string", "expires_at": "integer", "url": "string"}),
+	"Secret":      apiObject(map[string]string{"namespace": "string", "signed": "boolean", "signing_secret": "string"}),
+	"Results":     gin.H{"type": "array", "items": apiObject(map[string]string{"namespace": "string", "key": "string", "value": "number", "error": "string"})},
+	"HitBatch": gin.H{"type": "object", "properties": gin.H{
+		"keys": gin.H{"type": "array", "items": apiObject(map[string]string{"namespace": "string", "key": "string", "step": "number"})},
+	}},
+	"InfoBatch": gin.H{"type": "object", "properties": gin.H{
+		"keys": gin.H{"type": "array", "items": apiObject(map[string]string{"namespace": "string", "key": "string"})},
+	}},
+	"InfoResults": gin.H{"type": "array", "items": apiRef("Info")},
+	"Transaction": gin.H{"type": "array", "items": apiObject(map[string]string{"op": "string", "namespace": "string", "key": "string", "step": "number"})},
+	"Export":      gin.H{"type": "array", "items": apiObject(map[string]string{"key": "string", "value": "number", "ttl": "integer", "metadata": "object"})},
+}
+
+// apiPath turns a gin path into an OpenAPI one along with its parameters, e.g. /get/:namespace/*key into
+// /get/{namespace}/{key}.
+func apiPath(path string) (string, []string) {
+	var params []string
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		if strings.HasPrefix(segment, ":") || strings.HasPrefix(segment, "*") {
+			params = append(params, segment[1:])
+			segments[i] = "{" + segment[1:] + "}"
+		}
+	}
+	return strings.Join(segments, "/"), params
+}
+
+// viewName is the name of the view a route is handled by, without its package. Views built by a function, such as
+// OpenAPIView, are named after it rather than the closure it returns.
+func viewName(handler string) string {
+	for {
+		name := handler[strings.LastIndex(handler, ".")+1:]
+		if !strings.HasPrefix(name, "func") || strings.Trim(name[len("func"):], "0123456789") != "" {
+			return name
+		}
+		handler = handler[:strings.LastIndex(handler, ".")]
+	}
+}
+
+// staticView is the name viewName gives the routes of static files, which aren't part of the API.
+const staticView = "StaticFile"
+
+func apiRef(schema string) gin.H {
+	return gin.H{"$ref": "#/components/schemas/" + schema}
+}
+
+// BuildOpenAPI builds the OpenAPI 3 spec of routes, taking the paths from the router so only the per-operation metadata
+// lives in apiOperations. Routes whose view isn't in it, i.e. the static files, are left out.
+func BuildOpenAPI(routes gin.RoutesInfo) gin.H {
+	paths := gin.H{}
+	operationIDs := map[string]bool{}
+	for _, route := range routes {
+		name := viewName(route.Handler)
+		operation, ok := apiOperations[name]
+		if !ok {
+			continue
+		}
+		path, pathParams := apiPath(route.Path)
+		parameters := []gin.H{}
+		for _, param := range pathParams {
+			parameters = append(parameters, gin.H{"name": param, "in": "path", "required": true, "schema": gin.H{"type": "string"}})
+		}
+		for _, param := range operation.Query {
+			kind := param.Type
+			if kind == "" {
+				kind = "string"
+			}
+			schema := gin.H{"type": kind}
+			if param.Array {
+				schema = gin.H{"type": "array", "items": schema}
+			}
+			parameters = append(parameters, gin.H{"name": param.Name, "in": "query", "description": param.Description, "schema": schema})
+		}
+
+		status := operation.Status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		success := gin.H{"description": http.StatusText(status)}
+		if operation.Response != "" {
+			success["content"] = gin.H{"application/json": gin.H{"schema": apiRef(operation.Response)}}
+		}
+		errorResponse := gin.H{"description": "Error", "content": gin.H{"application/json": gin.H{"schema": apiRef("Error")}}}
+
+		method := strings.ToLower(route.Method)
+		// the same view can be registered for several methods and paths, operation ids have to be unique though
+		operationID := method + strings.TrimSuffix(name, "View")
+		for i := 2; operationIDs[operationID]; i++ {
+			operationID = method + strings.TrimSuffix(name, "View") + strconv.Itoa(i)
+		}
+		operationIDs[operationID] = true
+
+		spec := gin.H{
+			"operationId": operationID,
+			"summary":     operation.Summary,
+			"tags":        []string{operation.Tag},
+			"parameters":  parameters,
+			"responses":   gin.H{strconv.Itoa(status): success, "default": errorResponse},
+		}
+		if operation.Auth != "" {
+			spec["security"] = []gin.H{{"bearer": []string{}}, {"token": []string{}}}
+			spec["description"] = "Requires the admin key of the " + operation.Auth + "."
+		}
+		if operation.Body != "" {
+			spec["requestBody"] = gin.H{"content": gin.H{"application/json": gin.H{"schema": apiRef(operation.Body)}}}
+		}
+
+		if _, ok := paths[path]; !ok {
+			paths[path] = gin.H{}
+		}
+		paths[path].(gin.H)[method] = spec
+	}
+	return gin.H{
+		"openapi": "3.0.3",
+		"info":    gin.H{"title": "Abacus", "version": Version, "description": "A simple counting API, see " + DocsUrl},
+		"paths":   paths,
+		"components": gin.H{
+			"schemas": apiSchemas,
+			"securitySchemes": gin.H{
+				"bearer": gin.H{"type": "http", "scheme": "bearer"},
+				"token":  gin.H{"type": "apiKey", "in": "query", "name": "token"},
+			},
+		},
+	}
+}
+
+// OpenAPIView serves the OpenAPI spec of the routes of r, built on the first request once every route is registered.
+func OpenAPIView(r *gin.Engine) gin.HandlerFunc {
+	var once sync.Once
+	var spec gin.H
+	return func(c *gin.Context) {
+		once.Do(func() { spec = BuildOpenAPI(r.Routes()) })
+		c.JSON(http.StatusOK, spec)
+	}
+}
+
+// swaggerPage renders the spec with Swagger UI, loaded from a CDN so it doesn't have to be vendored.
+const swaggerPage = `<!DOCTYPE html>
+<html lang="en">
+<head>
+    <meta charset="utf-8">
+    <title>Abacus API</title>
+    <link rel="icon" href="/favicon.svg">
+    <link rel="stylesheet" href="https://unpkg.com/swagger-ui-dist@5/swagger-ui.css">
+</head>
+<body>
+<div id="swagger-ui"></div>
+<script src="https://unpkg.com/swagger-ui-dist@5/swagger-ui-bundle.js"></script>
+<script>
+    window.ui = SwaggerUIBundle({url: "/openapi.json", dom_id: "#swagger-ui"});
+</script>
+</body>
+</html>
+`
+
+// SwaggerView serves Swagger UI for the spec at /openapi.json.
+func SwaggerView(c *gin.Context) {
+	c.Data(http.StatusOK, "text/html; charset=utf-8", []byte(swaggerPage))
+}
@@ -0,0 +1,243 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             (unknown)
+// source: counter.proto
+
+package pb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	Counters_Get_FullMethodName  = "/abacus.Counters/Get"
+	Counters_Hit_FullMethodName  = "/abacus.Counters/Hit"
+	Counters_Set_FullMethodName  = "/abacus.Counters/Set"
+	Counters_Info_FullMethodName = "/abacus.Counters/Info"
+)
+
+// CountersClient is the client API for Counters service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// Counters serves the REST endpoints of the same names over gRPC, with the same validation, limits and errors (as
+// the closest gRPC status codes). Tokens are passed as "authorization: Bearer TOKEN" metadata, and the signatures of
+// namespaces that require signed requests as "x-signature" and "x-timestamp" metadata, signing the REST request.
+type CountersClient interface {
+	Get(ctx context.Context, in *CounterRequest, opts ...grpc.CallOption) (*Counter, error)
+	Hit(ctx context.Context, in *HitRequest, opts ...grpc.CallOption) (*Counter, error)
+	Set(ctx context.Context, in *SetRequest, opts ...grpc.CallOption) (*Counter, error)
+	Info(ctx context.Context, in *CounterRequest, opts ...grpc.CallOption) (*CounterInfo, error)
+}
+
+type countersClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewCountersClient(cc grpc.ClientConnInterface) CountersClient {
+	return &countersClient{cc}
+}
+
+func (c *countersClient) Get(ctx context.Context, in *CounterRequest, opts ...grpc.CallOption) (*Counter, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Counter)
+	err := c.cc.Invoke(ctx, Counters_Get_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *countersClient) Hit(ctx context.Context, in *HitRequest, opts ...grpc.CallOption) (*Counter, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Counter)
+	err := c.cc.Invoke(ctx, Counters_Hit_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *countersClient) Set(ctx context.Context, in *SetRequest, opts ...grpc.CallOption) (*Counter, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Counter)
+	err := c.cc.Invoke(ctx, Counters_Set_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *countersClient) Info(ctx context.Context, in *CounterRequest, opts ...grpc.CallOption) (*CounterInfo, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(CounterInfo)
+	err := c.cc.Invoke(ctx, Counters_Info_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// CountersServer is the server API for Counters service.
+// All implementations must embed UnimplementedCountersServer
+// for forward compatibility.
+//
+// Counters serves the REST endpoints of the same names over gRPC, with the same validation, limits and errors (as
+// the closest gRPC status codes). Tokens are passed as "authorization: Bearer TOKEN" metadata, and the signatures of
+// namespaces that require signed requests as "x-signature" and "x-timestamp" metadata, signing the REST request.
+type CountersServer interface {
+	Get(context.Context, *CounterRequest) (*Counter, error)
+	Hit(context.Context, *HitRequest) (*Counter, error)
+	Set(context.Context, *SetRequest) (*Counter, error)
+	Info(context.Context, *CounterRequest) (*CounterInfo, error)
+	mustEmbedUnimplementedCountersServer()
+}
+
+// UnimplementedCountersServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedCountersServer struct{}
+
+func (UnimplementedCountersServer) Get(context.Context, *CounterRequest) (*Counter, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Get not implemented")
+}
+func (UnimplementedCountersServer) Hit(context.Context, *HitRequest) (*Counter, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Hit not implemented")
+}
+func (UnimplementedCountersServer) Set(context.Context, *SetRequest) (*Counter, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Set not implemented")
+}
+func (UnimplementedCountersServer) Info(context.Context, *CounterRequest) (*CounterInfo, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Info not implemented")
+}
+func (UnimplementedCountersServer) mustEmbedUnimplementedCountersServer() {}
+func (UnimplementedCountersServer) testEmbeddedByValue()                  {}
+
+// UnsafeCountersServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to CountersServer will
+// result in compilation errors.
+type UnsafeCountersServer interface {
+	mustEmbedUnimplementedCountersServer()
+}
+
+func RegisterCountersServer(s grpc.ServiceRegistrar, srv CountersServer) {
+	// If the following call pancis, it indicates UnimplementedCountersServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&Counters_ServiceDesc, srv)
+}
+
+func _Counters_Get_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CounterRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CountersServer).Get(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Counters_Get_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CountersServer).Get(ctx, req.(*CounterRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Counters_Hit_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(HitRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CountersServer).Hit(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Counters_Hit_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CountersServer).Hit(ctx, req.(*HitRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Counters_Set_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SetRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CountersServer).Set(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Counters_Set_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CountersServer).Set(ctx, req.(*SetRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Counters_Info_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CounterRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CountersServer).Info(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Counters_Info_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CountersServer).Info(ctx, req.(*CounterRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// Counters_ServiceDesc is the grpc.ServiceDesc for Counters service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Counters_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "abacus.Counters",
+	HandlerType: (*CountersServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Get",
+			Handler:    _Counters_Get_Handler,
+		},
+		{
+			MethodName: "Hit",
+			Handler:    _Counters_Hit_Handler,
+		},
+		{
+			MethodName: "Set",
+			Handler:    _Counters_Set_Handler,
+		},
+		{
+			MethodName: "Info",
+			Handler:    _Counters_Info_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "counter.proto",
+}
@@ -0,0 +1,5 @@
+// Package pb holds the protobuf messages served to clients that ask for them with Accept: application/x-protobuf, and
+// the gRPC service served on GRPC_PORT.
+package pb
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative counter.proto
@@ -2,23 +2,35 @@ package main
 
 import (
 	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"io"
-	"log"
 	"math"
 	"net/http"
+	"net/netip"
+	"net/url"
+	"runtime"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/redis/go-redis/v9"
 
+	"github.com/goccy/go-json"
 	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
 
+	"github.com/jasonlovesdoggo/abacus/middleware"
+	"github.com/jasonlovesdoggo/abacus/pb"
 	"github.com/jasonlovesdoggo/abacus/utils"
 
 	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
 )
 
 func StreamValueView(c *gin.Context) {
@@ -33,6 +45,18 @@ func StreamValueView(c *gin.Context) {
 		c.AbortWithStatus(http.StatusBadRequest)
 		return
 	}
+	meta, err := utils.GetMetadata(context.Background(), readClient(), dbKey)
+	if err != nil {
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	if !authorizeShared(c, meta) {
+		return
+	}
+	if meta.IsFloat() {
+		c.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": "Streaming is only supported for integer counters."})
+		return
+	}
 
 	// Initialize client channel
 	clientChan := make(chan int)
@@ -58,29 +82,33 @@ func StreamValueView(c *gin.Context) {
 		}
 	}()
 
-	// Send initial value
-	initialVal := Client.Get(context.Background(), dbKey).Val()
-	if count, err := strconv.Atoi(initialVal); err == nil {
-		_, err := c.Writer.WriteString(fmt.Sprintf("data: {\"value\":%d}\n\n", count))
-		if err != nil {
-			log.Printf("Error writing to client: %v", err)
-			return
-		}
-		c.Writer.Flush()
+	// Send initial value, so clients can render right away instead of waiting for the next change. A counter that
+	// doesn't exist (yet) is reported as 0 without creating it, as that's the value its first hit starts from.
+	count, _ := strconv.Atoi(readClient().Get(context.Background(), dbKey).Val())
+	if _, err := c.Writer.WriteString(fmt.Sprintf("data: {\"value\":%d}\n\n", count)); err != nil {
+		middleware.Log(c).Warn("Error writing to client", "error", err)
+		return
 	}
+	c.Writer.Flush()
 
 	// Stream updates
+	serverClosing := shutdownCtx.Done()
 	c.Stream(func(w io.Writer) bool {
 		select {
 		case <-c.Request.Context().Done():
 			return false
+		case <-serverClosing:
+			// tell the client this was on purpose, so it can reconnect once we're back instead of seeing an error
+			c.Writer.WriteString("event: close\ndata: {\"reason\":\"server shutting down\"}\n\n")
+			c.Writer.Flush()
+			return false
 		case count, ok := <-clientChan:
 			if !ok {
 				return false
 			}
 			_, err := c.Writer.WriteString(fmt.Sprintf("data: {\"value\":%d}\n\n", count))
 			if err != nil {
-				log.Printf("Error writing to client: %v", err)
+				middleware.Log(c).Warn("Error writing to client", "error", err)
 				return false // Stream closed by client or server error
 			}
 			c.Writer.Flush()
@@ -89,7 +117,49 @@ func StreamValueView(c *gin.Context) {
 	})
 }
 
-func HitView(c *gin.Context) {
+// ExpiredStreamView streams the counters of a namespace as they expire, as {"namespace": ..., "key": ...} events.
+func ExpiredStreamView(c *gin.Context) {
+	expirations, stop := utils.Expirations.Listen(c.Param("namespace"))
+	defer stop()
+	c.Writer.WriteHeader(http.StatusOK)
+	c.Writer.Flush()
+
+	serverClosing := shutdownCtx.Done()
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case <-c.Request.Context().Done():
+			return false
+		case <-serverClosing:
+			c.Writer.WriteString("event: close\ndata: {\"reason\":\"server shutting down\"}\n\n")
+			c.Writer.Flush()
+			return false
+		case expiration := <-expirations:
+			data, _ := json.Marshal(expiration)
+			if _, err := c.Writer.WriteString("event: expired\ndata: " + string(data) + "\n\n"); err != nil {
+				middleware.Log(c).Warn("Error writing to client", "error", err)
+				return false
+			}
+			c.Writer.Flush()
+			return true
+		}
+	})
+}
+
+const (
+	wsWriteWait  = 10 * time.Second
+	wsPongWait   = 60 * time.Second
+	wsPingPeriod = wsPongWait * 9 / 10 // must be less than wsPongWait
+)
+
+var wsUpgrader = websocket.Upgrader{
+	CheckOrigin: func(r *http.Request) bool { // same as CORS
+		origin := r.Header.Get("Origin")
+		return CORSOrigins == nil || origin == "" || utils.OriginAllowed(CORSOrigins, origin)
+	},
+}
+
+// WebSocketView is StreamValueView over a WebSocket, for clients behind proxies that buffer SSE.
+func WebSocketView(c *gin.Context) {
 	namespace, key := utils.GetNamespaceKey(c)
 	if namespace == "" || key == "" {
 		return
@@ -98,244 +168,3138 @@ func HitView(c *gin.Context) {
 	if dbKey == "" { // error is handled in CreateKey
 		return
 	}
-	// Get data from Redis
-	val, err := Client.Incr(context.Background(), dbKey).Result()
+	meta, err := utils.GetMetadata(context.Background(), readClient(), dbKey)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get data. Try again later."})
+		respondJSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to get data. Try again later."})
 		return
 	}
-	// check if val is is greater than the max value of an int
-	if val > math.MaxInt {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Value is too large. Max value is " + strconv.Itoa(math.
-			MaxInt), "message": "If you are seeing this error and have a legitimate use case, please contact me @ abacus@jasoncameron.dev"})
+	if !authorizeShared(c, meta) {
+		return
+	}
+	if meta.IsFloat() {
+		respondJSON(c, http.StatusConflict, gin.H{"error": "Streaming is only supported for integer counters."})
 		return
 	}
-	go func() {
-		utils.SetStream(dbKey, int(val)) // #nosec G115 -- This is safe as we perform a check (
-		// see above) to ensure val is within the range of an int.
-		Client.Expire(context.Background(), dbKey, utils.BaseTTLPeriod)
-	}()
-	if c.Query("callback") != "" {
-		c.JSONP(http.StatusOK, gin.H{"value": val})
 
-	} else {
-		c.JSON(http.StatusOK, gin.H{"value": val})
+	conn, err := wsUpgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil { // the upgrader already responded
+		return
+	}
+	defer conn.Close()
 
+	clientChan := make(chan int)
+	utils.ValueEventServer.NewClients <- utils.KeyClientPair{
+		Key:    dbKey,
+		Client: clientChan,
 	}
-}
+	defer func() {
+		// Drain client channel to prevent blocking
+		go func() {
+			for range clientChan {
+			}
+		}()
+		utils.ValueEventServer.ClosedClients <- utils.KeyClientPair{
+			Key:    dbKey,
+			Client: clientChan,
+		}
+	}()
 
-func GetView(c *gin.Context) {
-	namespace, key := utils.GetNamespaceKey(c)
-	if namespace == "" || key == "" {
-		return
+	// the read loop processes pongs and notices when the client goes away, messages from the client are ignored
+	disconnected := make(chan struct{})
+	conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	})
+	go func() {
+		defer close(disconnected)
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	write := func(message func() error) bool {
+		conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+		if err := message(); err != nil {
+			middleware.Log(c).Warn("Error writing to client", "error", err)
+			return false
+		}
+		return true
 	}
-	dbKey := utils.CreateKey(c, namespace, key, false)
-	if dbKey == "" { // error is handled in CreateKey
+
+	// Send initial value, 0 if the counter doesn't exist (yet) like in StreamValueView
+	count, _ := strconv.Atoi(readClient().Get(context.Background(), dbKey).Val())
+	if !write(func() error { return conn.WriteJSON(gin.H{"value": count}) }) {
 		return
 	}
-	// Get data from Redis
-	val, err := Client.Get(context.Background(), dbKey).Result()
 
-	if errors.Is(err, redis.Nil) {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Key not found"})
-		return
-	} else if err != nil { // Other Redis errors
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get data. Try again later."})
-		return
+	ticker := time.NewTicker(wsPingPeriod)
+	defer ticker.Stop()
+	serverClosing := shutdownCtx.Done()
+	for {
+		select {
+		case <-disconnected:
+			return
+		case <-serverClosing:
+			write(func() error {
+				return conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseGoingAway, "server shutting down"))
+			})
+			return
+		case count, ok := <-clientChan:
+			if !ok {
+				write(func() error { return conn.WriteMessage(websocket.CloseMessage, []byte{}) })
+				return
+			}
+			if !write(func() error { return conn.WriteJSON(gin.H{"value": count}) }) {
+				return
+			}
+		case <-ticker.C:
+			if !write(func() error { return conn.WriteMessage(websocket.PingMessage, nil) }) {
+				return
+			}
+		}
 	}
+}
 
-	go func() {
-		Client.Expire(context.Background(), dbKey, utils.BaseTTLPeriod)
-	}()
-	intval, _ := strconv.Atoi(val)
-	if c.Query("callback") != "" {
-		c.JSONP(http.StatusOK, gin.H{"value": intval})
+const decrementStepError = "step must be positive, /dec always decrements. Use /hit to increment"
 
-	} else {
-		c.JSON(http.StatusOK, gin.H{"value": intval})
+func HitView(c *gin.Context) {
+	hit(c, false)
+}
+
+// DecView decrements a counter by 1, or by ?step=, which has to be positive. It is a hit otherwise, so the counter's
+// min is enforced the same way.
+func DecView(c *gin.Context) {
+	hit(c, true)
+}
+
+// baseContextKey holds the base ?base= asked for the value to be returned in as well, see parseBase.
+const baseContextKey = "base"
 
+// parseBase validates ?base=, responding with a 400 if it isn't between 2 and 36 and with a 409 for float counters,
+// and reports whether the request may go on. A valid base is kept for respondBody to format the value with.
+func parseBase(c *gin.Context, meta utils.Metadata) bool {
+	rawBase, ok := c.GetQuery("base")
+	if !ok {
+		return true
+	}
+	base, err := strconv.Atoi(rawBase)
+	if err != nil || base < 2 || base > 36 {
+		respondJSON(c, http.StatusBadRequest, gin.H{"error": "base must be a number between 2 and 36"})
+		return false
+	}
+	if meta.IsFloat() {
+		respondJSON(c, http.StatusConflict, gin.H{"error": "base only works with integer counters"})
+		return false
 	}
+	c.Set(baseContextKey, base)
+	return true
 }
 
-func CreateRandomView(c *gin.Context) {
-	key, _ := utils.GenerateRandomString(16)
-	namespace, err := utils.GenerateRandomString(16)
+// rolledKeyContextKey holds the key a hit with ?roll= went to, which is returned along with the value.
+const rolledKeyContextKey = "rolled_key"
+
+// parseCreateOnHit reports whether a hit may create its counter if it doesn't exist yet, CreateOnHit unless ?create=
+// says otherwise. It reports false as its second value after writing a 400 for an invalid ?create=.
+func parseCreateOnHit(c *gin.Context) (bool, bool) {
+	create, err := strconv.ParseBool(c.DefaultQuery("create", strconv.FormatBool(CreateOnHit)))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate random string. Try again later."})
-		return
+		respondJSON(c, http.StatusBadRequest, gin.H{"error": "create must be either true or false"})
+		return false, false
 	}
-
-	c.Params = gin.Params{gin.Param{Key: "namespace", Value: namespace}, gin.Param{Key: "key", Value: key}}
-	CreateView(c)
+	return create, true
 }
-func CreateView(c *gin.Context) {
+
+// hit changes a counter by ?step=, flipping its sign if decrement is set.
+func hit(c *gin.Context, decrement bool) {
 	namespace, key := utils.GetNamespaceKey(c)
 	if namespace == "" || key == "" {
 		return
 	}
+	var roll *utils.RollPeriod
+	if rawRoll := c.Query("roll"); rawRoll != "" {
+		period, err := utils.ParseRollPeriod(rawRoll)
+		if err != nil {
+			respondJSON(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		roll, key = &period, period.Key(key, time.Now())
+		c.Set(rolledKeyContextKey, key)
+	}
 	dbKey := utils.CreateKey(c, namespace, key, false)
 	if dbKey == "" { // error is handled in CreateKey
 		return
 	}
-	initialValue, err := strconv.Atoi(c.DefaultQuery("initializer", "0"))
+	meta, err := utils.GetMetadata(middleware.Context(c), Client, dbKey)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "initializer must be a number"})
+		respondJSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to get data. Try again later."})
 		return
 	}
-	// Get data from Redis
-	created := Client.SetNX(context.Background(), dbKey, initialValue, utils.BaseTTLPeriod)
-	if created.Val() == false {
-		c.JSON(http.StatusConflict, gin.H{"error": "Key already exists, please use a different key."})
+	if !authorizeRead(c, meta) {
 		return
 	}
-	AdminKey := uuid.New().String()                                            // Create a new admin key used for deletion and control
-	Client.Set(context.Background(), utils.CreateAdminKey(dbKey), AdminKey, 0) // todo: figure out how to handle admin keys (handle alongside admin orrrrrrr separately as in a routine once a month that deletes all admin keys with no corresponding key)
-	utils.SetStream(dbKey, initialValue)
-	c.JSON(http.StatusCreated, gin.H{"key": key, "namespace": namespace, "admin_key": AdminKey, "value": initialValue})
-}
-
-func InfoView(c *gin.Context) { // todo: write docs on what negative values mean (https://redis.io/commands/ttl/)
-	namespace, key := utils.GetNamespaceKey(c)
-	if namespace == "" || key == "" {
+	if ret := c.DefaultQuery("return", "value"); ret != "value" && ret != "previous" {
+		respondJSON(c, http.StatusBadRequest, gin.H{"error": "return must be either value or previous"})
 		return
 	}
-	dbKey := utils.CreateKey(c, namespace, key, true)
-	if dbKey == "" { // error is handled in CreateKey
+	if !parseBase(c, meta) {
 		return
 	}
-	dbValue := Client.Get(context.Background(), dbKey).Val()
-	count, _ := strconv.Atoi(dbValue)
-
-	isGenuine := Client.Exists(context.Background(), utils.CreateAdminKey(dbKey)).Val() == 0
-	expiresAt := Client.TTL(context.Background(), dbKey).Val()
-	exists := expiresAt != -2
-	if !exists {
-		count = -1
+	dryRun, err := strconv.ParseBool(c.DefaultQuery("dry_run", "false"))
+	if err != nil {
+		respondJSON(c, http.StatusBadRequest, gin.H{"error": "dry_run must be either true or false"})
+		return
 	}
-	c.JSON(http.StatusOK, gin.H{"value": count, "full_key": dbKey, "is_genuine": isGenuine, "expires_in": expiresAt.Seconds(), "expires_str": expiresAt.String(), "exists": exists})
-}
-
-func DeleteView(c *gin.Context) {
-	namespace, key := utils.GetNamespaceKey(c)
-	if namespace == "" || key == "" {
+	create, ok := parseCreateOnHit(c)
+	if !ok {
 		return
 	}
-	dbKey := utils.CreateKey(c, namespace, key, true)
-	if dbKey == "" { // error is handled in CreateKey
+	if !create {
+		// a counter deleted between this check and the increment is still recreated, which is fine for catching typos
+		exists, err := Client.Exists(middleware.Context(c), dbKey).Result()
+		if err != nil {
+			respondJSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to get data. Try again later."})
+			return
+		}
+		if exists == 0 {
+			respondJSON(c, http.StatusNotFound, gin.H{"error": "Key not found"})
+			return
+		}
+	}
+	rawStep := c.DefaultQuery("step", "1")
+	if meta.IsFloat() {
+		step, ok := parseFloatAmount(c, "step", rawStep)
+		if !ok {
+			return
+		}
+		if step == 0 {
+			respondJSON(c, http.StatusBadRequest, gin.H{"error": "changing value by 0 does nothing, please provide a non-zero value in the fmt of ?step=STEP"})
+			return
+		}
+		if decrement {
+			if step < 0 {
+				respondJSON(c, http.StatusBadRequest, gin.H{"error": decrementStepError})
+				return
+			}
+			step = -step
+		}
+		if dryRun {
+			respondDryRun(c, dbKey, meta, step)
+			return
+		}
+		visitor := hitVisitor(c, dbKey, meta, decrement)
+		if meta.Bounded() || visitor != "" {
+			result, err := incrementBounded(middleware.Context(c), Client.TxPipeline(), dbKey, meta, strconv.FormatFloat(step, 'f', -1, 64), visitor)
+			if err != nil {
+				failWrite(c, err, "Failed to get data. Try again later.")
+				return
+			}
+			if result.Status == utils.IncrRepeated {
+				respondRepeated(c, result.Value)
+				return
+			}
+			recordHit(middleware.Context(c), dbKey, meta, 0)
+			if rejectBounded(c, meta, result) {
+				return
+			}
+			utils.Events.Emit(dbKey, hitOp(decrement), result.Value)
+			notifyThreshold(namespace, key, meta, result.Value, step)
+			respondHit(c, meta, result.Value, result.Previous, result.Status)
+			return
+		}
+		val, err := Client.IncrByFloat(middleware.Context(c), dbKey, step).Result()
+		if err != nil {
+			failWrite(c, err, "Failed to get data. Try again later.")
+			return
+		}
+		recordHit(middleware.Context(c), dbKey, meta, step)
+		utils.Events.Emit(dbKey, hitOp(decrement), val)
+		notifyThreshold(namespace, key, meta, val, step)
+		respondHit(c, meta, val, val-step, utils.IncrApplied) // INCRBYFLOAT is atomic, so this is exactly the value it was applied to
 		return
 	}
-	adminDBKey := utils.CreateAdminKey(dbKey)    // Create the admin key
-	Client.Del(context.Background(), dbKey)      // Delete the normal key
-	Client.Del(context.Background(), adminDBKey) // delete the admin key as it's now useless
-	c.JSON(http.StatusOK, gin.H{"status": "ok", "message": "Deleted key: " + dbKey})
-	utils.CloseStream(dbKey)
-}
-
-func SetView(c *gin.Context) {
-	updatedValueRaw, _ := c.GetQuery("value")
-	if updatedValueRaw == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "value is required, please provide a number in the fmt of ?value=NEW_VALUE"})
+	step, ok := parseIntAmount(c, "step", rawStep)
+	if !ok {
 		return
-
 	}
-	updatedValue, err := strconv.Atoi(updatedValueRaw)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "value must be a number"})
+	if step == 0 {
+		respondJSON(c, http.StatusBadRequest, gin.H{"error": "changing value by 0 does nothing, please provide a non-zero value in the fmt of ?step=STEP"})
 		return
 	}
-	namespace, key := utils.GetNamespaceKey(c)
-	if namespace == "" || key == "" {
+	if decrement {
+		if step < 0 {
+			respondJSON(c, http.StatusBadRequest, gin.H{"error": decrementStepError})
+			return
+		}
+		step = -step
+	}
+	if dryRun {
+		respondDryRun(c, dbKey, meta, step)
 		return
 	}
-	dbKey := utils.CreateKey(c, namespace, key, false)
-	if dbKey == "" { // error is handled in CreateKey
+	// counters that never recorded an update are most likely new (float ones have their type recorded already), rolled
+	// ones aren't counted as a new one starts every period
+	if create && roll == nil && meta.LastUpdated.IsZero() && !claimCounter(c, namespace, dbKey) {
 		return
 	}
-
 	// Get data from Redis
-	val, err := Client.SetXX(context.Background(), dbKey, updatedValue, utils.BaseTTLPeriod).Result()
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to set data. Try again later."})
-		return
+	pipe := Client.TxPipeline()
+	if roll != nil && !meta.CustomTTL {
+		// the first hit of a period sets its counter up to expire RollKeep periods after the period is over
+		meta.CustomTTL, meta.TTL = true, time.Until(roll.Expiry(time.Now())).Round(time.Second)
+		pipe.SetNX(middleware.Context(c), dbKey, 0, meta.TTL)
+		utils.QueueMetadata(middleware.Context(c), pipe, dbKey, meta, meta.TTL)
 	}
-	if val == false {
-		c.JSON(http.StatusConflict, gin.H{"error": "Key does not exist, please use a different key."})
+	var val int64
+	var previous interface{}
+	status := int64(utils.IncrApplied)
+	if visitor := hitVisitor(c, dbKey, meta, decrement); meta.Bounded() || visitor != "" {
+		result, err := incrementBounded(middleware.Context(c), pipe, dbKey, meta, strconv.FormatInt(step, 10), visitor)
+		if err != nil {
+			failWrite(c, err, "Failed to get data. Try again later.")
+			return
+		}
+		if result.Status == utils.IncrRepeated {
+			respondRepeated(c, result.Value)
+			return
+		}
+		recordHit(middleware.Context(c), dbKey, meta, 0)
+		if rejectBounded(c, meta, result) {
+			return
+		}
+		val, _ = result.Value.(int64)
+		previous, status = result.Previous, result.Status
 	} else {
-		go utils.SetStream(dbKey, updatedValue)
-		c.JSON(http.StatusOK, gin.H{"value": updatedValue})
+		incr := pipe.IncrBy(middleware.Context(c), dbKey, step)
+		if _, err := pipe.Exec(middleware.Context(c)); err != nil {
+			failWrite(c, err, "Failed to get data. Try again later.")
+			return
+		}
+		recordHit(middleware.Context(c), dbKey, meta, float64(step))
+		val = incr.Val()
+		previous = val - step // INCRBY is atomic, so no other hit can have happened in between
+	}
+	// check if val is is greater than the max value of an int
+	if val > math.MaxInt {
+		respondJSON(c, http.StatusBadRequest, gin.H{"error": "Value is too large. Max value is " + strconv.Itoa(math.
+			MaxInt), "message": "If you are seeing this error and have a legitimate use case, please contact me @ abacus@jasoncameron.dev"})
+		return
 	}
+	go utils.SetStream(dbKey, int(val)) // #nosec G115 -- This is safe as we perform a check (
+	// see above) to ensure val is within the range of an int.
+	utils.Events.Emit(dbKey, hitOp(decrement), val)
+	notifyThreshold(namespace, key, meta, val, float64(step))
+	respondHit(c, meta, val, previous, status)
 }
 
-func ResetView(c *gin.Context) {
-	namespace, key := utils.GetNamespaceKey(c)
-	if namespace == "" || key == "" {
+type batchKey struct {
+	Namespace string      `json:"namespace"`
+	Key       string      `json:"key"`
+	Step      json.Number `json:"step"` // 1 if not given
+}
+
+type hitBatchRequest struct {
+	Keys []batchKey `json:"keys"`
+}
+
+// HitBatchView changes several counters by their own step (1 if not given) in a single pipeline. Unlike in a
+// transaction the entries are independent, one that is invalid, private or runs into a bound gets an error in its
+// result while the others are still applied. Entries of unique counters count the visitor making the batch once, like
+// /hit does.
+func HitBatchView(c *gin.Context) {
+	var request hitBatchRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		if middleware.BodyTooLarge(c, err) {
+			return
+		}
+		respondJSON(c, http.StatusBadRequest, gin.H{"error": "Invalid body, please provide a JSON body in the fmt of {\"keys\":[{\"namespace\":\"NAMESPACE\",\"key\":\"KEY\",\"step\":STEP}]}"})
 		return
 	}
-	dbKey := utils.CreateKey(c, namespace, key, false)
-	if dbKey == "" { // error is handled in CreateKey
+	if len(request.Keys) == 0 || len(request.Keys) > utils.MaxBatchSize {
+		respondJSON(c, http.StatusBadRequest, gin.H{"error": "keys must contain between 1 and " + strconv.Itoa(utils.MaxBatchSize) + " entries"})
 		return
 	}
-
-	// Get data from Redis
-	val, err := Client.SetXX(context.Background(), dbKey, 0, utils.BaseTTLPeriod).Result()
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to set data. Try again later."})
+	create, ok := parseCreateOnHit(c)
+	if !ok {
 		return
 	}
-	if val == false {
-		c.JSON(http.StatusConflict, gin.H{"error": "Key does not exist, please use a different key."})
-	} else {
-		c.JSON(http.StatusOK, gin.H{"value": 0})
-		go utils.SetStream(dbKey, 0)
+	results := make([]gin.H, len(request.Keys))
+	dbKeys := make([]string, len(request.Keys)) // empty for the entries that were rejected
+	for i, entry := range request.Keys {
+		namespace := entry.Namespace
+		if namespace == "" {
+			namespace = "default"
+		}
+		results[i] = gin.H{"namespace": namespace, "key": entry.Key}
+		dbKey, err := utils.ValidateKey(entry.Namespace, entry.Key)
+		if err != nil {
+			results[i]["error"] = err.Error()
+			continue
+		}
+		dbKeys[i] = dbKey
 	}
-}
 
-func UpdateByView(c *gin.Context) {
-	updatedValueRaw, _ := c.GetQuery("value")
-	if updatedValueRaw == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "value is required, please provide a number in the fmt of ?value=NEW_VALUE"})
+	ctx := middleware.Context(c)
+	// look up the metadata first so float counters can be incremented with INCRBYFLOAT
+	metaPipe := Client.Pipeline()
+	metaCmds := make([]*redis.MapStringStringCmd, len(dbKeys))
+	existsCmds := make([]*redis.IntCmd, len(dbKeys)) // only looked up if missing counters mustn't be created
+	for i, dbKey := range dbKeys {
+		if dbKey != "" {
+			metaCmds[i] = metaPipe.HGetAll(ctx, utils.CreateMetaKey(dbKey))
+			if !create {
+				existsCmds[i] = metaPipe.Exists(ctx, dbKey)
+			}
+		}
+	}
+	if _, err := metaPipe.Exec(ctx); err != nil {
+		respondJSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to get data. Try again later."})
 		return
+	}
+
+	metas := make([]utils.Metadata, len(dbKeys))
+	steps := make([]float64, len(dbKeys))
+	amounts := make([]string, len(dbKeys)) // empty for the entries that were rejected
+	for i, dbKey := range dbKeys {
+		if dbKey == "" {
+			continue
+		}
+		meta := utils.ParseMetadata(metaCmds[i].Val())
+		if meta.Private { // a batch carries no tokens, so private counters can't be hit
+			results[i]["error"] = "Counter is private"
+			continue
+		}
+		if existsCmds[i] != nil && existsCmds[i].Val() == 0 {
+			results[i]["error"] = "Key not found"
+			continue
+		}
+		amount, step, err := parseBatchStep(request.Keys[i].Step, meta)
+		if err != nil {
+			results[i]["error"] = err.Error()
+			continue
+		}
+		metas[i], steps[i], amounts[i] = meta, step, amount
+	}
 
+	// counters the batch is most likely about to create take a slot of their namespace first, see claimCounter
+	claimPipe := Client.Pipeline()
+	claimCmds := make([]*redis.Cmd, len(dbKeys))
+	for i, dbKey := range dbKeys {
+		if amounts[i] != "" && create && metas[i].LastUpdated.IsZero() {
+			claimCmds[i] = utils.CreateCounter.Eval(ctx, claimPipe, []string{dbKey, utils.CreateNamespaceKey(utils.NamespaceOf(dbKey))},
+				0, int64(utils.BaseTTLPeriod.Seconds()), MaxCounters)
+		}
 	}
-	incrByValue, err := strconv.Atoi(updatedValueRaw)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "value must be a number, this means no floats."})
+	if _, err := claimPipe.Exec(ctx); err != nil && !errors.Is(err, redis.Nil) {
+		respondJSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to get data. Try again later."})
 		return
 	}
-	if incrByValue == 0 {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "changing value by 0 does nothing, please provide a non-zero value in the fmt of ?value=NEW_VALUE"})
-		return
+	for i, cmd := range claimCmds {
+		if cmd == nil {
+			continue
+		}
+		if result, _ := cmd.Slice(); len(result) > 1 && result[0].(int64) == utils.CreateFull {
+			results[i]["error"] = namespaceFullError(result[1])
+			amounts[i] = ""
+		}
 	}
-	namespace, key := utils.GetNamespaceKey(c)
-	if namespace == "" || key == "" {
-		return
+
+	pipe := Client.Pipeline()
+	hitCmds := make([]redis.Cmder, len(dbKeys))
+	for i, dbKey := range dbKeys {
+		if amounts[i] == "" {
+			continue
+		}
+		if meta := metas[i]; meta.UniqueWindow > 0 { // each entry counts the visitor making the batch once, like /hit
+			hitCmds[i] = utils.IncrVisit(ctx, pipe, dbKey, meta, amounts[i], visitorID(c, dbKey))
+		} else if meta.Bounded() {
+			hitCmds[i] = utils.IncrBounded(ctx, pipe, dbKey, meta, amounts[i], "")
+		} else if meta.IsFloat() {
+			hitCmds[i] = pipe.IncrByFloat(ctx, dbKey, steps[i])
+		} else {
+			intStep, _ := strconv.ParseInt(amounts[i], 10, 64) // checked by parseBatchStep
+			hitCmds[i] = pipe.IncrBy(ctx, dbKey, intStep)
+		}
 	}
-	dbKey := utils.CreateKey(c, namespace, key, false)
-	if dbKey == "" { // error is handled in CreateKey
+	if _, err := pipe.Exec(ctx); err != nil && !utils.IsOverflow(err) { // only the entries that overflowed fail then
+		respondJSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to get data. Try again later."})
 		return
 	}
 
-	exists := Client.Exists(context.Background(), dbKey).Val() == 0
-	if exists {
-		c.JSON(http.StatusConflict, gin.H{"error": "Key does not exist, please first create it using /create."})
-		return
+	// the hits are only recorded once they were made, see recordHit, and those made by a script (of bounded and unique
+	// counters) only recorded as updates once it's known that they were applied. Repeated visits aren't hits at all.
+	recordPipe := Client.Pipeline()
+	for i, cmd := range hitCmds {
+		if cmd == nil || cmd.Err() != nil || repeatedVisit(cmd) {
+			continue
+		}
+		refreshExpiry(recordPipe, dbKeys[i], metas[i])
+		utils.QueueHit(ctx, recordPipe, dbKeys[i])
+		if _, scripted := cmd.(*redis.Cmd); !scripted {
+			utils.QueueUpdated(ctx, recordPipe, dbKeys[i], metas[i])
+			utils.QueueHistory(ctx, recordPipe, dbKeys[i], metas[i], steps[i])
+		}
+	}
+	recordPipe.Exec(ctx)
+	for i, cmd := range hitCmds {
+		if cmd != nil && cmd.Err() == nil && !repeatedVisit(cmd) {
+			utils.Counters.Invalidate(dbKeys[i])
+		}
 	}
 
-	// Get data from Redis
-	val, err := Client.IncrByFloat(context.Background(), dbKey, float64(incrByValue)).Result()
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to set data. Try again later."})
-		return
+	for i, cmd := range hitCmds {
+		if cmd != nil && utils.IsOverflow(cmd.Err()) {
+			results[i]["error"] = overflowError
+			continue
+		}
+		namespace, key, _ := utils.SplitDBKey(dbKeys[i])
+		switch cmd := cmd.(type) {
+		case *redis.IntCmd:
+			results[i]["value"] = cmd.Val()
+			go utils.SetStream(dbKeys[i], int(cmd.Val()))
+			utils.Events.Emit(dbKeys[i], "hit", cmd.Val())
+			notifyThreshold(namespace, key, metas[i], cmd.Val(), steps[i])
+		case *redis.FloatCmd:
+			results[i]["value"] = cmd.Val()
+			utils.Events.Emit(dbKeys[i], "hit", cmd.Val())
+			notifyThreshold(namespace, key, metas[i], cmd.Val(), steps[i])
+		case *redis.Cmd: // counters with a max or min, and unique ones
+			result, _ := cmd.Slice()
+			value := parseCounterValue(fmt.Sprint(result[1]))
+			results[i]["value"] = value
+			switch result[0].(int64) {
+			case utils.IncrRepeated:
+				results[i]["counted"] = false
+				continue
+			case utils.IncrAboveMax:
+				results[i]["error"] = "Counter has reached its max value of " + strconv.FormatFloat(metas[i].Max, 'f', -1, 64)
+				continue
+			case utils.IncrBelowMin:
+				results[i]["error"] = "Counter has reached its min value of " + strconv.FormatFloat(metas[i].Min, 'f', -1, 64)
+				continue
+			case utils.IncrClamped:
+				results[i]["clamped"] = true
+			case utils.IncrDeleted:
+				results[i]["deleted"] = true
+				utils.Counters.Invalidate(dbKeys[i])
+				utils.Events.Emit(dbKeys[i], "delete", nil)
+				continue
+			}
+			current, _ := strconv.ParseFloat(fmt.Sprint(result[1]), 64)
+			previous, _ := strconv.ParseFloat(fmt.Sprint(result[2]), 64)
+			utils.SetUpdated(ctx, Client, dbKeys[i], metas[i])
+			utils.RecordHistory(ctx, Client, dbKeys[i], metas[i], current-previous) // clamped changes are smaller than the step
+			if intValue, ok := value.(int64); ok {
+				go utils.SetStream(dbKeys[i], int(intValue))
+			}
+			if metas[i].UniqueWindow > 0 {
+				results[i]["counted"] = true
+			}
+			utils.Events.Emit(dbKeys[i], "hit", value)
+			notifyThreshold(namespace, key, metas[i], value, steps[i])
+		}
 	}
+	respondJSON(c, http.StatusOK, results)
+}
 
-	c.JSON(http.StatusOK, gin.H{"value": int64(val)})
+// repeatedVisit reports whether cmd is a hit of /hit-batch to a unique counter that didn't count, as its visitor was
+// counted already.
+func repeatedVisit(cmd redis.Cmder) bool {
+	scripted, ok := cmd.(*redis.Cmd)
+	if !ok {
+		return false
+	}
+	result, _ := scripted.Slice()
+	return len(result) > 0 && result[0] == int64(utils.IncrRepeated)
+}
+
+// parseBatchStep parses the step of a /hit-batch entry by the type of its counter, returning it as given along with
+// its value.
+func parseBatchStep(raw json.Number, meta utils.Metadata) (string, float64, error) {
+	amount := raw.String()
+	if amount == "" {
+		return "1", 1, nil
+	}
+	step, err := strconv.ParseFloat(amount, 64)
+	if err != nil {
+		return "", 0, errors.New("step must be a number")
+	}
+	if _, err := strconv.ParseInt(amount, 10, 64); err != nil && !meta.IsFloat() {
+		return "", 0, errors.New("This is an integer counter, step must be an integer")
+	}
+	if step == 0 {
+		return "", 0, errors.New("changing value by 0 does nothing, please provide a non-zero step")
+	}
+	return amount, step, nil
+}
+
+type transactionOperation struct {
+	Op        string      `json:"op"`
+	Namespace string      `json:"namespace"`
+	Key       string      `json:"key"`
+	Step      json.Number `json:"step"`
+}
+
+// TransactionView applies a list of increments and decrements to counters atomically, either all of them are made or
+// none, e.g. to move an amount from one counter to another. Bounds are never clamped to in a transaction, a change
+// that would pass one fails the whole transaction instead.
+func TransactionView(c *gin.Context) {
+	var operations []transactionOperation
+	decoder := json.NewDecoder(c.Request.Body)
+	decoder.UseNumber() // keeps the steps exact until the counter type says how to parse them
+	if err := decoder.Decode(&operations); err != nil {
+		if middleware.BodyTooLarge(c, err) {
+			return
+		}
+		respondJSON(c, http.StatusBadRequest, gin.H{"error": "Invalid body, please provide a JSON array in the fmt of [{\"op\":\"incr\",\"namespace\":\"NAMESPACE\",\"key\":\"KEY\",\"step\":STEP}]"})
+		return
+	}
+	if len(operations) == 0 || len(operations) > utils.MaxBatchSize {
+		respondJSON(c, http.StatusBadRequest, gin.H{"error": "operations must contain between 1 and " + strconv.Itoa(utils.MaxBatchSize) + " entries"})
+		return
+	}
+	dbKeys := make([]string, len(operations))
+	for i, operation := range operations {
+		if operation.Op != "incr" && operation.Op != "decr" {
+			respondJSON(c, http.StatusBadRequest, gin.H{"error": "operations[" + strconv.Itoa(i) + "]: op must be either incr or decr"})
+			return
+		}
+		dbKey, err := utils.ValidateKey(operation.Namespace, operation.Key)
+		if err != nil {
+			respondJSON(c, http.StatusBadRequest, gin.H{"error": "operations[" + strconv.Itoa(i) + "]: " + err.Error()})
+			return
+		}
+		if operations[i].Namespace == "" {
+			operations[i].Namespace = "default"
+		}
+		// a cluster keeps each namespace in its own hash slot, and a script can only touch the keys of one slot
+		if RedisCluster && operations[i].Namespace != operations[0].Namespace {
+			respondJSON(c, http.StatusBadRequest, gin.H{"error": "operations[" + strconv.Itoa(i) + "]: On this server the operations of a transaction must all be in the same namespace"})
+			return
+		}
+		dbKeys[i] = dbKey
+	}
+
+	ctx := middleware.Context(c)
+	metaPipe := Client.Pipeline()
+	metaCmds := make([]*redis.MapStringStringCmd, len(dbKeys))
+	for i, dbKey := range dbKeys {
+		metaCmds[i] = metaPipe.HGetAll(ctx, utils.CreateMetaKey(dbKey))
+	}
+	if _, err := metaPipe.Exec(ctx); err != nil {
+		respondJSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to get data. Try again later."})
+		return
+	}
+	metas := make([]utils.Metadata, len(dbKeys))
+	steps := make([]float64, len(dbKeys)) // for the webhooks
+	args := make([]interface{}, 0, len(dbKeys)*utils.TransactionArgs)
+	for i, operation := range operations {
+		meta := utils.ParseMetadata(metaCmds[i].Val())
+		name := "operations[" + strconv.Itoa(i) + "].step"
+		if meta.Private {
+			allowed, err := middleware.CanModify(middleware.Context(c), Client, utils.GetAuthToken(c), operation.Namespace, operation.Key)
+			if err != nil {
+				respondJSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to get data. Try again later."})
+				return
+			} else if !allowed {
+				respondJSON(c, http.StatusUnauthorized, gin.H{"error": "operations[" + strconv.Itoa(i) + "]: This counter is private, please provide its admin token in the format of a Bearer token header or ?token=ADMIN_TOKEN"})
+				return
+			}
+		}
+		rawStep := operation.Step.String()
+		if rawStep == "" {
+			rawStep = "1"
+		}
+		var amount string
+		if meta.IsFloat() {
+			step, ok := parseFloatAmount(c, name, rawStep)
+			if !ok {
+				return
+			}
+			steps[i] = step
+			amount = strconv.FormatFloat(step, 'f', -1, 64)
+		} else {
+			step, ok := parseIntAmount(c, name, rawStep)
+			if !ok {
+				return
+			}
+			steps[i] = float64(step)
+			amount = strconv.FormatInt(step, 10)
+		}
+		if steps[i] <= 0 {
+			respondJSON(c, http.StatusBadRequest, gin.H{"error": name + " must be positive, op says whether it is added or subtracted"})
+			return
+		}
+		if operation.Op == "decr" {
+			steps[i], amount = -steps[i], "-"+amount
+		}
+		var maxValue, minValue string
+		if meta.HasMax {
+			maxValue = strconv.FormatFloat(meta.Max, 'f', -1, 64)
+		}
+		if meta.HasMin {
+			minValue = strconv.FormatFloat(meta.Min, 'f', -1, 64)
+		}
+		metas[i] = meta
+		args = append(args, amount, maxValue, minValue, meta.Type)
+	}
+
+	// counters the transaction is most likely about to create take a slot of their namespace first, see claimCounter.
+	// If a namespace is full, counters claimed in another one are left at 0, like those of hits that are refused.
+	claimPipe := Client.Pipeline()
+	claimCmds := make([]*redis.Cmd, len(dbKeys))
+	for i, dbKey := range dbKeys {
+		if metas[i].LastUpdated.IsZero() {
+			claimCmds[i] = utils.CreateCounter.Eval(ctx, claimPipe, []string{dbKey, utils.CreateNamespaceKey(operations[i].Namespace)},
+				0, int64(utils.BaseTTLPeriod.Seconds()), MaxCounters)
+		}
+	}
+	if _, err := claimPipe.Exec(ctx); err != nil && !errors.Is(err, redis.Nil) {
+		respondJSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to get data. Try again later."})
+		return
+	}
+	for i, cmd := range claimCmds {
+		if cmd == nil {
+			continue
+		}
+		if result, _ := cmd.Slice(); len(result) > 1 && result[0].(int64) == utils.CreateFull {
+			respondJSON(c, http.StatusConflict, gin.H{"error": "operations[" + strconv.Itoa(i) + "]: " + namespaceFullError(result[1]), "index": i})
+			return
+		}
+	}
+
+	result, err := utils.ApplyTransaction.Run(ctx, Client, dbKeys, args...).Slice()
+	if err != nil {
+		failWrite(c, err, "Failed to set data. Try again later.")
+		return
+	}
+	if status := result[0].(int64); status != utils.TransactionDone {
+		i := int(result[1].(int64)) - 1
+		prefix := "operations[" + strconv.Itoa(i) + "]: "
+		value := parseCounterValue(fmt.Sprint(result[2]))
+		switch status {
+		case utils.TransactionAboveMax:
+			respondJSON(c, http.StatusConflict, gin.H{"error": prefix + "Counter has reached its max value of " + strconv.FormatFloat(metas[i].Max, 'f', -1, 64), "index": i, "value": value})
+		case utils.TransactionBelowMin:
+			respondJSON(c, http.StatusConflict, gin.H{"error": prefix + "Counter has reached its min value of " + strconv.FormatFloat(metas[i].Min, 'f', -1, 64), "index": i, "value": value})
+		case utils.TransactionOverflow:
+			respondJSON(c, http.StatusConflict, gin.H{"error": prefix + overflowError, "index": i, "value": value})
+		default:
+			respondJSON(c, http.StatusConflict, gin.H{"error": prefix + "This is an integer counter holding a decimal value, it can't be changed by an integer step", "index": i, "value": value})
+		}
+		return
+	}
+
+	pipe := Client.Pipeline()
+	for i, dbKey := range dbKeys {
+		refreshExpiry(pipe, dbKey, metas[i])
+		utils.QueueUpdated(ctx, pipe, dbKey, metas[i])
+		utils.QueueHistory(ctx, pipe, dbKey, metas[i], steps[i])
+	}
+	pipe.Exec(ctx) // the values are already changed, a failure here only leaves expiries and timestamps behind
+	for _, dbKey := range dbKeys {
+		utils.Counters.Invalidate(dbKey)
+	}
+
+	values := result[1].([]interface{})
+	results := make([]gin.H, len(operations))
+	for i, operation := range operations {
+		value := parseCounterValue(fmt.Sprint(values[i]))
+		results[i] = gin.H{"namespace": operation.Namespace, "key": operation.Key, "value": value}
+		if intValue, ok := value.(int64); ok {
+			go utils.SetStream(dbKeys[i], int(intValue))
+		}
+		utils.Events.Emit(dbKeys[i], operation.Op, value)
+		notifyThreshold(operation.Namespace, operation.Key, metas[i], value, steps[i])
+	}
+	respondJSON(c, http.StatusOK, results)
+}
+
+// ReserveView advances a counter by ?count= in a single increment, returning the range of values it skipped over as
+// if it had been hit count times. Concurrent reservations never overlap.
+func ReserveView(c *gin.Context) {
+	namespace, key := utils.GetNamespaceKey(c)
+	if namespace == "" || key == "" {
+		return
+	}
+	dbKey := utils.CreateKey(c, namespace, key, false)
+	if dbKey == "" { // error is handled in CreateKey
+		return
+	}
+	count, ok := parseIntAmount(c, "count", c.DefaultQuery("count", "1"))
+	if !ok {
+		return
+	}
+	if count <= 0 {
+		respondJSON(c, http.StatusBadRequest, gin.H{"error": "count must be a positive integer"})
+		return
+	}
+	meta, err := utils.GetMetadata(middleware.Context(c), Client, dbKey)
+	if err != nil {
+		respondJSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to get data. Try again later."})
+		return
+	}
+	if !authorizeRead(c, meta) {
+		return
+	}
+	if meta.IsFloat() {
+		respondJSON(c, http.StatusConflict, gin.H{"error": "Ranges can only be reserved on integer counters"})
+		return
+	}
+	// reserving on a counter that most likely doesn't exist yet creates it, taking a slot of its namespace like a hit
+	if meta.LastUpdated.IsZero() && !claimCounter(c, namespace, dbKey) {
+		return
+	}
+
+	var end int64
+	if meta.Bounded() {
+		result, err := incrementBounded(middleware.Context(c), Client.TxPipeline(), dbKey, meta, strconv.FormatInt(count, 10), "")
+		if err != nil {
+			failWrite(c, err, "Failed to get data. Try again later.")
+			return
+		}
+		pipe := Client.Pipeline()
+		refreshExpiry(pipe, dbKey, meta)
+		pipe.Exec(middleware.Context(c))
+		if rejectBounded(c, meta, result) {
+			return
+		}
+		end, _ = result.Value.(int64)
+	} else {
+		var err error
+		if end, err = Client.IncrBy(middleware.Context(c), dbKey, count).Result(); err != nil {
+			failWrite(c, err, "Failed to get data. Try again later.")
+			return
+		}
+		pipe := Client.Pipeline()
+		refreshExpiry(pipe, dbKey, meta)
+		utils.QueueUpdated(middleware.Context(c), pipe, dbKey, meta)
+		utils.QueueHistory(middleware.Context(c), pipe, dbKey, meta, float64(count))
+		pipe.Exec(middleware.Context(c))
+		utils.Counters.Invalidate(dbKey)
+	}
+	go utils.SetStream(dbKey, int(end))
+	utils.Events.Emit(dbKey, "reserve", end)
+	notifyThreshold(namespace, key, meta, end, float64(count))
+	respondJSON(c, http.StatusOK, gin.H{"start": end - count + 1, "end": end})
+}
+
+func GetView(c *gin.Context) {
+	namespace, key := utils.GetNamespaceKey(c)
+	if namespace == "" || key == "" {
+		return
+	}
+	dbKey := utils.CreateKey(c, namespace, key, false)
+	if dbKey == "" { // error is handled in CreateKey
+		return
+	}
+	if c.Query("shards") == "true" {
+		shardedGet(c, dbKey)
+		return
+	}
+	value, meta, cached := utils.Counters.Get(dbKey) // only enabled by COUNTER_CACHE_SIZE
+	var err error
+	if !cached {
+		if meta, err = utils.GetMetadata(middleware.Context(c), readClient(), dbKey); err != nil {
+			respondJSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to get data. Try again later."})
+			return
+		}
+	}
+	if !authorizeShared(c, meta) {
+		return
+	}
+	if !parseBase(c, meta) {
+		return
+	}
+	if !cached {
+		// Get data from Redis
+		value, err = readCounter(middleware.Context(c), dbKey)
+		if errors.Is(err, redis.Nil) {
+			respondJSON(c, http.StatusNotFound, gin.H{"error": "Key not found"})
+			return
+		} else if err != nil { // Other Redis errors
+			respondJSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to get data. Try again later."})
+			return
+		}
+		utils.Counters.Set(dbKey, value, meta)
+	}
+
+	etag := counterETag(value, meta)
+	c.Header("ETag", etag)
+	if !meta.LastUpdated.IsZero() {
+		c.Header("Last-Modified", meta.LastUpdated.UTC().Format(http.TimeFormat))
+	}
+	if notModified(c, meta, etag) {
+		c.Status(http.StatusNotModified)
+		return
+	}
+	respondValue(c, value)
+}
+
+// ExistsView responds with whether a counter exists, which is cheaper than reading it and never creates it.
+// Private counters only tell those who may read them.
+func ExistsView(c *gin.Context) {
+	namespace, key := utils.GetNamespaceKey(c)
+	if namespace == "" || key == "" {
+		return
+	}
+	dbKey := utils.CreateKey(c, namespace, key, false)
+	if dbKey == "" { // error is handled in CreateKey
+		return
+	}
+	meta, err := utils.GetMetadata(middleware.Context(c), readClient(), dbKey)
+	if err != nil {
+		respondJSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to get data. Try again later."})
+		return
+	}
+	if !authorizeRead(c, meta) {
+		return
+	}
+	exists, err := readClient().Exists(middleware.Context(c), dbKey).Result()
+	if err != nil {
+		respondJSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to get data. Try again later."})
+		return
+	}
+	respondJSON(c, http.StatusOK, gin.H{"exists": exists == 1})
+}
+
+// shardedGet responds with the sum of the counter dbKey across this shard and the ones in REDIS_SHARDS, for counters
+// whose writes are spread over shards. Shards that don't have the counter count as 0, and if one of them can't be
+// read the request fails rather than returning a partial total.
+func shardedGet(c *gin.Context, dbKey string) {
+	meta, err := utils.GetMetadata(middleware.Context(c), readClient(), dbKey)
+	if err != nil {
+		respondJSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to get data. Try again later."})
+		return
+	}
+	if !authorizeShared(c, meta) {
+		return
+	}
+	clients := []redis.UniversalClient{readClient()}
+	for _, shard := range ShardClients {
+		clients = append(clients, shard)
+	}
+	values := make([]string, len(clients))
+	errs := make([]error, len(clients))
+	var wg sync.WaitGroup
+	for i, client := range clients {
+		wg.Add(1)
+		go func(i int, client redis.UniversalClient) {
+			defer wg.Done()
+			values[i], errs[i] = client.Get(middleware.Context(c), dbKey).Result()
+		}(i, client)
+	}
+	wg.Wait()
+
+	var intTotal int64
+	var floatTotal float64
+	isFloat, found := false, 0
+	for i, err := range errs {
+		if errors.Is(err, redis.Nil) {
+			continue
+		} else if err != nil {
+			respondJSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to get data from every shard. Try again later."})
+			return
+		}
+		found++
+		switch value := parseCounterValue(values[i]).(type) {
+		case int64:
+			intTotal += value
+		case float64:
+			floatTotal, isFloat = floatTotal+value, true
+		}
+	}
+	if found == 0 {
+		respondJSON(c, http.StatusNotFound, gin.H{"error": "Key not found"})
+		return
+	}
+	var total interface{} = intTotal
+	if isFloat {
+		total = floatTotal + float64(intTotal)
+	}
+	respondBody(c, total, gin.H{"value": total, "shards": found})
+}
+
+// counterETag identifies the current state of a counter. It's weak, as the same state is sent in several formats
+// and possibly compressed.
+func counterETag(value interface{}, meta utils.Metadata) string {
+	sum := sha256.Sum256([]byte(fmt.Sprint(value) + ":" + strconv.FormatInt(meta.LastUpdated.UnixMilli(), 10)))
+	return `W/"` + hex.EncodeToString(sum[:8]) + `"`
+}
+
+// notModified reports whether the request is conditional on a state of the counter it still has. If-None-Match
+// takes precedence over If-Modified-Since, which can only be told for counters that were written since their last
+// update started being tracked. HTTP dates only have a resolution of seconds, so the last update is compared by its
+// second, as Last-Modified reports it: a change later in the same second as the given date can't be told from it and
+// isn't one, clients that mustn't miss those have to use the ETag.
+func notModified(c *gin.Context, meta utils.Metadata, etag string) bool {
+	if header := c.GetHeader("If-None-Match"); header != "" {
+		for _, candidate := range strings.Split(header, ",") {
+			candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+			if candidate == "*" || candidate == strings.TrimPrefix(etag, "W/") {
+				return true
+			}
+		}
+		return false
+	}
+	since, err := http.ParseTime(c.GetHeader("If-Modified-Since"))
+	return err == nil && !meta.LastUpdated.IsZero() && !meta.LastUpdated.Truncate(time.Second).After(since)
+}
+
+func BadgeView(c *gin.Context) {
+	namespace, key := utils.GetNamespaceKey(c)
+	if namespace == "" || key == "" {
+		return
+	}
+	dbKey := utils.CreateKey(c, namespace, key, false)
+	if dbKey == "" { // error is handled in CreateKey
+		return
+	}
+	style := c.DefaultQuery("style", utils.BadgeStyleFlat)
+	if style != utils.BadgeStyleFlat && style != utils.BadgeStylePlastic {
+		respondJSON(c, http.StatusBadRequest, gin.H{"error": "style must be either " + utils.BadgeStyleFlat + " or " + utils.BadgeStylePlastic})
+		return
+	}
+	color, err := utils.BadgeColor(c.DefaultQuery("color", "blue"))
+	if err != nil {
+		respondJSON(c, http.StatusBadRequest, gin.H{"error": "Invalid color: " + err.Error()})
+		return
+	}
+	label := c.DefaultQuery("label", key)
+	if len(label) > utils.MaxLength {
+		label = label[:utils.MaxLength]
+	}
+	meta, err := utils.GetMetadata(middleware.Context(c), Client, dbKey)
+	if err != nil {
+		respondJSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to get data. Try again later."})
+		return
+	}
+	if !authorizeRead(c, meta) {
+		return
+	}
+
+	value, err := readCounter(middleware.Context(c), dbKey)
+	valueText := fmt.Sprint(value)
+	if errors.Is(err, redis.Nil) {
+		// still render a badge so the image doesn't show up as broken
+		valueText = "not found"
+		color, _ = utils.BadgeColor("lightgrey")
+	} else if err != nil {
+		respondJSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to get data. Try again later."})
+		return
+	}
+
+	if meta.Private {
+		c.Header("Cache-Control", "private, max-age=60") // shared caches mustn't hand it to others
+	} else {
+		c.Header("Cache-Control", "max-age=60")
+	}
+	c.Data(http.StatusOK, "image/svg+xml", []byte(utils.RenderBadge(label, valueText, color, style)))
+}
+
+func CreateRandomView(c *gin.Context) {
+	key, _ := utils.GenerateRandomString(16)
+	namespace, err := utils.GenerateRandomString(16)
+	if err != nil {
+		respondJSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to generate random string. Try again later."})
+		return
+	}
+
+	c.Params = gin.Params{gin.Param{Key: "namespace", Value: namespace}, gin.Param{Key: "key", Value: key}}
+	CreateView(c)
+}
+func CreateView(c *gin.Context) {
+	namespace, key := utils.GetNamespaceKey(c)
+	if namespace == "" || key == "" {
+		return
+	}
+	dbKey := utils.CreateKey(c, namespace, key, false)
+	if dbKey == "" { // error is handled in CreateKey
+		return
+	}
+	overwrite, err := strconv.ParseBool(c.DefaultQuery("overwrite", "false"))
+	if err != nil {
+		respondJSON(c, http.StatusBadRequest, gin.H{"error": "overwrite must be either true or false"})
+		return
+	}
+	meta, initialValue, ttl, ok := parseCreateOptions(c)
+	if !ok {
+		return
+	}
+	// Get data from Redis
+	result, err := utils.CreateCounter.Run(middleware.Context(c), Client, []string{dbKey, utils.CreateNamespaceKey(namespace)},
+		initialValue, int64(ttl.Seconds()), MaxCounters).Slice()
+	if err != nil {
+		respondJSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to create key. Try again later."})
+		return
+	}
+	switch result[0].(int64) {
+	case utils.CreateFull:
+		respondJSON(c, http.StatusConflict, gin.H{"error": namespaceFullError(result[1])})
+		return
+	case utils.CreateExists:
+		if overwrite {
+			overwriteCounter(c, dbKey, meta, initialValue, ttl)
+			return
+		}
+		var existing interface{} // null if the key expired in between, or if it is private
+		existingMeta, err := utils.GetMetadata(middleware.Context(c), Client, dbKey)
+		if raw, getErr := Client.Get(middleware.Context(c), dbKey).Result(); getErr == nil && err == nil && !existingMeta.Private {
+			existing = parseCounterValue(raw)
+		}
+		respondJSON(c, http.StatusConflict, gin.H{"error": "Key already exists, please use a different key.", "created": false, "key": key, "namespace": namespace, "value": existing})
+		return
+	}
+	AdminKey := finishCreate(c, namespace, dbKey, meta, initialValue, ttl)
+	if AdminKey == "" {
+		return
+	}
+	// the admin key is only ever handed out here, it can't be read back later
+	respondJSON(c, http.StatusCreated, gin.H{"key": key, "namespace": namespace, "admin_key": AdminKey, "admin_url": adminURL(c, namespace, key, AdminKey),
+		"value": initialValue, "created": true})
+}
+
+// upsertContextKey marks a hit made by /upsert on a counter that existed already, whose response says it wasn't created.
+const upsertContextKey = "upsert"
+
+// UpsertView changes a counter by ?step= like /hit if it exists, and creates it with the settings /create takes
+// otherwise. A created counter starts at ?initializer= plus the step, which is set in the same atomic step as creating
+// it, so concurrent upserts neither create it twice nor lose a hit.
+func UpsertView(c *gin.Context) {
+	namespace, key := utils.GetNamespaceKey(c)
+	if namespace == "" || key == "" {
+		return
+	}
+	dbKey := utils.CreateKey(c, namespace, key, false)
+	if dbKey == "" { // error is handled in CreateKey
+		return
+	}
+	meta, initialValue, ttl, ok := parseCreateOptions(c)
+	if !ok {
+		return
+	}
+	rawStep := c.DefaultQuery("step", "1")
+	step, ok := parseFloatAmount(c, "step", rawStep)
+	if !ok {
+		return
+	}
+	var value interface{}
+	if meta.IsFloat() {
+		sum := initialValue.(float64) + step
+		if math.IsInf(sum, 0) {
+			respondJSON(c, http.StatusConflict, gin.H{"error": overflowError})
+			return
+		}
+		value = sum
+	} else if intStep, err := strconv.ParseInt(rawStep, 10, 64); err == nil {
+		sum, ok := utils.AddInt64(int64(initialValue.(int)), intStep)
+		if !ok {
+			respondJSON(c, http.StatusConflict, gin.H{"error": overflowError})
+			return
+		}
+		value = int(sum)
+	} else { // only an existing float counter can take a decimal step, /hit tells the others why not
+		c.Set(upsertContextKey, true)
+		hit(c, false)
+		return
+	}
+	if step == 0 {
+		respondJSON(c, http.StatusBadRequest, gin.H{"error": "changing value by 0 does nothing, please provide a non-zero value in the fmt of ?step=STEP"})
+		return
+	}
+	created, _ := strconv.ParseFloat(fmt.Sprint(value), 64)
+	if meta.HasMax && created > meta.Max {
+		respondJSON(c, http.StatusBadRequest, gin.H{"error": "initializer plus step can't be larger than max"})
+		return
+	}
+	if meta.HasMin && created < meta.Min {
+		respondJSON(c, http.StatusBadRequest, gin.H{"error": "initializer plus step can't be smaller than min"})
+		return
+	}
+
+	ctx := middleware.Context(c)
+	result, err := utils.CreateCounter.Run(ctx, Client, []string{dbKey, utils.CreateNamespaceKey(namespace)},
+		value, int64(ttl.Seconds()), MaxCounters).Slice()
+	if err != nil {
+		respondJSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to create key. Try again later."})
+		return
+	}
+	switch result[0].(int64) {
+	case utils.CreateFull:
+		respondJSON(c, http.StatusConflict, gin.H{"error": namespaceFullError(result[1])})
+		return
+	case utils.CreateExists: // its own settings apply, not the ones given
+		c.Set(upsertContextKey, true)
+		hit(c, false)
+		return
+	}
+	AdminKey := finishCreate(c, namespace, dbKey, meta, value, ttl)
+	if AdminKey == "" {
+		return
+	}
+	recordVisitor(c, dbKey, meta) // the creating hit counts its visitor like any other
+	utils.RecordHistory(ctx, Client, dbKey, meta, step)
+	respondJSON(c, http.StatusCreated, gin.H{"key": key, "namespace": namespace, "admin_key": AdminKey, "admin_url": adminURL(c, namespace, key, AdminKey),
+		"value": value, "created": true})
+}
+
+// finishCreate stores the settings of the counter dbKey that CreateCounter just created with value and returns its new
+// admin key. If that fails, it deletes the counter again, responds with a 500 and returns "".
+func finishCreate(c *gin.Context, namespace, dbKey string, meta utils.Metadata, value interface{}, ttl time.Duration) string {
+	AdminKey := uuid.New().String() // Create a new admin key used for deletion and control
+	if err := utils.SetMetadata(middleware.Context(c), Client, dbKey, meta, ttl); err != nil ||
+		Client.Set(middleware.Context(c), utils.CreateAdminKey(dbKey), AdminKey, 0).Err() != nil { // todo: figure out how to handle admin keys (handle alongside admin orrrrrrr separately as in a routine once a month that deletes all admin keys with no corresponding key)
+		// don't leave a counter of the wrong type, or one nobody can manage, behind, which also frees its slot
+		utils.DeleteCounter.Run(middleware.Context(c), Client, utils.DeleteCounterKeys(namespace, dbKey))
+		respondJSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to create key. Try again later."})
+		return ""
+	}
+	utils.SetUpdated(middleware.Context(c), Client, dbKey, meta)
+	if intValue, ok := value.(int); ok {
+		utils.SetStream(dbKey, intValue)
+	}
+	utils.Events.Emit(dbKey, "create", value)
+	return AdminKey
+}
+
+// namespaceFullError is the error of creating a counter in a namespace that holds limit counters already.
+func namespaceFullError(limit interface{}) string {
+	return "Namespace is full, it can hold at most " + fmt.Sprint(limit) + " counters. Delete some or use a different namespace."
+}
+
+// claimCounter creates the counter dbKey at 0 for a hit that is about to create it, counting it in its namespace the
+// way /create does so hits can't fill a namespace past its limit either. It is a no-op for counters that exist
+// already. It responds and reports false if the namespace is full or redis fails.
+func claimCounter(c *gin.Context, namespace, dbKey string) bool {
+	result, err := utils.CreateCounter.Run(middleware.Context(c), Client, []string{dbKey, utils.CreateNamespaceKey(namespace)},
+		0, int64(utils.BaseTTLPeriod.Seconds()), MaxCounters).Slice()
+	if err != nil {
+		respondJSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to get data. Try again later."})
+		return false
+	}
+	if result[0].(int64) == utils.CreateFull {
+		respondJSON(c, http.StatusConflict, gin.H{"error": namespaceFullError(result[1])})
+		return false
+	}
+	return true
+}
+
+// parseCreateOptions parses the settings of a counter created by /create or /upsert along with its initial value and
+// ttl. It responds with a 400 and reports false if any of them is invalid.
+func parseCreateOptions(c *gin.Context) (utils.Metadata, interface{}, time.Duration, bool) {
+	meta := utils.Metadata{Type: c.DefaultQuery("type", utils.IntCounter)}
+	if meta.Type != utils.IntCounter && meta.Type != utils.FloatCounter {
+		respondJSON(c, http.StatusBadRequest, gin.H{"error": "type must be either " + utils.IntCounter + " or " + utils.FloatCounter})
+		return meta, nil, 0, false
+	}
+	rawInitial := c.DefaultQuery("initializer", "0")
+	if initial, ok := c.GetQuery("initial"); ok {
+		if _, both := c.GetQuery("initializer"); both {
+			respondJSON(c, http.StatusBadRequest, gin.H{"error": "initial and initializer are the same, please only provide one of them"})
+			return meta, nil, 0, false
+		}
+		rawInitial = initial
+	}
+	var initialValue interface{}
+	if meta.IsFloat() {
+		floatValue, err := strconv.ParseFloat(rawInitial, 64)
+		if err != nil {
+			respondJSON(c, http.StatusBadRequest, gin.H{"error": "initializer must be a number"})
+			return meta, nil, 0, false
+		}
+		initialValue = floatValue
+	} else {
+		intValue, err := strconv.Atoi(rawInitial)
+		if err != nil {
+			respondJSON(c, http.StatusBadRequest, gin.H{"error": "initializer must be a number"})
+			return meta, nil, 0, false
+		}
+		initialValue = intValue
+	}
+	ttl := utils.BaseTTLPeriod
+	if rawTTL, ok := c.GetQuery("ttl"); ok {
+		seconds, err := strconv.ParseInt(rawTTL, 10, 64)
+		if err != nil || seconds < 0 {
+			respondJSON(c, http.StatusBadRequest, gin.H{"error": "ttl must be a positive number of seconds, or 0 for no expiry"})
+			return meta, nil, 0, false
+		}
+		if time.Duration(seconds)*time.Second > MaxTTL {
+			respondJSON(c, http.StatusBadRequest, gin.H{"error": "ttl is too large. Max ttl is " + strconv.FormatInt(int64(MaxTTL.Seconds()), 10) + " seconds"})
+			return meta, nil, 0, false
+		}
+		ttl = time.Duration(seconds) * time.Second // a ttl of 0 means the key never expires
+		meta.TTL, meta.CustomTTL = ttl, true
+	}
+	if rawRefresh, ok := c.GetQuery("refresh_ttl"); ok {
+		refresh, err := strconv.ParseBool(rawRefresh)
+		if err != nil {
+			respondJSON(c, http.StatusBadRequest, gin.H{"error": "refresh_ttl must be either true or false"})
+			return meta, nil, 0, false
+		}
+		meta.RefreshTTL = refresh
+	}
+	initial, _ := strconv.ParseFloat(fmt.Sprint(initialValue), 64)
+	if rawMax, ok := c.GetQuery("max"); ok {
+		if meta.Max, ok = parseBound(c, meta, "max", rawMax); !ok {
+			return meta, nil, 0, false
+		}
+		if initial > meta.Max {
+			respondJSON(c, http.StatusBadRequest, gin.H{"error": "initializer can't be larger than max"})
+			return meta, nil, 0, false
+		}
+		meta.HasMax = true
+	}
+	if rawMin, ok := c.GetQuery("min"); ok {
+		if meta.Min, ok = parseBound(c, meta, "min", rawMin); !ok {
+			return meta, nil, 0, false
+		}
+		if initial < meta.Min {
+			respondJSON(c, http.StatusBadRequest, gin.H{"error": "initializer can't be smaller than min"})
+			return meta, nil, 0, false
+		}
+		meta.HasMin = true
+	}
+	switch c.DefaultQuery("min_mode", "clamp") {
+	case "clamp":
+	case "reject":
+		meta.RejectBelowMin = true
+	default:
+		respondJSON(c, http.StatusBadRequest, gin.H{"error": "min_mode must be either clamp or reject"})
+		return meta, nil, 0, false
+	}
+	switch c.DefaultQuery("visibility", "public") {
+	case "public":
+	case "private":
+		meta.Private = true
+	default:
+		respondJSON(c, http.StatusBadRequest, gin.H{"error": "visibility must be either public or private"})
+		return meta, nil, 0, false
+	}
+	if rawHistory, ok := c.GetQuery("history"); ok {
+		history, err := strconv.ParseBool(rawHistory)
+		if err != nil {
+			respondJSON(c, http.StatusBadRequest, gin.H{"error": "history must be either true or false"})
+			return meta, nil, 0, false
+		}
+		meta.History = history
+	}
+	if rawUnique, ok := c.GetQuery("unique"); ok {
+		unique, err := strconv.ParseBool(rawUnique)
+		if err != nil {
+			respondJSON(c, http.StatusBadRequest, gin.H{"error": "unique must be either true or false"})
+			return meta, nil, 0, false
+		}
+		if unique {
+			meta.UniqueWindow = utils.DefaultUniqueWindow
+		}
+	}
+	if rawWindow, ok := c.GetQuery("unique_window"); ok {
+		seconds, err := strconv.ParseInt(rawWindow, 10, 64)
+		if err != nil || seconds <= 0 {
+			respondJSON(c, http.StatusBadRequest, gin.H{"error": "unique_window must be a positive number of seconds"})
+			return meta, nil, 0, false
+		}
+		if time.Duration(seconds)*time.Second > MaxTTL {
+			respondJSON(c, http.StatusBadRequest, gin.H{"error": "unique_window is too large. Max unique_window is " + strconv.FormatInt(int64(MaxTTL.Seconds()), 10) + " seconds"})
+			return meta, nil, 0, false
+		}
+		meta.UniqueWindow = time.Duration(seconds) * time.Second // implies ?unique=true
+	}
+	if rawDelete, ok := c.GetQuery("delete_at_zero"); ok {
+		deleteAtZero, err := strconv.ParseBool(rawDelete)
+		if err != nil {
+			respondJSON(c, http.StatusBadRequest, gin.H{"error": "delete_at_zero must be either true or false"})
+			return meta, nil, 0, false
+		}
+		meta.DeleteAtZero = deleteAtZero
+	}
+	if rawTags := c.QueryArray("tag"); len(rawTags) > 0 {
+		tags, err := utils.ParseTags(rawTags)
+		if err != nil {
+			respondJSON(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+			return meta, nil, 0, false
+		}
+		meta.Tags = tags
+	}
+	if err := utils.ValidateDescription(c.Query("description")); err != nil {
+		respondJSON(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+		return meta, nil, 0, false
+	}
+	meta.Description = c.Query("description")
+	meta.CreatedAt = time.Now()
+	if CreatorIPSalt != "" {
+		meta.CreatorIP = utils.HashIP(CreatorIPSalt, c.ClientIP())
+	}
+	return meta, initialValue, ttl, true
+}
+
+// adminURL returns a link to the /info of a counter that carries its admin key, for its creator to bookmark. It
+// starts with PUBLIC_URL if configured, and with the address the request was made to otherwise.
+func adminURL(c *gin.Context, namespace, key, adminKey string) string {
+	return baseURL(c) + "/info/" + namespace + "/" + key + "?" + url.Values{"token": {adminKey}}.Encode()
+}
+
+// baseURL is the URL the server is reached at, PublicURL if it is set and the host of the request otherwise. Behind
+// a trusted proxy, which terminates TLS for the server, the scheme is taken from its X-Forwarded-Proto.
+func baseURL(c *gin.Context) string {
+	if PublicURL != "" {
+		return PublicURL
+	}
+	scheme := "http"
+	if c.Request.TLS != nil {
+		scheme = "https"
+	}
+	if fromTrustedProxy(c) {
+		forwarded, _, _ := strings.Cut(c.GetHeader("X-Forwarded-Proto"), ",")
+		if forwarded = strings.ToLower(strings.TrimSpace(forwarded)); forwarded == "http" || forwarded == "https" {
+			scheme = forwarded
+		}
+	}
+	return scheme + "://" + c.Request.Host
+}
+
+// fromTrustedProxy reports whether the request was made by one of TrustedProxies, whose X-Forwarded headers can be
+// believed.
+func fromTrustedProxy(c *gin.Context) bool {
+	remote, err := netip.ParseAddr(c.RemoteIP())
+	if err != nil {
+		return false
+	}
+	remote = remote.Unmap()
+	for _, proxy := range TrustedProxies {
+		if prefix, err := netip.ParsePrefix(proxy); err == nil {
+			if prefix.Contains(remote) {
+				return true
+			}
+		} else if addr, err := netip.ParseAddr(proxy); err == nil && addr.Unmap() == remote {
+			return true
+		}
+	}
+	return false
+}
+
+// overwriteCounter recreates the existing counter dbKey with a new value and settings for /create?overwrite=true,
+// which requires a token that may modify it. The admin key stays the same.
+func overwriteCounter(c *gin.Context, dbKey string, meta utils.Metadata, value interface{}, ttl time.Duration) {
+	namespace, key := utils.ResolveNamespaceKey(c)
+	allowed, err := middleware.CanModify(middleware.Context(c), Client, utils.GetAuthToken(c), namespace, key)
+	if err != nil {
+		respondJSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to get data. Try again later."})
+		return
+	} else if !allowed {
+		respondJSON(c, http.StatusUnauthorized, gin.H{"error": "Key already exists, overwriting it requires its admin key or the namespace admin key"})
+		return
+	}
+	ctx := middleware.Context(c)
+	pipe := Client.TxPipeline()
+	pipe.Set(ctx, dbKey, value, ttl)
+	pipe.Del(ctx, utils.CreateMetaKey(dbKey), utils.CreateHistoryKey(dbKey), utils.CreateVisitorsKey(dbKey))
+	utils.QueueMetadata(ctx, pipe, dbKey, meta, ttl)
+	utils.QueueUpdated(ctx, pipe, dbKey, meta)
+	_, err = pipe.Exec(ctx)
+	utils.Counters.Invalidate(dbKey)
+	if err != nil {
+		respondJSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to set data. Try again later."})
+		return
+	}
+	if intValue, ok := value.(int); ok {
+		go utils.SetStream(dbKey, intValue)
+	}
+	utils.Events.Emit(dbKey, "create", value)
+	respondJSON(c, http.StatusOK, gin.H{"key": key, "namespace": namespace, "value": value, "created": false, "overwritten": true})
+}
+
+func InfoView(c *gin.Context) { // todo: write docs on what negative values mean (https://redis.io/commands/ttl/)
+	namespace, key := utils.GetNamespaceKey(c)
+	if namespace == "" || key == "" {
+		return
+	}
+	dbKey := utils.CreateKey(c, namespace, key, true)
+	if dbKey == "" { // error is handled in CreateKey
+		return
+	}
+	dbValue := readClient().Get(middleware.Context(c), dbKey).Val()
+	count := parseCounterValue(dbValue)
+
+	meta, err := utils.GetMetadata(middleware.Context(c), readClient(), dbKey)
+	if err != nil {
+		respondJSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to get data. Try again later."})
+		return
+	}
+	if !authorizeShared(c, meta) {
+		return
+	}
+	isGenuine := readClient().Exists(middleware.Context(c), utils.CreateAdminKey(dbKey)).Val() == 0
+	expiresAt := readClient().TTL(middleware.Context(c), dbKey).Val()
+	ratePipe := readClient().Pipeline()
+	rate := utils.QueueHitRate(middleware.Context(c), ratePipe, dbKey)
+	ratePipe.Exec(middleware.Context(c)) // missing buckets fail with redis.Nil, they had no hits
+	body := infoBody(dbKey, count, meta, isGenuine, expiresAt)
+	body["hits_last_minute"] = rate.LastMinute()
+	if meta.CreatorIP != "" { // only shown to those who may modify the counter, as it links the counters of a creator
+		namespace, key := utils.ResolveNamespaceKey(c)
+		if allowed, err := middleware.CanModify(middleware.Context(c), Client, utils.GetAuthToken(c), namespace, key); err == nil && allowed {
+			body["created_ip"] = meta.CreatorIP
+		}
+	}
+	c.Header("Vary", "Accept")
+	if c.NegotiateFormat(gin.MIMEJSON, binding.MIMEPROTOBUF) == binding.MIMEPROTOBUF {
+		c.ProtoBuf(http.StatusOK, infoMessage(body["value"], meta, body))
+		return
+	}
+	respondJSON(c, http.StatusOK, body)
+}
+
+// infoBody builds the JSON body /info describes the counter dbKey with, given its value, metadata, whether it has no
+// admin key and its ttl in redis (-2 if it doesn't exist).
+func infoBody(dbKey string, count interface{}, meta utils.Metadata, isGenuine bool, expiresAt time.Duration) gin.H {
+	exists := expiresAt != -2
+	if !exists {
+		count = -1
+	}
+	var maxValue, minValue interface{} // null if the counter has no max/min
+	if meta.HasMax {
+		maxValue = meta.Max
+	}
+	if meta.HasMin {
+		minValue = meta.Min
+	}
+	visibility := "public"
+	if meta.Private {
+		visibility = "private"
+	}
+	var createdAt, lastUpdated interface{} // null for counters that predate tracking them
+	if !meta.CreatedAt.IsZero() {
+		createdAt = meta.CreatedAt.UnixMilli()
+	}
+	if !meta.LastUpdated.IsZero() {
+		lastUpdated = meta.LastUpdated.UnixMilli()
+	}
+	tags := meta.Tags
+	if tags == nil {
+		tags = map[string]string{}
+	}
+	return gin.H{"value": count, "type": meta.Type, "tags": tags, "created_at": createdAt, "last_updated": lastUpdated, "ttl": meta.TTL.Seconds(), "refresh_ttl": meta.Refreshes(), "max": maxValue, "min": minValue, "visibility": visibility, "description": meta.Description, "full_key": dbKey, "is_genuine": isGenuine, "expires_in": expiresAt.Seconds(), "expires_str": expiresAt.String(), "exists": exists, "history": meta.History, "delete_at_zero": meta.DeleteAtZero, "unique_window": meta.UniqueWindow.Seconds()}
+}
+
+type infoBatchRequest struct {
+	Keys []struct {
+		Namespace string `json:"namespace"`
+		Key       string `json:"key"`
+	} `json:"keys"`
+}
+
+// InfoBatchView returns what /info does for several counters in a single pipeline. Like in /hit-batch every entry is
+// independent, invalid and private ones get an error in their result and missing ones are flagged with "exists": false.
+func InfoBatchView(c *gin.Context) {
+	var request infoBatchRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		if middleware.BodyTooLarge(c, err) {
+			return
+		}
+		respondJSON(c, http.StatusBadRequest, gin.H{"error": "Invalid body, please provide a JSON body in the fmt of {\"keys\":[{\"namespace\":\"NAMESPACE\",\"key\":\"KEY\"}]}"})
+		return
+	}
+	if len(request.Keys) == 0 || len(request.Keys) > utils.MaxBatchSize {
+		respondJSON(c, http.StatusBadRequest, gin.H{"error": "keys must contain between 1 and " + strconv.Itoa(utils.MaxBatchSize) + " entries"})
+		return
+	}
+
+	ctx := middleware.Context(c)
+	pipe := readClient().Pipeline()
+	results := make([]gin.H, len(request.Keys))
+	dbKeys := make([]string, len(request.Keys)) // empty for the entries that were rejected
+	valueCmds := make([]*redis.StringCmd, len(request.Keys))
+	metaCmds := make([]*redis.MapStringStringCmd, len(request.Keys))
+	adminCmds := make([]*redis.IntCmd, len(request.Keys))
+	ttlCmds := make([]*redis.DurationCmd, len(request.Keys))
+	rates := make([]utils.HitRate, len(request.Keys))
+	for i, entry := range request.Keys {
+		namespace := entry.Namespace
+		if namespace == "" {
+			namespace = "default"
+		}
+		results[i] = gin.H{"namespace": namespace, "key": entry.Key}
+		dbKey, err := utils.ValidateKey(entry.Namespace, entry.Key)
+		if err != nil {
+			results[i]["error"] = err.Error()
+			continue
+		}
+		dbKeys[i] = dbKey
+		valueCmds[i] = pipe.Get(ctx, dbKey)
+		metaCmds[i] = pipe.HGetAll(ctx, utils.CreateMetaKey(dbKey))
+		adminCmds[i] = pipe.Exists(ctx, utils.CreateAdminKey(dbKey))
+		ttlCmds[i] = pipe.TTL(ctx, dbKey)
+		rates[i] = utils.QueueHitRate(ctx, pipe, dbKey)
+	}
+	if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil { // missing counters are flagged below
+		respondJSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to get data. Try again later."})
+		return
+	}
+
+	for i, dbKey := range dbKeys {
+		if dbKey == "" {
+			continue
+		}
+		meta := utils.ParseMetadata(metaCmds[i].Val())
+		if meta.Private { // a batch carries no tokens, so private counters can't be read
+			results[i]["error"] = "Counter is private"
+			continue
+		}
+		body := infoBody(dbKey, parseCounterValue(valueCmds[i].Val()), meta, adminCmds[i].Val() == 0, ttlCmds[i].Val())
+		body["hits_last_minute"] = rates[i].LastMinute()
+		for field, value := range results[i] {
+			body[field] = value
+		}
+		results[i] = body
+	}
+	respondJSON(c, http.StatusOK, results)
+}
+
+// infoMessage converts the JSON body of /info into its protobuf message.
+func infoMessage(value interface{}, meta utils.Metadata, body gin.H) *pb.CounterInfo {
+	expiresIn := body["expires_in"].(float64)
+	message := &pb.CounterInfo{
+		Type:       meta.Type,
+		Tags:       meta.Tags,
+		Ttl:        int64(meta.TTL.Seconds()),
+		RefreshTtl: meta.Refreshes(),
+		Visibility: body["visibility"].(string),
+		FullKey:    body["full_key"].(string),
+		IsGenuine:  body["is_genuine"].(bool),
+		ExpiresIn:  int64(expiresIn),
+		Exists:     body["exists"].(bool),
+	}
+	if intValue, ok := value.(int64); ok {
+		message.Value = &pb.CounterInfo_IntValue{IntValue: intValue}
+	} else if floatValue, ok := value.(float64); ok {
+		message.Value = &pb.CounterInfo_FloatValue{FloatValue: floatValue}
+	} else { // -1 for counters that don't exist
+		message.Value = &pb.CounterInfo_IntValue{IntValue: -1}
+	}
+	if !meta.CreatedAt.IsZero() {
+		createdAt := meta.CreatedAt.UnixMilli()
+		message.CreatedAt = &createdAt
+	}
+	if !meta.LastUpdated.IsZero() {
+		lastUpdated := meta.LastUpdated.UnixMilli()
+		message.LastUpdated = &lastUpdated
+	}
+	if meta.HasMax {
+		message.Max = &meta.Max
+	}
+	if meta.HasMin {
+		message.Min = &meta.Min
+	}
+	message.CreatedIp, _ = body["created_ip"].(string)
+	return message
+}
+
+// TakeSnapshotView freezes the current value of a counter into the snapshot ?name=, which never changes afterwards,
+// e.g. to report on the value a counter had at the end of a period while it keeps counting.
+func TakeSnapshotView(c *gin.Context) {
+	namespace, key := utils.GetNamespaceKey(c)
+	if namespace == "" || key == "" {
+		return
+	}
+	dbKey := utils.CreateKey(c, namespace, key, false)
+	if dbKey == "" { // error is handled in CreateKey
+		return
+	}
+	name, ok := c.GetQuery("name")
+	if !ok {
+		respondJSON(c, http.StatusBadRequest, gin.H{"error": "name is required, please provide the name of the snapshot in the fmt of ?name=NAME"})
+		return
+	}
+	if err := utils.ValidateSnapshotName(name); err != nil {
+		respondJSON(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	takenAt := time.Now()
+	result, err := utils.TakeSnapshot.Run(middleware.Context(c), Client, []string{dbKey, utils.CreateSnapshotsKey(dbKey)},
+		name, takenAt.UnixMilli(), utils.MaxSnapshots).Slice()
+	if err != nil {
+		respondJSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to get data. Try again later."})
+		return
+	}
+	switch result[0].(int64) {
+	case utils.SnapshotMissing:
+		respondJSON(c, http.StatusNotFound, gin.H{"error": "Key not found"})
+	case utils.SnapshotExists:
+		respondJSON(c, http.StatusConflict, gin.H{"error": "A snapshot named " + name + " was taken already, snapshots never change once taken."})
+	case utils.SnapshotFull:
+		respondJSON(c, http.StatusConflict, gin.H{"error": "This counter has reached its max of " + strconv.Itoa(utils.MaxSnapshots) + " snapshots."})
+	default:
+		respondJSON(c, http.StatusCreated, snapshotBody(utils.Snapshot{Name: name, Value: fmt.Sprint(result[1]), TakenAt: takenAt}))
+	}
+}
+
+// SnapshotView returns the snapshot ?name= of a counter, or all of its snapshots (oldest first) without a name.
+func SnapshotView(c *gin.Context) {
+	namespace, key := utils.GetNamespaceKey(c)
+	if namespace == "" || key == "" {
+		return
+	}
+	dbKey := utils.CreateKey(c, namespace, key, false)
+	if dbKey == "" { // error is handled in CreateKey
+		return
+	}
+	ctx := middleware.Context(c)
+	meta, err := utils.GetMetadata(ctx, readClient(), dbKey)
+	if err != nil {
+		respondJSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to get data. Try again later."})
+		return
+	}
+	if !authorizeRead(c, meta) {
+		return
+	}
+	snapshots, err := utils.GetSnapshots(ctx, readClient(), dbKey)
+	if err != nil {
+		respondJSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to get data. Try again later."})
+		return
+	}
+	name, ok := c.GetQuery("name")
+	if !ok {
+		bodies := make([]gin.H, len(snapshots))
+		for i, snapshot := range snapshots {
+			bodies[i] = snapshotBody(snapshot)
+		}
+		respondJSON(c, http.StatusOK, gin.H{"namespace": namespace, "key": key, "snapshots": bodies})
+		return
+	}
+	for _, snapshot := range snapshots {
+		if snapshot.Name == name {
+			respondJSON(c, http.StatusOK, snapshotBody(snapshot))
+			return
+		}
+	}
+	respondJSON(c, http.StatusNotFound, gin.H{"error": "Snapshot not found"})
+}
+
+func snapshotBody(snapshot utils.Snapshot) gin.H {
+	return gin.H{"name": snapshot.Name, "value": parseCounterValue(snapshot.Value), "taken_at": snapshot.TakenAt.UnixMilli()}
+}
+
+// HistoryView sums up the changes of a counter created with ?history=true into buckets of ?bucket= (1h by default),
+// from ?from= until ?to= (the last 24 hours by default), given as unix seconds or RFC3339 times. Only increments and
+// decrements are recorded, along with the time they were made at.
+func HistoryView(c *gin.Context) {
+	namespace, key := utils.GetNamespaceKey(c)
+	if namespace == "" || key == "" {
+		return
+	}
+	dbKey := utils.CreateKey(c, namespace, key, false)
+	if dbKey == "" { // error is handled in CreateKey
+		return
+	}
+	to, ok := parseHistoryTime(c, "to", time.Now())
+	if !ok {
+		return
+	}
+	from, ok := parseHistoryTime(c, "from", to.Add(-24*time.Hour))
+	if !ok {
+		return
+	}
+	bucket, err := time.ParseDuration(c.DefaultQuery("bucket", "1h"))
+	if err != nil || bucket < time.Second {
+		respondJSON(c, http.StatusBadRequest, gin.H{"error": "bucket must be a duration of at least 1s, such as 5m or 1h"})
+		return
+	}
+	if !from.Before(to) {
+		respondJSON(c, http.StatusBadRequest, gin.H{"error": "from must be before to"})
+		return
+	}
+	if to.Sub(from) > bucket*utils.MaxHistoryBuckets {
+		respondJSON(c, http.StatusBadRequest, gin.H{"error": "Too many buckets, at most " + strconv.Itoa(utils.MaxHistoryBuckets) + " can be returned. Use a larger bucket or a shorter range."})
+		return
+	}
+
+	ctx := middleware.Context(c)
+	meta, err := utils.GetMetadata(ctx, readClient(), dbKey)
+	if err != nil {
+		respondJSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to get data. Try again later."})
+		return
+	}
+	if !authorizeRead(c, meta) {
+		return
+	}
+	exists, err := readClient().Exists(ctx, dbKey).Result()
+	if err != nil {
+		respondJSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to get data. Try again later."})
+		return
+	} else if exists == 0 {
+		respondJSON(c, http.StatusNotFound, gin.H{"error": "Key not found"})
+		return
+	}
+	if !meta.History {
+		respondJSON(c, http.StatusConflict, gin.H{"error": "This counter doesn't record its history, create it with ?history=true to do so."})
+		return
+	}
+	sums, err := utils.ReadHistory(ctx, readClient(), dbKey, from, to, bucket)
+	if err != nil {
+		respondJSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to get data. Try again later."})
+		return
+	}
+	buckets := make([]gin.H, len(sums))
+	for i, sum := range sums {
+		var delta interface{} = sum
+		if !meta.IsFloat() {
+			delta = int64(sum)
+		}
+		start := from.Add(time.Duration(i) * bucket)
+		buckets[i] = gin.H{"time": start.Unix(), "date": utils.FormatTime(start), "delta": delta}
+	}
+	respondJSON(c, http.StatusOK, gin.H{
+		"namespace": namespace,
+		"key":       key,
+		"from":      from.Unix(),
+		"to":        to.Unix(),
+		"bucket":    bucket.Seconds(),
+		"timezone":  utils.Location.String(),
+		"buckets":   buckets,
+	})
+}
+
+// parseHistoryTime parses the time given as unix seconds, RFC3339 or a local date by the query parameter name, which defaults to
+// fallback. It responds with a 400 and reports false if the time is malformed.
+func parseHistoryTime(c *gin.Context, name string, fallback time.Time) (time.Time, bool) {
+	raw, ok := c.GetQuery(name)
+	if !ok {
+		return fallback, true
+	}
+	if seconds, err := strconv.ParseInt(raw, 10, 64); err == nil {
+		return time.Unix(seconds, 0), true
+	}
+	parsed, err := utils.ParseTime(raw)
+	if err != nil {
+		respondJSON(c, http.StatusBadRequest, gin.H{"error": name + " must be a time in unix seconds, RFC3339 or a date in the server's timezone, such as 2024-01-02T15:04:05Z or 2024-01-02"})
+		return time.Time{}, false
+	}
+	return parsed, true
+}
+
+func ListView(c *gin.Context) {
+	namespace := c.Param("namespace")
+	pattern, err := utils.CreateListPattern(namespace, c.Query("prefix"))
+	if err != nil {
+		respondJSON(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	cursor, err := strconv.ParseUint(c.DefaultQuery("cursor", "0"), 10, 64)
+	if err != nil {
+		respondJSON(c, http.StatusBadRequest, gin.H{"error": "cursor must be the cursor returned by the previous page"})
+		return
+	}
+	tags, err := utils.ParseTags(c.QueryArray("tag")) // only counters carrying all of them are listed
+	if err != nil {
+		respondJSON(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// SCAN instead of KEYS so large namespaces don't block the server, a page may contain less than ListPageSize keys
+	ctx := middleware.Context(c)
+	node, err := utils.NamespaceNode(ctx, readClient(), namespace)
+	if err != nil {
+		respondJSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to get data. Try again later."})
+		return
+	}
+	dbKeys, nextCursor, err := node.Scan(ctx, cursor, pattern, utils.ListPageSize).Result()
+	if err != nil {
+		respondJSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to get data. Try again later."})
+		return
+	}
+	counters := make([]gin.H, 0, len(dbKeys))
+	if len(dbKeys) > 0 {
+		values, err := readClient().MGet(ctx, dbKeys...).Result()
+		if err != nil {
+			respondJSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to get data. Try again later."})
+			return
+		}
+		private, err := utils.PrivateCounters(ctx, readClient(), dbKeys)
+		if err != nil {
+			respondJSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to get data. Try again later."})
+			return
+		}
+		descriptions, err := utils.Descriptions(ctx, readClient(), dbKeys)
+		if err != nil {
+			respondJSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to get data. Try again later."})
+			return
+		}
+		matches := make([]bool, len(dbKeys))
+		if len(tags) > 0 {
+			if matches, err = utils.MatchTags(ctx, readClient(), dbKeys, tags); err != nil {
+				respondJSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to get data. Try again later."})
+				return
+			}
+		}
+		for i, value := range values {
+			raw, ok := value.(string)
+			if !ok || private[i] { // expired since the scan, or not for everyone to see
+				continue
+			}
+			if len(tags) > 0 && !matches[i] {
+				continue
+			}
+			key := strings.TrimPrefix(dbKeys[i], utils.BuildDBKey(namespace, ""))
+			counter := gin.H{"key": key, "value": parseCounterValue(raw)}
+			if descriptions[i] != "" {
+				counter["description"] = descriptions[i]
+			}
+			counters = append(counters, counter)
+		}
+	}
+	// a cursor of 0 means there are no more pages
+	respondJSON(c, http.StatusOK, gin.H{"namespace": namespace, "keys": counters, "cursor": strconv.FormatUint(nextCursor, 10)})
+}
+
+// SumView adds up the counters of a namespace, optionally only those whose keys start with ?prefix=. The total stays
+// an exact integer unless the namespace contains float counters.
+func SumView(c *gin.Context) {
+	namespace := c.Param("namespace")
+	pattern, err := utils.CreateListPattern(namespace, c.Query("prefix"))
+	if err != nil {
+		respondJSON(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx := middleware.Context(c)
+	node, err := utils.NamespaceNode(ctx, Client, namespace)
+	if err != nil {
+		respondJSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to get data. Try again later."})
+		return
+	}
+	var intTotal int64
+	var floatTotal float64
+	hasFloats := false
+	count := 0
+	var cursor uint64
+	for {
+		// the pattern only matches counter values (K:), never the metadata and admin keys stored alongside them
+		dbKeys, next, err := node.Scan(ctx, cursor, pattern, utils.ListPageSize).Result()
+		if err != nil {
+			respondJSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to get data. Try again later."})
+			return
+		}
+		if len(dbKeys) > 0 {
+			values, err := Client.MGet(ctx, dbKeys...).Result()
+			if err != nil {
+				respondJSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to get data. Try again later."})
+				return
+			}
+			private, err := utils.PrivateCounters(ctx, Client, dbKeys)
+			if err != nil {
+				respondJSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to get data. Try again later."})
+				return
+			}
+			for i, value := range values {
+				raw, ok := value.(string)
+				if !ok || private[i] { // expired since the scan, or not for everyone to see
+					continue
+				}
+				if intValue, err := strconv.ParseInt(raw, 10, 64); err == nil {
+					intTotal += intValue
+				} else if floatValue, err := strconv.ParseFloat(raw, 64); err == nil {
+					floatTotal += floatValue
+					hasFloats = true
+				} else {
+					continue
+				}
+				count++
+			}
+		}
+		if next == 0 {
+			break
+		}
+		cursor = next
+	}
+	var total interface{} = intTotal
+	if hasFloats {
+		total = float64(intTotal) + floatTotal
+	}
+	respondJSON(c, http.StatusOK, gin.H{"namespace": namespace, "total": total, "count": count})
+}
+
+type exportEntry struct {
+	Key      string            `json:"key"`
+	Value    interface{}       `json:"value"`
+	TTL      int64             `json:"ttl"`      // in seconds, -1 if the counter never expires
+	Metadata map[string]string `json:"metadata"` // the M: hash as stored, see DB.md
+}
+
+// ExportView streams every counter of a namespace as a JSON array. The namespace is scanned a page at a time and
+// each page is flushed right away, so large namespaces are never held in memory as a whole.
+func ExportView(c *gin.Context) {
+	namespace := c.Param("namespace")
+	pattern, err := utils.CreateListPattern(namespace, "")
+	if err != nil {
+		respondJSON(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx := middleware.Context(c)
+	node, err := utils.NamespaceNode(ctx, Client, namespace)
+	if err != nil {
+		respondJSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to get data. Try again later."})
+		return
+	}
+	c.Header("Content-Type", "application/json; charset=utf-8")
+	c.Header("Content-Disposition", `attachment; filename="`+namespace+`.json"`)
+	c.Status(http.StatusOK)
+	// once streaming has started the status can't change anymore, so errors leave the array unterminated instead,
+	// which makes sure a broken export can't be mistaken for a complete one
+	if _, err := c.Writer.WriteString("["); err != nil {
+		return
+	}
+	first := true
+	var cursor uint64
+	for {
+		dbKeys, next, err := node.Scan(ctx, cursor, pattern, utils.ListPageSize).Result()
+		if err != nil {
+			middleware.Log(c).Error("Error exporting namespace", "namespace", namespace, "error", err)
+			return
+		}
+		pipe := Client.Pipeline()
+		valueCmds := make([]*redis.StringCmd, len(dbKeys))
+		ttlCmds := make([]*redis.DurationCmd, len(dbKeys))
+		metaCmds := make([]*redis.MapStringStringCmd, len(dbKeys))
+		for i, dbKey := range dbKeys {
+			valueCmds[i] = pipe.Get(ctx, dbKey)
+			ttlCmds[i] = pipe.TTL(ctx, dbKey)
+			metaCmds[i] = pipe.HGetAll(ctx, utils.CreateMetaKey(dbKey))
+		}
+		if _, err := pipe.Exec(ctx); err != nil && !errors.Is(err, redis.Nil) {
+			middleware.Log(c).Error("Error exporting namespace", "namespace", namespace, "error", err)
+			return
+		}
+		for i, dbKey := range dbKeys {
+			if valueCmds[i].Err() != nil { // expired since the scan
+				continue
+			}
+			ttl := int64(-1)
+			if ttlCmds[i].Val() > 0 {
+				ttl = int64(ttlCmds[i].Val().Seconds())
+			}
+			entry, err := json.Marshal(exportEntry{
+				Key:      strings.TrimPrefix(dbKey, utils.BuildDBKey(namespace, "")),
+				Value:    parseCounterValue(valueCmds[i].Val()),
+				TTL:      ttl,
+				Metadata: metaCmds[i].Val(),
+			})
+			if err != nil {
+				middleware.Log(c).Error("Error exporting namespace", "namespace", namespace, "error", err)
+				return
+			}
+			if !first {
+				entry = append([]byte(","), entry...)
+			}
+			if _, err := c.Writer.Write(entry); err != nil {
+				return // client went away
+			}
+			first = false
+		}
+		c.Writer.Flush()
+		if next == 0 {
+			break
+		}
+		cursor = next
+	}
+	c.Writer.WriteString("]")
+}
+
+type importCounter struct {
+	dbKey string
+	value string
+	ttl   time.Duration
+	meta  utils.Metadata
+}
+
+// ImportView restores counters from the JSON array produced by ExportView. Every entry is validated before anything
+// is written, so a malformed dump is rejected as a whole. With ?overwrite=false, counters that already exist are
+// skipped instead of replaced.
+func ImportView(c *gin.Context) {
+	namespace := c.Param("namespace")
+	overwrite, err := strconv.ParseBool(c.DefaultQuery("overwrite", "true"))
+	if err != nil {
+		respondJSON(c, http.StatusBadRequest, gin.H{"error": "overwrite must be either true or false"})
+		return
+	}
+	var entries []exportEntry
+	decoder := json.NewDecoder(c.Request.Body)
+	decoder.UseNumber() // keeps integers exact, they'd lose precision past 2^53 as float64
+	if err := decoder.Decode(&entries); err != nil {
+		if middleware.BodyTooLarge(c, err) {
+			return
+		}
+		respondJSON(c, http.StatusBadRequest, gin.H{"error": "Invalid body, please provide the JSON array returned by /export/:namespace"})
+		return
+	}
+	counters := make([]importCounter, len(entries))
+	for i, entry := range entries {
+		counter, err := parseImportEntry(namespace, entry)
+		if err != nil {
+			respondJSON(c, http.StatusBadRequest, gin.H{"error": "entries[" + strconv.Itoa(i) + "]: " + err.Error()})
+			return
+		}
+		counters[i] = counter
+	}
+
+	ctx := middleware.Context(c)
+	pipe := Client.Pipeline()
+	setCmds := make([]*redis.StatusCmd, len(counters))
+	for i, counter := range counters {
+		args := redis.SetArgs{TTL: counter.ttl}
+		if !overwrite {
+			args.Mode = "NX"
+		}
+		setCmds[i] = pipe.SetArgs(ctx, counter.dbKey, counter.value, args)
+	}
+	pipe.Exec(ctx) // errors are checked per counter below
+
+	// only the metadata of counters that were actually written may be replaced
+	imported, skipped := 0, 0
+	importErrors := make([]gin.H, 0)
+	metaPipe := Client.Pipeline()
+	for i, cmd := range setCmds {
+		if errors.Is(cmd.Err(), redis.Nil) { // NX and the counter exists
+			skipped++
+			continue
+		} else if cmd.Err() != nil {
+			importErrors = append(importErrors, gin.H{"key": entries[i].Key, "error": "Failed to set data."})
+			continue
+		}
+		imported++
+		utils.Events.Emit(counters[i].dbKey, "import", counters[i].value)
+		metaPipe.Del(ctx, utils.CreateMetaKey(counters[i].dbKey))
+		utils.QueueMetadata(ctx, metaPipe, counters[i].dbKey, counters[i].meta, counters[i].ttl)
+		utils.QueueUpdated(ctx, metaPipe, counters[i].dbKey, counters[i].meta)
+	}
+	if imported > 0 {
+		_, err := metaPipe.Exec(ctx)
+		for i, cmd := range setCmds {
+			if cmd.Err() == nil {
+				utils.Counters.Invalidate(counters[i].dbKey)
+			}
+		}
+		if err != nil {
+			respondJSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to set data. Try again later."})
+			return
+		}
+	}
+	respondJSON(c, http.StatusOK, gin.H{"imported": imported, "skipped": skipped, "errors": importErrors})
+}
+
+// ResetAllView resets every counter of a namespace to 0, or to ?value=, keeping their keys, settings and expiries, e.g.
+// for monthly metrics. Counters whose bounds don't allow the value are skipped. As this can't be undone,
+// ?confirm=true is required.
+func ResetAllView(c *gin.Context) {
+	namespace := c.Param("namespace")
+	if c.Query("confirm") != "true" {
+		respondJSON(c, http.StatusBadRequest, gin.H{"error": "Resetting overwrites every counter of the namespace for good, " +
+			"pass ?confirm=true to go ahead"})
+		return
+	}
+	value, err := strconv.ParseInt(c.DefaultQuery("value", "0"), 10, 64) // integers fit int and float counters alike
+	if err != nil {
+		respondJSON(c, http.StatusBadRequest, gin.H{"error": "value must be an integer, this means no floats."})
+		return
+	}
+	pattern, err := utils.CreateListPattern(namespace, "")
+	if err != nil {
+		respondJSON(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx := middleware.Context(c)
+	node, err := utils.NamespaceNode(ctx, Client, namespace)
+	if err != nil {
+		respondJSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to get data. Try again later."})
+		return
+	}
+	var reset, skipped int64
+	var cursor uint64
+	for {
+		dbKeys, next, err := node.Scan(ctx, cursor, pattern, utils.ListPageSize).Result()
+		if err != nil {
+			middleware.Log(c).Error("Error resetting namespace", "namespace", namespace, "reset", reset, "error", err)
+			respondJSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to reset the namespace, some counters " +
+				"may be left, please retry"})
+			return
+		}
+		pipe := Client.Pipeline()
+		metaCmds := make([]*redis.MapStringStringCmd, len(dbKeys))
+		for i, dbKey := range dbKeys {
+			metaCmds[i] = pipe.HGetAll(ctx, utils.CreateMetaKey(dbKey))
+		}
+		if _, err := pipe.Exec(ctx); err != nil && !errors.Is(err, redis.Nil) {
+			middleware.Log(c).Error("Error resetting namespace", "namespace", namespace, "reset", reset, "error", err)
+			respondJSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to reset the namespace, some counters " +
+				"may be left, please retry"})
+			return
+		}
+		pipe = Client.Pipeline()
+		setCmds := make([]*redis.BoolCmd, len(dbKeys))
+		for i, dbKey := range dbKeys {
+			meta := utils.ParseMetadata(metaCmds[i].Val())
+			if (meta.HasMax && float64(value) > meta.Max) || (meta.HasMin && float64(value) < meta.Min) {
+				skipped++
+				continue
+			}
+			setCmds[i] = pipe.SetXX(ctx, dbKey, value, overwriteTTL(meta))
+			utils.QueueUpdated(ctx, pipe, dbKey, meta)
+		}
+		_, err = pipe.Exec(ctx)
+		for i, cmd := range setCmds {
+			if cmd != nil {
+				utils.Counters.Invalidate(dbKeys[i])
+			}
+		}
+		if err != nil && !errors.Is(err, redis.Nil) {
+			middleware.Log(c).Error("Error resetting namespace", "namespace", namespace, "reset", reset, "error", err)
+			respondJSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to reset the namespace, some counters " +
+				"may be left, please retry"})
+			return
+		}
+		for i, cmd := range setCmds {
+			if cmd != nil && cmd.Val() { // false if it expired since the scan
+				reset++
+				go utils.SetStream(dbKeys[i], int(value))
+				utils.Events.Emit(dbKeys[i], "reset", value)
+			}
+		}
+		if next == 0 {
+			break
+		}
+		cursor = next
+	}
+	respondJSON(c, http.StatusOK, gin.H{"namespace": namespace, "reset": reset, "skipped": skipped})
+}
+
+// SigningSecretView sets up a new secret for signing the admin requests of a namespace, which requires them to be
+// signed from then on, or stops requiring it when called with DELETE.
+func SigningSecretView(c *gin.Context) {
+	namespace := c.Param("namespace")
+	var secret string
+	if c.Request.Method != http.MethodDelete {
+		raw := make([]byte, 32)
+		if _, err := rand.Read(raw); err != nil {
+			respondJSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to generate a secret. Try again later."})
+			return
+		}
+		secret = hex.EncodeToString(raw)
+	}
+	if err := utils.SetSigningSecret(middleware.Context(c), Client, namespace, secret); err != nil {
+		respondJSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to get data. Try again later."})
+		return
+	}
+	if secret == "" {
+		respondJSON(c, http.StatusOK, gin.H{"namespace": namespace, "signed": false})
+		return
+	}
+	respondJSON(c, http.StatusCreated, gin.H{"namespace": namespace, "signed": true, "signing_secret": secret})
+}
+
+// ShareView mints a share token that lets anyone holding it read a (private) counter for ?ttl= seconds (an hour by
+// default), without being able to change it. The tokens of a namespace are revoked all at once by RevokeShareView.
+func ShareView(c *gin.Context) {
+	namespace, key := utils.GetNamespaceKey(c)
+	if namespace == "" || key == "" {
+		return
+	}
+	dbKey := utils.CreateKey(c, namespace, key, true)
+	if dbKey == "" { // error is handled in CreateKey
+		return
+	}
+	ttl := utils.DefaultShareTTL
+	if rawTTL, ok := c.GetQuery("ttl"); ok {
+		seconds, err := strconv.ParseInt(rawTTL, 10, 64)
+		if err != nil || seconds < 1 || time.Duration(seconds)*time.Second > utils.MaxShareTTL {
+			respondJSON(c, http.StatusBadRequest, gin.H{"error": "ttl must be a number of seconds between 1 and " + strconv.Itoa(int(utils.MaxShareTTL.Seconds()))})
+			return
+		}
+		ttl = time.Duration(seconds) * time.Second
+	}
+	ctx := middleware.Context(c)
+	exists, err := Client.Exists(ctx, dbKey).Result()
+	if err != nil {
+		respondJSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to get data. Try again later."})
+		return
+	} else if exists == 0 {
+		respondJSON(c, http.StatusNotFound, gin.H{"error": "Key not found"})
+		return
+	}
+	secret, err := utils.GetShareSecret(ctx, Client, namespace)
+	if err != nil {
+		respondJSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to get data. Try again later."})
+		return
+	}
+	expires := time.Now().Add(ttl)
+	token := utils.SignShareToken(secret, dbKey, expires)
+	respondJSON(c, http.StatusCreated, gin.H{"namespace": namespace, "key": key, "token": token, "expires_at": expires.Unix(),
+		"url": baseURL(c) + "/get/" + namespace + "/" + key + "?" + url.Values{"token": {token}}.Encode()})
+}
+
+// RevokeShareView revokes every share token of a namespace by rotating the secret they are signed with.
+func RevokeShareView(c *gin.Context) {
+	namespace := c.Param("namespace")
+	if err := utils.RevokeShareTokens(middleware.Context(c), Client, namespace); err != nil {
+		respondJSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to get data. Try again later."})
+		return
+	}
+	respondJSON(c, http.StatusOK, gin.H{"namespace": namespace, "revoked": true})
+}
+
+// PurgeView deletes every counter of a namespace at once. As this can't be undone, ?confirm=true is required.
+func PurgeView(c *gin.Context) {
+	namespace := c.Param("namespace")
+	if c.Query("confirm") != "true" {
+		respondJSON(c, http.StatusBadRequest, gin.H{"error": "Purging deletes every counter of the namespace for good, " +
+			"pass ?confirm=true to go ahead"})
+		return
+	}
+	deleted, err := utils.PurgeNamespace(middleware.Context(c), Client, namespace)
+	if err != nil {
+		middleware.Log(c).Error("Error purging namespace", "namespace", namespace, "deleted", deleted, "error", err)
+		respondJSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to purge the namespace, some counters " +
+			"may be left, please retry"})
+		return
+	}
+	respondJSON(c, http.StatusOK, gin.H{"namespace": namespace, "deleted": deleted})
+}
+
+// parseImportEntry validates an entry of an import, converting it into what needs to be written.
+func parseImportEntry(namespace string, entry exportEntry) (importCounter, error) {
+	dbKey, err := utils.ValidateKey(namespace, entry.Key)
+	if err != nil {
+		return importCounter{}, err
+	}
+	meta := utils.ParseMetadata(entry.Metadata)
+	if meta.Type != utils.IntCounter && meta.Type != utils.FloatCounter {
+		return importCounter{}, errors.New("invalid metadata: type must be either int or float")
+	}
+	if meta.WebhookURL != "" {
+		if err := utils.ValidateWebhookURL(meta.WebhookURL); err != nil {
+			return importCounter{}, fmt.Errorf("invalid metadata: %w", err)
+		}
+	}
+	if err := utils.ValidateTags(meta.Tags); err != nil {
+		return importCounter{}, fmt.Errorf("invalid metadata: %w", err)
+	}
+	if err := utils.ValidateDescription(meta.Description); err != nil {
+		return importCounter{}, fmt.Errorf("invalid metadata: %w", err)
+	}
+
+	number, ok := entry.Value.(json.Number)
+	if !ok {
+		return importCounter{}, errors.New("value must be a number")
+	}
+	value := number.String()
+	if meta.IsFloat() {
+		floatValue, err := number.Float64()
+		if err != nil {
+			return importCounter{}, errors.New("value must be a number")
+		}
+		value = strconv.FormatFloat(floatValue, 'f', -1, 64)
+	} else if _, err := number.Int64(); err != nil {
+		return importCounter{}, errors.New("value must be an integer, this is an integer counter")
+	}
+
+	var ttl time.Duration // never expires
+	if entry.TTL > 0 {
+		ttl = time.Duration(entry.TTL) * time.Second
+	} else if entry.TTL != -1 {
+		return importCounter{}, errors.New("ttl must be a number of seconds, or -1 if the counter never expires")
+	}
+	return importCounter{dbKey: dbKey, value: value, ttl: ttl, meta: meta}, nil
+}
+
+// NamespaceTokenView claims a namespace by creating its admin token, or rotates the token if the namespace was already
+// claimed, in which case the current token is required.
+func NamespaceTokenView(c *gin.Context) {
+	namespace := c.Param("namespace")
+	if err := utils.ValidateNamespace(namespace); err != nil {
+		respondJSON(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	ctx := middleware.Context(c)
+	claimed, err := utils.HasNamespaceToken(ctx, Client, namespace)
+	if err != nil {
+		respondJSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to get data. Try again later."})
+		return
+	}
+	newToken := uuid.New().String()
+
+	if claimed {
+		valid, err := utils.CheckNamespaceToken(ctx, Client, namespace, utils.GetAuthToken(c))
+		if err != nil {
+			respondJSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to get data. Try again later."})
+			return
+		} else if !valid {
+			respondJSON(c, http.StatusUnauthorized, gin.H{"error": "token is invalid, rotating the namespace token requires the current one"})
+			return
+		}
+		if err := utils.SetNamespaceToken(ctx, Client, namespace, newToken); err != nil {
+			respondJSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to set data. Try again later."})
+			return
+		}
+		respondJSON(c, http.StatusOK, gin.H{"namespace": namespace, "admin_key": newToken})
+		return
+	}
+
+	// only empty namespaces can be claimed, otherwise anyone could take over the counters of others
+	if namespace == "default" {
+		respondJSON(c, http.StatusConflict, gin.H{"error": "The default namespace can't be claimed."})
+		return
+	}
+	hasCounters, err := utils.NamespaceHasCounters(ctx, Client, namespace)
+	if err != nil {
+		respondJSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to get data. Try again later."})
+		return
+	}
+	if hasCounters {
+		respondJSON(c, http.StatusConflict, gin.H{"error": "Namespace already has counters, only empty namespaces can be claimed."})
+		return
+	}
+	if ok, err := utils.ClaimNamespaceToken(ctx, Client, namespace, newToken); err != nil {
+		respondJSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to set data. Try again later."})
+		return
+	} else if !ok {
+		respondJSON(c, http.StatusConflict, gin.H{"error": "Namespace was claimed in the meantime."})
+		return
+	}
+	respondJSON(c, http.StatusCreated, gin.H{"namespace": namespace, "admin_key": newToken})
+}
+
+func DeleteView(c *gin.Context) {
+	namespace, key := utils.GetNamespaceKey(c)
+	if namespace == "" || key == "" {
+		return
+	}
+	dbKey := utils.CreateKey(c, namespace, key, true)
+	if dbKey == "" { // error is handled in CreateKey
+		return
+	}
+	// the admin key and metadata are useless without the counter, so they go along with it
+	if err := utils.DeleteCounter.Run(middleware.Context(c), Client, utils.DeleteCounterKeys(namespace, dbKey)).Err(); err != nil {
+		respondJSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to delete key. Try again later."})
+		return
+	}
+	utils.Counters.Invalidate(dbKey)
+	respondJSON(c, http.StatusOK, gin.H{"status": "ok", "message": "Deleted key: " + dbKey})
+	utils.CloseStream(dbKey)
+	utils.Events.Emit(dbKey, "delete", nil)
+}
+
+// parseSetValue parses the ?value= a counter is set to, reporting false if it is missing or not an integer, with the
+// error already written to the response.
+func parseSetValue(c *gin.Context) (int, bool) {
+	updatedValueRaw, _ := c.GetQuery("value")
+	if updatedValueRaw == "" {
+		respondJSON(c, http.StatusBadRequest, gin.H{"error": "value is required, please provide a number in the fmt of ?value=NEW_VALUE"})
+		return 0, false
+	}
+	updatedValue, err := strconv.Atoi(updatedValueRaw)
+	if errors.Is(err, strconv.ErrRange) {
+		respondJSON(c, http.StatusBadRequest, gin.H{"error": "value must be between " + strconv.Itoa(math.MinInt) + " and " + strconv.Itoa(math.MaxInt)})
+		return 0, false
+	}
+	if err != nil {
+		respondJSON(c, http.StatusBadRequest, gin.H{"error": "value must be a number"})
+		return 0, false
+	}
+	return updatedValue, true
+}
+
+func SetView(c *gin.Context) {
+	updatedValue, ok := parseSetValue(c)
+	if !ok {
+		return
+	}
+	rawExpected, compareAndSet := c.GetQuery("expected")
+	expected, err := strconv.Atoi(rawExpected)
+	if compareAndSet && err != nil {
+		respondJSON(c, http.StatusBadRequest, gin.H{"error": "expected must be a number"})
+		return
+	}
+	namespace, key := utils.GetNamespaceKey(c)
+	if namespace == "" || key == "" {
+		return
+	}
+	dbKey := utils.CreateKey(c, namespace, key, false)
+	if dbKey == "" { // error is handled in CreateKey
+		return
+	}
+	meta, err := utils.GetMetadata(middleware.Context(c), Client, dbKey)
+	if err != nil {
+		respondJSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to get data. Try again later."})
+		return
+	}
+
+	if compareAndSet {
+		ttl := "keep"
+		if expiry := overwriteTTL(meta); expiry != redis.KeepTTL {
+			ttl = strconv.FormatInt(int64(expiry.Seconds()), 10)
+		}
+		result, err := utils.CompareAndSet.Run(middleware.Context(c), Client, []string{dbKey}, expected, updatedValue, ttl).Slice()
+		if err != nil {
+			respondJSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to set data. Try again later."})
+			return
+		}
+		switch result[0].(int64) {
+		case utils.CASMissing:
+			respondJSON(c, http.StatusConflict, gin.H{"error": "Key does not exist, please use a different key."})
+		case utils.CASMismatch:
+			respondJSON(c, http.StatusConflict, gin.H{"error": "Value does not match the expected value, it was not changed.", "value": parseCounterValue(result[1].(string))})
+		default:
+			utils.SetUpdated(middleware.Context(c), Client, dbKey, meta)
+			go utils.SetStream(dbKey, updatedValue)
+			utils.Events.Emit(dbKey, "set", updatedValue)
+			respondBody(c, int64(updatedValue), gin.H{"value": updatedValue})
+		}
+		return
+	}
+
+	// Get data from Redis
+	val, err := Client.SetXX(middleware.Context(c), dbKey, updatedValue, overwriteTTL(meta)).Result()
+	if err != nil {
+		respondJSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to set data. Try again later."})
+		return
+	}
+	if val == false {
+		respondJSON(c, http.StatusConflict, gin.H{"error": "Key does not exist, please use a different key."})
+	} else {
+		utils.SetUpdated(middleware.Context(c), Client, dbKey, meta)
+		go utils.SetStream(dbKey, updatedValue)
+		utils.Events.Emit(dbKey, "set", updatedValue)
+		respondBody(c, int64(updatedValue), gin.H{"value": updatedValue})
+	}
+}
+
+// MaxView sets a counter to ?value= only if that is greater than its current value, e.g. to record the peak of
+// something measured elsewhere.
+func MaxView(c *gin.Context) {
+	setExtreme(c, "max")
+}
+
+// MinView is MaxView for values less than the current one.
+func MinView(c *gin.Context) {
+	setExtreme(c, "min")
+}
+
+// setExtreme sets a counter to ?value= if that is a new max or min (as extreme says) in one atomic step, responding
+// with the value it holds afterwards and whether it changed.
+func setExtreme(c *gin.Context, extreme string) {
+	candidate, ok := parseSetValue(c)
+	if !ok {
+		return
+	}
+	namespace, key := utils.GetNamespaceKey(c)
+	if namespace == "" || key == "" {
+		return
+	}
+	dbKey := utils.CreateKey(c, namespace, key, false)
+	if dbKey == "" { // error is handled in CreateKey
+		return
+	}
+	meta, err := utils.GetMetadata(middleware.Context(c), Client, dbKey)
+	if err != nil {
+		respondJSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to get data. Try again later."})
+		return
+	}
+	ttl := "keep"
+	if expiry := overwriteTTL(meta); expiry != redis.KeepTTL {
+		ttl = strconv.FormatInt(int64(expiry.Seconds()), 10)
+	}
+	// only the bound in the direction of the change applies, as for hits
+	var bound, minMode string
+	if extreme == "max" && meta.HasMax {
+		bound = strconv.FormatFloat(meta.Max, 'f', -1, 64)
+	} else if extreme == "min" && meta.HasMin {
+		bound = strconv.FormatFloat(meta.Min, 'f', -1, 64)
+	}
+	if meta.RejectBelowMin {
+		minMode = "reject"
+	}
+	result, err := utils.SetExtreme.Run(middleware.Context(c), Client, []string{dbKey}, candidate, extreme, ttl, meta.Type, bound, minMode).Slice()
+	if err != nil {
+		respondJSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to set data. Try again later."})
+		return
+	}
+	var value interface{}
+	if len(result) > 1 {
+		value = parseCounterValue(fmt.Sprint(result[1]))
+	}
+	body := gin.H{"value": value, "changed": result[0].(int64) == utils.ExtremeSet}
+	if len(result) > 2 && result[2].(int64) == 1 {
+		body["clamped"] = true
+	}
+	switch result[0].(int64) {
+	case utils.ExtremeMissing:
+		respondJSON(c, http.StatusConflict, gin.H{"error": "Key does not exist, please use a different key."})
+	case utils.ExtremeAboveMax:
+		respondJSON(c, http.StatusConflict, gin.H{"error": "Counter has reached its max value of " + bound, "value": value})
+	case utils.ExtremeBelowMin:
+		respondJSON(c, http.StatusConflict, gin.H{"error": "Counter has reached its min value of " + bound, "value": value})
+	case utils.ExtremeKept:
+		respondBody(c, value, body)
+	default:
+		utils.SetUpdated(middleware.Context(c), Client, dbKey, meta)
+		if intValue, ok := value.(int64); ok {
+			go utils.SetStream(dbKey, int(intValue))
+		}
+		utils.Events.Emit(dbKey, "set", value)
+		respondBody(c, value, body)
+	}
+}
+
+func ResetView(c *gin.Context) {
+	namespace, key := utils.GetNamespaceKey(c)
+	if namespace == "" || key == "" {
+		return
+	}
+	dbKey := utils.CreateKey(c, namespace, key, false)
+	if dbKey == "" { // error is handled in CreateKey
+		return
+	}
+	meta, err := utils.GetMetadata(middleware.Context(c), Client, dbKey)
+	if err != nil {
+		respondJSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to get data. Try again later."})
+		return
+	}
+
+	var resetValue interface{} = int64(0)
+	if rawValue, ok := c.GetQuery("value"); ok { // reset to a custom starting point, which has to be within the bounds
+		var value float64
+		if meta.IsFloat() {
+			if value, ok = parseFloatAmount(c, "value", rawValue); !ok {
+				return
+			}
+			resetValue = value
+		} else {
+			intValue, ok := parseIntAmount(c, "value", rawValue)
+			if !ok {
+				return
+			}
+			value, resetValue = float64(intValue), intValue
+		}
+		if meta.HasMax && value > meta.Max {
+			respondJSON(c, http.StatusBadRequest, gin.H{"error": "value can't be larger than max"})
+			return
+		}
+		if meta.HasMin && value < meta.Min {
+			respondJSON(c, http.StatusBadRequest, gin.H{"error": "value can't be smaller than min"})
+			return
+		}
+	}
+
+	// Get data from Redis
+	val, err := Client.SetXX(middleware.Context(c), dbKey, resetValue, overwriteTTL(meta)).Result()
+	if err != nil {
+		respondJSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to set data. Try again later."})
+		return
+	}
+	if val == false {
+		respondJSON(c, http.StatusConflict, gin.H{"error": "Key does not exist, please use a different key."})
+	} else {
+		utils.SetUpdated(middleware.Context(c), Client, dbKey, meta)
+		respondJSON(c, http.StatusOK, gin.H{"value": resetValue})
+		if intValue, ok := resetValue.(int64); ok {
+			go utils.SetStream(dbKey, int(intValue))
+		}
+		utils.Events.Emit(dbKey, "reset", resetValue)
+	}
+}
+
+// RenameView moves a counter to the key in ?to=, within the same namespace, keeping its value, expiry, metadata and
+// admin key.
+func RenameView(c *gin.Context) {
+	namespace, key := utils.GetNamespaceKey(c)
+	if namespace == "" || key == "" {
+		return
+	}
+	dbKey := utils.CreateKey(c, namespace, key, false)
+	if dbKey == "" { // error is handled in CreateKey
+		return
+	}
+	to, ok := c.GetQuery("to")
+	if !ok {
+		respondJSON(c, http.StatusBadRequest, gin.H{"error": "to is required, please provide the new key in the fmt of ?to=NEW_KEY"})
+		return
+	}
+	newDBKey, err := utils.ValidateKey(namespace, to)
+	if err != nil {
+		respondJSON(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if newDBKey == dbKey {
+		respondJSON(c, http.StatusBadRequest, gin.H{"error": "to must be different from the current key"})
+		return
+	}
+
+	result, err := utils.RenameCounter.Run(middleware.Context(c), Client, utils.RenameCounterKeys(dbKey, newDBKey)).Int64()
+	if err != nil {
+		respondJSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to set data. Try again later."})
+		return
+	}
+	switch result {
+	case utils.RenameMissing:
+		respondJSON(c, http.StatusConflict, gin.H{"error": "Key does not exist, please use a different key."})
+	case utils.RenameExists:
+		respondJSON(c, http.StatusConflict, gin.H{"error": "A counter named " + to + " already exists, please use a different key."})
+	default:
+		utils.Counters.Invalidate(dbKey)
+		utils.Counters.Invalidate(newDBKey)
+		respondJSON(c, http.StatusOK, gin.H{"status": "ok", "message": "Renamed key: " + dbKey + " to " + newDBKey})
+		utils.CloseStream(dbKey) // streams of the old key would never see another update
+		utils.Events.Emit(dbKey, "delete", nil)
+		utils.Events.Emit(newDBKey, "rename", nil)
+	}
+}
+
+// MergeView adds the value of the counter in ?from= to this one, deleting the source if ?delete=true is given. Like
+// /set, the merged total isn't held to the target's max and min.
+func MergeView(c *gin.Context) {
+	namespace, key := utils.GetNamespaceKey(c)
+	if namespace == "" || key == "" {
+		return
+	}
+	dbKey := utils.CreateKey(c, namespace, key, false)
+	if dbKey == "" { // error is handled in CreateKey
+		return
+	}
+	from, ok := c.GetQuery("from")
+	if !ok {
+		respondJSON(c, http.StatusBadRequest, gin.H{"error": "from is required, please provide the key to merge in the fmt of ?from=SOURCE_KEY"})
+		return
+	}
+	sourceDBKey, err := utils.ValidateKey(namespace, from)
+	if err != nil {
+		respondJSON(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if sourceDBKey == dbKey {
+		respondJSON(c, http.StatusBadRequest, gin.H{"error": "from must be different from the current key"})
+		return
+	}
+	deleteSource, err := strconv.ParseBool(c.DefaultQuery("delete", "false"))
+	if err != nil {
+		respondJSON(c, http.StatusBadRequest, gin.H{"error": "delete must be either true or false"})
+		return
+	}
+	if deleteSource { // reading public sources is allowed to anyone, but deleting them needs the right to modify them too
+		allowed, err := middleware.CanModify(middleware.Context(c), Client, utils.GetAuthToken(c), namespace, from)
+		if err != nil {
+			respondJSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to get data. Try again later."})
+			return
+		} else if !allowed {
+			respondJSON(c, http.StatusUnauthorized, gin.H{"error": "token is invalid for " + from + ", deleting it requires its admin key or the namespace admin key"})
+			return
+		}
+	}
+	// the admin key of the target doesn't grant reading a private source, which the total would give away
+	sourceMeta, err := utils.GetMetadata(middleware.Context(c), Client, sourceDBKey)
+	if err != nil {
+		respondJSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to get data. Try again later."})
+		return
+	}
+	if readable, err := canRead(c, sourceMeta, namespace, from, false); err != nil {
+		respondJSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to get data. Try again later."})
+		return
+	} else if !readable {
+		respondJSON(c, http.StatusForbidden, gin.H{"error": "Key " + from + " is private, merging it requires its admin key or the namespace admin key"})
+		return
+	}
+	meta, err := utils.GetMetadata(middleware.Context(c), Client, dbKey)
+	if err != nil {
+		respondJSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to get data. Try again later."})
+		return
+	}
+
+	mode := ""
+	if deleteSource {
+		mode = "delete"
+	}
+	keys := []string{dbKey, sourceDBKey, utils.CreateMetaKey(sourceDBKey), utils.CreateAdminKey(sourceDBKey), utils.CreateNamespaceKey(namespace), utils.CreateHistoryKey(sourceDBKey), utils.CreateVisitorsKey(sourceDBKey),
+		utils.CreateSnapshotsKey(sourceDBKey)}
+	result, err := utils.MergeCounters.Run(middleware.Context(c), Client, keys, meta.Type, mode).Slice()
+	if err != nil {
+		failWrite(c, err, "Failed to set data. Try again later.")
+		return
+	}
+	switch result[0].(int64) {
+	case utils.MergeSourceMissing:
+		respondJSON(c, http.StatusConflict, gin.H{"error": "Key " + from + " does not exist, there is nothing to merge."})
+	case utils.MergeTargetMissing:
+		respondJSON(c, http.StatusConflict, gin.H{"error": "Key does not exist, please use a different key."})
+	case utils.MergeNotInteger:
+		respondJSON(c, http.StatusConflict, gin.H{"error": "This is an integer counter, " + from + " holds a decimal value that can't be merged into it."})
+	default:
+		utils.SetUpdated(middleware.Context(c), Client, dbKey, meta)
+		total := parseCounterValue(fmt.Sprint(result[1]))
+		if intTotal, ok := total.(int64); ok {
+			go utils.SetStream(dbKey, int(intTotal))
+		}
+		utils.Events.Emit(dbKey, "merge", total)
+		if deleteSource {
+			utils.Counters.Invalidate(sourceDBKey)
+			utils.CloseStream(sourceDBKey)
+			utils.Events.Emit(sourceDBKey, "delete", nil)
+		}
+		respondJSON(c, http.StatusOK, gin.H{"value": total})
+	}
+}
+
+type updateRequest struct {
+	Delta json.Number `json:"delta"`
+}
+
+// UpdateByView changes a counter by the amount given as ?value=, or as the delta of a JSON ({"delta": -5}) or form
+// body, whichever is present.
+func UpdateByView(c *gin.Context) {
+	name, updatedValueRaw, ok := updateDelta(c)
+	if !ok {
+		return
+	}
+	if updatedValueRaw == "" {
+		respondJSON(c, http.StatusBadRequest, gin.H{"error": "value is required, please provide a number in the fmt of ?value=NEW_VALUE, or a body in the fmt of {\"delta\":DELTA}"})
+		return
+
+	}
+	namespace, key := utils.GetNamespaceKey(c)
+	if namespace == "" || key == "" {
+		return
+	}
+	dbKey := utils.CreateKey(c, namespace, key, false)
+	if dbKey == "" { // error is handled in CreateKey
+		return
+	}
+
+	exists := Client.Exists(middleware.Context(c), dbKey).Val() == 0
+	if exists {
+		respondJSON(c, http.StatusConflict, gin.H{"error": "Key does not exist, please first create it using /create."})
+		return
+	}
+	meta, err := utils.GetMetadata(middleware.Context(c), Client, dbKey)
+	if err != nil {
+		respondJSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to get data. Try again later."})
+		return
+	}
+
+	if meta.IsFloat() {
+		incrByValue, ok := parseFloatAmount(c, name, updatedValueRaw)
+		if !ok {
+			return
+		}
+		if incrByValue == 0 {
+			respondJSON(c, http.StatusBadRequest, gin.H{"error": "changing value by 0 does nothing, please provide a non-zero value in the fmt of ?value=NEW_VALUE"})
+			return
+		}
+		if meta.Bounded() {
+			result, err := incrementBounded(middleware.Context(c), Client.TxPipeline(), dbKey, meta, strconv.FormatFloat(incrByValue, 'f', -1, 64), "")
+			if err != nil {
+				failWrite(c, err, "Failed to set data. Try again later.")
+				return
+			}
+			if rejectBounded(c, meta, result) {
+				return
+			}
+			utils.Events.Emit(dbKey, "update", result.Value)
+			respondJSON(c, http.StatusOK, boundedBody(result))
+			return
+		}
+		val, err := Client.IncrByFloat(middleware.Context(c), dbKey, incrByValue).Result()
+		if err != nil {
+			failWrite(c, err, "Failed to set data. Try again later.")
+			return
+		}
+		utils.SetUpdated(middleware.Context(c), Client, dbKey, meta)
+		utils.RecordHistory(middleware.Context(c), Client, dbKey, meta, incrByValue)
+		utils.Events.Emit(dbKey, "update", val)
+		respondJSON(c, http.StatusOK, gin.H{"value": val})
+		return
+	}
+
+	incrByValue, ok := parseIntAmount(c, name, updatedValueRaw)
+	if !ok {
+		return
+	}
+	if incrByValue == 0 {
+		respondJSON(c, http.StatusBadRequest, gin.H{"error": "changing value by 0 does nothing, please provide a non-zero value in the fmt of ?value=NEW_VALUE"})
+		return
+	}
+
+	if meta.Bounded() {
+		result, err := incrementBounded(middleware.Context(c), Client.TxPipeline(), dbKey, meta, strconv.FormatInt(incrByValue, 10), "")
+		if err != nil {
+			failWrite(c, err, "Failed to set data. Try again later.")
+			return
+		}
+		if rejectBounded(c, meta, result) {
+			return
+		}
+		val, _ := result.Value.(int64)
+		respondJSON(c, http.StatusOK, boundedBody(result))
+		go utils.SetStream(dbKey, int(val))
+		utils.Events.Emit(dbKey, "update", result.Value)
+		return
+	}
+
+	// Get data from Redis
+	val, err := Client.IncrBy(middleware.Context(c), dbKey, incrByValue).Result()
+	if err != nil {
+		failWrite(c, err, "Failed to set data. Try again later.")
+		return
+	}
+	utils.SetUpdated(middleware.Context(c), Client, dbKey, meta)
+	utils.RecordHistory(middleware.Context(c), Client, dbKey, meta, float64(incrByValue))
+
+	respondJSON(c, http.StatusOK, gin.H{"value": val})
 	go utils.SetStream(dbKey, int(val))
+	utils.Events.Emit(dbKey, "update", val)
+}
+
+// updateDelta returns the raw amount /update changes the counter by, along with the name it was given as for error
+// messages. It is empty if no amount was given. If the JSON body is malformed, it responds with a 400 and reports
+// false.
+func updateDelta(c *gin.Context) (string, string, bool) {
+	if raw := c.Query("value"); raw != "" {
+		return "value", raw, true
+	}
+	switch c.ContentType() {
+	case gin.MIMEJSON:
+		var request updateRequest
+		decoder := json.NewDecoder(c.Request.Body)
+		decoder.UseNumber() // keeps the delta exact until the counter type says how to parse it
+		if err := decoder.Decode(&request); err != nil {
+			if middleware.BodyTooLarge(c, err) {
+				return "", "", false
+			}
+			respondJSON(c, http.StatusBadRequest, gin.H{"error": "Invalid body, please provide a JSON object in the fmt of {\"delta\":DELTA}"})
+			return "", "", false
+		}
+		return "delta", request.Delta.String(), true
+	case gin.MIMEPOSTForm, gin.MIMEMultipartPOSTForm:
+		return "delta", c.PostForm("delta"), true
+	}
+	return "value", "", true
+}
+
+type webhookRequest struct {
+	URL   string `json:"url"`
+	Every int64  `json:"every"`
+}
+
+func WebhookView(c *gin.Context) {
+	var request webhookRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		if middleware.BodyTooLarge(c, err) {
+			return
+		}
+		respondJSON(c, http.StatusBadRequest, gin.H{"error": "Invalid body, please provide a JSON body in the fmt of {\"url\":\"URL\",\"every\":EVERY}"})
+		return
+	}
+	if err := utils.ValidateWebhookURL(request.URL); err != nil {
+		respondJSON(c, http.StatusBadRequest, gin.H{"error": "Invalid url: " + err.Error()})
+		return
+	}
+	if request.Every <= 0 {
+		respondJSON(c, http.StatusBadRequest, gin.H{"error": "every must be a positive integer"})
+		return
+	}
+	namespace, key := utils.GetNamespaceKey(c)
+	if namespace == "" || key == "" {
+		return
+	}
+	dbKey := utils.CreateKey(c, namespace, key, false)
+	if dbKey == "" { // error is handled in CreateKey
+		return
+	}
+	// the metadata expires alongside the counter, so use its remaining ttl
+	ttl, err := Client.TTL(middleware.Context(c), dbKey).Result()
+	if err != nil {
+		respondJSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to get data. Try again later."})
+		return
+	}
+	if ttl == -2 {
+		respondJSON(c, http.StatusConflict, gin.H{"error": "Key does not exist, please first create it using /create."})
+		return
+	}
+	meta, err := utils.GetMetadata(middleware.Context(c), Client, dbKey)
+	if err != nil {
+		respondJSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to get data. Try again later."})
+		return
+	}
+	meta.WebhookURL, meta.WebhookEvery = request.URL, request.Every
+	if err := utils.SetMetadata(middleware.Context(c), Client, dbKey, meta, ttl); err != nil {
+		respondJSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to set data. Try again later."})
+		return
+	}
+	respondJSON(c, http.StatusOK, gin.H{"url": request.URL, "every": request.Every})
+}
+
+// DescriptionView replaces the description of a counter with ?description=, an empty one removing it.
+func DescriptionView(c *gin.Context) {
+	description := c.Query("description")
+	if err := utils.ValidateDescription(description); err != nil {
+		respondJSON(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	namespace, key := utils.GetNamespaceKey(c)
+	if namespace == "" || key == "" {
+		return
+	}
+	dbKey := utils.CreateKey(c, namespace, key, false)
+	if dbKey == "" { // error is handled in CreateKey
+		return
+	}
+	// the metadata expires alongside the counter, so use its remaining ttl
+	ttl, err := Client.TTL(middleware.Context(c), dbKey).Result()
+	if err != nil {
+		respondJSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to get data. Try again later."})
+		return
+	}
+	if ttl == -2 {
+		respondJSON(c, http.StatusNotFound, gin.H{"error": "Key not found"})
+		return
+	}
+	if err := utils.SetDescription(middleware.Context(c), Client, dbKey, description, ttl); err != nil {
+		respondJSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to set data. Try again later."})
+		return
+	}
+	respondJSON(c, http.StatusOK, gin.H{"namespace": namespace, "key": key, "description": description})
+}
+
+// NoRouteView answers requests for unknown routes. The root and browsers (asking for text/html) are redirected to the
+// docs, unless the path starts like one of the routes of r, e.g. a mistyped /get/..., which gets a 404 like any API
+// client.
+func NoRouteView(r *gin.Engine) gin.HandlerFunc {
+	var once sync.Once
+	prefixes := make(map[string]bool)
+	return func(c *gin.Context) {
+		once.Do(func() {
+			for _, route := range r.Routes() {
+				prefixes[apiPrefix(route.Path)] = true
+			}
+		})
+		browser := strings.Contains(c.GetHeader("Accept"), "text/html") && !prefixes[apiPrefix(c.Request.URL.Path)]
+		if c.Request.URL.Path == "/" || browser {
+			c.Redirect(http.StatusPermanentRedirect, DocsUrl)
+			return
+		}
+		respondJSON(c, http.StatusNotFound, gin.H{"error": "There is no " + c.Request.Method + " " + c.Request.URL.Path +
+			" route, see " + DocsUrl + " for the available ones"})
+	}
+}
+
+// apiPrefix returns the first segment of path, e.g. "get" for /get/:namespace/*key.
+func apiPrefix(path string) string {
+	prefix, _, _ := strings.Cut(strings.TrimPrefix(path, "/"), "/")
+	return prefix
+}
+
+// MaintenanceView turns the read-only mode of this instance on or off with ?read_only=, which requires the
+// OPERATOR_TOKEN. It responds with the current mode, so it only reports it without ?read_only=.
+func MaintenanceView(c *gin.Context) {
+	if subtle.ConstantTimeCompare([]byte(utils.GetAuthToken(c)), []byte(OperatorToken)) != 1 {
+		respondJSON(c, http.StatusUnauthorized, gin.H{"error": "token is invalid, the operator token is required"})
+		return
+	}
+	if rawReadOnly, ok := c.GetQuery("read_only"); ok {
+		enabled, err := strconv.ParseBool(rawReadOnly)
+		if err != nil {
+			respondJSON(c, http.StatusBadRequest, gin.H{"error": "read_only must be either true or false"})
+			return
+		}
+		middleware.SetReadOnly(enabled, "/maintenance from "+c.ClientIP())
+	}
+	respondJSON(c, http.StatusOK, gin.H{"read_only": middleware.IsReadOnly()})
+}
+
+const healthCheckTimeout = 2 * time.Second
+
+// VersionView reports which build of the server is running.
+func VersionView(c *gin.Context) {
+	respondJSON(c, http.StatusOK, buildInfo())
+}
+
+// buildInfo describes the build of the server, empty strings standing for what isn't known about it.
+func buildInfo() gin.H {
+	return gin.H{"version": Version, "git_commit": GitCommit, "build_time": BuildTime, "go_version": runtime.Version()}
+}
+
+// LivezView reports that the server is up, without checking its databases.
+func LivezView(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+// DocsView redirects to the documentation.
+func DocsView(c *gin.Context) {
+	c.Redirect(http.StatusPermanentRedirect, DocsUrl)
+}
+
+// MetricsView serves the prometheus metrics of the server and of client.
+func MetricsView(client redis.UniversalClient) gin.HandlerFunc {
+	handler := utils.MetricsHandler(client)
+	return func(c *gin.Context) {
+		handler.ServeHTTP(c.Writer, c.Request)
+	}
+}
+
+// HealthCheckView reports whether the server is ready to serve requests, which requires both redis databases to be
+// reachable. It responds with a 503 naming the failing ones otherwise.
+func HealthCheckView(c *gin.Context) {
+	checks := gin.H{}
+	healthy := true
+	clients := map[string]redis.UniversalClient{"redis": Client, "rate_limit_redis": RateLimitClient}
+	if ReplicaClient != nil {
+		clients["redis_replica"] = ReplicaClient
+	}
+	for name, client := range clients {
+		ctx, cancel := context.WithTimeout(context.Background(), healthCheckTimeout)
+		err := client.Ping(ctx).Err()
+		cancel()
+		if err != nil {
+			checks[name] = err.Error()
+			healthy = false
+		} else {
+			checks[name] = "ok"
+		}
+	}
+	body := gin.H{"status": "ok", "uptime": time.Since(StartTime).String(), "checks": checks, "read_only": middleware.IsReadOnly()}
+	if RedisBreaker != nil {
+		body["breaker"] = RedisBreaker.Status()
+	}
+	if ReplicaClient != nil && ReplicaBreaker != nil {
+		body["replica_breaker"] = ReplicaBreaker.Status()
+	}
+	if !healthy {
+		body["status"] = "unavailable"
+		respondJSON(c, http.StatusServiceUnavailable, body)
+		return
+	}
+	respondJSON(c, http.StatusOK, body)
 }
 
 func StatsView(c *gin.Context) {
+	order := c.DefaultQuery("sort", "count")
+	if order != "count" && order != "name" {
+		respondJSON(c, http.StatusBadRequest, gin.H{"error": "sort must be either count or name"})
+		return
+	}
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(utils.CensusTopNamespaces)))
+	if err != nil || limit < 1 || limit > utils.CensusMaxPage {
+		respondJSON(c, http.StatusBadRequest, gin.H{"error": "limit must be a number between 1 and " + strconv.Itoa(utils.CensusMaxPage)})
+		return
+	}
+	offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if err != nil || offset < 0 {
+		respondJSON(c, http.StatusBadRequest, gin.H{"error": "offset must be a non-negative number"})
+		return
+	}
+
 	// get average ttl using INFO
 
-	ctx := context.Background()
+	ctx := middleware.Context(c)
 	infoStr, err := Client.Info(ctx).Result()
 	if err != nil {
 		panic(err)
@@ -367,9 +3331,16 @@ func StatsView(c *gin.Context) {
 	create, _ := strconv.Atoi(Client.Get(ctx, "stats:create").Val())
 
 	totalKeys := create + (hits / 60) // 60 hits per key (average taken from the first 6m requests) ~ Json
+	pool := Client.PoolStats()
+	census, err := Census.Get(ctx)
+	if err != nil {
+		respondJSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to get data. Try again later."})
+		return
+	}
 
-	c.JSON(http.StatusOK, gin.H{
+	respondJSON(c, http.StatusOK, gin.H{
 		"version":                     Version,
+		"build":                       buildInfo(),
 		"uptime":                      time.Since(StartTime).String(),
 		"db_uptime":                   infoDict["Server"]["uptime_in_seconds"],
 		"db_version":                  infoDict["Server"]["redis_version"],
@@ -381,7 +3352,450 @@ func StatsView(c *gin.Context) {
 			"hit":    hits,
 			"create": create,
 		},
+		// connection pool of this instance, running out of idle connections shows up as timeouts
+		"db_pool": gin.H{
+			"total_conns": pool.TotalConns,
+			"idle_conns":  pool.IdleConns,
+			"stale_conns": pool.StaleConns,
+			"hits":        pool.Hits,
+			"misses":      pool.Misses,
+			"timeouts":    pool.Timeouts,
+		},
+		"db_num":     DbNum,
 		"total_keys": totalKeys,
 		"shard":      Shard,
+		"timezone":   utils.Location.String(),
+		// counted with a scan of the whole database, so only every CensusInterval
+		"counters": gin.H{
+			"total":          census.Total,
+			"namespaces":     len(census.Namespaces),
+			"top_namespaces": census.Page(order, offset, limit),
+			"age":            time.Since(census.TakenAt).Round(time.Second).String(),
+		},
 	})
 }
+
+// respondValue writes a counter value in the format the client asked for: plain text (via ?format=text or
+// Accept: text/plain), protobuf (via Accept: application/x-protobuf), JSONP (via ?callback) or JSON, which is the
+// default.
+func respondValue(c *gin.Context, value interface{}) {
+	respondBody(c, value, gin.H{"value": value})
+}
+
+// respondBody is respondValue with a custom body for the JSON formats, plain text responses only contain the value
+// (in the base of ?base= if one was given, which the JSON formats add as value_in_base).
+func respondBody(c *gin.Context, value interface{}, body gin.H) {
+	c.Header("Vary", "Accept")
+	var text interface{} = value
+	if intValue, ok := value.(int64); ok && c.GetInt(baseContextKey) != 0 {
+		text = strconv.FormatInt(intValue, c.GetInt(baseContextKey))
+		body["value_in_base"] = text
+	}
+	format := c.NegotiateFormat(gin.MIMEJSON, gin.MIMEPlain, binding.MIMEPROTOBUF)
+	if c.Query("format") == "text" || format == gin.MIMEPlain {
+		c.String(http.StatusOK, "%v", text)
+	} else if format == binding.MIMEPROTOBUF {
+		message := &pb.Counter{}
+		message.Clamped, _ = body["clamped"].(bool)
+		if intValue, ok := value.(int64); ok {
+			message.Value = &pb.Counter_IntValue{IntValue: intValue}
+		} else {
+			floatValue, _ := strconv.ParseFloat(fmt.Sprint(value), 64)
+			message.Value = &pb.Counter_FloatValue{FloatValue: floatValue}
+		}
+		c.ProtoBuf(http.StatusOK, message)
+	} else if c.Query("callback") != "" {
+		c.JSONP(http.StatusOK, shapeBody(c, body))
+	} else {
+		respondJSON(c, http.StatusOK, body)
+	}
+}
+
+// respondJSON writes body as JSON, with its field names in the casing the client asked for (see utils.WantsCamelCase).
+func respondJSON(c *gin.Context, code int, body interface{}) {
+	c.JSON(code, shapeBody(c, body))
+}
+
+// shapeBody converts the field names of body to camelCase if the client asked for it, they are snake_case otherwise.
+func shapeBody(c *gin.Context, body interface{}) interface{} {
+	if utils.WantsCamelCase(c) {
+		return utils.CamelKeys(body)
+	}
+	return body
+}
+
+// respondHit responds to a hit with the new value, or the value before the hit if ?return=previous was given. status
+// is one of the utils.BoundedIncr results, flagging clamped hits and hits that deleted the counter.
+func respondHit(c *gin.Context, meta utils.Metadata, value, previous interface{}, status int64) {
+	body := boundedBody(boundedResult{Value: value, Status: status})
+	if key := c.GetString(rolledKeyContextKey); key != "" {
+		body["key"] = key
+	}
+	if meta.UniqueWindow > 0 {
+		body["counted"] = true
+	}
+	if c.GetBool(upsertContextKey) {
+		body["created"] = false
+	}
+	if c.Query("return") == "previous" {
+		value = previous
+		body["value"] = value
+	}
+	respondBody(c, value, body)
+}
+
+// boundedBody is the body of a response to a change made with incrementBounded, flagging if it was clamped or
+// deleted the counter.
+func boundedBody(result boundedResult) gin.H {
+	switch result.Status {
+	case utils.IncrClamped:
+		return gin.H{"value": result.Value, "clamped": true}
+	case utils.IncrDeleted:
+		return gin.H{"value": result.Value, "deleted": true}
+	}
+	return gin.H{"value": result.Value}
+}
+
+// authorizeRead responds with a 401 if the counter is private, unless the request carries a token that may modify
+// it. It reports whether the request may go on.
+func authorizeRead(c *gin.Context, meta utils.Metadata) bool {
+	return authorize(c, meta, false)
+}
+
+// authorizeShared is authorizeRead for the views a share token grants reading the counter with too: /get, /stream,
+// /ws and /info.
+func authorizeShared(c *gin.Context, meta utils.Metadata) bool {
+	return authorize(c, meta, true)
+}
+
+// authorize implements authorizeRead and authorizeShared, accepting share tokens only if shareable is set.
+func authorize(c *gin.Context, meta utils.Metadata, shareable bool) bool {
+	namespace, key := utils.ResolveNamespaceKey(c)
+	allowed, err := canRead(c, meta, namespace, key, shareable)
+	share := utils.IsShareToken(utils.GetAuthToken(c))
+	if err != nil {
+		respondJSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to get data. Try again later."})
+		return false
+	} else if !allowed && share && !shareable {
+		respondJSON(c, http.StatusForbidden, gin.H{"error": "A share token only grants reading the counter with /get, /stream, /ws and /info"})
+		return false
+	} else if !allowed && share {
+		respondJSON(c, http.StatusUnauthorized, gin.H{"error": "The share token is invalid, has expired or was revoked"})
+		return false
+	} else if !allowed {
+		respondJSON(c, http.StatusUnauthorized, gin.H{"error": "This counter is private, please provide its admin token in the format of a Bearer token header or ?token=ADMIN_TOKEN"})
+		return false
+	}
+	return true
+}
+
+// canRead reports whether the request may read the counter namespace/key with the metadata meta: any request for
+// public counters, only those carrying its admin key or the namespace admin key otherwise, or a share token for it if
+// shareable is set.
+func canRead(c *gin.Context, meta utils.Metadata, namespace, key string, shareable bool) (bool, error) {
+	if !meta.Private {
+		return true, nil
+	}
+	token := utils.GetAuthToken(c)
+	if shareable && utils.IsShareToken(token) {
+		return utils.CheckShareToken(middleware.Context(c), Client, namespace, utils.BuildDBKey(namespace, key), token)
+	}
+	return middleware.CanModify(middleware.Context(c), Client, token, namespace, key)
+}
+
+// readClient returns the client views that only read counters use: the replica if one is configured, as those views
+// get most of the traffic, and the primary otherwise. The replica may lag slightly behind the primary. While the
+// breaker of the replica is open, reads fall back to the primary rather than failing.
+func readClient() redis.UniversalClient {
+	if ReplicaClient != nil && (ReplicaBreaker == nil || !ReplicaBreaker.Open()) {
+		return ReplicaClient
+	}
+	return Client
+}
+
+// readCounter fetches the value of a counter from the read client and refreshes its expiry on the primary. The error
+// is redis.Nil if the counter doesn't exist.
+func readCounter(ctx context.Context, dbKey string) (interface{}, error) {
+	val, err := readClient().Get(ctx, dbKey).Result()
+	if err != nil {
+		return nil, err
+	}
+	reader, primary := readClient(), Client // resolved now, as the goroutine may outlive the request
+	go func() {
+		if meta, err := utils.GetMetadata(context.Background(), reader, dbKey); err == nil {
+			touch(primary, dbKey, meta)
+		}
+	}()
+	return parseCounterValue(val), nil
+}
+
+// touch refreshes the expiry of a counter that was read (and that of its metadata and snapshots) on the primary
+// client using the default TTL. Counters created with a custom TTL keep their original expiry.
+func touch(client redis.UniversalClient, dbKey string, meta utils.Metadata) {
+	if meta.CustomTTL {
+		return
+	}
+	pipe := client.Pipeline()
+	refreshExpiry(pipe, dbKey, meta)
+	pipe.Exec(context.Background())
+}
+
+// refreshExpiry queues pushing the expiry of a counter (and its metadata and snapshots, so they don't expire before
+// it) forward once it was changed: by the default TTL, or by its original one for a sliding-window counter. Counters
+// with a fixed custom TTL keep their expiry.
+func refreshExpiry(pipe redis.Pipeliner, dbKey string, meta utils.Metadata) {
+	if !meta.Refreshes() {
+		return
+	}
+	ttl := utils.BaseTTLPeriod
+	if meta.CustomTTL {
+		ttl = meta.TTL
+	}
+	pipe.Expire(context.Background(), dbKey, ttl)
+	pipe.Expire(context.Background(), utils.CreateMetaKey(dbKey), ttl)
+	utils.QueueSnapshotsExpiry(context.Background(), pipe, dbKey, ttl)
+}
+
+// recordHit refreshes the expiry of the counter dbKey and counts a hit of it in its hit rate once the hit was made,
+// or refused by the counter's max or min. step is what the hit changed the counter by, which unless it is 0 is also
+// recorded as its last update and in its history. These can't be queued along with the change itself: MULTI doesn't
+// roll back the commands queued with one that fails, such as an INCRBY that would overflow.
+func recordHit(ctx context.Context, dbKey string, meta utils.Metadata, step float64) {
+	pipe := Client.Pipeline()
+	refreshExpiry(pipe, dbKey, meta)
+	utils.QueueHit(ctx, pipe, dbKey)
+	if step != 0 {
+		utils.QueueUpdated(ctx, pipe, dbKey, meta)
+		utils.QueueHistory(ctx, pipe, dbKey, meta, step)
+	}
+	pipe.Exec(ctx)
+	utils.Counters.Invalidate(dbKey)
+}
+
+// hitOp is the op of the events of /hit and /dec.
+func hitOp(decrement bool) string {
+	if decrement {
+		return "dec"
+	}
+	return "hit"
+}
+
+// notifyThreshold calls the webhook of a counter in the background if a hit by step took it across a multiple of its
+// threshold. Only increments notify, as the thresholds are meant for milestones.
+func notifyThreshold(namespace, key string, meta utils.Metadata, value interface{}, step float64) {
+	if meta.WebhookURL == "" || step <= 0 {
+		return
+	}
+	current, _ := strconv.ParseFloat(fmt.Sprint(value), 64)
+	if utils.CrossedThreshold(current-step, current, meta.WebhookEvery) {
+		go utils.SendWebhook(meta.WebhookURL, utils.WebhookPayload{Namespace: namespace, Key: key, Value: value, Timestamp: utils.FormatTime(time.Now())})
+	}
+}
+
+// respondDryRun responds with the value a hit by step would take the counter to, without changing anything. It
+// mirrors utils.BoundedIncr, so a refused or clamped hit, or one that would delete the counter, is previewed as such.
+func respondDryRun(c *gin.Context, dbKey string, meta utils.Metadata, step interface{}) {
+	raw, err := Client.Get(middleware.Context(c), dbKey).Result()
+	if errors.Is(err, redis.Nil) { // the first hit starts from 0
+		raw = "0"
+	} else if err != nil {
+		respondJSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to get data. Try again later."})
+		return
+	}
+	current := parseCounterValue(raw)
+	currentFloat, _ := strconv.ParseFloat(raw, 64)
+	amount, _ := strconv.ParseFloat(fmt.Sprint(step), 64)
+
+	body := gin.H{"dry_run": true, "value": current, "next_value": current}
+	switch {
+	case meta.HasMax && amount > 0 && currentFloat+amount > meta.Max:
+		body["rejected"] = "Counter has reached its max value of " + strconv.FormatFloat(meta.Max, 'f', -1, 64)
+	case meta.DeleteAtZero && amount < 0 && (currentFloat+amount <= 0 || (meta.HasMin && currentFloat+amount < meta.Min)):
+		body["deleted"] = true
+		next := currentFloat + amount
+		if meta.HasMin && next < meta.Min {
+			next = meta.Min
+		}
+		body["next_value"] = parseCounterValue(strconv.FormatFloat(next, 'f', -1, 64))
+	case meta.HasMin && amount < 0 && currentFloat+amount < meta.Min && meta.RejectBelowMin:
+		body["rejected"] = "Counter has reached its min value of " + strconv.FormatFloat(meta.Min, 'f', -1, 64)
+	case meta.HasMin && amount < 0 && currentFloat+amount < meta.Min:
+		body["clamped"] = true
+		if currentFloat > meta.Min {
+			body["next_value"] = parseCounterValue(strconv.FormatFloat(meta.Min, 'f', -1, 64))
+		}
+	default:
+		intCurrent, isInt := current.(int64)
+		if intStep, ok := step.(int64); ok && isInt {
+			body["next_value"] = intCurrent + intStep
+		} else {
+			body["next_value"] = currentFloat + amount
+		}
+	}
+	respondJSON(c, http.StatusOK, body)
+}
+
+// boundedResult is the outcome of incrementBounded.
+type boundedResult struct {
+	Value    interface{} // the new value, or the unchanged one if the change was refused
+	Previous interface{} // the value before the change
+	Status   int64       // one of the utils.BoundedIncr results
+}
+
+// incrementBounded atomically changes a counter that has a max or a min by amount, executing pipe along with it so
+// any queued commands (such as refreshExpiry) are applied too. For a hit to a unique counter, with or without bounds,
+// visitor is the visitor that made it (see hitVisitor), and the change is only made if they weren't counted yet.
+func incrementBounded(ctx context.Context, pipe redis.Pipeliner, dbKey string, meta utils.Metadata, amount, visitor string) (boundedResult, error) {
+	var cmd *redis.Cmd
+	if visitor != "" {
+		cmd = utils.IncrVisit(ctx, pipe, dbKey, meta, amount, visitor)
+	} else {
+		cmd = utils.IncrBounded(ctx, pipe, dbKey, meta, amount, "")
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return boundedResult{}, err
+	}
+	result, err := cmd.Slice()
+	if err != nil {
+		return boundedResult{}, err
+	}
+	bounded := boundedResult{
+		Value:    parseCounterValue(fmt.Sprint(result[1])),
+		Previous: parseCounterValue(fmt.Sprint(result[2])),
+		Status:   result[0].(int64),
+	}
+	if bounded.Status == utils.IncrDeleted {
+		utils.Counters.Invalidate(dbKey)
+	} else if bounded.Status == utils.IncrApplied || (bounded.Status == utils.IncrClamped && bounded.Value != bounded.Previous) {
+		value, _ := strconv.ParseFloat(fmt.Sprint(result[1]), 64)
+		previous, _ := strconv.ParseFloat(fmt.Sprint(result[2]), 64)
+		pipe := Client.Pipeline()
+		utils.QueueUpdated(ctx, pipe, dbKey, meta)
+		utils.QueueHistory(ctx, pipe, dbKey, meta, value-previous) // clamped changes are smaller than amount
+		pipe.Exec(ctx)
+		utils.Counters.Invalidate(dbKey)
+	}
+	return bounded, nil
+}
+
+// overflowError is the error of changes redis refused as they would take a counter out of the range of its type.
+const overflowError = "The change would take the counter past the largest (or smallest) value it can hold, which is " +
+	"9223372036854775807 for int counters, so it wasn't made"
+
+// failWrite responds to a write to a counter that failed with err: with a 409 if it would have taken the counter past
+// the range of its type, which redis refuses rather than wrapping around, and with a 500 saying message otherwise.
+func failWrite(c *gin.Context, err error, message string) {
+	if utils.IsOverflow(err) {
+		respondJSON(c, http.StatusConflict, gin.H{"error": overflowError})
+		return
+	}
+	respondJSON(c, http.StatusInternalServerError, gin.H{"error": message})
+}
+
+// rejectBounded responds with a 409, including the unchanged value, if incrementBounded refused a change because it
+// would take the counter past one of its bounds. It reports whether it responded.
+func rejectBounded(c *gin.Context, meta utils.Metadata, result boundedResult) bool {
+	switch result.Status {
+	case utils.IncrAboveMax:
+		respondJSON(c, http.StatusConflict, gin.H{"error": "Counter has reached its max value of " + strconv.FormatFloat(meta.Max, 'f', -1, 64), "value": result.Value})
+	case utils.IncrBelowMin:
+		respondJSON(c, http.StatusConflict, gin.H{"error": "Counter has reached its min value of " + strconv.FormatFloat(meta.Min, 'f', -1, 64), "value": result.Value})
+	default:
+		return false
+	}
+	return true
+}
+
+// overwriteTTL returns the expiry to use when overwriting the value of a counter.
+func overwriteTTL(meta utils.Metadata) time.Duration {
+	if meta.CustomTTL {
+		return redis.KeepTTL
+	}
+	return utils.BaseTTLPeriod
+}
+
+// parseCounterValue converts a raw redis value into a number, keeping integer counters exact.
+func parseCounterValue(raw string) interface{} {
+	if intValue, err := strconv.ParseInt(raw, 10, 64); err == nil {
+		return intValue
+	}
+	floatValue, _ := strconv.ParseFloat(raw, 64)
+	return floatValue
+}
+
+// parseIntAmount parses an amount meant for an integer counter. Decimals are rejected with a 409, as they
+// require a float counter, anything else that isn't a number is rejected with a 400.
+func parseIntAmount(c *gin.Context, name, raw string) (int64, bool) {
+	amount, err := strconv.ParseInt(raw, 10, 64)
+	if err == nil {
+		return amount, true
+	}
+	if _, floatErr := strconv.ParseFloat(raw, 64); floatErr == nil {
+		respondJSON(c, http.StatusConflict, gin.H{"error": "This is an integer counter, " + name + " must be an integer. Create the counter with ?type=float to use decimals."})
+	} else {
+		respondJSON(c, http.StatusBadRequest, gin.H{"error": name + " must be an integer, this means no floats."})
+	}
+	return 0, false
+}
+
+// parseBound parses the max or min of a new counter, which has to be an integer for integer counters.
+func parseBound(c *gin.Context, meta utils.Metadata, name, raw string) (float64, bool) {
+	if meta.IsFloat() {
+		return parseFloatAmount(c, name, raw)
+	}
+	bound, err := strconv.ParseInt(raw, 10, 64)
+	if err != nil {
+		respondJSON(c, http.StatusBadRequest, gin.H{"error": name + " must be an integer, this means no floats."})
+		return 0, false
+	}
+	return float64(bound), true
+}
+
+// parseFloatAmount parses an amount meant for a float counter.
+func parseFloatAmount(c *gin.Context, name, raw string) (float64, bool) {
+	amount, err := strconv.ParseFloat(raw, 64)
+	if err != nil {
+		respondJSON(c, http.StatusBadRequest, gin.H{"error": name + " must be a number"})
+		return 0, false
+	}
+	return amount, true
+}
+
+// hitVisitor returns the visitor (see visitorID) of a hit to the unique counter dbKey, which it only changes if they
+// weren't counted in the current window yet. It is empty for other counters and for decrements, which always apply.
+func hitVisitor(c *gin.Context, dbKey string, meta utils.Metadata, decrement bool) string {
+	if meta.UniqueWindow <= 0 || decrement {
+		return ""
+	}
+	return visitorID(c, dbKey)
+}
+
+// respondRepeated responds with the unchanged value of a unique counter to a hit by a visitor that was counted already
+// in the current window.
+func respondRepeated(c *gin.Context, value interface{}) {
+	body := gin.H{"value": value, "counted": false}
+	if c.GetBool(upsertContextKey) {
+		body["created"] = false
+	}
+	respondBody(c, value, body)
+}
+
+// recordVisitor adds the visitor making the request to the current window of the unique counter dbKey. It is a no-op
+// for counters that don't count unique visitors.
+func recordVisitor(c *gin.Context, dbKey string, meta utils.Metadata) {
+	if meta.UniqueWindow <= 0 {
+		return
+	}
+	utils.RecordVisitor.Run(middleware.Context(c), Client, []string{utils.CreateVisitorsKey(dbKey)}, visitorID(c, dbKey),
+		int64(meta.UniqueWindow.Seconds()))
+}
+
+// visitorID identifies the visitor making the request to the unique counter dbKey by the VisitorCookie, or their IP
+// without one, hashed with the counter so the same visitor can't be matched up across counters.
+func visitorID(c *gin.Context, dbKey string) string {
+	visitor, err := c.Cookie(utils.VisitorCookie)
+	if err != nil || visitor == "" {
+		visitor = c.ClientIP()
+	}
+	return utils.HashIP(dbKey, visitor)
+}
@@ -2,23 +2,40 @@ package main
 
 import (
 	"bufio"
+	"bytes"
+	"compress/gzip"
 	"context"
+	"crypto/ed25519"
 	"errors"
 	"fmt"
+	"io"
+	"log/slog"
+	"math"
 	"net"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
+	"github.com/alicebob/miniredis/v2"
 	"github.com/redis/go-redis/v9"
 
+	"github.com/jasonlovesdoggo/abacus/middleware"
+	"github.com/jasonlovesdoggo/abacus/pb"
 	"github.com/jasonlovesdoggo/abacus/utils"
 
 	"github.com/goccy/go-json"
+	"google.golang.org/protobuf/proto"
 
 	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/gorilla/websocket"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -118,6 +135,27 @@ func TestCreateView(t *testing.T) {
 		assert.Equal(t, http.StatusCreated, w1.Code)
 		assert.Equal(t, http.StatusConflict, w2.Code)
 	})
+
+	t.Run("Created flag", func(t *testing.T) {
+		w1 := httptest.NewRecorder()
+		req1, _ := http.NewRequest("POST", "/create/test/created_key?initializer=5", nil)
+		r.ServeHTTP(w1, req1)
+
+		var response map[string]interface{}
+		json.Unmarshal(w1.Body.Bytes(), &response)
+		assert.Equal(t, true, response["created"])
+
+		// the existing value is returned untouched, without the admin key
+		w2 := httptest.NewRecorder()
+		req2, _ := http.NewRequest("POST", "/create/test/created_key?initializer=9", nil)
+		r.ServeHTTP(w2, req2)
+
+		response = nil
+		json.Unmarshal(w2.Body.Bytes(), &response)
+		assert.Equal(t, false, response["created"])
+		assert.Equal(t, float64(5), response["value"])
+		assert.NotContains(t, response, "admin_key")
+	})
 }
 
 func TestHitView(t *testing.T) {
@@ -159,6 +197,124 @@ func TestHitView(t *testing.T) {
 
 		assert.Equal(t, float64(7), response["value"])
 	})
+
+	t.Run("Hit with custom step", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/hit/test/hit_key?step=5", nil)
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var response map[string]interface{}
+		err := json.Unmarshal(w.Body.Bytes(), &response)
+		assert.NoError(t, err)
+
+		assert.Equal(t, float64(12), response["value"])
+	})
+
+	t.Run("Hit with negative step", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/hit/test/hit_key?step=-2", nil)
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var response map[string]interface{}
+		err := json.Unmarshal(w.Body.Bytes(), &response)
+		assert.NoError(t, err)
+
+		assert.Equal(t, float64(10), response["value"])
+	})
+
+	t.Run("Hit with invalid step", func(t *testing.T) {
+		for _, step := range []string{"abc", "0"} {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", "/hit/test/hit_key?step="+step, nil)
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+		}
+	})
+
+	t.Run("Hit integer counter with decimal step", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/hit/test/hit_key?step=1.5", nil)
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusConflict, w.Code)
+	})
+}
+
+func TestFloatCounter(t *testing.T) {
+	r := setupTestRouter()
+
+	createW := httptest.NewRecorder()
+	createReq, _ := http.NewRequest("POST", "/create/test/float_key?type=float&initializer=1.25", nil)
+	r.ServeHTTP(createW, createReq)
+	assert.Equal(t, http.StatusCreated, createW.Code)
+
+	var createResponse map[string]interface{}
+	json.Unmarshal(createW.Body.Bytes(), &createResponse)
+	adminToken := createResponse["admin_key"].(string)
+	assert.Equal(t, 1.25, createResponse["value"])
+
+	t.Run("Hit float counter with decimal step", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/hit/test/float_key?step=0.5", nil)
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var response map[string]interface{}
+		err := json.Unmarshal(w.Body.Bytes(), &response)
+		assert.NoError(t, err)
+
+		assert.Equal(t, 1.75, response["value"])
+	})
+
+	t.Run("Update float counter", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/update/test/float_key?value=-0.25", nil)
+		req.Header.Set("Authorization", "Bearer "+adminToken)
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	t.Run("Get and info report the decimal value", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/get/test/float_key", nil)
+		r.ServeHTTP(w, req)
+
+		var response map[string]interface{}
+		json.Unmarshal(w.Body.Bytes(), &response)
+		assert.Equal(t, 1.5, response["value"])
+
+		infoW := httptest.NewRecorder()
+		infoReq, _ := http.NewRequest("GET", "/info/test/float_key", nil)
+		r.ServeHTTP(infoW, infoReq)
+
+		var infoResponse map[string]interface{}
+		json.Unmarshal(infoW.Body.Bytes(), &infoResponse)
+		assert.Equal(t, 1.5, infoResponse["value"])
+		assert.Equal(t, "float", infoResponse["type"])
+	})
+
+	t.Run("Stream float counter", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/stream/test/float_key", nil)
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusConflict, w.Code)
+	})
+
+	t.Run("Create with unknown type", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/create/test/bad_type_key?type=decimal", nil)
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
 }
 
 func TestGetView(t *testing.T) {
@@ -280,6 +436,15 @@ func TestStatsView(t *testing.T) {
 	assert.Equal(t, float64(300), commands["hit"])
 	assert.Equal(t, float64(1000), commands["total"]) // Note: JSON numbers are unmarshaled as float64
 	assert.Equal(t, Version, responseData["version"])
+	assert.Equal(t, "UTC", responseData["timezone"])
+
+	pool := responseData["db_pool"].(map[string]interface{})
+	for _, field := range []string{"total_conns", "idle_conns", "stale_conns", "hits", "misses", "timeouts"} {
+		assert.Contains(t, pool, field)
+	}
+	assert.GreaterOrEqual(t, pool["total_conns"], float64(1)) // stats were just read through the pool
+	assert.Equal(t, float64(DbNum), responseData["db_num"])
+	assert.Contains(t, responseData, "shard")
 }
 func TestDeleteView(t *testing.T) {
 	r := setupTestRouter()
@@ -307,6 +472,28 @@ func TestDeleteView(t *testing.T) {
 		assert.Equal(t, int64(0), exists)
 	})
 
+	t.Run("Delete with the DELETE method", func(t *testing.T) {
+		for i, path := range []string{"/delete/test/delete_method_key", "/test/delete_method_key"} {
+			createW := httptest.NewRecorder()
+			createReq, _ := http.NewRequest("POST", "/create/test/delete_method_key", nil)
+			r.ServeHTTP(createW, createReq)
+			assert.Equal(t, http.StatusCreated, createW.Code)
+			var createResponse map[string]interface{}
+			json.Unmarshal(createW.Body.Bytes(), &createResponse)
+
+			unauthorizedW := httptest.NewRecorder()
+			unauthorizedReq, _ := http.NewRequest("DELETE", path, nil)
+			r.ServeHTTP(unauthorizedW, unauthorizedReq)
+			assert.Equal(t, http.StatusBadRequest, unauthorizedW.Code, "path %d", i)
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("DELETE", path, nil)
+			req.Header.Set("Authorization", "Bearer "+createResponse["admin_key"].(string))
+			r.ServeHTTP(w, req)
+			assert.Equal(t, http.StatusOK, w.Code, "path %d", i)
+			assert.Equal(t, int64(0), Client.Exists(context.Background(), "K:test:delete_method_key").Val(), "path %d", i)
+		}
+	})
 }
 
 func TestSetView(t *testing.T) {
@@ -338,6 +525,37 @@ func TestSetView(t *testing.T) {
 		assert.Equal(t, 42, val)
 	})
 
+	t.Run("Compare and set", func(t *testing.T) {
+		createW := httptest.NewRecorder()
+		createReq, _ := http.NewRequest("POST", "/create/test/cas_key?initializer=10", nil)
+		r.ServeHTTP(createW, createReq)
+
+		var createResponse map[string]interface{}
+		json.Unmarshal(createW.Body.Bytes(), &createResponse)
+		adminToken := createResponse["admin_key"].(string)
+
+		// wrong expectation, the value must stay untouched
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/set/test/cas_key?value=20&expected=9", nil)
+		req.Header.Set("Authorization", "Bearer "+adminToken)
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusConflict, w.Code)
+
+		var response map[string]interface{}
+		json.Unmarshal(w.Body.Bytes(), &response)
+		assert.Equal(t, float64(10), response["value"])
+
+		w = httptest.NewRecorder()
+		req, _ = http.NewRequest("POST", "/set/test/cas_key?value=20&expected=10", nil)
+		req.Header.Set("Authorization", "Bearer "+adminToken)
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		val, _ := Client.Get(context.Background(), "K:test:cas_key").Int()
+		assert.Equal(t, 20, val)
+		assert.Greater(t, Client.TTL(context.Background(), "K:test:cas_key").Val(), time.Duration(0))
+	})
+
 }
 
 func TestResetView(t *testing.T) {
@@ -394,6 +612,51 @@ func TestResetView(t *testing.T) {
 		// Should get an unauthorized error (or whichever error your Auth middleware returns)
 		assert.NotEqual(t, http.StatusOK, w.Code) // Assert it's not 200 OK
 	})
+
+	t.Run("Reset to a custom value", func(t *testing.T) {
+		createW := httptest.NewRecorder()
+		createReq, _ := http.NewRequest("POST", "/create/test/reset_value_key?initializer=50&min=10&max=1000", nil)
+		r.ServeHTTP(createW, createReq)
+		var createResponse map[string]interface{}
+		json.Unmarshal(createW.Body.Bytes(), &createResponse)
+		adminToken := createResponse["admin_key"].(string)
+
+		reset := func(query string) (int, map[string]interface{}) {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("POST", "/reset/test/reset_value_key?"+query, nil)
+			req.Header.Set("Authorization", "Bearer "+adminToken)
+			r.ServeHTTP(w, req)
+			var response map[string]interface{}
+			json.Unmarshal(w.Body.Bytes(), &response)
+			return w.Code, response
+		}
+
+		code, response := reset("value=100")
+		assert.Equal(t, http.StatusOK, code)
+		assert.Equal(t, float64(100), response["value"])
+		assert.Equal(t, "100", Client.Get(context.Background(), "K:test:reset_value_key").Val())
+
+		for _, value := range []string{"1001", "9", "1.5", "abc"} {
+			code, _ = reset("value=" + value)
+			assert.NotEqual(t, http.StatusOK, code, value)
+		}
+		assert.Equal(t, "100", Client.Get(context.Background(), "K:test:reset_value_key").Val())
+	})
+
+	t.Run("Reset a float counter to a custom value", func(t *testing.T) {
+		createW := httptest.NewRecorder()
+		createReq, _ := http.NewRequest("POST", "/create/test/reset_float_key?type=float&initializer=2.5", nil)
+		r.ServeHTTP(createW, createReq)
+		var createResponse map[string]interface{}
+		json.Unmarshal(createW.Body.Bytes(), &createResponse)
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/reset/test/reset_float_key?value=0.25", nil)
+		req.Header.Set("Authorization", "Bearer "+createResponse["admin_key"].(string))
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "0.25", Client.Get(context.Background(), "K:test:reset_float_key").Val())
+	})
 }
 
 func TestUpdateByView(t *testing.T) {
@@ -445,8 +708,10 @@ func TestUpdateByView(t *testing.T) {
 		req.Header.Set("Authorization", "Bearer "+adminToken)
 		r.ServeHTTP(w, req)
 
+		// the token of another counter is refused before the view runs
 		assert.Equal(t, http.StatusBadRequest, w.Code)
-		assert.Contains(t, w.Body.String(), "Key does not exist")
+		assert.Contains(t, w.Body.String(), "does not have an admin key")
+		assert.Zero(t, Client.Exists(context.Background(), "K:test:nonexistent_update_key").Val())
 	})
 
 	t.Run("Update without admin token", func(t *testing.T) {
@@ -467,6 +732,47 @@ func TestUpdateByView(t *testing.T) {
 		assert.Equal(t, beforeUpdateVal, val)
 
 	})
+
+	t.Run("Update with the delta in the body", func(t *testing.T) {
+		createW := httptest.NewRecorder()
+		createReq, _ := http.NewRequest("POST", "/create/test/update_body_key?initializer=10", nil)
+		r.ServeHTTP(createW, createReq)
+		var createResponse map[string]interface{}
+		json.Unmarshal(createW.Body.Bytes(), &createResponse)
+		adminToken := createResponse["admin_key"].(string)
+
+		update := func(contentType, body string) *httptest.ResponseRecorder {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("POST", "/update/test/update_body_key", strings.NewReader(body))
+			req.Header.Set("Content-Type", contentType)
+			req.Header.Set("Authorization", "Bearer "+adminToken)
+			r.ServeHTTP(w, req)
+			return w
+		}
+
+		w := update("application/json", `{"delta": -5}`)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.JSONEq(t, `{"value": 5}`, w.Body.String())
+
+		w = update("application/x-www-form-urlencoded", "delta=7")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.JSONEq(t, `{"value": 12}`, w.Body.String())
+
+		w = update("application/json", `{"delta": 1.5}`) // integer counters can't take decimals
+		assert.Equal(t, http.StatusConflict, w.Code)
+		assert.Contains(t, w.Body.String(), "delta")
+
+		w = update("application/json", `{"delta": `)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), "Invalid body")
+
+		w = update("application/json", `{}`)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), "value is required")
+
+		val, _ := Client.Get(context.Background(), "K:test:update_body_key").Int()
+		assert.Equal(t, 12, val)
+	})
 }
 
 func TestStreamValueView(t *testing.T) {
@@ -493,6 +799,9 @@ func TestStreamValueView(t *testing.T) {
 		time.Sleep(100 * time.Millisecond)
 
 		assert.Contains(t, w.Header().Get("Content-Type"), "text/event-stream")
+		assert.Equal(t, "no-cache", w.Header().Get("Cache-Control"))
+		assert.Equal(t, "keep-alive", w.Header().Get("Connection"))
+		assert.Equal(t, "no", w.Header().Get("X-Accel-Buffering"))
 
 		// Hit the key to generate updates
 		hitReq, _ := http.NewRequest("GET", "/hit/test/stream_key", nil)
@@ -513,4 +822,4635 @@ func TestStreamValueView(t *testing.T) {
 		case <-time.After(1 * time.Second): // Ensure test doesn't hang forever
 		}
 	})
+
+	t.Run("Initial value is sent on connect", func(t *testing.T) {
+		createW := httptest.NewRecorder()
+		createReq, _ := http.NewRequest("POST", "/create/test/stream_initial_key?initializer=12", nil)
+		r.ServeHTTP(createW, createReq)
+
+		for path, expected := range map[string]string{
+			"/stream/test/stream_initial_key": "data: {\"value\":12}\n\n",
+			"/stream/test/stream_missing_key": "data: {\"value\":0}\n\n",
+		} {
+			w := newMockResponseWriter()
+			ctx, cancel := context.WithCancel(context.Background())
+			req, _ := http.NewRequestWithContext(ctx, "GET", path, nil)
+			go r.ServeHTTP(w, req)
+
+			time.Sleep(50 * time.Millisecond)
+			assert.Equal(t, expected, w.Body.String(), path)
+			cancel()
+		}
+		// reading the initial value doesn't create the key
+		assert.Equal(t, int64(0), Client.Exists(context.Background(), "K:test:stream_missing_key").Val())
+	})
+}
+
+func TestBadgeView(t *testing.T) {
+	r := setupTestRouter()
+
+	t.Run("Badge for existing key", func(t *testing.T) {
+		createW := httptest.NewRecorder()
+		createReq, _ := http.NewRequest("POST", "/create/test/badge_key?initializer=1337", nil)
+		r.ServeHTTP(createW, createReq)
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/badge/test/badge_key?label=views&color=ff69b4&style=plastic", nil)
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "image/svg+xml", w.Header().Get("Content-Type"))
+		assert.Equal(t, "max-age=60", w.Header().Get("Cache-Control"))
+		assert.Contains(t, w.Body.String(), ">views<")
+		assert.Contains(t, w.Body.String(), ">1337<")
+		assert.Contains(t, w.Body.String(), "#ff69b4")
+	})
+
+	t.Run("Badge for non-existent key", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/badge/test/nonexistent_badge_key", nil)
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), ">not found<")
+	})
+
+	t.Run("Badge with invalid style", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/badge/test/badge_key?style=3d", nil)
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+}
+
+func TestHitBatchView(t *testing.T) {
+	r := setupTestRouter()
+
+	t.Run("Hit multiple keys", func(t *testing.T) {
+		createW := httptest.NewRecorder()
+		createReq, _ := http.NewRequest("POST", "/create/test/batch_key_a?initializer=10", nil)
+		r.ServeHTTP(createW, createReq)
+
+		w := httptest.NewRecorder()
+		body := `{"keys":[{"namespace":"test","key":"batch_key_a"},{"namespace":"test","key":"batch_key_b"}]}`
+		req, _ := http.NewRequest("POST", "/hit-batch", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var response []map[string]interface{}
+		err := json.Unmarshal(w.Body.Bytes(), &response)
+		assert.NoError(t, err)
+
+		assert.Len(t, response, 2)
+		assert.Equal(t, "batch_key_a", response[0]["key"])
+		assert.Equal(t, float64(11), response[0]["value"])
+		assert.Equal(t, "batch_key_b", response[1]["key"])
+		assert.Equal(t, float64(1), response[1]["value"])
+	})
+
+	t.Run("Invalid keys don't fail the batch", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		body := `{"keys":[{"namespace":"test","key":"batch_key_a"},{"namespace":"test","key":"a$"}]}`
+		req, _ := http.NewRequest("POST", "/hit-batch", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		var response []map[string]interface{}
+		json.Unmarshal(w.Body.Bytes(), &response)
+		assert.Len(t, response, 2)
+		assert.Equal(t, float64(12), response[0]["value"])
+		assert.NotContains(t, response[0], "error")
+		assert.Equal(t, "a$", response[1]["key"])
+		assert.Contains(t, response[1], "error")
+		assert.NotContains(t, response[1], "value")
+
+		val, _ := Client.Get(context.Background(), "K:test:batch_key_a").Int()
+		assert.Equal(t, 12, val) // the valid entry was still applied
+	})
+
+	t.Run("Steps per entry", func(t *testing.T) {
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "/create/batch_step_ns/decimal?type=float", nil))
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "/create/batch_step_ns/capped?max=10&initializer=8", nil))
+		w := httptest.NewRecorder()
+		body := `{"keys":[{"namespace":"batch_step_ns","key":"views","step":25},{"namespace":"batch_step_ns","key":"decimal","step":1.5},` +
+			`{"namespace":"batch_step_ns","key":"views","step":-5},{"namespace":"batch_step_ns","key":"views","step":0.5},` +
+			`{"namespace":"batch_step_ns","key":"views","step":0},{"namespace":"batch_step_ns","key":"capped","step":5}]}`
+		req, _ := http.NewRequest("POST", "/hit-batch", strings.NewReader(body))
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var response []map[string]interface{}
+		json.Unmarshal(w.Body.Bytes(), &response)
+		assert.Len(t, response, 6)
+		assert.Equal(t, float64(25), response[0]["value"])
+		assert.Equal(t, 1.5, response[1]["value"])
+		assert.Equal(t, float64(20), response[2]["value"])
+		assert.Contains(t, response[3]["error"], "integer")
+		assert.Contains(t, response[4]["error"], "0 does nothing")
+		assert.Equal(t, float64(8), response[5]["value"])
+		assert.Contains(t, response[5]["error"], "max value of 10")
+		val, _ := Client.Get(context.Background(), "K:batch_step_ns:views").Int()
+		assert.Equal(t, 20, val)
+	})
+
+	t.Run("Reject empty batch", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/hit-batch", strings.NewReader(`{"keys":[]}`))
+		req.Header.Set("Content-Type", "application/json")
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+}
+
+func TestCustomTTL(t *testing.T) {
+	r := setupTestRouter()
+
+	t.Run("Create key with custom ttl", func(t *testing.T) {
+		createW := httptest.NewRecorder()
+		createReq, _ := http.NewRequest("POST", "/create/test/ttl_key?ttl=3600", nil)
+		r.ServeHTTP(createW, createReq)
+		assert.Equal(t, http.StatusCreated, createW.Code)
+
+		var createResponse map[string]interface{}
+		json.Unmarshal(createW.Body.Bytes(), &createResponse)
+		adminToken := createResponse["admin_key"].(string)
+
+		// neither hitting nor setting the counter should push the expiry back to the default
+		hitW := httptest.NewRecorder()
+		hitReq, _ := http.NewRequest("GET", "/hit/test/ttl_key", nil)
+		r.ServeHTTP(hitW, hitReq)
+
+		setW := httptest.NewRecorder()
+		setReq, _ := http.NewRequest("POST", "/set/test/ttl_key?value=5", nil)
+		setReq.Header.Set("Authorization", "Bearer "+adminToken)
+		r.ServeHTTP(setW, setReq)
+		assert.Equal(t, http.StatusOK, setW.Code)
+		time.Sleep(50 * time.Millisecond) // expiry refreshes happen in the background
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/info/test/ttl_key", nil)
+		r.ServeHTTP(w, req)
+
+		var response map[string]interface{}
+		err := json.Unmarshal(w.Body.Bytes(), &response)
+		assert.NoError(t, err)
+
+		assert.Equal(t, float64(3600), response["ttl"])
+		assert.False(t, response["refresh_ttl"].(bool))
+		assert.LessOrEqual(t, response["expires_in"], float64(3600))
+		assert.Greater(t, response["expires_in"], float64(0))
+	})
+
+	t.Run("Create key without expiry", func(t *testing.T) {
+		createW := httptest.NewRecorder()
+		createReq, _ := http.NewRequest("POST", "/create/test/no_ttl_key?ttl=0", nil)
+		r.ServeHTTP(createW, createReq)
+		assert.Equal(t, http.StatusCreated, createW.Code)
+
+		hitW := httptest.NewRecorder()
+		hitReq, _ := http.NewRequest("GET", "/hit/test/no_ttl_key", nil)
+		r.ServeHTTP(hitW, hitReq)
+		time.Sleep(50 * time.Millisecond)
+
+		assert.Equal(t, time.Duration(-1), Client.TTL(context.Background(), "K:test:no_ttl_key").Val())
+	})
+
+	t.Run("Create key with sliding ttl", func(t *testing.T) {
+		createW := httptest.NewRecorder()
+		createReq, _ := http.NewRequest("POST", "/create/test/sliding_ttl_key?ttl=3600&refresh_ttl=true", nil)
+		r.ServeHTTP(createW, createReq)
+		assert.Equal(t, http.StatusCreated, createW.Code)
+
+		// pretend most of the ttl has passed
+		Client.Expire(context.Background(), "K:test:sliding_ttl_key", 10*time.Second)
+
+		hitW := httptest.NewRecorder()
+		hitReq, _ := http.NewRequest("GET", "/hit/test/sliding_ttl_key", nil)
+		r.ServeHTTP(hitW, hitReq)
+		assert.Equal(t, http.StatusOK, hitW.Code)
+
+		assert.Equal(t, time.Hour, Client.TTL(context.Background(), "K:test:sliding_ttl_key").Val())
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/info/test/sliding_ttl_key", nil)
+		r.ServeHTTP(w, req)
+
+		var response map[string]interface{}
+		json.Unmarshal(w.Body.Bytes(), &response)
+		assert.True(t, response["refresh_ttl"].(bool))
+	})
+
+	t.Run("Hits refresh the expiry of the metadata too", func(t *testing.T) {
+		ctx := context.Background()
+		createW := httptest.NewRecorder()
+		createReq, _ := http.NewRequest("POST", "/create/test/default_ttl_key", nil)
+		r.ServeHTTP(createW, createReq)
+		assert.Equal(t, http.StatusCreated, createW.Code)
+
+		// pretend most of the ttl has passed
+		Client.Expire(ctx, "K:test:default_ttl_key", 10*time.Second)
+		Client.Expire(ctx, "M:test:default_ttl_key", 10*time.Second)
+
+		hitW := httptest.NewRecorder()
+		hitReq, _ := http.NewRequest("GET", "/hit/test/default_ttl_key", nil)
+		r.ServeHTTP(hitW, hitReq)
+		assert.Equal(t, http.StatusOK, hitW.Code)
+		assert.Equal(t, utils.BaseTTLPeriod, Client.TTL(ctx, "K:test:default_ttl_key").Val())
+		assert.Equal(t, utils.BaseTTLPeriod, Client.TTL(ctx, "M:test:default_ttl_key").Val())
+	})
+
+	t.Run("Create key with invalid ttl", func(t *testing.T) {
+		for _, ttl := range []string{"-1", "abc", "999999999999"} {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("POST", "/create/test/bad_ttl_key?ttl="+ttl, nil)
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+		}
+	})
+}
+
+func TestPlainTextFormat(t *testing.T) {
+	r := setupTestRouter()
+
+	createW := httptest.NewRecorder()
+	createReq, _ := http.NewRequest("POST", "/create/test/text_key?initializer=41", nil)
+	r.ServeHTTP(createW, createReq)
+
+	t.Run("Hit with format query", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/hit/test/text_key?format=text", nil)
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Header().Get("Content-Type"), "text/plain")
+		assert.Equal(t, "42", w.Body.String())
+	})
+
+	t.Run("Get with Accept header", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/get/test/text_key", nil)
+		req.Header.Set("Accept", "text/plain")
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "42", w.Body.String())
+	})
+
+	t.Run("Get defaults to JSON", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/get/test/text_key", nil)
+		req.Header.Set("Accept", "*/*")
+		r.ServeHTTP(w, req)
+
+		assert.Contains(t, w.Header().Get("Content-Type"), "application/json")
+		assert.Equal(t, `{"value":42}`, w.Body.String())
+	})
+}
+
+func TestMetrics(t *testing.T) {
+	os.Setenv("METRICS_ENABLED", "true")
+	defer os.Unsetenv("METRICS_ENABLED")
+	r := setupTestRouter()
+
+	createW := httptest.NewRecorder()
+	createReq, _ := http.NewRequest("POST", "/create/test/metrics_key?initializer=5", nil)
+	r.ServeHTTP(createW, createReq)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/metrics", nil)
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `abacus_counter{key="metrics_key",namespace="test"} 5`)
+	assert.Contains(t, w.Body.String(), "abacus_requests_total")
+	assert.Contains(t, w.Body.String(), "abacus_rate_limited_total")
+	assert.Contains(t, w.Body.String(), "abacus_redis_errors_total")
+}
+
+func TestCounterMax(t *testing.T) {
+	r := setupTestRouter()
+
+	createW := httptest.NewRecorder()
+	createReq, _ := http.NewRequest("POST", "/create/test/max_key?initializer=8&max=10", nil)
+	r.ServeHTTP(createW, createReq)
+	assert.Equal(t, http.StatusCreated, createW.Code)
+
+	var createResponse map[string]interface{}
+	json.Unmarshal(createW.Body.Bytes(), &createResponse)
+	adminToken := createResponse["admin_key"].(string)
+
+	t.Run("Hit up to the max", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/hit/test/max_key?step=2", nil)
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var response map[string]interface{}
+		json.Unmarshal(w.Body.Bytes(), &response)
+		assert.Equal(t, float64(10), response["value"])
+	})
+
+	t.Run("Hit past the max", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/hit/test/max_key", nil)
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusConflict, w.Code)
+
+		var response map[string]interface{}
+		json.Unmarshal(w.Body.Bytes(), &response)
+		assert.Equal(t, float64(10), response["value"])
+
+		val, _ := Client.Get(context.Background(), "K:test:max_key").Int()
+		assert.Equal(t, 10, val)
+	})
+
+	t.Run("Update past the max", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/update/test/max_key?value=5", nil)
+		req.Header.Set("Authorization", "Bearer "+adminToken)
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusConflict, w.Code)
+
+		w = httptest.NewRecorder()
+		req, _ = http.NewRequest("POST", "/update/test/max_key?value=-5", nil)
+		req.Header.Set("Authorization", "Bearer "+adminToken)
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	t.Run("Batch hit respects the max", func(t *testing.T) {
+		Client.Set(context.Background(), "K:test:max_key", 10, 0)
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/hit-batch", strings.NewReader(`{"keys":[{"namespace":"test","key":"max_key"}]}`))
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var response []map[string]interface{}
+		json.Unmarshal(w.Body.Bytes(), &response)
+		assert.Equal(t, float64(10), response[0]["value"])
+		assert.Contains(t, response[0], "error")
+	})
+
+	t.Run("Info reports the max", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/info/test/max_key", nil)
+		r.ServeHTTP(w, req)
+
+		var response map[string]interface{}
+		json.Unmarshal(w.Body.Bytes(), &response)
+		assert.Equal(t, float64(10), response["value"])
+		assert.Equal(t, float64(10), response["max"])
+	})
+
+	t.Run("Max above 2^53", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/create/test/large_max_key?initializer=9007199254740992&max=9007199254740992", nil)
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusCreated, w.Code)
+
+		// 2^53 + 1 is 2^53 as a double
+		w = httptest.NewRecorder()
+		req, _ = http.NewRequest("GET", "/hit/test/large_max_key", nil)
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusConflict, w.Code)
+		assert.Equal(t, "9007199254740992", Client.Get(context.Background(), "K:test:large_max_key").Val())
+	})
+
+	t.Run("Hits recreating the counter give it its expiry", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/create/test/expiring_max_key?max=10&ttl=3600", nil)
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusCreated, w.Code)
+		Client.Del(context.Background(), "K:test:expiring_max_key")
+
+		w = httptest.NewRecorder()
+		req, _ = http.NewRequest("GET", "/hit/test/expiring_max_key", nil)
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, time.Hour, Client.TTL(context.Background(), "K:test:expiring_max_key").Val())
+	})
+
+	t.Run("Invalid max", func(t *testing.T) {
+		for _, query := range []string{"max=abc", "max=1.5", "initializer=20&max=10"} {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("POST", "/create/test/bad_max_key?"+query, nil)
+			r.ServeHTTP(w, req)
+			assert.Equal(t, http.StatusBadRequest, w.Code, query)
+		}
+	})
+}
+
+func TestCounterMin(t *testing.T) {
+	r := setupTestRouter()
+
+	t.Run("Decrements clamp at the min", func(t *testing.T) {
+		createW := httptest.NewRecorder()
+		createReq, _ := http.NewRequest("POST", "/create/test/min_key?initializer=3&min=0", nil)
+		r.ServeHTTP(createW, createReq)
+		assert.Equal(t, http.StatusCreated, createW.Code)
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/hit/test/min_key?step=-2", nil)
+		r.ServeHTTP(w, req)
+		var response map[string]interface{}
+		json.Unmarshal(w.Body.Bytes(), &response)
+		assert.Equal(t, float64(1), response["value"])
+		assert.NotContains(t, response, "clamped")
+
+		w = httptest.NewRecorder()
+		req, _ = http.NewRequest("GET", "/hit/test/min_key?step=-2", nil)
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+		response = nil
+		json.Unmarshal(w.Body.Bytes(), &response)
+		assert.Equal(t, float64(0), response["value"])
+		assert.Equal(t, true, response["clamped"])
+
+		val, _ := Client.Get(context.Background(), "K:test:min_key").Int()
+		assert.Equal(t, 0, val)
+	})
+
+	t.Run("Decrements past the min are rejected", func(t *testing.T) {
+		createW := httptest.NewRecorder()
+		createReq, _ := http.NewRequest("POST", "/create/test/min_reject_key?initializer=1&min=0&min_mode=reject", nil)
+		r.ServeHTTP(createW, createReq)
+		var createResponse map[string]interface{}
+		json.Unmarshal(createW.Body.Bytes(), &createResponse)
+		adminToken := createResponse["admin_key"].(string)
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/update/test/min_reject_key?value=-2", nil)
+		req.Header.Set("Authorization", "Bearer "+adminToken)
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusConflict, w.Code)
+
+		var response map[string]interface{}
+		json.Unmarshal(w.Body.Bytes(), &response)
+		assert.Equal(t, float64(1), response["value"])
+	})
+
+	t.Run("Float counters clamp too", func(t *testing.T) {
+		createW := httptest.NewRecorder()
+		createReq, _ := http.NewRequest("POST", "/create/test/min_float_key?type=float&initializer=0.5&min=0.25", nil)
+		r.ServeHTTP(createW, createReq)
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/hit/test/min_float_key?step=-1", nil)
+		r.ServeHTTP(w, req)
+		var response map[string]interface{}
+		json.Unmarshal(w.Body.Bytes(), &response)
+		assert.Equal(t, 0.25, response["value"])
+		assert.Equal(t, true, response["clamped"])
+	})
+
+	t.Run("Invalid min", func(t *testing.T) {
+		for _, query := range []string{"min=abc", "initializer=-1&min=0", "min=0&min_mode=wrap"} {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("POST", "/create/test/bad_min_key?"+query, nil)
+			r.ServeHTTP(w, req)
+			assert.Equal(t, http.StatusBadRequest, w.Code, query)
+		}
+	})
+}
+
+func TestWebhookView(t *testing.T) {
+	utils.AllowPrivateWebhooks = true // the test server listens on loopback
+	defer func() { utils.AllowPrivateWebhooks = false }()
+	r := setupTestRouter()
+
+	payloads := make(chan utils.WebhookPayload, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		var payload utils.WebhookPayload
+		json.NewDecoder(req.Body).Decode(&payload)
+		payloads <- payload
+	}))
+	defer server.Close()
+
+	createW := httptest.NewRecorder()
+	createReq, _ := http.NewRequest("POST", "/create/test/webhook_key?initializer=8", nil)
+	r.ServeHTTP(createW, createReq)
+	var createResponse map[string]interface{}
+	json.Unmarshal(createW.Body.Bytes(), &createResponse)
+	adminToken := createResponse["admin_key"].(string)
+
+	t.Run("Register webhook", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/webhook/test/webhook_key", strings.NewReader(`{"url":"`+server.URL+`","every":10}`))
+		req.Header.Set("Authorization", "Bearer "+adminToken)
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	t.Run("Crossing the threshold calls the webhook", func(t *testing.T) {
+		for i := 0; i < 3; i++ { // 9, 10, 11
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", "/hit/test/webhook_key", nil)
+			r.ServeHTTP(w, req)
+			assert.Equal(t, http.StatusOK, w.Code)
+		}
+
+		select {
+		case payload := <-payloads:
+			sentAt, err := time.Parse(time.RFC3339, payload.Timestamp)
+			assert.NoError(t, err)
+			assert.WithinDuration(t, time.Now(), sentAt, 5*time.Second)
+			payload.Timestamp = ""
+			assert.Equal(t, utils.WebhookPayload{Namespace: "test", Key: "webhook_key", Value: float64(10)}, payload)
+		case <-time.After(2 * time.Second):
+			t.Fatal("webhook was not called")
+		}
+		select {
+		case payload := <-payloads:
+			t.Fatalf("webhook called again with %v", payload)
+		case <-time.After(100 * time.Millisecond):
+		}
+	})
+
+	t.Run("Batched hits call the webhook too", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/hit-batch", strings.NewReader(`{"keys":[{"namespace":"test","key":"webhook_key","step":9}]}`)) // 11 to 20
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		select {
+		case payload := <-payloads:
+			assert.Equal(t, "webhook_key", payload.Key)
+			assert.Equal(t, float64(20), payload.Value)
+		case <-time.After(2 * time.Second):
+			t.Fatal("webhook was not called")
+		}
+	})
+
+	t.Run("Invalid webhooks", func(t *testing.T) {
+		utils.AllowPrivateWebhooks = false
+		defer func() { utils.AllowPrivateWebhooks = true }()
+		for _, body := range []string{`{"url":"ftp://example.com","every":10}`, `{"url":"` + server.URL + `","every":0}`, `nope`,
+			`{"url":"http://169.254.169.254/latest/meta-data","every":10}`, `{"url":"` + server.URL + `","every":10}`} {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("POST", "/webhook/test/webhook_key", strings.NewReader(body))
+			req.Header.Set("Authorization", "Bearer "+adminToken)
+			r.ServeHTTP(w, req)
+			assert.Equal(t, http.StatusBadRequest, w.Code, body)
+		}
+	})
+}
+
+func TestListView(t *testing.T) {
+	r := setupTestRouter()
+
+	for _, key := range []string{"page_home", "page_about", "other"} {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/create/listtest/"+key+"?initializer=3", nil)
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusCreated, w.Code)
+	}
+
+	list := func(query string) map[string]interface{} {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/list/listtest"+query, nil)
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var response map[string]interface{}
+		json.Unmarshal(w.Body.Bytes(), &response)
+		return response
+	}
+
+	t.Run("List all keys", func(t *testing.T) {
+		response := list("")
+		assert.Len(t, response["keys"], 3)
+		assert.Equal(t, "0", response["cursor"])
+	})
+
+	t.Run("List keys with a prefix", func(t *testing.T) {
+		keys := map[string]interface{}{}
+		for _, entry := range list("?prefix=page_")["keys"].([]interface{}) {
+			entry := entry.(map[string]interface{})
+			keys[entry["key"].(string)] = entry["value"]
+		}
+		assert.Equal(t, map[string]interface{}{"page_home": float64(3), "page_about": float64(3)}, keys)
+	})
+
+	t.Run("Invalid parameters", func(t *testing.T) {
+		for _, path := range []string{"/list/listtest?prefix=pa*", "/list/listtest?cursor=abc", "/list/ab"} {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", path, nil)
+			r.ServeHTTP(w, req)
+			assert.Equal(t, http.StatusBadRequest, w.Code, path)
+		}
+	})
+}
+
+func TestNamespaceToken(t *testing.T) {
+	r := setupTestRouter()
+
+	claim := func(token string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/namespace/nstoken/token", nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	w := claim("")
+	assert.Equal(t, http.StatusCreated, w.Code)
+	var claimResponse map[string]interface{}
+	json.Unmarshal(w.Body.Bytes(), &claimResponse)
+	namespaceToken := claimResponse["admin_key"].(string)
+
+	// the token is only stored hashed
+	stored := Client.HGet(context.Background(), "N:nstoken", "admin_token").Val()
+	assert.NotEqual(t, namespaceToken, stored)
+	assert.Equal(t, utils.HashToken(namespaceToken), stored)
+
+	createW := httptest.NewRecorder()
+	createReq, _ := http.NewRequest("POST", "/create/nstoken/counter?initializer=5", nil)
+	r.ServeHTTP(createW, createReq)
+	assert.Equal(t, http.StatusCreated, createW.Code)
+	var createResponse map[string]interface{}
+	json.Unmarshal(createW.Body.Bytes(), &createResponse)
+	keyToken := createResponse["admin_key"].(string)
+
+	t.Run("Namespace token authorizes its counters", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/set/nstoken/counter?value=7", nil)
+		req.Header.Set("Authorization", "Bearer "+namespaceToken)
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	t.Run("Per-key token still works", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/set/nstoken/counter?value=8", nil)
+		req.Header.Set("Authorization", "Bearer "+keyToken)
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	t.Run("Namespace token doesn't authorize other namespaces", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/reset/test/sample_key", nil)
+		req.Header.Set("Authorization", "Bearer "+namespaceToken)
+		r.ServeHTTP(w, req)
+		assert.NotEqual(t, http.StatusOK, w.Code)
+	})
+
+	t.Run("Rotate token", func(t *testing.T) {
+		assert.Equal(t, http.StatusUnauthorized, claim("").Code)
+		assert.Equal(t, http.StatusUnauthorized, claim(keyToken).Code)
+
+		w := claim(namespaceToken)
+		assert.Equal(t, http.StatusOK, w.Code)
+		var response map[string]interface{}
+		json.Unmarshal(w.Body.Bytes(), &response)
+		assert.NotEqual(t, namespaceToken, response["admin_key"])
+
+		// the old token stops working
+		w = httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/set/nstoken/counter?value=9", nil)
+		req.Header.Set("Authorization", "Bearer "+namespaceToken)
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+
+	t.Run("Namespaces with counters can't be claimed", func(t *testing.T) {
+		for _, path := range []string{"/namespace/test/token", "/namespace/default/token"} {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("POST", path, nil)
+			r.ServeHTTP(w, req)
+			assert.Equal(t, http.StatusConflict, w.Code, path)
+		}
+	})
+}
+
+func TestJWTAuth(t *testing.T) {
+	r := setupTestRouter()
+
+	publicKey, privateKey, _ := ed25519.GenerateKey(nil)
+	middleware.JWTKey = publicKey
+	defer func() { middleware.JWTKey = nil }()
+
+	sign := func(claims jwt.MapClaims) string {
+		token, _ := jwt.NewWithClaims(jwt.SigningMethodEdDSA, claims).SignedString(privateKey)
+		return token
+	}
+	set := func(path, token string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", path, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		r.ServeHTTP(w, req)
+		return w
+	}
+	expiry := time.Now().Add(time.Hour).Unix()
+
+	createW := httptest.NewRecorder()
+	createReq, _ := http.NewRequest("POST", "/create/jwttest/counter", nil)
+	r.ServeHTTP(createW, createReq)
+	var createResponse map[string]interface{}
+	json.Unmarshal(createW.Body.Bytes(), &createResponse)
+	adminToken := createResponse["admin_key"].(string)
+
+	t.Run("Scoped token", func(t *testing.T) {
+		token := sign(jwt.MapClaims{"scope": "other jwttest", "exp": expiry})
+		assert.Equal(t, http.StatusOK, set("/set/jwttest/counter?value=3", token).Code)
+	})
+
+	t.Run("Subject token", func(t *testing.T) {
+		token := sign(jwt.MapClaims{"sub": "jwttest", "exp": expiry})
+		assert.Equal(t, http.StatusOK, set("/set/jwttest/counter?value=4", token).Code)
+	})
+
+	t.Run("Token for another namespace", func(t *testing.T) {
+		token := sign(jwt.MapClaims{"scope": "other", "exp": expiry})
+		assert.Equal(t, http.StatusForbidden, set("/set/jwttest/counter?value=5", token).Code)
+	})
+
+	t.Run("Invalid tokens", func(t *testing.T) {
+		_, otherKey, _ := ed25519.GenerateKey(nil)
+		forged, _ := jwt.NewWithClaims(jwt.SigningMethodEdDSA, jwt.MapClaims{"scope": "*", "exp": expiry}).SignedString(otherKey)
+		for _, token := range []string{
+			sign(jwt.MapClaims{"scope": "*", "exp": time.Now().Add(-time.Hour).Unix()}), // expired
+			sign(jwt.MapClaims{"scope": "*"}),                                           // no expiry
+			forged,
+		} {
+			assert.Equal(t, http.StatusUnauthorized, set("/set/jwttest/counter?value=6", token).Code)
+		}
+	})
+
+	t.Run("Admin keys keep working", func(t *testing.T) {
+		assert.Equal(t, http.StatusOK, set("/set/jwttest/counter?value=7", adminToken).Code)
+	})
+}
+
+func TestRateLimitHeaders(t *testing.T) {
+	os.Setenv("RATE_LIMIT_ENABLED", "true")
+	defer os.Unsetenv("RATE_LIMIT_ENABLED")
+	r := setupTestRouter()
+
+	get := func() *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/healthcheck", nil)
+		req.RemoteAddr = "203.0.113.7:1234" // don't share the budget with other tests
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	w := get()
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "30", w.Header().Get("X-RateLimit-Limit"))
+	assert.Equal(t, "29", w.Header().Get("X-RateLimit-Remaining"))
+	assert.NotEmpty(t, w.Header().Get("X-RateLimit-Reset"))
+
+	for i := 0; i < 100 && w.Code != http.StatusTooManyRequests; i++ {
+		w = get()
+	}
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "0", w.Header().Get("X-RateLimit-Remaining"))
+	retryAfter, err := strconv.Atoi(w.Header().Get("Retry-After"))
+	assert.NoError(t, err)
+	assert.Greater(t, retryAfter, 0)
+}
+
+func TestRateLimitTiers(t *testing.T) {
+	os.Setenv("RATE_LIMIT_ENABLED", "true")
+	defer os.Unsetenv("RATE_LIMIT_ENABLED")
+	r := setupTestRouter()
+
+	RateLimitClient.HSet(context.Background(), middleware.CreateTierKey("trusted-integration"), "limit", 2, "window", 60)
+
+	get := func(apiKey string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/healthcheck", nil)
+		req.RemoteAddr = "203.0.113.8:1234"
+		if apiKey != "" {
+			req.Header.Set(middleware.APIKeyHeader, apiKey)
+		}
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	w := get("trusted-integration")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "2", w.Header().Get("X-RateLimit-Limit"))
+	assert.Equal(t, "1", w.Header().Get("X-RateLimit-Remaining"))
+	assert.Equal(t, "2;w=60", w.Header().Get("RateLimit-Policy"))
+
+	assert.Equal(t, http.StatusOK, get("trusted-integration").Code)
+	assert.Equal(t, http.StatusTooManyRequests, get("trusted-integration").Code)
+
+	// unknown keys and requests without a key get the default limit
+	for _, apiKey := range []string{"", "unknown"} {
+		w := get(apiKey)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "30", w.Header().Get("X-RateLimit-Limit"))
+	}
+}
+
+func TestWebSocketView(t *testing.T) {
+	r := setupTestRouter()
+	server := httptest.NewServer(r)
+	defer server.Close()
+
+	createW := httptest.NewRecorder()
+	createReq, _ := http.NewRequest("POST", "/create/test/ws_key?initializer=4", nil)
+	r.ServeHTTP(createW, createReq)
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/ws/test/ws_key", nil)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+
+	var message map[string]interface{}
+	assert.NoError(t, conn.ReadJSON(&message))
+	assert.Equal(t, float64(4), message["value"]) // the current value is sent right away
+
+	hitW := httptest.NewRecorder()
+	hitReq, _ := http.NewRequest("GET", "/hit/test/ws_key", nil)
+	r.ServeHTTP(hitW, hitReq)
+
+	assert.NoError(t, conn.ReadJSON(&message))
+	assert.Equal(t, float64(5), message["value"])
+}
+
+func TestStreamShutdown(t *testing.T) {
+	r := setupTestRouter()
+	// use a shutdown of our own, the real one can only happen once
+	originalCtx, originalShutdown := shutdownCtx, shutdown
+	shutdownCtx, shutdown = context.WithCancel(context.Background())
+	defer func() { shutdownCtx, shutdown = originalCtx, originalShutdown }()
+
+	server := httptest.NewServer(r)
+	defer server.Close()
+
+	w := newMockResponseWriter()
+	req, _ := http.NewRequest("GET", "/stream/test/shutdown_key", nil)
+	sseDone := make(chan struct{})
+	go func() {
+		defer close(sseDone)
+		r.ServeHTTP(w, req)
+	}()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/ws/test/shutdown_key", nil)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	var message map[string]interface{}
+	assert.NoError(t, conn.ReadJSON(&message)) // initial value
+
+	time.Sleep(50 * time.Millisecond) // let the streams start
+	shutdown()
+
+	select {
+	case <-sseDone:
+		assert.Contains(t, w.Body.String(), "event: close\n")
+	case <-time.After(time.Second):
+		t.Fatal("stream didn't close on shutdown")
+	}
+	_, _, err = conn.ReadMessage()
+	assert.True(t, websocket.IsCloseError(err, websocket.CloseGoingAway), "expected a going away close, got %v", err)
+}
+
+func TestHitReturnPrevious(t *testing.T) {
+	r := setupTestRouter()
+
+	hit := func(query string) (int, map[string]interface{}) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/hit/test/previous_key?"+query, nil)
+		r.ServeHTTP(w, req)
+		var response map[string]interface{}
+		json.Unmarshal(w.Body.Bytes(), &response)
+		return w.Code, response
+	}
+
+	t.Run("Previous value is returned", func(t *testing.T) {
+		code, response := hit("step=10&return=previous")
+		assert.Equal(t, http.StatusOK, code)
+		assert.Equal(t, float64(0), response["value"])
+
+		_, response = hit("step=10&return=previous")
+		assert.Equal(t, float64(10), response["value"])
+
+		_, response = hit("return=value")
+		assert.Equal(t, float64(21), response["value"])
+	})
+
+	t.Run("Concurrent hits get distinct previous values", func(t *testing.T) {
+		const hits = 20
+		values := make(chan float64, hits)
+		var wg sync.WaitGroup
+		for i := 0; i < hits; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_, response := hit("return=previous")
+				values <- response["value"].(float64)
+			}()
+		}
+		wg.Wait()
+		close(values)
+
+		seen := make(map[float64]bool)
+		for value := range values {
+			assert.False(t, seen[value], "previous value %v was returned twice", value)
+			seen[value] = true
+		}
+		assert.Len(t, seen, hits)
+	})
+
+	t.Run("Bounded counter", func(t *testing.T) {
+		createW := httptest.NewRecorder()
+		createReq, _ := http.NewRequest("POST", "/create/test/previous_bounded_key?initializer=3&min=0", nil)
+		r.ServeHTTP(createW, createReq)
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/hit/test/previous_bounded_key?step=-5&return=previous", nil)
+		r.ServeHTTP(w, req)
+		var response map[string]interface{}
+		json.Unmarshal(w.Body.Bytes(), &response)
+		assert.Equal(t, float64(3), response["value"])
+		assert.Equal(t, true, response["clamped"])
+	})
+
+	t.Run("Invalid return", func(t *testing.T) {
+		code, _ := hit("return=next")
+		assert.Equal(t, http.StatusBadRequest, code)
+	})
+}
+
+func TestReserveView(t *testing.T) {
+	r := setupTestRouter()
+
+	reserve := func(key, query string) (int, map[string]interface{}) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/reserve/test/"+key+"?"+query, nil)
+		r.ServeHTTP(w, req)
+		var response map[string]interface{}
+		json.Unmarshal(w.Body.Bytes(), &response)
+		return w.Code, response
+	}
+
+	t.Run("Reserve a range", func(t *testing.T) {
+		code, response := reserve("reserve_key", "count=100")
+		assert.Equal(t, http.StatusOK, code)
+		assert.Equal(t, float64(1), response["start"])
+		assert.Equal(t, float64(100), response["end"])
+
+		_, response = reserve("reserve_key", "count=5")
+		assert.Equal(t, float64(101), response["start"])
+		assert.Equal(t, float64(105), response["end"])
+	})
+
+	t.Run("Concurrent reservations don't overlap", func(t *testing.T) {
+		const reservers, count = 10, 20
+		starts := make(chan float64, reservers)
+		var wg sync.WaitGroup
+		for i := 0; i < reservers; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_, response := reserve("reserve_concurrent_key", "count="+strconv.Itoa(count))
+				assert.Equal(t, response["start"].(float64)+count-1, response["end"])
+				starts <- response["start"].(float64)
+			}()
+		}
+		wg.Wait()
+		close(starts)
+
+		seen := make(map[float64]bool)
+		for start := range starts {
+			assert.Zero(t, int(start-1)%count, "range starting at %v overlaps another", start)
+			seen[start] = true
+		}
+		assert.Len(t, seen, reservers)
+	})
+
+	t.Run("Past the max", func(t *testing.T) {
+		createW := httptest.NewRecorder()
+		createReq, _ := http.NewRequest("POST", "/create/test/reserve_max_key?max=10", nil)
+		r.ServeHTTP(createW, createReq)
+
+		code, response := reserve("reserve_max_key", "count=11")
+		assert.Equal(t, http.StatusConflict, code)
+		assert.Equal(t, float64(0), response["value"])
+	})
+
+	t.Run("Invalid count", func(t *testing.T) {
+		code, _ := reserve("reserve_key", "count=0")
+		assert.Equal(t, http.StatusBadRequest, code)
+		code, _ = reserve("reserve_key", "count=abc")
+		assert.Equal(t, http.StatusBadRequest, code)
+	})
+
+	t.Run("Float counter", func(t *testing.T) {
+		createW := httptest.NewRecorder()
+		createReq, _ := http.NewRequest("POST", "/create/test/reserve_float_key?type=float", nil)
+		r.ServeHTTP(createW, createReq)
+
+		code, _ := reserve("reserve_float_key", "count=5")
+		assert.Equal(t, http.StatusConflict, code)
+	})
+}
+
+func TestExportView(t *testing.T) {
+	r := setupTestRouter()
+
+	claimW := httptest.NewRecorder()
+	claimReq, _ := http.NewRequest("POST", "/namespace/export_ns/token", nil)
+	r.ServeHTTP(claimW, claimReq)
+	assert.Equal(t, http.StatusCreated, claimW.Code)
+	var claimResponse map[string]interface{}
+	json.Unmarshal(claimW.Body.Bytes(), &claimResponse)
+	namespaceToken := claimResponse["admin_key"].(string)
+
+	for _, path := range []string{"/create/export_ns/plain?initializer=3", "/create/export_ns/bounded?initializer=2&max=10&ttl=3600"} {
+		createW := httptest.NewRecorder()
+		createReq, _ := http.NewRequest("POST", path, nil)
+		r.ServeHTTP(createW, createReq)
+		assert.Equal(t, http.StatusCreated, createW.Code)
+	}
+
+	t.Run("Export with the namespace token", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/export/export_ns", nil)
+		req.Header.Set("Authorization", "Bearer "+namespaceToken)
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var entries []map[string]interface{}
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &entries))
+		assert.Len(t, entries, 2)
+		byKey := make(map[string]map[string]interface{})
+		for _, entry := range entries {
+			byKey[entry["key"].(string)] = entry
+		}
+		assert.Equal(t, float64(3), byKey["plain"]["value"])
+		assert.Equal(t, float64(2), byKey["bounded"]["value"])
+		assert.Equal(t, "10", byKey["bounded"]["metadata"].(map[string]interface{})["max"])
+		ttl := byKey["bounded"]["ttl"].(float64)
+		assert.True(t, ttl > 3500 && ttl <= 3600, "unexpected ttl %v", ttl)
+	})
+
+	t.Run("Empty namespace", func(t *testing.T) {
+		claimW := httptest.NewRecorder()
+		claimReq, _ := http.NewRequest("POST", "/namespace/export_empty/token", nil)
+		r.ServeHTTP(claimW, claimReq)
+		var claimResponse map[string]interface{}
+		json.Unmarshal(claimW.Body.Bytes(), &claimResponse)
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/export/export_empty?token="+claimResponse["admin_key"].(string), nil)
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "[]", w.Body.String())
+	})
+
+	t.Run("Per-key token is not enough", func(t *testing.T) {
+		createW := httptest.NewRecorder()
+		createReq, _ := http.NewRequest("POST", "/create/export_ns/keyed", nil)
+		r.ServeHTTP(createW, createReq)
+		var createResponse map[string]interface{}
+		json.Unmarshal(createW.Body.Bytes(), &createResponse)
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/export/export_ns", nil)
+		req.Header.Set("Authorization", "Bearer "+createResponse["admin_key"].(string))
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+
+	t.Run("Missing token", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/export/export_ns", nil)
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+}
+
+func TestImportView(t *testing.T) {
+	r := setupTestRouter()
+
+	claimW := httptest.NewRecorder()
+	claimReq, _ := http.NewRequest("POST", "/namespace/import_ns/token", nil)
+	r.ServeHTTP(claimW, claimReq)
+	assert.Equal(t, http.StatusCreated, claimW.Code)
+	var claimResponse map[string]interface{}
+	json.Unmarshal(claimW.Body.Bytes(), &claimResponse)
+	namespaceToken := claimResponse["admin_key"].(string)
+
+	importDump := func(query, body string) (int, map[string]interface{}) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/import/import_ns?"+query, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+namespaceToken)
+		r.ServeHTTP(w, req)
+		var response map[string]interface{}
+		json.Unmarshal(w.Body.Bytes(), &response)
+		return w.Code, response
+	}
+
+	t.Run("Import a dump", func(t *testing.T) {
+		code, response := importDump("", `[
+			{"key": "imported", "value": 42, "ttl": 3600, "metadata": {}},
+			{"key": "imported_float", "value": 1.5, "ttl": -1, "metadata": {"type": "float", "max": "10"}}
+		]`)
+		assert.Equal(t, http.StatusOK, code)
+		assert.Equal(t, float64(2), response["imported"])
+		assert.Equal(t, float64(0), response["skipped"])
+		assert.Empty(t, response["errors"])
+
+		assert.Equal(t, "42", Client.Get(context.Background(), "K:import_ns:imported").Val())
+		ttl := Client.TTL(context.Background(), "K:import_ns:imported").Val()
+		assert.True(t, ttl > 3500*time.Second && ttl <= time.Hour, "unexpected ttl %v", ttl)
+		assert.Equal(t, "1.5", Client.Get(context.Background(), "K:import_ns:imported_float").Val())
+		assert.Equal(t, time.Duration(-1), Client.TTL(context.Background(), "K:import_ns:imported_float").Val())
+
+		meta, _ := utils.GetMetadata(context.Background(), Client, "K:import_ns:imported_float")
+		assert.True(t, meta.IsFloat())
+		assert.Equal(t, float64(10), meta.Max)
+	})
+
+	t.Run("Existing counters are skipped without overwrite", func(t *testing.T) {
+		code, response := importDump("overwrite=false", `[
+			{"key": "imported", "value": 7, "ttl": -1, "metadata": {"max": "100"}},
+			{"key": "imported_new", "value": 7, "ttl": -1, "metadata": {}}
+		]`)
+		assert.Equal(t, http.StatusOK, code)
+		assert.Equal(t, float64(1), response["imported"])
+		assert.Equal(t, float64(1), response["skipped"])
+
+		assert.Equal(t, "42", Client.Get(context.Background(), "K:import_ns:imported").Val())
+		meta, _ := utils.GetMetadata(context.Background(), Client, "K:import_ns:imported")
+		assert.False(t, meta.HasMax) // the metadata of skipped counters is left alone too
+		assert.Equal(t, "7", Client.Get(context.Background(), "K:import_ns:imported_new").Val())
+	})
+
+	t.Run("Overwrite replaces counters", func(t *testing.T) {
+		code, response := importDump("", `[{"key": "imported_float", "value": 3, "ttl": -1, "metadata": {}}]`)
+		assert.Equal(t, http.StatusOK, code)
+		assert.Equal(t, float64(1), response["imported"])
+
+		assert.Equal(t, "3", Client.Get(context.Background(), "K:import_ns:imported_float").Val())
+		meta, _ := utils.GetMetadata(context.Background(), Client, "K:import_ns:imported_float")
+		assert.False(t, meta.IsFloat())
+		assert.False(t, meta.HasMax)
+	})
+
+	t.Run("Malformed entries reject the whole dump", func(t *testing.T) {
+		for name, entry := range map[string]string{
+			"short key":       `{"key": "ab", "value": 1, "ttl": -1}`,
+			"decimal int":     `{"key": "malformed", "value": 1.5, "ttl": -1}`,
+			"string value":    `{"key": "malformed", "value": "1", "ttl": -1}`,
+			"missing ttl":     `{"key": "malformed", "value": 1}`,
+			"bad type":        `{"key": "malformed", "value": 1, "ttl": -1, "metadata": {"type": "string"}}`,
+			"bad webhook url": `{"key": "malformed", "value": 1, "ttl": -1, "metadata": {"webhook_url": "nope"}}`,
+		} {
+			code, _ := importDump("", `[{"key": "valid_entry", "value": 1, "ttl": -1}, `+entry+`]`)
+			assert.Equal(t, http.StatusBadRequest, code, name)
+		}
+		code, _ := importDump("", `{"key": "valid_entry"}`)
+		assert.Equal(t, http.StatusBadRequest, code)
+		assert.Equal(t, int64(0), Client.Exists(context.Background(), "K:import_ns:valid_entry").Val())
+	})
+
+	t.Run("Export round trip", func(t *testing.T) {
+		exportW := httptest.NewRecorder()
+		exportReq, _ := http.NewRequest("GET", "/export/import_ns", nil)
+		exportReq.Header.Set("Authorization", "Bearer "+namespaceToken)
+		r.ServeHTTP(exportW, exportReq)
+
+		code, response := importDump("", exportW.Body.String())
+		assert.Equal(t, http.StatusOK, code)
+		assert.Equal(t, float64(3), response["imported"])
+	})
+}
+
+func TestSumView(t *testing.T) {
+	r := setupTestRouter()
+
+	for _, path := range []string{
+		"/create/sum_ns/page_home?initializer=40",
+		"/create/sum_ns/page_about?initializer=2",
+		"/create/sum_ns/other?initializer=100&max=1000", // has a metadata key, which must not be counted
+	} {
+		createW := httptest.NewRecorder()
+		createReq, _ := http.NewRequest("POST", path, nil)
+		r.ServeHTTP(createW, createReq)
+		assert.Equal(t, http.StatusCreated, createW.Code)
+	}
+
+	sum := func(query string) (int, map[string]interface{}) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/sum/sum_ns?"+query, nil)
+		r.ServeHTTP(w, req)
+		var response map[string]interface{}
+		json.Unmarshal(w.Body.Bytes(), &response)
+		return w.Code, response
+	}
+
+	t.Run("Whole namespace", func(t *testing.T) {
+		code, response := sum("")
+		assert.Equal(t, http.StatusOK, code)
+		assert.Equal(t, "sum_ns", response["namespace"])
+		assert.Equal(t, float64(142), response["total"])
+		assert.Equal(t, float64(3), response["count"])
+	})
+
+	t.Run("Prefix", func(t *testing.T) {
+		_, response := sum("prefix=page_")
+		assert.Equal(t, float64(42), response["total"])
+		assert.Equal(t, float64(2), response["count"])
+	})
+
+	t.Run("Float counters", func(t *testing.T) {
+		createW := httptest.NewRecorder()
+		createReq, _ := http.NewRequest("POST", "/create/sum_ns/page_float?type=float&initializer=0.5", nil)
+		r.ServeHTTP(createW, createReq)
+
+		_, response := sum("prefix=page_")
+		assert.Equal(t, 42.5, response["total"])
+		assert.Equal(t, float64(3), response["count"])
+	})
+
+	t.Run("Empty namespace", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/sum/sum_empty_ns", nil)
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.JSONEq(t, `{"namespace": "sum_empty_ns", "total": 0, "count": 0}`, w.Body.String())
+	})
+
+	t.Run("Invalid prefix", func(t *testing.T) {
+		code, _ := sum("prefix=*")
+		assert.Equal(t, http.StatusBadRequest, code)
+	})
+}
+
+func TestRenameView(t *testing.T) {
+	r := setupTestRouter()
+
+	create := func(path string) string {
+		createW := httptest.NewRecorder()
+		createReq, _ := http.NewRequest("POST", path, nil)
+		r.ServeHTTP(createW, createReq)
+		assert.Equal(t, http.StatusCreated, createW.Code)
+		var createResponse map[string]interface{}
+		json.Unmarshal(createW.Body.Bytes(), &createResponse)
+		return createResponse["admin_key"].(string)
+	}
+	rename := func(key, query, token string) int {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/rename/test/"+key+"?"+query, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	adminToken := create("/create/test/rename_typo?initializer=42&max=100&ttl=3600")
+
+	t.Run("Rename a counter", func(t *testing.T) {
+		assert.Equal(t, http.StatusOK, rename("rename_typo", "to=rename_fixed", adminToken))
+
+		ctx := context.Background()
+		assert.Equal(t, int64(0), Client.Exists(ctx, "K:test:rename_typo", "M:test:rename_typo", "A:test:rename_typo").Val())
+		assert.Equal(t, "42", Client.Get(ctx, "K:test:rename_fixed").Val())
+		ttl := Client.TTL(ctx, "K:test:rename_fixed").Val()
+		assert.True(t, ttl > 3500*time.Second && ttl <= time.Hour, "unexpected ttl %v", ttl)
+		meta, _ := utils.GetMetadata(ctx, Client, "K:test:rename_fixed")
+		assert.Equal(t, float64(100), meta.Max)
+		assert.Equal(t, adminToken, Client.Get(ctx, "A:test:rename_fixed").Val())
+	})
+
+	t.Run("Target already exists", func(t *testing.T) {
+		create("/create/test/rename_taken")
+		assert.Equal(t, http.StatusConflict, rename("rename_fixed", "to=rename_taken", adminToken))
+		assert.Equal(t, "42", Client.Get(context.Background(), "K:test:rename_fixed").Val())
+	})
+
+	t.Run("Invalid target", func(t *testing.T) {
+		assert.Equal(t, http.StatusBadRequest, rename("rename_fixed", "", adminToken))
+		assert.Equal(t, http.StatusBadRequest, rename("rename_fixed", "to=ab", adminToken))
+		assert.Equal(t, http.StatusBadRequest, rename("rename_fixed", "to=rename_fixed", adminToken))
+	})
+
+	t.Run("Wrong token", func(t *testing.T) {
+		otherToken := create("/create/test/rename_other")
+		assert.Equal(t, http.StatusUnauthorized, rename("rename_fixed", "to=rename_stolen", otherToken))
+	})
+}
+
+func TestMergeView(t *testing.T) {
+	r := setupTestRouter()
+
+	create := func(path string) string {
+		createW := httptest.NewRecorder()
+		createReq, _ := http.NewRequest("POST", path, nil)
+		r.ServeHTTP(createW, createReq)
+		assert.Equal(t, http.StatusCreated, createW.Code)
+		var createResponse map[string]interface{}
+		json.Unmarshal(createW.Body.Bytes(), &createResponse)
+		return createResponse["admin_key"].(string)
+	}
+	merge := func(key, query, token string) (int, map[string]interface{}) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/merge/test/"+key+"?"+query, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		r.ServeHTTP(w, req)
+		var response map[string]interface{}
+		json.Unmarshal(w.Body.Bytes(), &response)
+		return w.Code, response
+	}
+
+	targetToken := create("/create/test/merge_target?initializer=10")
+	sourceToken := create("/create/test/merge_source?initializer=5")
+
+	t.Run("Merge keeping the source", func(t *testing.T) {
+		code, response := merge("merge_target", "from=merge_source", targetToken)
+		assert.Equal(t, http.StatusOK, code)
+		assert.Equal(t, float64(15), response["value"])
+		assert.Equal(t, "5", Client.Get(context.Background(), "K:test:merge_source").Val())
+	})
+
+	t.Run("Deleting the source needs its token", func(t *testing.T) {
+		code, _ := merge("merge_target", "from=merge_source&delete=true", targetToken)
+		assert.Equal(t, http.StatusUnauthorized, code)
+		code, _ = merge("merge_target", "from=merge_source&delete=true", sourceToken) // and the target's
+		assert.Equal(t, http.StatusUnauthorized, code)
+		assert.Equal(t, "15", Client.Get(context.Background(), "K:test:merge_target").Val())
+	})
+
+	t.Run("Merge deleting the source", func(t *testing.T) {
+		// the namespace token authorizes both counters
+		claimW := httptest.NewRecorder()
+		claimReq, _ := http.NewRequest("POST", "/namespace/merge_ns/token", nil)
+		r.ServeHTTP(claimW, claimReq)
+		var claimResponse map[string]interface{}
+		json.Unmarshal(claimW.Body.Bytes(), &claimResponse)
+		namespaceToken := claimResponse["admin_key"].(string)
+		create("/create/merge_ns/target?initializer=10")
+		create("/create/merge_ns/source?initializer=5")
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/merge/merge_ns/target?from=source&delete=true", nil)
+		req.Header.Set("Authorization", "Bearer "+namespaceToken)
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.JSONEq(t, `{"value": 15}`, w.Body.String())
+		assert.Equal(t, int64(0), Client.Exists(context.Background(), "K:merge_ns:source", "A:merge_ns:source").Val())
+
+		w = httptest.NewRecorder()
+		req, _ = http.NewRequest("POST", "/merge/merge_ns/target?from=source", nil)
+		req.Header.Set("Authorization", "Bearer "+namespaceToken)
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusConflict, w.Code) // nothing left to merge
+	})
+
+	t.Run("Private sources need the right to read them", func(t *testing.T) {
+		create("/create/test/merge_private?initializer=1234&visibility=private")
+		code, response := merge("merge_target", "from=merge_private", targetToken)
+		assert.Equal(t, http.StatusForbidden, code)
+		assert.NotContains(t, response, "value")
+		assert.Equal(t, "15", Client.Get(context.Background(), "K:test:merge_target").Val())
+		assert.Equal(t, "1234", Client.Get(context.Background(), "K:test:merge_private").Val())
+
+		claimW := httptest.NewRecorder()
+		claimReq, _ := http.NewRequest("POST", "/namespace/merge_private_ns/token", nil)
+		r.ServeHTTP(claimW, claimReq)
+		var claimResponse map[string]interface{}
+		json.Unmarshal(claimW.Body.Bytes(), &claimResponse)
+		namespaceToken := claimResponse["admin_key"].(string)
+		create("/create/merge_private_ns/target?initializer=1")
+		create("/create/merge_private_ns/source?initializer=2&visibility=private")
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/merge/merge_private_ns/target?from=source", nil)
+		req.Header.Set("Authorization", "Bearer "+namespaceToken)
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.JSONEq(t, `{"value": 3}`, w.Body.String())
+	})
+
+	t.Run("Decimals can't be merged into integer counters", func(t *testing.T) {
+		create("/create/test/merge_float?type=float&initializer=1.5")
+		code, _ := merge("merge_target", "from=merge_float", targetToken)
+		assert.Equal(t, http.StatusConflict, code)
+
+		floatToken := create("/create/test/merge_float_target?type=float&initializer=1")
+		code, response := merge("merge_float_target", "from=merge_float", floatToken)
+		assert.Equal(t, http.StatusOK, code)
+		assert.Equal(t, 2.5, response["value"])
+	})
+
+	t.Run("Invalid source", func(t *testing.T) {
+		code, _ := merge("merge_target", "", targetToken)
+		assert.Equal(t, http.StatusBadRequest, code)
+		code, _ = merge("merge_target", "from=merge_target", targetToken)
+		assert.Equal(t, http.StatusBadRequest, code)
+		code, _ = merge("merge_target", "from=merge_float&delete=maybe", targetToken)
+		assert.Equal(t, http.StatusBadRequest, code)
+	})
+}
+
+func TestCORSOrigins(t *testing.T) {
+	CORSOrigins = []string{"https://example.com", "*.example.org"}
+	defer func() { CORSOrigins = nil }()
+	r := setupTestRouter()
+
+	get := func(origin string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/get/test/cors_key", nil)
+		req.Header.Set("Origin", origin)
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	for _, origin := range []string{"https://example.com", "https://blog.example.org"} {
+		w := get(origin)
+		assert.Equal(t, origin, w.Header().Get("Access-Control-Allow-Origin"), origin)
+	}
+	w := get("https://evil.com")
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Empty(t, w.Header().Get("Access-Control-Allow-Origin"))
+
+	t.Run("WebSockets follow the allowlist", func(t *testing.T) {
+		server := httptest.NewServer(r)
+		defer server.Close()
+		url := "ws" + strings.TrimPrefix(server.URL, "http") + "/ws/test/cors_key"
+
+		_, resp, err := websocket.DefaultDialer.Dial(url, http.Header{"Origin": {"https://evil.com"}})
+		assert.Error(t, err)
+		if resp != nil {
+			assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+		}
+
+		conn, _, err := websocket.DefaultDialer.Dial(url, http.Header{"Origin": {"https://blog.example.org"}})
+		if assert.NoError(t, err) {
+			conn.Close()
+		}
+	})
+}
+
+func TestPrivateCounter(t *testing.T) {
+	r := setupTestRouter()
+
+	createW := httptest.NewRecorder()
+	createReq, _ := http.NewRequest("POST", "/create/private_ns/secret?initializer=7&visibility=private", nil)
+	r.ServeHTTP(createW, createReq)
+	assert.Equal(t, http.StatusCreated, createW.Code)
+	var createResponse map[string]interface{}
+	json.Unmarshal(createW.Body.Bytes(), &createResponse)
+	adminToken := createResponse["admin_key"].(string)
+
+	request := func(method, path, token string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, path, nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("Reads need the admin token", func(t *testing.T) {
+		for _, path := range []string{"/get/private_ns/secret", "/hit/private_ns/secret", "/info/private_ns/secret", "/badge/private_ns/secret"} {
+			assert.Equal(t, http.StatusUnauthorized, request("GET", path, "").Code, path)
+			assert.Equal(t, http.StatusUnauthorized, request("GET", path, "not-the-token").Code, path)
+		}
+		assert.Equal(t, http.StatusUnauthorized, request("POST", "/reserve/private_ns/secret", "").Code)
+		assert.Equal(t, http.StatusUnauthorized, request("GET", "/stream/private_ns/secret", "").Code)
+		assert.Equal(t, "7", Client.Get(context.Background(), "K:private_ns:secret").Val()) // the failed hits did nothing
+
+		w := request("GET", "/get/private_ns/secret", adminToken)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.JSONEq(t, `{"value": 7}`, w.Body.String())
+		w = request("GET", "/hit/private_ns/secret?token="+adminToken, "")
+		assert.JSONEq(t, `{"value": 8}`, w.Body.String())
+
+		w = request("GET", "/info/private_ns/secret", adminToken)
+		var info map[string]interface{}
+		json.Unmarshal(w.Body.Bytes(), &info)
+		assert.Equal(t, "private", info["visibility"])
+	})
+
+	t.Run("Private values don't leak elsewhere", func(t *testing.T) {
+		request("POST", "/create/private_ns/open?initializer=1", "")
+
+		w := request("POST", "/create/private_ns/secret", "")
+		assert.Equal(t, http.StatusConflict, w.Code)
+		assert.NotContains(t, w.Body.String(), `"value":8`)
+
+		w = request("GET", "/list/private_ns", "")
+		assert.Contains(t, w.Body.String(), `"open"`)
+		assert.NotContains(t, w.Body.String(), `"secret"`)
+		w = request("GET", "/sum/private_ns", "")
+		assert.JSONEq(t, `{"namespace": "private_ns", "total": 1, "count": 1}`, w.Body.String())
+
+		batchW := httptest.NewRecorder()
+		batchReq, _ := http.NewRequest("POST", "/hit-batch", strings.NewReader(`{"keys": [{"namespace": "private_ns", "key": "secret"}]}`))
+		r.ServeHTTP(batchW, batchReq)
+		assert.Contains(t, batchW.Body.String(), "Counter is private")
+		assert.Equal(t, "8", Client.Get(context.Background(), "K:private_ns:secret").Val())
+	})
+
+	t.Run("Invalid visibility", func(t *testing.T) {
+		w := request("POST", "/create/private_ns/invalid?visibility=hidden", "")
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+}
+
+func TestHealthCheck(t *testing.T) {
+	r := setupTestRouter()
+
+	t.Run("Healthy", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/healthcheck", nil)
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var response map[string]interface{}
+		json.Unmarshal(w.Body.Bytes(), &response)
+		assert.Equal(t, "ok", response["status"])
+		assert.NotEmpty(t, response["uptime"])
+		assert.Equal(t, map[string]interface{}{"redis": "ok", "rate_limit_redis": "ok"}, response["checks"])
+	})
+
+	t.Run("Unreachable redis", func(t *testing.T) {
+		listener, _ := net.Listen("tcp", "127.0.0.1:0")
+		addr := listener.Addr().String()
+		listener.Close() // nothing listens there anymore
+		originalClient := RateLimitClient
+		RateLimitClient = redis.NewClient(&redis.Options{Addr: addr, MaxRetries: -1})
+		defer func() { RateLimitClient = originalClient }()
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/healthcheck", nil)
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+
+		var response map[string]interface{}
+		json.Unmarshal(w.Body.Bytes(), &response)
+		checks := response["checks"].(map[string]interface{})
+		assert.Equal(t, "ok", checks["redis"])
+		assert.NotEqual(t, "ok", checks["rate_limit_redis"])
+
+		livezW := httptest.NewRecorder()
+		livezReq, _ := http.NewRequest("GET", "/livez", nil)
+		r.ServeHTTP(livezW, livezReq)
+		assert.Equal(t, http.StatusOK, livezW.Code)
+	})
+}
+
+func TestRequestLogging(t *testing.T) {
+	router := setupTestRouter()
+	var logs bytes.Buffer
+	defaultLogger := slog.Default()
+	slog.SetDefault(slog.New(slog.NewJSONHandler(&logs, nil)))
+	defer slog.SetDefault(defaultLogger)
+
+	t.Run("Generate request id", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/livez", nil)
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.NotEmpty(t, w.Header().Get(middleware.RequestIDHeader))
+	})
+
+	t.Run("Log lines carry the request id", func(t *testing.T) {
+		logs.Reset()
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/get/logging_ns/key?token=secret", nil)
+		req.Header.Set(middleware.RequestIDHeader, "client-request-1")
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, "client-request-1", w.Header().Get(middleware.RequestIDHeader))
+		var line map[string]interface{}
+		lines := bytes.Split(bytes.TrimSpace(logs.Bytes()), []byte("\n"))
+		err := json.Unmarshal(lines[len(lines)-1], &line) // the request is logged once it's been handled
+		assert.NoError(t, err)
+		assert.Equal(t, "request", line["msg"])
+		assert.Equal(t, "client-request-1", line["request_id"])
+		assert.Equal(t, "/get/logging_ns/key", line["path"])
+		assert.Equal(t, float64(http.StatusNotFound), line["status"])
+		assert.NotContains(t, logs.String(), "secret")
+	})
+
+	t.Run("Replace malformed request id", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/livez", nil)
+		req.Header.Set(middleware.RequestIDHeader, "not a valid\tid")
+		router.ServeHTTP(w, req)
+
+		assert.NotEqual(t, "not a valid\tid", w.Header().Get(middleware.RequestIDHeader))
+		assert.NotEmpty(t, w.Header().Get(middleware.RequestIDHeader))
+	})
+}
+
+func TestKeyCharacters(t *testing.T) {
+	r := setupTestRouter()
+
+	testCases := []struct {
+		name  string
+		path  string
+		error string
+	}{
+		{"Separator in key", "/hit/chars_ns/a:b", "Invalid key: must not contain ':'"},
+		{"Separator in namespace", "/hit/chars:ns/key", "Invalid namespace: must not contain ':'"},
+		{"Whitespace in legacy key", "/info/chars_ns/a%20b", "Invalid key: must not contain whitespace"},
+		{"Control character in legacy key", "/info/chars_ns/a%07b", "Invalid key: must not contain control characters"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", tc.path, nil)
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+			var response map[string]interface{}
+			json.Unmarshal(w.Body.Bytes(), &response)
+			assert.Equal(t, tc.error, response["error"])
+		})
+	}
+}
+
+func TestHitDryRun(t *testing.T) {
+	r := setupTestRouter()
+	dryRun := func(path string) (int, map[string]interface{}) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", path, nil)
+		r.ServeHTTP(w, req)
+		var response map[string]interface{}
+		json.Unmarshal(w.Body.Bytes(), &response)
+		return w.Code, response
+	}
+
+	t.Run("Preview without incrementing", func(t *testing.T) {
+		createReq, _ := http.NewRequest("POST", "/create/dry_run_ns/plain?initializer=5", nil)
+		r.ServeHTTP(httptest.NewRecorder(), createReq)
+
+		code, response := dryRun("/hit/dry_run_ns/plain?dry_run=true&step=3")
+		assert.Equal(t, http.StatusOK, code)
+		assert.Equal(t, true, response["dry_run"])
+		assert.Equal(t, float64(5), response["value"])
+		assert.Equal(t, float64(8), response["next_value"])
+		assert.Equal(t, "5", Client.Get(context.Background(), "K:dry_run_ns:plain").Val())
+	})
+
+	t.Run("Preview a counter that doesn't exist", func(t *testing.T) {
+		code, response := dryRun("/hit/dry_run_ns/missing?dry_run=true")
+		assert.Equal(t, http.StatusOK, code)
+		assert.Equal(t, float64(0), response["value"])
+		assert.Equal(t, float64(1), response["next_value"])
+		assert.Equal(t, int64(0), Client.Exists(context.Background(), "K:dry_run_ns:missing").Val())
+	})
+
+	t.Run("Preview bounds", func(t *testing.T) {
+		createReq, _ := http.NewRequest("POST", "/create/dry_run_ns/bounded?initializer=8&max=10&min=5", nil)
+		r.ServeHTTP(httptest.NewRecorder(), createReq)
+
+		_, response := dryRun("/hit/dry_run_ns/bounded?dry_run=true&step=5")
+		assert.Equal(t, float64(8), response["next_value"])
+		assert.Equal(t, "Counter has reached its max value of 10", response["rejected"])
+
+		_, response = dryRun("/hit/dry_run_ns/bounded?dry_run=true&step=-5")
+		assert.Equal(t, float64(5), response["next_value"])
+		assert.Equal(t, true, response["clamped"])
+		assert.Equal(t, "8", Client.Get(context.Background(), "K:dry_run_ns:bounded").Val())
+	})
+
+	t.Run("Preview a float counter", func(t *testing.T) {
+		createReq, _ := http.NewRequest("POST", "/create/dry_run_ns/float?type=float&initializer=1.5", nil)
+		r.ServeHTTP(httptest.NewRecorder(), createReq)
+
+		_, response := dryRun("/hit/dry_run_ns/float?dry_run=true&step=0.25")
+		assert.Equal(t, 1.5, response["value"])
+		assert.Equal(t, 1.75, response["next_value"])
+	})
+
+	t.Run("Invalid dry_run", func(t *testing.T) {
+		code, _ := dryRun("/hit/dry_run_ns/plain?dry_run=maybe")
+		assert.Equal(t, http.StatusBadRequest, code)
+	})
+}
+
+func TestLastUpdated(t *testing.T) {
+	r := setupTestRouter()
+	request := func(method, path string, header http.Header) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, path, nil)
+		for name, values := range header {
+			req.Header[name] = values
+		}
+		r.ServeHTTP(w, req)
+		return w
+	}
+	lastUpdated := func(key string) interface{} {
+		var info map[string]interface{}
+		json.Unmarshal(request("GET", "/info/updated_ns/"+key, nil).Body.Bytes(), &info)
+		return info["last_updated"]
+	}
+
+	t.Run("Writes are recorded", func(t *testing.T) {
+		before := time.Now().UnixMilli()
+		request("POST", "/create/updated_ns/counter", nil)
+		created, ok := lastUpdated("counter").(float64)
+		assert.True(t, ok)
+		assert.GreaterOrEqual(t, int64(created), before)
+
+		Client.HSet(context.Background(), "M:updated_ns:counter", "last_updated", 1000)
+		assert.Equal(t, float64(1000), lastUpdated("counter"))
+		request("GET", "/hit/updated_ns/counter", nil)
+		assert.GreaterOrEqual(t, int64(lastUpdated("counter").(float64)), before)
+	})
+
+	t.Run("Refused hits aren't recorded", func(t *testing.T) {
+		request("POST", "/create/updated_ns/bounded?initializer=5&max=5", nil)
+		Client.HSet(context.Background(), "M:updated_ns:bounded", "last_updated", 1000)
+		w := request("GET", "/hit/updated_ns/bounded", nil)
+		assert.Equal(t, http.StatusConflict, w.Code)
+		assert.Equal(t, float64(1000), lastUpdated("bounded"))
+	})
+
+	t.Run("Untracked counters", func(t *testing.T) {
+		Client.Set(context.Background(), "K:updated_ns:legacy", 3, 0)
+		assert.Nil(t, lastUpdated("legacy"))
+		w := request("GET", "/get/updated_ns/legacy", http.Header{"If-Modified-Since": {time.Now().UTC().Format(http.TimeFormat)}})
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Empty(t, w.Header().Get("Last-Modified"))
+	})
+
+	t.Run("Conditional get", func(t *testing.T) {
+		modified := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+		Client.HSet(context.Background(), "M:updated_ns:counter", "last_updated", modified.Add(300*time.Millisecond).UnixMilli())
+
+		w := request("GET", "/get/updated_ns/counter", nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "Wed, 01 May 2024 12:00:00 GMT", w.Header().Get("Last-Modified"))
+
+		w = request("GET", "/get/updated_ns/counter", http.Header{"If-Modified-Since": {"Wed, 01 May 2024 12:00:00 GMT"}})
+		assert.Equal(t, http.StatusNotModified, w.Code)
+		assert.Empty(t, w.Body.String())
+
+		w = request("GET", "/get/updated_ns/counter", http.Header{"If-Modified-Since": {"Wed, 01 May 2024 11:59:59 GMT"}})
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		// a write later in the same second has the same Last-Modified, the next second's is newer
+		Client.HSet(context.Background(), "M:updated_ns:counter", "last_updated", modified.Add(999*time.Millisecond).UnixMilli())
+		w = request("GET", "/get/updated_ns/counter", http.Header{"If-Modified-Since": {"Wed, 01 May 2024 12:00:00 GMT"}})
+		assert.Equal(t, http.StatusNotModified, w.Code)
+		Client.HSet(context.Background(), "M:updated_ns:counter", "last_updated", modified.Add(time.Second).UnixMilli())
+		w = request("GET", "/get/updated_ns/counter", http.Header{"If-Modified-Since": {"Wed, 01 May 2024 12:00:00 GMT"}})
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "Wed, 01 May 2024 12:00:01 GMT", w.Header().Get("Last-Modified"))
+	})
+}
+
+func TestCreationMetadata(t *testing.T) {
+	r := setupTestRouter()
+	info := func(path, token string) map[string]interface{} {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", path, nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		r.ServeHTTP(w, req)
+		var response map[string]interface{}
+		json.Unmarshal(w.Body.Bytes(), &response)
+		return response
+	}
+	create := func(path string) string {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", path, nil)
+		req.RemoteAddr = "192.0.2.1:4321"
+		r.ServeHTTP(w, req)
+		var response map[string]interface{}
+		json.Unmarshal(w.Body.Bytes(), &response)
+		return response["admin_key"].(string)
+	}
+
+	t.Run("Record creation time", func(t *testing.T) {
+		before := time.Now().UnixMilli()
+		adminKey := create("/create/created_ns/plain")
+		response := info("/info/created_ns/plain", adminKey)
+		assert.GreaterOrEqual(t, int64(response["created_at"].(float64)), before)
+		assert.NotContains(t, response, "created_ip") // not opted in
+	})
+
+	t.Run("Record the hashed creator ip", func(t *testing.T) {
+		CreatorIPSalt = "test-salt"
+		defer func() { CreatorIPSalt = "" }()
+		adminKey := create("/create/created_ns/with_ip")
+
+		response := info("/info/created_ns/with_ip", adminKey)
+		assert.Equal(t, utils.HashIP("test-salt", "192.0.2.1"), response["created_ip"])
+		assert.NotEqual(t, utils.HashIP("other-salt", "192.0.2.1"), response["created_ip"])
+		assert.NotContains(t, info("/info/created_ns/with_ip", ""), "created_ip") // only shown with a token
+	})
+
+	t.Run("Legacy counters", func(t *testing.T) {
+		Client.Set(context.Background(), "K:created_ns:legacy", 1, 0)
+		response := info("/info/created_ns/legacy", "")
+		assert.Contains(t, response, "created_at")
+		assert.Nil(t, response["created_at"])
+	})
+}
+
+func TestCompression(t *testing.T) {
+	os.Setenv("COMPRESSION_ENABLED", "true")
+	defer os.Unsetenv("COMPRESSION_ENABLED")
+	r := setupTestRouter()
+	for i := 0; i < 50; i++ {
+		Client.Set(context.Background(), "K:compress_ns:counter_"+strconv.Itoa(i), i, 0)
+	}
+	request := func(path, acceptEncoding string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", path, nil)
+		if acceptEncoding != "" {
+			req.Header.Set("Accept-Encoding", acceptEncoding)
+		}
+		r.ServeHTTP(w, req)
+		return w
+	}
+	assertCounters := func(t *testing.T, body io.Reader) {
+		var response map[string]interface{}
+		assert.NoError(t, json.NewDecoder(body).Decode(&response))
+		assert.Equal(t, "compress_ns", response["namespace"])
+		assert.NotEmpty(t, response["keys"])
+	}
+
+	t.Run("Compress large responses with gzip", func(t *testing.T) {
+		w := request("/list/compress_ns", "gzip, deflate, br")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "gzip", w.Header().Get("Content-Encoding"))
+		assert.Contains(t, w.Header().Values("Vary"), "Accept-Encoding")
+		reader, err := gzip.NewReader(w.Body)
+		assert.NoError(t, err)
+		assertCounters(t, reader)
+	})
+
+	t.Run("Leave small responses alone", func(t *testing.T) {
+		w := request("/get/compress_ns/counter_7", "gzip")
+		assert.Empty(t, w.Header().Get("Content-Encoding"))
+		assert.JSONEq(t, `{"value":7}`, w.Body.String())
+	})
+
+	t.Run("Leave responses alone without Accept-Encoding", func(t *testing.T) {
+		w := request("/list/compress_ns", "")
+		assert.Empty(t, w.Header().Get("Content-Encoding"))
+		assertCounters(t, w.Body)
+
+		w = request("/list/compress_ns", "br, deflate")
+		assert.Empty(t, w.Header().Get("Content-Encoding"))
+	})
+}
+
+func TestHeadRequests(t *testing.T) {
+	server := httptest.NewServer(setupTestRouter()) // only a real server drops the body of HEAD responses
+	defer server.Close()
+	createReq, _ := http.NewRequest("POST", server.URL+"/create/head_ns/counter?initializer=3", nil)
+	createResp, err := http.DefaultClient.Do(createReq)
+	assert.NoError(t, err)
+	createResp.Body.Close()
+
+	for _, path := range []string{"/get/head_ns/counter", "/info/head_ns/counter"} {
+		t.Run(path, func(t *testing.T) {
+			getResp, err := http.Get(server.URL + path)
+			assert.NoError(t, err)
+			getResp.Body.Close()
+
+			resp, err := http.Head(server.URL + path)
+			assert.NoError(t, err)
+			defer resp.Body.Close()
+			body, _ := io.ReadAll(resp.Body)
+			assert.Equal(t, getResp.StatusCode, resp.StatusCode)
+			assert.Equal(t, getResp.Header.Get("Content-Type"), resp.Header.Get("Content-Type"))
+			assert.Empty(t, body)
+		})
+	}
+
+	t.Run("Validators", func(t *testing.T) {
+		resp, err := http.Head(server.URL + "/get/head_ns/counter")
+		assert.NoError(t, err)
+		resp.Body.Close()
+		assert.NotEmpty(t, resp.Header.Get("Last-Modified"))
+		assert.NotEmpty(t, resp.Header.Get("ETag"))
+	})
+
+	t.Run("Missing counter", func(t *testing.T) {
+		resp, err := http.Head(server.URL + "/get/head_ns/missing")
+		assert.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	})
+}
+
+func TestETag(t *testing.T) {
+	r := setupTestRouter()
+	get := func(header http.Header) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/get/etag_ns/counter", nil)
+		for name, values := range header {
+			req.Header[name] = values
+		}
+		r.ServeHTTP(w, req)
+		return w
+	}
+	createReq, _ := http.NewRequest("POST", "/create/etag_ns/counter?initializer=5", nil)
+	r.ServeHTTP(httptest.NewRecorder(), createReq)
+
+	etag := get(nil).Header().Get("ETag")
+	assert.True(t, strings.HasPrefix(etag, `W/"`), etag)
+
+	t.Run("Unchanged counter", func(t *testing.T) {
+		w := get(http.Header{"If-None-Match": {etag}})
+		assert.Equal(t, http.StatusNotModified, w.Code)
+		assert.Equal(t, etag, w.Header().Get("ETag"))
+		assert.Empty(t, w.Body.String())
+
+		w = get(http.Header{"If-None-Match": {`"other", ` + strings.TrimPrefix(etag, "W/")}})
+		assert.Equal(t, http.StatusNotModified, w.Code)
+		w = get(http.Header{"If-None-Match": {"*"}})
+		assert.Equal(t, http.StatusNotModified, w.Code)
+	})
+
+	t.Run("Changed counter", func(t *testing.T) {
+		hitReq, _ := http.NewRequest("GET", "/hit/etag_ns/counter", nil)
+		r.ServeHTTP(httptest.NewRecorder(), hitReq)
+
+		w := get(http.Header{"If-None-Match": {etag}})
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.NotEqual(t, etag, w.Header().Get("ETag"))
+		assert.JSONEq(t, `{"value":6}`, w.Body.String())
+	})
+
+	t.Run("If-None-Match takes precedence", func(t *testing.T) {
+		future := time.Now().Add(time.Hour).UTC().Format(http.TimeFormat)
+		w := get(http.Header{"If-None-Match": {etag}, "If-Modified-Since": {future}})
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+}
+
+func TestDecView(t *testing.T) {
+	r := setupTestRouter()
+	dec := func(method, path string) (int, map[string]interface{}) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, path, nil)
+		r.ServeHTTP(w, req)
+		var response map[string]interface{}
+		json.Unmarshal(w.Body.Bytes(), &response)
+		return w.Code, response
+	}
+	createReq, _ := http.NewRequest("POST", "/create/dec_ns/counter?initializer=10&min=0", nil)
+	r.ServeHTTP(httptest.NewRecorder(), createReq)
+
+	t.Run("Decrement by one", func(t *testing.T) {
+		code, response := dec("GET", "/dec/dec_ns/counter")
+		assert.Equal(t, http.StatusOK, code)
+		assert.Equal(t, float64(9), response["value"])
+	})
+
+	t.Run("Decrement by step", func(t *testing.T) {
+		code, response := dec("POST", "/dec/dec_ns/counter?step=4")
+		assert.Equal(t, http.StatusOK, code)
+		assert.Equal(t, float64(5), response["value"])
+	})
+
+	t.Run("Clamp to min", func(t *testing.T) {
+		code, response := dec("GET", "/dec/dec_ns/counter?step=20")
+		assert.Equal(t, http.StatusOK, code)
+		assert.Equal(t, float64(0), response["value"])
+		assert.Equal(t, true, response["clamped"])
+	})
+
+	t.Run("Reject negative steps", func(t *testing.T) {
+		code, response := dec("GET", "/dec/dec_ns/counter?step=-2")
+		assert.Equal(t, http.StatusBadRequest, code)
+		assert.Contains(t, response["error"], "step must be positive")
+		code, _ = dec("GET", "/dec/dec_ns/counter?step=0")
+		assert.Equal(t, http.StatusBadRequest, code)
+	})
+
+	t.Run("Decrement a float counter", func(t *testing.T) {
+		createReq, _ := http.NewRequest("POST", "/create/dec_ns/float?type=float&initializer=2.5", nil)
+		r.ServeHTTP(httptest.NewRecorder(), createReq)
+		code, response := dec("GET", "/dec/dec_ns/float?step=0.5")
+		assert.Equal(t, http.StatusOK, code)
+		assert.Equal(t, 2.0, response["value"])
+	})
+}
+
+func TestStatsCensus(t *testing.T) {
+	r := setupTestRouter()
+	for i := 0; i < 200; i++ {
+		Client.Set(context.Background(), "K:census_big_ns:counter_"+strconv.Itoa(i), i, 0)
+	}
+	counters := func() map[string]interface{} {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/stats", nil)
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+		var response map[string]interface{}
+		json.Unmarshal(w.Body.Bytes(), &response)
+		return response["counters"].(map[string]interface{})
+	}
+
+	census := counters()
+	total := census["total"].(float64)
+	assert.GreaterOrEqual(t, total, float64(200))
+	namespaces := census["top_namespaces"].([]interface{})
+	assert.LessOrEqual(t, len(namespaces), utils.CensusTopNamespaces)
+	largest := namespaces[0].(map[string]interface{})
+	assert.Equal(t, "census_big_ns", largest["namespace"])
+	assert.Equal(t, float64(200), largest["counters"])
+	assert.Equal(t, "0s", census["age"])
+
+	t.Run("Cached", func(t *testing.T) {
+		Client.Set(context.Background(), "K:census_ns:uncounted", 1, 0)
+		assert.Equal(t, total, counters()["total"])
+	})
+}
+
+func TestCounterCache(t *testing.T) {
+	r := setupTestRouter()
+	utils.Counters = utils.NewCounterCache(10, time.Minute)
+	defer func() { utils.Counters = nil }()
+	request := func(method, path string) map[string]interface{} {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, path, nil)
+		r.ServeHTTP(w, req)
+		var response map[string]interface{}
+		json.Unmarshal(w.Body.Bytes(), &response)
+		return response
+	}
+	create := request("POST", "/create/cache_ns/counter?initializer=5")
+	token := create["admin_key"].(string)
+
+	t.Run("Serve reads from memory", func(t *testing.T) {
+		assert.Equal(t, float64(5), request("GET", "/get/cache_ns/counter")["value"])
+		Client.Set(context.Background(), "K:cache_ns:counter", 100, 0) // bypasses the cache
+		assert.Equal(t, float64(5), request("GET", "/get/cache_ns/counter")["value"])
+	})
+
+	t.Run("Hits invalidate", func(t *testing.T) {
+		assert.Equal(t, float64(101), request("GET", "/hit/cache_ns/counter")["value"])
+		assert.Equal(t, float64(101), request("GET", "/get/cache_ns/counter")["value"])
+	})
+
+	t.Run("Sets invalidate", func(t *testing.T) {
+		request("POST", "/set/cache_ns/counter?value=42&token="+token)
+		assert.Equal(t, float64(42), request("GET", "/get/cache_ns/counter")["value"])
+	})
+
+	t.Run("Deletes invalidate", func(t *testing.T) {
+		request("POST", "/delete/cache_ns/counter?token="+token)
+		assert.Equal(t, "Key not found", request("GET", "/get/cache_ns/counter")["error"])
+	})
+}
+
+func TestCreateInitialValue(t *testing.T) {
+	r := setupTestRouter()
+	request := func(method, path, token string) (int, map[string]interface{}) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, path, nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		r.ServeHTTP(w, req)
+		var response map[string]interface{}
+		json.Unmarshal(w.Body.Bytes(), &response)
+		return w.Code, response
+	}
+
+	code, response := request("POST", "/create/initial_ns/counter?initial=42&max=100", "")
+	assert.Equal(t, http.StatusCreated, code)
+	assert.Equal(t, float64(42), response["value"])
+	adminKey := response["admin_key"].(string)
+
+	t.Run("Validate against bounds", func(t *testing.T) {
+		code, response := request("POST", "/create/initial_ns/too_large?initial=101&max=100", "")
+		assert.Equal(t, http.StatusBadRequest, code)
+		assert.Equal(t, "initializer can't be larger than max", response["error"])
+
+		code, _ = request("POST", "/create/initial_ns/both?initial=1&initializer=2", "")
+		assert.Equal(t, http.StatusBadRequest, code)
+	})
+
+	t.Run("Existing keys are left alone", func(t *testing.T) {
+		code, response := request("POST", "/create/initial_ns/counter?initial=7", "")
+		assert.Equal(t, http.StatusConflict, code)
+		assert.Equal(t, float64(42), response["value"])
+	})
+
+	t.Run("Overwrite requires a token", func(t *testing.T) {
+		code, _ := request("POST", "/create/initial_ns/counter?initial=7&overwrite=true", "")
+		assert.Equal(t, http.StatusUnauthorized, code)
+		code, _ = request("POST", "/create/initial_ns/counter?initial=7&overwrite=true", "wrong")
+		assert.Equal(t, http.StatusUnauthorized, code)
+		assert.Equal(t, "42", Client.Get(context.Background(), "K:initial_ns:counter").Val())
+	})
+
+	t.Run("Overwrite", func(t *testing.T) {
+		code, response := request("POST", "/create/initial_ns/counter?initial=7&overwrite=true", adminKey)
+		assert.Equal(t, http.StatusOK, code)
+		assert.Equal(t, float64(7), response["value"])
+		assert.Equal(t, true, response["overwritten"])
+		assert.Equal(t, "7", Client.Get(context.Background(), "K:initial_ns:counter").Val())
+		assert.Equal(t, adminKey, Client.Get(context.Background(), "A:initial_ns:counter").Val())
+		assert.False(t, Client.HExists(context.Background(), "M:initial_ns:counter", "max").Val()) // settings were replaced
+	})
+
+	t.Run("Overwrite a key that doesn't exist", func(t *testing.T) {
+		code, response := request("POST", "/create/initial_ns/fresh?initial=3&overwrite=true", "")
+		assert.Equal(t, http.StatusCreated, code)
+		assert.Equal(t, float64(3), response["value"])
+	})
+}
+
+func TestNamespaceQuota(t *testing.T) {
+	r := setupTestRouter()
+	MaxCounters = 2
+	defer func() { MaxCounters = 0 }()
+	request := func(method, path, token string) (int, map[string]interface{}) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, path, nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		r.ServeHTTP(w, req)
+		var response map[string]interface{}
+		json.Unmarshal(w.Body.Bytes(), &response)
+		return w.Code, response
+	}
+	countField := func(namespace string) string {
+		return Client.HGet(context.Background(), "N:"+namespace, "counters").Val()
+	}
+
+	code, response := request("POST", "/create/quota_ns/first", "")
+	assert.Equal(t, http.StatusCreated, code)
+	adminKey := response["admin_key"].(string)
+	code, _ = request("POST", "/create/quota_ns/second", "")
+	assert.Equal(t, http.StatusCreated, code)
+	assert.Equal(t, "2", countField("quota_ns"))
+
+	t.Run("Full namespaces refuse new counters", func(t *testing.T) {
+		code, response := request("POST", "/create/quota_ns/third", "")
+		assert.Equal(t, http.StatusConflict, code)
+		assert.Contains(t, response["error"], "at most 2 counters")
+		assert.Equal(t, int64(0), Client.Exists(context.Background(), "K:quota_ns:third").Val())
+		assert.Equal(t, "2", countField("quota_ns"))
+	})
+
+	t.Run("Existing counters don't take a slot", func(t *testing.T) {
+		code, _ := request("POST", "/create/quota_ns/first", "")
+		assert.Equal(t, http.StatusConflict, code)
+		code, _ = request("POST", "/create/quota_ns/first?overwrite=true&initial=5", adminKey)
+		assert.Equal(t, http.StatusOK, code)
+		assert.Equal(t, "2", countField("quota_ns"))
+	})
+
+	t.Run("Deleting frees a slot", func(t *testing.T) {
+		code, _ := request("DELETE", "/delete/quota_ns/first", adminKey)
+		assert.Equal(t, http.StatusOK, code)
+		assert.Equal(t, "1", countField("quota_ns"))
+		request("DELETE", "/delete/quota_ns/first", adminKey)
+		assert.Equal(t, "1", countField("quota_ns"), "deleting a missing counter frees nothing")
+		code, _ = request("POST", "/create/quota_ns/third", "")
+		assert.Equal(t, http.StatusCreated, code)
+	})
+
+	t.Run("Namespaces can override the limit", func(t *testing.T) {
+		Client.HSet(context.Background(), "N:quota_big", "max_counters", 3)
+		for _, key := range []string{"aaa", "bbb", "ccc"} {
+			code, _ := request("POST", "/create/quota_big/"+key, "")
+			assert.Equal(t, http.StatusCreated, code)
+		}
+		code, _ := request("POST", "/create/quota_big/ddd", "")
+		assert.Equal(t, http.StatusConflict, code)
+
+		Client.HSet(context.Background(), "N:quota_unlimited", "max_counters", 0)
+		for _, key := range []string{"aaa", "bbb", "ccc"} {
+			code, _ := request("POST", "/create/quota_unlimited/"+key, "")
+			assert.Equal(t, http.StatusCreated, code)
+		}
+	})
+
+	t.Run("Merging away a counter frees its slot", func(t *testing.T) {
+		code, response := request("POST", "/namespace/quota_merge/token", "") // authorizes both counters
+		assert.Equal(t, http.StatusCreated, code)
+		namespaceToken := response["admin_key"].(string)
+		request("POST", "/create/quota_merge/target", "")
+		request("POST", "/create/quota_merge/source", "")
+		code, _ = request("POST", "/merge/quota_merge/target?from=source&delete=true", namespaceToken)
+		assert.Equal(t, http.StatusOK, code)
+		assert.Equal(t, "1", countField("quota_merge"))
+	})
+
+	t.Run("Hits that create counters take a slot", func(t *testing.T) {
+		code, _ := request("GET", "/hit/quota_hits/first", "")
+		assert.Equal(t, http.StatusOK, code)
+		request("GET", "/hit/quota_hits/first", "")
+		code, _ = request("GET", "/hit/quota_hits/second", "")
+		assert.Equal(t, http.StatusOK, code)
+		assert.Equal(t, "2", countField("quota_hits"))
+
+		code, response := request("GET", "/hit/quota_hits/third", "")
+		assert.Equal(t, http.StatusConflict, code)
+		assert.Contains(t, response["error"], "at most 2 counters")
+		assert.Equal(t, int64(0), Client.Exists(context.Background(), "K:quota_hits:third").Val())
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/hit-batch", strings.NewReader(`{"keys":[{"namespace":"quota_hits","key":"first"},{"namespace":"quota_hits","key":"third"}]}`))
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.JSONEq(t, `[{"namespace": "quota_hits", "key": "first", "value": 3},
+			{"namespace": "quota_hits", "key": "third", "error": "`+namespaceFullError(2)+`"}]`, w.Body.String())
+		assert.Equal(t, int64(0), Client.Exists(context.Background(), "K:quota_hits:third").Val())
+		assert.Equal(t, "2", countField("quota_hits"))
+	})
+
+	t.Run("Reserves that create counters take a slot", func(t *testing.T) {
+		code, response := request("POST", "/reserve/quota_reserve/first?count=5", "")
+		assert.Equal(t, http.StatusOK, code)
+		assert.Equal(t, float64(5), response["end"])
+		code, _ = request("POST", "/reserve/quota_reserve/first?count=5", "")
+		assert.Equal(t, http.StatusOK, code)
+		code, _ = request("POST", "/reserve/quota_reserve/second", "")
+		assert.Equal(t, http.StatusOK, code)
+		assert.Equal(t, "2", countField("quota_reserve"))
+
+		code, response = request("POST", "/reserve/quota_reserve/third", "")
+		assert.Equal(t, http.StatusConflict, code)
+		assert.Contains(t, response["error"], "at most 2 counters")
+		assert.Equal(t, int64(0), Client.Exists(context.Background(), "K:quota_reserve:third").Val())
+	})
+
+	t.Run("Transactions that create counters take a slot", func(t *testing.T) {
+		transaction := func(body string) (int, map[string]interface{}) {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("POST", "/transaction", strings.NewReader(body))
+			r.ServeHTTP(w, req)
+			var response map[string]interface{}
+			json.Unmarshal(w.Body.Bytes(), &response)
+			return w.Code, response
+		}
+		code, _ := transaction(`[{"op":"incr","namespace":"quota_tx","key":"first"},{"op":"incr","namespace":"quota_tx","key":"first"}]`)
+		assert.Equal(t, http.StatusOK, code)
+		assert.Equal(t, "1", countField("quota_tx"))
+		code, _ = transaction(`[{"op":"incr","namespace":"quota_tx","key":"first"},{"op":"incr","namespace":"quota_tx","key":"second"}]`)
+		assert.Equal(t, http.StatusOK, code)
+		assert.Equal(t, "2", countField("quota_tx"))
+
+		code, response := transaction(`[{"op":"decr","namespace":"quota_tx","key":"first"},{"op":"incr","namespace":"quota_tx","key":"third"}]`)
+		assert.Equal(t, http.StatusConflict, code)
+		assert.Equal(t, "operations[1]: "+namespaceFullError(2), response["error"])
+		assert.Equal(t, int64(0), Client.Exists(context.Background(), "K:quota_tx:third").Val())
+		assert.Equal(t, "3", Client.Get(context.Background(), "K:quota_tx:first").Val(), "nothing was changed")
+	})
+}
+
+// slowRedis delays every command by delay, giving up early if the context of the command is done first.
+type slowRedis struct {
+	delay time.Duration
+}
+
+func (h slowRedis) DialHook(next redis.DialHook) redis.DialHook {
+	return next
+}
+
+func (h slowRedis) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		if err := h.wait(ctx); err != nil {
+			cmd.SetErr(err)
+			return err
+		}
+		return next(ctx, cmd)
+	}
+}
+
+func (h slowRedis) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		if err := h.wait(ctx); err != nil {
+			for _, cmd := range cmds {
+				cmd.SetErr(err)
+			}
+			return err
+		}
+		return next(ctx, cmds)
+	}
+}
+
+func (h slowRedis) wait(ctx context.Context) error {
+	select {
+	case <-time.After(h.delay):
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func TestRedisTimeout(t *testing.T) {
+	originalClient, originalTimeout := Client, RedisTimeout
+	defer func() { Client, RedisTimeout = originalClient, originalTimeout }()
+	RedisTimeout = 50 * time.Millisecond
+	r := setupTestRouter()
+	request := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", path, nil)
+		r.ServeHTTP(w, req)
+		return w
+	}
+	assert.Equal(t, http.StatusCreated, request("/create/timeout_ns/counter").Code)
+
+	Client = redis.NewClient(&redis.Options{Addr: originalClient.(*redis.Client).Options().Addr, ContextTimeoutEnabled: true})
+	Client.AddHook(slowRedis{delay: time.Second})
+	defer Client.Close()
+
+	t.Run("Slow calls fail fast", func(t *testing.T) {
+		for _, path := range []string{"/get/timeout_ns/counter", "/hit/timeout_ns/counter", "/info/timeout_ns/counter"} {
+			start := time.Now()
+			w := request(path)
+			assert.Equal(t, http.StatusServiceUnavailable, w.Code, path)
+			assert.Less(t, time.Since(start), 500*time.Millisecond, path)
+		}
+	})
+
+	t.Run("Authorizing requests is bound by the deadline too", func(t *testing.T) {
+		slowRouter := setupTestRouter() // the auth middleware holds on to the client it was set up with
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/export/timeout_ns", nil)
+		req.Header.Set("Authorization", "Bearer some-token")
+		start := time.Now()
+		slowRouter.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+		assert.Less(t, time.Since(start), 500*time.Millisecond)
+	})
+
+	t.Run("Calls within the deadline succeed", func(t *testing.T) {
+		Client.Close()
+		Client = redis.NewClient(&redis.Options{Addr: originalClient.(*redis.Client).Options().Addr, ContextTimeoutEnabled: true})
+		Client.AddHook(slowRedis{delay: time.Millisecond})
+		w := request("/get/timeout_ns/counter")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.JSONEq(t, `{"value": 0}`, w.Body.String())
+	})
+}
+
+func TestRedisBreaker(t *testing.T) {
+	originalClient, originalBreaker := Client, RedisBreaker
+	defer func() { Client, RedisBreaker = originalClient, originalBreaker }()
+	listener, _ := net.Listen("tcp", "127.0.0.1:0")
+	addr := listener.Addr().String()
+	listener.Close() // nothing listens there anymore
+	RedisBreaker = utils.NewBreaker(2, time.Minute)
+	Client = redis.NewClient(&redis.Options{Addr: addr, MaxRetries: -1})
+	Client.AddHook(RedisBreaker)
+	defer Client.Close()
+	r := setupTestRouter()
+	request := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", path, nil)
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	assert.Equal(t, http.StatusInternalServerError, request("/get/breaker_ns/counter").Code)
+	w := request("/get/breaker_ns/counter")
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code, "the failure that opens the breaker")
+	assert.Equal(t, "60", w.Header().Get("Retry-After"))
+
+	w = request("/hit/breaker_ns/counter")
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.NotEmpty(t, w.Header().Get("Retry-After"))
+
+	w = request("/healthcheck")
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	var response map[string]interface{}
+	json.Unmarshal(w.Body.Bytes(), &response)
+	assert.Equal(t, utils.ErrCircuitOpen.Error(), response["checks"].(map[string]interface{})["redis"])
+	breaker := response["breaker"].(map[string]interface{})
+	assert.Equal(t, utils.BreakerOpen, breaker["state"])
+	assert.GreaterOrEqual(t, breaker["consecutive_failures"], float64(2))
+}
+
+func TestReplicaBreaker(t *testing.T) {
+	listener, _ := net.Listen("tcp", "127.0.0.1:0")
+	addr := listener.Addr().String()
+	listener.Close() // the replica is down
+	ReplicaBreaker = utils.NewBreaker(1, time.Minute)
+	ReplicaClient = redis.NewClient(&redis.Options{Addr: addr, MaxRetries: -1})
+	ReplicaClient.AddHook(ReplicaBreaker)
+	defer func() { ReplicaClient.Close(); ReplicaClient, ReplicaBreaker = nil, nil }()
+	r := setupTestRouter()
+	request := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", path, nil)
+		r.ServeHTTP(w, req)
+		return w
+	}
+	Client.Set(context.Background(), "K:replica_breaker_ns:counter", 3, 0)
+
+	assert.Equal(t, http.StatusInternalServerError, request("/get/replica_breaker_ns/counter").Code, "the failure that opens the breaker")
+	w := request("/get/replica_breaker_ns/counter")
+	assert.Equal(t, http.StatusOK, w.Code, "read from the primary")
+	assert.JSONEq(t, `{"value": 3}`, w.Body.String())
+
+	var response map[string]interface{}
+	json.Unmarshal(request("/healthcheck").Body.Bytes(), &response)
+	assert.Equal(t, utils.BreakerOpen, response["replica_breaker"].(map[string]interface{})["state"])
+}
+
+func TestReadReplica(t *testing.T) {
+	replica := miniredis.RunT(t)
+	ReplicaClient = redis.NewClient(&redis.Options{Addr: replica.Addr()})
+	defer func() { ReplicaClient.Close(); ReplicaClient = nil }()
+	r := setupTestRouter()
+	request := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", path, nil)
+		r.ServeHTTP(w, req)
+		return w
+	}
+	ctx := context.Background()
+	Client.Set(ctx, "K:replica_ns:counter", 1, 0)
+	replica.Set("K:replica_ns:counter", "5") // the replica lags behind
+
+	t.Run("Reads use the replica", func(t *testing.T) {
+		assert.JSONEq(t, `{"value": 5}`, request("/get/replica_ns/counter").Body.String())
+		var info map[string]interface{}
+		json.Unmarshal(request("/info/replica_ns/counter").Body.Bytes(), &info)
+		assert.Equal(t, float64(5), info["value"])
+		var list map[string]interface{}
+		json.Unmarshal(request("/list/replica_ns").Body.Bytes(), &list)
+		assert.Equal(t, []interface{}{map[string]interface{}{"key": "counter", "value": float64(5)}}, list["keys"])
+	})
+
+	t.Run("Writes use the primary", func(t *testing.T) {
+		assert.JSONEq(t, `{"value": 2}`, request("/hit/replica_ns/counter").Body.String())
+		assert.Equal(t, "2", Client.Get(ctx, "K:replica_ns:counter").Val())
+		value, _ := replica.Get("K:replica_ns:counter")
+		assert.Equal(t, "5", value)
+	})
+
+	t.Run("Health includes the replica", func(t *testing.T) {
+		var response map[string]interface{}
+		json.Unmarshal(request("/healthcheck").Body.Bytes(), &response)
+		assert.Equal(t, "ok", response["checks"].(map[string]interface{})["redis_replica"])
+	})
+}
+
+func TestAdminURL(t *testing.T) {
+	r := setupTestRouter()
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "http://counter.test/create/admin_url_ns/counter?visibility=private", nil)
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	var response map[string]interface{}
+	json.Unmarshal(w.Body.Bytes(), &response)
+	adminKey := response["admin_key"].(string)
+	assert.Equal(t, "http://counter.test/info/admin_url_ns/counter?token="+adminKey, response["admin_url"])
+
+	t.Run("The url manages the counter", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", response["admin_url"].(string), nil)
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code) // private counters need the admin key
+	})
+
+	t.Run("Only handed out on creation", func(t *testing.T) {
+		for _, path := range []string{"/create/admin_url_ns/counter", "/info/admin_url_ns/counter?token=" + adminKey} {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", path, nil)
+			r.ServeHTTP(w, req)
+			assert.NotContains(t, w.Body.String(), adminKey, path)
+		}
+	})
+
+	t.Run("Scheme forwarded by trusted proxies", func(t *testing.T) {
+		TrustedProxies = []string{"198.51.100.0/24"}
+		defer func() { TrustedProxies = nil }()
+		for remote, scheme := range map[string]string{"198.51.100.7:1234": "https", "203.0.113.50:1234": "http"} {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("POST", "http://counter.test/create/admin_url_ns/proxied_"+scheme, nil)
+			req.RemoteAddr = remote
+			req.Header.Set("X-Forwarded-Proto", "https")
+			r.ServeHTTP(w, req)
+			assert.Equal(t, http.StatusCreated, w.Code)
+			var response map[string]interface{}
+			json.Unmarshal(w.Body.Bytes(), &response)
+			assert.True(t, strings.HasPrefix(response["admin_url"].(string), scheme+"://counter.test/"), remote)
+		}
+	})
+
+	t.Run("Public url", func(t *testing.T) {
+		PublicURL = "https://abacus.example.com"
+		defer func() { PublicURL = "" }()
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/create/admin_url_ns/public", nil)
+		r.ServeHTTP(w, req)
+		var response map[string]interface{}
+		json.Unmarshal(w.Body.Bytes(), &response)
+		assert.Equal(t, "https://abacus.example.com/info/admin_url_ns/public?token="+response["admin_key"].(string), response["admin_url"])
+	})
+}
+
+func TestTransaction(t *testing.T) {
+	r := setupTestRouter()
+	transaction := func(body, token string) (int, string) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/transaction", strings.NewReader(body))
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		r.ServeHTTP(w, req)
+		return w.Code, w.Body.String()
+	}
+	create := func(path string) map[string]interface{} {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", path, nil)
+		r.ServeHTTP(w, req)
+		var response map[string]interface{}
+		json.Unmarshal(w.Body.Bytes(), &response)
+		return response
+	}
+	ctx := context.Background()
+	create("/create/transaction_ns/source?initial=10&min=0")
+	create("/create/transaction_ns/dest")
+
+	t.Run("Transfer", func(t *testing.T) {
+		code, body := transaction(`[{"op":"decr","namespace":"transaction_ns","key":"source","step":4},{"op":"incr","namespace":"transaction_ns","key":"dest","step":4}]`, "")
+		assert.Equal(t, http.StatusOK, code)
+		assert.JSONEq(t, `[{"namespace":"transaction_ns","key":"source","value":6},{"namespace":"transaction_ns","key":"dest","value":4}]`, body)
+	})
+
+	t.Run("All or nothing", func(t *testing.T) {
+		code, body := transaction(`[{"op":"incr","namespace":"transaction_ns","key":"dest","step":7},{"op":"decr","namespace":"transaction_ns","key":"source","step":7}]`, "")
+		assert.Equal(t, http.StatusConflict, code, body) // the floor of the source isn't clamped to
+		assert.JSONEq(t, `{"error":"operations[1]: Counter has reached its min value of 0","index":1,"value":6}`, body)
+		assert.Equal(t, "4", Client.Get(ctx, "K:transaction_ns:dest").Val())
+		assert.Equal(t, "6", Client.Get(ctx, "K:transaction_ns:source").Val())
+
+		// repeated counters see each other's changes
+		code, _ = transaction(`[{"op":"decr","namespace":"transaction_ns","key":"source","step":4},{"op":"decr","namespace":"transaction_ns","key":"source","step":4}]`, "")
+		assert.Equal(t, http.StatusConflict, code)
+		assert.Equal(t, "6", Client.Get(ctx, "K:transaction_ns:source").Val())
+	})
+
+	t.Run("Overflows change nothing", func(t *testing.T) {
+		create("/create/transaction_ns/huge?initializer=9223372036854775806")
+		code, body := transaction(`[{"op":"incr","namespace":"transaction_ns","key":"dest"},{"op":"incr","namespace":"transaction_ns","key":"huge"},{"op":"incr","namespace":"transaction_ns","key":"huge"}]`, "")
+		assert.Equal(t, http.StatusConflict, code, body)
+		assert.JSONEq(t, `{"error":"operations[2]: `+overflowError+`","index":2,"value":9223372036854775807}`, body)
+		assert.Equal(t, "4", Client.Get(ctx, "K:transaction_ns:dest").Val())
+		assert.Equal(t, "9223372036854775806", Client.Get(ctx, "K:transaction_ns:huge").Val())
+
+		code, body = transaction(`[{"op":"decr","namespace":"transaction_ns","key":"huge","step":9223372036854775806},{"op":"incr","namespace":"transaction_ns","key":"huge"}]`, "")
+		assert.Equal(t, http.StatusOK, code)
+		assert.JSONEq(t, `[{"namespace":"transaction_ns","key":"huge","value":0},{"namespace":"transaction_ns","key":"huge","value":1}]`, body)
+	})
+
+	t.Run("Float counters", func(t *testing.T) {
+		create("/create/transaction_ns/float?type=float")
+		code, body := transaction(`[{"op":"incr","namespace":"transaction_ns","key":"float","step":1.5},{"op":"decr","namespace":"transaction_ns","key":"dest","step":1.5}]`, "")
+		assert.Equal(t, http.StatusConflict, code, "integer counters only take integer steps")
+		assert.Contains(t, body, "operations[1].step must be an integer")
+		assert.Equal(t, "0", Client.Get(ctx, "K:transaction_ns:float").Val())
+
+		code, body = transaction(`[{"op":"incr","namespace":"transaction_ns","key":"float","step":1.5}]`, "")
+		assert.Equal(t, http.StatusOK, code)
+		assert.JSONEq(t, `[{"namespace":"transaction_ns","key":"float","value":1.5}]`, body)
+	})
+
+	t.Run("Private counters need a token", func(t *testing.T) {
+		adminKey := create("/create/transaction_ns/private?visibility=private")["admin_key"].(string)
+		body := `[{"op":"incr","namespace":"transaction_ns","key":"private"}]`
+		code, _ := transaction(body, "")
+		assert.Equal(t, http.StatusUnauthorized, code)
+		code, response := transaction(body, adminKey)
+		assert.Equal(t, http.StatusOK, code)
+		assert.JSONEq(t, `[{"namespace":"transaction_ns","key":"private","value":1}]`, response)
+	})
+
+	t.Run("Invalid operations", func(t *testing.T) {
+		for _, body := range []string{
+			`[]`,
+			`{"op":"incr"}`,
+			`[{"op":"set","namespace":"transaction_ns","key":"dest"}]`,
+			`[{"op":"incr","namespace":"transaction_ns","key":"dest","step":-1}]`,
+			`[{"op":"incr","namespace":"transaction_ns","key":"dest","step":0}]`,
+			`[{"op":"incr","namespace":"transaction_ns","key":"a b"}]`,
+		} {
+			code, _ := transaction(body, "")
+			assert.Equal(t, http.StatusBadRequest, code, body)
+		}
+		assert.Equal(t, "4", Client.Get(ctx, "K:transaction_ns:dest").Val())
+	})
+}
+
+func TestCounterTags(t *testing.T) {
+	r := setupTestRouter()
+	request := func(method, path string) (int, map[string]interface{}) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, path, nil)
+		r.ServeHTTP(w, req)
+		var response map[string]interface{}
+		json.Unmarshal(w.Body.Bytes(), &response)
+		return w.Code, response
+	}
+	for _, path := range []string{
+		"/create/tags_ns/web_prod?tag=env:prod&tag=team:web",
+		"/create/tags_ns/web_dev?tag=env:dev&tag=team:web",
+		"/create/tags_ns/untagged",
+	} {
+		code, _ := request("POST", path)
+		assert.Equal(t, http.StatusCreated, code, path)
+	}
+
+	t.Run("Info shows the tags", func(t *testing.T) {
+		_, response := request("GET", "/info/tags_ns/web_prod")
+		assert.Equal(t, map[string]interface{}{"env": "prod", "team": "web"}, response["tags"])
+		_, response = request("GET", "/info/tags_ns/untagged")
+		assert.Equal(t, map[string]interface{}{}, response["tags"])
+	})
+
+	t.Run("List filters by tag", func(t *testing.T) {
+		listed := func(query string) []string {
+			code, response := request("GET", "/list/tags_ns"+query)
+			assert.Equal(t, http.StatusOK, code, query)
+			keys := make([]string, 0)
+			for _, counter := range response["keys"].([]interface{}) {
+				keys = append(keys, counter.(map[string]interface{})["key"].(string))
+			}
+			sort.Strings(keys)
+			return keys
+		}
+		assert.Equal(t, []string{"untagged", "web_dev", "web_prod"}, listed(""))
+		assert.Equal(t, []string{"web_dev", "web_prod"}, listed("?tag=team:web"))
+		assert.Equal(t, []string{"web_prod"}, listed("?tag=team:web&tag=env:prod"))
+		assert.Equal(t, []string{}, listed("?tag=env:staging"))
+	})
+
+	t.Run("Invalid tags", func(t *testing.T) {
+		code, _ := request("POST", "/create/tags_ns/invalid?tag=env")
+		assert.Equal(t, http.StatusBadRequest, code)
+		code, _ = request("GET", "/list/tags_ns?tag=env")
+		assert.Equal(t, http.StatusBadRequest, code)
+	})
+}
+
+func TestProtobuf(t *testing.T) {
+	r := setupTestRouter()
+	request := func(path, accept string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", path, nil)
+		req.Header.Set("Accept", accept)
+		r.ServeHTTP(w, req)
+		return w
+	}
+	const protobufType = "application/x-protobuf"
+	request("/create/protobuf_ns/counter?initial=41&min=0&tag=env:prod", "")
+	request("/create/protobuf_ns/float?type=float&initial=1.5", "")
+
+	t.Run("Hit and get", func(t *testing.T) {
+		w := request("/hit/protobuf_ns/counter", protobufType)
+		assert.Equal(t, protobufType, w.Header().Get("Content-Type"))
+		var counter pb.Counter
+		assert.NoError(t, proto.Unmarshal(w.Body.Bytes(), &counter))
+		assert.Equal(t, int64(42), counter.GetIntValue())
+
+		w = request("/get/protobuf_ns/float", protobufType)
+		assert.NoError(t, proto.Unmarshal(w.Body.Bytes(), &counter))
+		assert.Equal(t, 1.5, counter.GetFloatValue())
+
+		w = request("/dec/protobuf_ns/counter?step=100", protobufType)
+		assert.NoError(t, proto.Unmarshal(w.Body.Bytes(), &counter))
+		assert.Equal(t, int64(0), counter.GetIntValue())
+		assert.True(t, counter.GetClamped())
+	})
+
+	t.Run("Info", func(t *testing.T) {
+		w := request("/info/protobuf_ns/counter", protobufType)
+		var info pb.CounterInfo
+		assert.NoError(t, proto.Unmarshal(w.Body.Bytes(), &info))
+		assert.Equal(t, "int", info.GetType())
+		assert.Equal(t, map[string]string{"env": "prod"}, info.GetTags())
+		assert.Equal(t, float64(0), info.GetMin())
+		assert.Nil(t, info.Max)
+		assert.NotNil(t, info.CreatedAt)
+		assert.True(t, info.GetExists())
+		assert.Equal(t, "K:protobuf_ns:counter", info.GetFullKey())
+	})
+
+	t.Run("JSON stays the default", func(t *testing.T) {
+		for _, accept := range []string{"", "*/*", "application/json"} {
+			w := request("/get/protobuf_ns/counter", accept)
+			assert.JSONEq(t, `{"value": 0}`, w.Body.String(), accept)
+		}
+	})
+}
+
+func TestCamelCaseResponses(t *testing.T) {
+	r := setupTestRouter()
+	request := func(path, accept string) map[string]interface{} {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", path, nil)
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code, path)
+		var response map[string]interface{}
+		json.Unmarshal(w.Body.Bytes(), &response)
+		return response
+	}
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/create/casing_ns/counter?tag=build_id:42", nil)
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	t.Run("Snake case by default", func(t *testing.T) {
+		response := request("/info/casing_ns/counter", "")
+		assert.Contains(t, response, "last_updated")
+		assert.Contains(t, response, "is_genuine")
+		assert.NotContains(t, response, "lastUpdated")
+	})
+
+	t.Run("Camel case via the query", func(t *testing.T) {
+		response := request("/info/casing_ns/counter?case=camel", "")
+		assert.Contains(t, response, "lastUpdated")
+		assert.Contains(t, response, "isGenuine")
+		assert.Contains(t, response, "fullKey")
+		assert.NotContains(t, response, "last_updated")
+		// tags are data, their names are kept as is
+		assert.Equal(t, map[string]interface{}{"build_id": "42"}, response["tags"])
+	})
+
+	t.Run("Camel case via Accept", func(t *testing.T) {
+		response := request("/info/casing_ns/counter", "application/json; case=camel")
+		assert.Contains(t, response, "lastUpdated")
+		response = request("/stats", "application/json; case=camel")
+		assert.Contains(t, response, "dbPool")
+		assert.Contains(t, response["dbPool"], "totalConns")
+	})
+
+	t.Run("Errors too", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/get/casing_ns/missing?case=camel", nil)
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+		assert.Contains(t, w.Body.String(), `"error"`)
+	})
+}
+
+func TestRateLimitKey(t *testing.T) {
+	os.Setenv("RATE_LIMIT_ENABLED", "true")
+	defer os.Unsetenv("RATE_LIMIT_ENABLED")
+	get := func(r *gin.Engine, remoteAddr, forwardedFor, apiKey string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/healthcheck", nil)
+		req.RemoteAddr = remoteAddr
+		if forwardedFor != "" {
+			req.Header.Set("X-Forwarded-For", forwardedFor)
+		}
+		if apiKey != "" {
+			req.Header.Set(middleware.APIKeyHeader, apiKey)
+		}
+		r.ServeHTTP(w, req)
+		return w
+	}
+	remaining := func(w *httptest.ResponseRecorder) string { return w.Header().Get("X-RateLimit-Remaining") }
+
+	t.Run("Forged X-Forwarded-For is ignored", func(t *testing.T) {
+		r := setupTestRouter()
+		assert.Equal(t, "29", remaining(get(r, "198.51.100.1:1234", "192.0.2.10", "")))
+		// a client making up a new address for every request still shares one budget
+		assert.Equal(t, "28", remaining(get(r, "198.51.100.1:1234", "192.0.2.11", "")))
+		assert.Equal(t, "27", remaining(get(r, "198.51.100.1:1234", "", "")))
+	})
+
+	t.Run("Trusted proxies forward the client IP", func(t *testing.T) {
+		TrustedProxies = []string{"198.51.100.0/24"}
+		defer func() { TrustedProxies = nil }()
+		r := setupTestRouter()
+		assert.Equal(t, "29", remaining(get(r, "198.51.100.2:1234", "192.0.2.20", "")))
+		assert.Equal(t, "29", remaining(get(r, "198.51.100.2:1234", "192.0.2.21", "")))
+		assert.Equal(t, "28", remaining(get(r, "198.51.100.3:1234", "192.0.2.20", "")))
+		// only the addresses added by trusted proxies count, not what the client prepended
+		assert.Equal(t, "27", remaining(get(r, "198.51.100.2:1234", "192.0.2.99, 192.0.2.20", "")))
+		// a client outside the allowlist can't claim to be someone else
+		assert.Equal(t, "29", remaining(get(r, "203.0.113.50:1234", "192.0.2.21", "")))
+	})
+
+	t.Run("Registered API keys get their own budget", func(t *testing.T) {
+		middleware.RateLimitKey = middleware.RateLimitByAPIKey
+		defer func() { middleware.RateLimitKey = middleware.RateLimitByIP }()
+		for _, apiKey := range []string{"first-client", "second-client"} {
+			RateLimitClient.HSet(context.Background(), middleware.CreateTierKey(apiKey), "registered", 1)
+			defer RateLimitClient.Del(context.Background(), middleware.CreateTierKey(apiKey))
+		}
+		r := setupTestRouter()
+		assert.Equal(t, "29", remaining(get(r, "198.51.100.4:1234", "", "first-client")))
+		assert.Equal(t, "29", remaining(get(r, "198.51.100.4:1234", "", "second-client")))
+		assert.Equal(t, "28", remaining(get(r, "198.51.100.5:1234", "", "first-client")))
+		// requests without a key fall back to their IP
+		assert.Equal(t, "29", remaining(get(r, "198.51.100.4:1234", "", "")))
+	})
+
+	t.Run("Unknown API keys share the budget of their IP", func(t *testing.T) {
+		middleware.RateLimitKey = middleware.RateLimitByAPIKey
+		defer func() { middleware.RateLimitKey = middleware.RateLimitByIP }()
+		r := setupTestRouter()
+		for i := 0; i < 5; i++ {
+			assert.Equal(t, strconv.Itoa(29-i), remaining(get(r, "198.51.100.6:1234", "", "made-up-"+strconv.Itoa(i))))
+		}
+		assert.Equal(t, "24", remaining(get(r, "198.51.100.6:1234", "", "")))
+	})
+
+	t.Run("IPv6 clients share the budget of their /64", func(t *testing.T) {
+		r := setupTestRouter()
+		assert.Equal(t, "29", remaining(get(r, "[2001:db8:aa:1::1]:1234", "", "")))
+		assert.Equal(t, "28", remaining(get(r, "[2001:db8:aa:1:ffff::2]:1234", "", "")))
+		assert.Equal(t, "29", remaining(get(r, "[2001:db8:aa:2::1]:1234", "", "")))
+	})
+
+	t.Run("IPv4 prefixes", func(t *testing.T) {
+		middleware.RateLimitIPv4Prefix = 24
+		defer func() { middleware.RateLimitIPv4Prefix = 32 }()
+		r := setupTestRouter()
+		assert.Equal(t, "29", remaining(get(r, "192.0.2.130:1234", "", "")))
+		assert.Equal(t, "28", remaining(get(r, "192.0.2.131:1234", "", "")))
+		assert.Equal(t, "29", remaining(get(r, "192.0.3.130:1234", "", "")))
+	})
+}
+
+func TestCounterHistory(t *testing.T) {
+	r := setupTestRouter()
+	request := func(method, path string) (int, map[string]interface{}) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, path, nil)
+		r.ServeHTTP(w, req)
+		var response map[string]interface{}
+		json.Unmarshal(w.Body.Bytes(), &response)
+		return w.Code, response
+	}
+	code, created := request("POST", "/create/history_ns/tracked?history=true")
+	assert.Equal(t, http.StatusCreated, code)
+	code, _ = request("POST", "/create/history_ns/untracked")
+	assert.Equal(t, http.StatusCreated, code)
+
+	for i := 0; i < 3; i++ {
+		request("GET", "/hit/history_ns/tracked")
+	}
+	request("GET", "/hit/history_ns/tracked?step=5")
+	request("GET", "/dec/history_ns/tracked")
+
+	deltas := func(response map[string]interface{}) []float64 {
+		var deltas []float64
+		for _, bucket := range response["buckets"].([]interface{}) {
+			deltas = append(deltas, bucket.(map[string]interface{})["delta"].(float64))
+		}
+		return deltas
+	}
+
+	t.Run("Changes are bucketed", func(t *testing.T) {
+		code, response := request("GET", "/history/history_ns/tracked")
+		assert.Equal(t, http.StatusOK, code)
+		assert.Equal(t, float64(3600), response["bucket"])
+		buckets := deltas(response)
+		assert.Len(t, buckets, 24)
+		assert.Equal(t, float64(7), buckets[len(buckets)-1]) // 3 hits, one of 5 and a decrement, all just now
+		total := 0.0
+		for _, delta := range buckets {
+			total += delta
+		}
+		assert.Equal(t, float64(7), total)
+	})
+
+	t.Run("Range outside of the changes", func(t *testing.T) {
+		now := time.Now().Unix()
+		path := "/history/history_ns/tracked?bucket=1m&from=" + strconv.FormatInt(now-7200, 10) + "&to=" + strconv.FormatInt(now-3600, 10)
+		code, response := request("GET", path)
+		assert.Equal(t, http.StatusOK, code)
+		buckets := deltas(response)
+		assert.Len(t, buckets, 60)
+		assert.Equal(t, make([]float64, 60), buckets)
+	})
+
+	t.Run("Dates in the server's timezone", func(t *testing.T) {
+		code, response := request("GET", "/history/history_ns/tracked?bucket=24h&from=2024-04-30&to=2024-05-02")
+		assert.Equal(t, http.StatusOK, code)
+		assert.Equal(t, "UTC", response["timezone"])
+		assert.Equal(t, float64(1714435200), response["from"])
+		bucket := response["buckets"].([]interface{})[1].(map[string]interface{})
+		assert.Equal(t, "2024-05-01T00:00:00Z", bucket["date"])
+	})
+
+	t.Run("Counters without history", func(t *testing.T) {
+		code, _ := request("GET", "/history/history_ns/untracked")
+		assert.Equal(t, http.StatusConflict, code)
+		code, _ = request("GET", "/history/history_ns/missing")
+		assert.Equal(t, http.StatusNotFound, code)
+		_, info := request("GET", "/info/history_ns/tracked")
+		assert.Equal(t, true, info["history"])
+	})
+
+	t.Run("Invalid parameters", func(t *testing.T) {
+		for _, query := range []string{"?bucket=soon", "?bucket=1ms", "?from=yesterday", "?from=2000000000&to=1000000000", "?bucket=1s&from=0"} {
+			code, _ := request("GET", "/history/history_ns/tracked"+query)
+			assert.Equal(t, http.StatusBadRequest, code, query)
+		}
+	})
+
+	t.Run("Deleted along with the counter", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/delete/history_ns/tracked", nil)
+		req.Header.Set("Authorization", "Bearer "+created["admin_key"].(string))
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+		exists, _ := Client.Exists(context.Background(), utils.CreateHistoryKey(utils.BuildDBKey("history_ns", "tracked"))).Result()
+		assert.Equal(t, int64(0), exists)
+	})
+}
+
+func TestDeleteAtZero(t *testing.T) {
+	r := setupTestRouter()
+	request := func(method, path string) (int, map[string]interface{}) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, path, nil)
+		r.ServeHTTP(w, req)
+		var response map[string]interface{}
+		json.Unmarshal(w.Body.Bytes(), &response)
+		return w.Code, response
+	}
+	dbKey := utils.BuildDBKey("refcount_ns", "resource")
+	code, _ := request("POST", "/create/refcount_ns/resource?initializer=2&delete_at_zero=true")
+	assert.Equal(t, http.StatusCreated, code)
+	_, info := request("GET", "/info/refcount_ns/resource")
+	assert.Equal(t, true, info["delete_at_zero"])
+
+	t.Run("Dry runs preview the deletion", func(t *testing.T) {
+		_, response := request("GET", "/dec/refcount_ns/resource?step=2&dry_run=true")
+		assert.Equal(t, true, response["deleted"])
+		assert.Equal(t, float64(0), response["next_value"])
+		code, _ := request("GET", "/get/refcount_ns/resource")
+		assert.Equal(t, http.StatusOK, code)
+	})
+
+	t.Run("Decrementing to zero deletes the counter", func(t *testing.T) {
+		code, response := request("GET", "/dec/refcount_ns/resource")
+		assert.Equal(t, http.StatusOK, code)
+		assert.Equal(t, float64(1), response["value"])
+		assert.NotContains(t, response, "deleted")
+
+		code, response = request("GET", "/dec/refcount_ns/resource")
+		assert.Equal(t, http.StatusOK, code)
+		assert.Equal(t, float64(0), response["value"])
+		assert.Equal(t, true, response["deleted"])
+
+		code, _ = request("GET", "/get/refcount_ns/resource")
+		assert.Equal(t, http.StatusNotFound, code)
+		for _, key := range []string{dbKey, utils.CreateMetaKey(dbKey), utils.CreateAdminKey(dbKey)} {
+			exists, _ := Client.Exists(context.Background(), key).Result()
+			assert.Equal(t, int64(0), exists, key)
+		}
+		counters, _ := Client.HGet(context.Background(), utils.CreateNamespaceKey("refcount_ns"), "counters").Int()
+		assert.Equal(t, 0, counters)
+	})
+
+	t.Run("Decrementing past the min deletes the counter", func(t *testing.T) {
+		code, _ := request("POST", "/create/refcount_ns/floored?initializer=3&min=1&delete_at_zero=true")
+		assert.Equal(t, http.StatusCreated, code)
+		code, response := request("GET", "/dec/refcount_ns/floored?step=5")
+		assert.Equal(t, http.StatusOK, code)
+		assert.Equal(t, float64(1), response["value"])
+		assert.Equal(t, true, response["deleted"])
+		code, _ = request("GET", "/get/refcount_ns/floored")
+		assert.Equal(t, http.StatusNotFound, code)
+	})
+
+	t.Run("Increments and other counters are unaffected", func(t *testing.T) {
+		code, _ := request("POST", "/create/refcount_ns/regular?initializer=1")
+		assert.Equal(t, http.StatusCreated, code)
+		_, response := request("GET", "/dec/refcount_ns/regular")
+		assert.Equal(t, float64(0), response["value"])
+		code, _ = request("GET", "/get/refcount_ns/regular")
+		assert.Equal(t, http.StatusOK, code)
+
+		code, _ = request("POST", "/create/refcount_ns/negative?initializer=-2&delete_at_zero=true")
+		assert.Equal(t, http.StatusCreated, code)
+		_, response = request("GET", "/hit/refcount_ns/negative")
+		assert.Equal(t, float64(-1), response["value"])
+		code, _ = request("GET", "/get/refcount_ns/negative")
+		assert.Equal(t, http.StatusOK, code)
+	})
+}
+
+func TestReadOnlyMode(t *testing.T) {
+	OperatorToken = "operator-secret"
+	defer func() { OperatorToken = "" }()
+	r := setupTestRouter()
+	request := func(method, path, token string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, path, nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		r.ServeHTTP(w, req)
+		return w
+	}
+	w := request("POST", "/create/readonly_ns/counter?initializer=5", "")
+	assert.Equal(t, http.StatusCreated, w.Code)
+	var created map[string]interface{}
+	json.Unmarshal(w.Body.Bytes(), &created)
+	adminKey := created["admin_key"].(string)
+
+	t.Run("Toggling requires the operator token", func(t *testing.T) {
+		assert.Equal(t, http.StatusUnauthorized, request("POST", "/maintenance?read_only=true", "").Code)
+		assert.Equal(t, http.StatusUnauthorized, request("POST", "/maintenance?read_only=true", adminKey).Code)
+		assert.Equal(t, http.StatusBadRequest, request("POST", "/maintenance?read_only=maybe", "operator-secret").Code)
+		assert.False(t, middleware.IsReadOnly())
+	})
+
+	t.Run("Writes are rejected while reads keep working", func(t *testing.T) {
+		w := request("POST", "/maintenance?read_only=true", "operator-secret")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.JSONEq(t, `{"read_only": true}`, w.Body.String())
+		defer middleware.SetReadOnly(false, "test")
+
+		for _, write := range [][2]string{
+			{"GET", "/hit/readonly_ns/counter"},
+			{"POST", "/create/readonly_ns/other"},
+			{"POST", "/set/readonly_ns/counter?value=1"},
+			{"POST", "/reset/readonly_ns/counter"},
+			{"POST", "/update/readonly_ns/counter?value=1"},
+			{"POST", "/delete/readonly_ns/counter"},
+		} {
+			w := request(write[0], write[1], adminKey)
+			assert.Equal(t, http.StatusServiceUnavailable, w.Code, write[1])
+			assert.Contains(t, w.Body.String(), "read-only mode", write[1])
+		}
+		assert.Equal(t, http.StatusOK, request("GET", "/get/readonly_ns/counter", "").Code)
+		assert.Equal(t, http.StatusOK, request("GET", "/info/readonly_ns/counter", "").Code)
+		val, _ := Client.Get(context.Background(), utils.BuildDBKey("readonly_ns", "counter")).Int()
+		assert.Equal(t, 5, val)
+		assert.Contains(t, request("GET", "/healthcheck", "").Body.String(), `"read_only":true`)
+	})
+
+	t.Run("Writes work again once it is turned off", func(t *testing.T) {
+		w := request("POST", "/maintenance?read_only=false", "operator-secret")
+		assert.JSONEq(t, `{"read_only": false}`, w.Body.String())
+		assert.Equal(t, http.StatusOK, request("GET", "/hit/readonly_ns/counter", "").Code)
+	})
+}
+
+func TestPurgeNamespace(t *testing.T) {
+	r := setupTestRouter()
+
+	claimW := httptest.NewRecorder()
+	claimReq, _ := http.NewRequest("POST", "/namespace/purge_ns/token", nil)
+	r.ServeHTTP(claimW, claimReq)
+	assert.Equal(t, http.StatusCreated, claimW.Code)
+	var claimResponse map[string]interface{}
+	json.Unmarshal(claimW.Body.Bytes(), &claimResponse)
+	namespaceToken := claimResponse["admin_key"].(string)
+
+	for _, path := range []string{"/create/purge_ns/first?max=10", "/create/purge_ns/second", "/create/purge_keep/other"} {
+		createW := httptest.NewRecorder()
+		createReq, _ := http.NewRequest("POST", path, nil)
+		r.ServeHTTP(createW, createReq)
+		assert.Equal(t, http.StatusCreated, createW.Code)
+	}
+
+	t.Run("Without confirmation", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/purge/purge_ns", nil)
+		req.Header.Set("Authorization", "Bearer "+namespaceToken)
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+
+		getW := httptest.NewRecorder()
+		getReq, _ := http.NewRequest("GET", "/get/purge_ns/first", nil)
+		r.ServeHTTP(getW, getReq)
+		assert.Equal(t, http.StatusOK, getW.Code)
+	})
+
+	t.Run("Without the namespace token", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/purge/purge_ns?confirm=true", nil)
+		req.Header.Set("Authorization", "Bearer wrong-token")
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+
+	t.Run("Purge", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/purge/purge_ns?confirm=true", nil)
+		req.Header.Set("Authorization", "Bearer "+namespaceToken)
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+		var response map[string]interface{}
+		json.Unmarshal(w.Body.Bytes(), &response)
+		assert.Equal(t, float64(2), response["deleted"])
+
+		for _, path := range []string{"/get/purge_ns/first", "/get/purge_ns/second"} {
+			getW := httptest.NewRecorder()
+			getReq, _ := http.NewRequest("GET", path, nil)
+			r.ServeHTTP(getW, getReq)
+			assert.Equal(t, http.StatusNotFound, getW.Code)
+		}
+		assert.Zero(t, Client.Exists(context.Background(), utils.CreateMetaKey(utils.BuildDBKey("purge_ns", "first"))).Val())
+
+		getW := httptest.NewRecorder()
+		getReq, _ := http.NewRequest("GET", "/get/purge_keep/other", nil)
+		r.ServeHTTP(getW, getReq)
+		assert.Equal(t, http.StatusOK, getW.Code)
+
+		// the namespace is still claimed
+		ok, err := utils.CheckNamespaceToken(context.Background(), Client, "purge_ns", namespaceToken)
+		assert.NoError(t, err)
+		assert.True(t, ok)
+	})
+}
+
+func TestOpenAPI(t *testing.T) {
+	r := setupTestRouter()
+
+	t.Run("Every route is in the spec", func(t *testing.T) {
+		paths := BuildOpenAPI(r.Routes())["paths"].(gin.H)
+		for _, route := range r.Routes() {
+			if viewName(route.Handler) == staticView {
+				continue
+			}
+			path, _ := apiPath(route.Path)
+			operations, _ := paths[path].(gin.H)
+			assert.Contains(t, operations, strings.ToLower(route.Method), "%s %s (%s) is missing from the spec, document its view in apiOperations",
+				route.Method, route.Path, viewName(route.Handler))
+		}
+	})
+
+	t.Run("View names", func(t *testing.T) {
+		assert.Equal(t, "HitView", viewName("github.com/jasonlovesdoggo/abacus.HitView"))
+		assert.Equal(t, "OpenAPIView", viewName("github.com/jasonlovesdoggo/abacus.OpenAPIView.func1"))
+		assert.Equal(t, staticView, viewName("github.com/gin-gonic/gin.(*RouterGroup).StaticFile.func1"))
+	})
+
+	t.Run("Spec", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/openapi.json", nil)
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+		var spec map[string]interface{}
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &spec))
+		assert.Equal(t, "3.0.3", spec["openapi"])
+
+		paths := spec["paths"].(map[string]interface{})
+		hit := paths["/hit/{namespace}/{key}"].(map[string]interface{})["get"].(map[string]interface{})
+		assert.Equal(t, "getHit", hit["operationId"])
+		names := []string{}
+		for _, param := range hit["parameters"].([]interface{}) {
+			names = append(names, param.(map[string]interface{})["name"].(string))
+		}
+		assert.Subset(t, names, []string{"namespace", "key", "step"})
+
+		purge := paths["/purge/{namespace}"].(map[string]interface{})["post"].(map[string]interface{})
+		assert.NotEmpty(t, purge["security"])
+		assert.Contains(t, purge["responses"], "200")
+
+		// the same view on several routes still gets unique operation ids
+		deletes := map[string]bool{}
+		for _, path := range []string{"/delete/{namespace}/{key}", "/{namespace}/{key}"} {
+			deletes[paths[path].(map[string]interface{})["delete"].(map[string]interface{})["operationId"].(string)] = true
+		}
+		assert.Len(t, deletes, 2)
+	})
+
+	t.Run("Swagger UI", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/swagger", nil)
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), "/openapi.json")
+	})
+}
+
+func TestUniqueHits(t *testing.T) {
+	r := setupTestRouter()
+
+	hitAs := func(path, cookie string) map[string]interface{} {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", path, nil)
+		if cookie != "" {
+			req.AddCookie(&http.Cookie{Name: utils.VisitorCookie, Value: cookie})
+		}
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+		var response map[string]interface{}
+		json.Unmarshal(w.Body.Bytes(), &response)
+		return response
+	}
+
+	createW := httptest.NewRecorder()
+	createReq, _ := http.NewRequest("POST", "/create/unique_ns/visits?unique=true", nil)
+	r.ServeHTTP(createW, createReq)
+	assert.Equal(t, http.StatusCreated, createW.Code)
+
+	t.Run("Each visitor counts once", func(t *testing.T) {
+		first := hitAs("/hit/unique_ns/visits", "")
+		assert.Equal(t, float64(1), first["value"])
+		assert.Equal(t, true, first["counted"])
+
+		again := hitAs("/hit/unique_ns/visits", "")
+		assert.Equal(t, float64(1), again["value"])
+		assert.Equal(t, false, again["counted"])
+
+		other := hitAs("/hit/unique_ns/visits", "someone-else")
+		assert.Equal(t, float64(2), other["value"])
+		assert.Equal(t, true, other["counted"])
+		assert.Equal(t, false, hitAs("/hit/unique_ns/visits", "someone-else")["counted"])
+	})
+
+	t.Run("Decrements are not deduplicated", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/dec/unique_ns/visits", nil)
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `"value":1`)
+	})
+
+	t.Run("The window expires", func(t *testing.T) {
+		createW := httptest.NewRecorder()
+		createReq, _ := http.NewRequest("POST", "/create/unique_ns/windowed?unique_window=60", nil)
+		r.ServeHTTP(createW, createReq)
+		assert.Equal(t, http.StatusCreated, createW.Code)
+
+		assert.Equal(t, true, hitAs("/hit/unique_ns/windowed", "")["counted"])
+		assert.Equal(t, false, hitAs("/hit/unique_ns/windowed", "")["counted"])
+		ttl := Client.TTL(context.Background(), utils.CreateVisitorsKey(utils.BuildDBKey("unique_ns", "windowed"))).Val()
+		assert.True(t, ttl > 0 && ttl <= time.Minute, "unexpected ttl %v", ttl)
+
+		Client.Del(context.Background(), utils.CreateVisitorsKey(utils.BuildDBKey("unique_ns", "windowed")))
+		response := hitAs("/hit/unique_ns/windowed", "")
+		assert.Equal(t, true, response["counted"])
+		assert.Equal(t, float64(2), response["value"])
+	})
+
+	t.Run("Failed hits don't count the visitor", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/create/unique_ns/full?unique=true&initializer=9223372036854775807", nil)
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusCreated, w.Code)
+		w = httptest.NewRecorder()
+		req, _ = http.NewRequest("GET", "/hit/unique_ns/full", nil)
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusConflict, w.Code)
+		assert.Equal(t, int64(0), Client.SCard(context.Background(), utils.CreateVisitorsKey(utils.BuildDBKey("unique_ns", "full"))).Val())
+
+		Client.Set(context.Background(), utils.BuildDBKey("unique_ns", "full"), 0, 0)
+		response := hitAs("/hit/unique_ns/full", "")
+		assert.Equal(t, true, response["counted"])
+		assert.Equal(t, float64(1), response["value"])
+	})
+
+	t.Run("Concurrent hits count the visitor once", func(t *testing.T) {
+		for _, query := range []string{"unique=true", "unique=true&max=100"} {
+			key := "concurrent" + strconv.Itoa(len(query))
+			createReq, _ := http.NewRequest("POST", "/create/unique_ns/"+key+"?"+query, nil)
+			createW := httptest.NewRecorder()
+			r.ServeHTTP(createW, createReq)
+			assert.Equal(t, http.StatusCreated, createW.Code)
+
+			var wg sync.WaitGroup
+			for i := 0; i < 20; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					hitAs("/hit/unique_ns/"+key, "")
+				}()
+			}
+			wg.Wait()
+			assert.Equal(t, "1", Client.Get(context.Background(), utils.BuildDBKey("unique_ns", key)).Val(), query)
+		}
+	})
+
+	t.Run("Batches count each visitor once", func(t *testing.T) {
+		createReq, _ := http.NewRequest("POST", "/create/unique_ns/batched?unique=true", nil)
+		r.ServeHTTP(httptest.NewRecorder(), createReq)
+		body := `{"keys":[{"namespace":"unique_ns","key":"batched"},{"namespace":"unique_ns","key":"batched_regular"}]}`
+		for i := 0; i < 3; i++ {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("POST", "/hit-batch", strings.NewReader(body))
+			r.ServeHTTP(w, req)
+			assert.Equal(t, http.StatusOK, w.Code)
+			var results []map[string]interface{}
+			json.Unmarshal(w.Body.Bytes(), &results)
+			assert.Equal(t, float64(1), results[0]["value"])
+			assert.Equal(t, i == 0, results[0]["counted"])
+			assert.NotContains(t, results[1], "counted")
+		}
+		assert.Equal(t, true, hitAs("/hit/unique_ns/batched", "someone-else")["counted"])
+	})
+
+	t.Run("Invalid window", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/create/unique_ns/invalid?unique_window=-5", nil)
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("Regular counters count every hit", func(t *testing.T) {
+		hitAs("/hit/unique_ns/regular", "")
+		response := hitAs("/hit/unique_ns/regular", "")
+		assert.Equal(t, float64(2), response["value"])
+		assert.NotContains(t, response, "counted")
+	})
+}
+
+func TestRequestSigning(t *testing.T) {
+	RequestSigning = true
+	defer func() { RequestSigning = false }()
+	r := setupTestRouter()
+
+	claimW := httptest.NewRecorder()
+	claimReq, _ := http.NewRequest("POST", "/namespace/signed_ns/token", nil)
+	r.ServeHTTP(claimW, claimReq)
+	assert.Equal(t, http.StatusCreated, claimW.Code)
+	var claimResponse map[string]interface{}
+	json.Unmarshal(claimW.Body.Bytes(), &claimResponse)
+	namespaceToken := claimResponse["admin_key"].(string)
+
+	createW := httptest.NewRecorder()
+	createReq, _ := http.NewRequest("POST", "/create/signed_ns/counter?initializer=5", nil)
+	r.ServeHTTP(createW, createReq)
+	assert.Equal(t, http.StatusCreated, createW.Code)
+
+	set := func(value, secret string, signedAt time.Time, tamper bool) *httptest.ResponseRecorder {
+		uri := "/set/signed_ns/counter?value=" + value
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", uri, nil)
+		req.Header.Set("Authorization", "Bearer "+namespaceToken)
+		if secret != "" {
+			timestamp := strconv.FormatInt(signedAt.Unix(), 10)
+			req.Header.Set(middleware.TimestampHeader, timestamp)
+			req.Header.Set(middleware.SignatureHeader, utils.SignRequest(secret, timestamp, "POST", uri, nil))
+			if tamper {
+				req.URL.RawQuery = "value=1000"
+			}
+		}
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("Namespaces without a secret don't need signatures", func(t *testing.T) {
+		assert.Equal(t, http.StatusOK, set("6", "", time.Time{}, false).Code)
+	})
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/namespace/signed_ns/secret", nil)
+	req.Header.Set("Authorization", "Bearer "+namespaceToken)
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	var secretResponse map[string]interface{}
+	json.Unmarshal(w.Body.Bytes(), &secretResponse)
+	secret := secretResponse["signing_secret"].(string)
+	assert.Len(t, secret, 64)
+
+	t.Run("Unsigned requests are rejected", func(t *testing.T) {
+		assert.Equal(t, http.StatusUnauthorized, set("7", "", time.Time{}, false).Code)
+	})
+
+	t.Run("Signed requests go through", func(t *testing.T) {
+		w := set("8", secret, time.Now(), false)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `"value":8`)
+	})
+
+	t.Run("Wrong secret", func(t *testing.T) {
+		assert.Equal(t, http.StatusUnauthorized, set("9", "not-the-secret", time.Now(), false).Code)
+	})
+
+	t.Run("Tampered request", func(t *testing.T) {
+		assert.Equal(t, http.StatusUnauthorized, set("9", secret, time.Now(), true).Code)
+	})
+
+	t.Run("Expired signature", func(t *testing.T) {
+		assert.Equal(t, http.StatusUnauthorized, set("9", secret, time.Now().Add(-time.Hour), false).Code)
+	})
+
+	t.Run("Signed bodies", func(t *testing.T) {
+		body := `{"delta": 2}`
+		uri := "/update/signed_ns/counter"
+		timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", uri, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+namespaceToken)
+		req.Header.Set(middleware.TimestampHeader, timestamp)
+		req.Header.Set(middleware.SignatureHeader, utils.SignRequest(secret, timestamp, "POST", uri, []byte(body)))
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `"value":10`)
+	})
+
+	t.Run("Rotating or removing the secret needs a signature too", func(t *testing.T) {
+		for _, method := range []string{"POST", "DELETE"} {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(method, "/namespace/signed_ns/secret", nil)
+			req.Header.Set("Authorization", "Bearer "+namespaceToken)
+			r.ServeHTTP(w, req)
+			assert.Equal(t, http.StatusUnauthorized, w.Code, method)
+		}
+		assert.Equal(t, http.StatusOK, set("10", secret, time.Now(), false).Code) // the secret is unchanged
+	})
+
+	t.Run("Turning signing off", func(t *testing.T) {
+		uri := "/namespace/signed_ns/secret"
+		timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("DELETE", uri, nil)
+		req.Header.Set("Authorization", "Bearer "+namespaceToken)
+		req.Header.Set(middleware.TimestampHeader, timestamp)
+		req.Header.Set(middleware.SignatureHeader, utils.SignRequest(secret, timestamp, "DELETE", uri, nil))
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, http.StatusOK, set("11", "", time.Time{}, false).Code)
+	})
+}
+
+func TestDefaultTTL(t *testing.T) {
+	utils.BaseTTLPeriod = 30 * 24 * time.Hour
+	defer func() { utils.BaseTTLPeriod = utils.DefaultBaseTTLPeriod }()
+	r := setupTestRouter()
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/create/default_ttl_ns/counter", nil)
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, 30*24*time.Hour, Client.TTL(context.Background(), utils.BuildDBKey("default_ttl_ns", "counter")).Val())
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/hit/default_ttl_ns/other", nil)
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, 30*24*time.Hour, Client.TTL(context.Background(), utils.BuildDBKey("default_ttl_ns", "other")).Val())
+}
+
+func TestSnapshots(t *testing.T) {
+	r := setupTestRouter()
+	request := func(method, path, token string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, path, nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		r.ServeHTTP(w, req)
+		return w
+	}
+	w := request("POST", "/create/snapshot_ns/sales?initializer=40", "")
+	assert.Equal(t, http.StatusCreated, w.Code)
+	var created map[string]interface{}
+	json.Unmarshal(w.Body.Bytes(), &created)
+	adminKey := created["admin_key"].(string)
+
+	t.Run("Taking a snapshot requires the admin key", func(t *testing.T) {
+		assert.Equal(t, http.StatusBadRequest, request("POST", "/snapshot/snapshot_ns/sales?name=eoy2024", "").Code)
+		assert.Equal(t, http.StatusUnauthorized, request("POST", "/snapshot/snapshot_ns/sales?name=eoy2024", "wrong").Code)
+	})
+
+	t.Run("Snapshots keep their value", func(t *testing.T) {
+		w := request("POST", "/snapshot/snapshot_ns/sales?name=eoy2024", adminKey)
+		assert.Equal(t, http.StatusCreated, w.Code)
+		var snapshot map[string]interface{}
+		json.Unmarshal(w.Body.Bytes(), &snapshot)
+		assert.Equal(t, "eoy2024", snapshot["name"])
+		assert.Equal(t, float64(40), snapshot["value"])
+
+		request("GET", "/hit/snapshot_ns/sales", "")
+		w = request("GET", "/snapshot/snapshot_ns/sales?name=eoy2024", "")
+		assert.Equal(t, http.StatusOK, w.Code)
+		json.Unmarshal(w.Body.Bytes(), &snapshot)
+		assert.Equal(t, float64(40), snapshot["value"])
+
+		// taken snapshots can't be replaced
+		assert.Equal(t, http.StatusConflict, request("POST", "/snapshot/snapshot_ns/sales?name=eoy2024", adminKey).Code)
+	})
+
+	t.Run("Listing snapshots", func(t *testing.T) {
+		assert.Equal(t, http.StatusCreated, request("POST", "/snapshot/snapshot_ns/sales?name=q1", adminKey).Code)
+		w := request("GET", "/snapshot/snapshot_ns/sales", "")
+		assert.Equal(t, http.StatusOK, w.Code)
+		var response map[string]interface{}
+		json.Unmarshal(w.Body.Bytes(), &response)
+		snapshots := response["snapshots"].([]interface{})
+		assert.Len(t, snapshots, 2)
+		assert.Equal(t, float64(41), snapshots[1].(map[string]interface{})["value"])
+	})
+
+	t.Run("Errors", func(t *testing.T) {
+		assert.Equal(t, http.StatusNotFound, request("GET", "/snapshot/snapshot_ns/sales?name=missing", "").Code)
+		assert.Equal(t, http.StatusBadRequest, request("POST", "/snapshot/snapshot_ns/sales?name=bad:name", adminKey).Code)
+		assert.Equal(t, http.StatusBadRequest, request("POST", "/snapshot/snapshot_ns/sales", adminKey).Code)
+	})
+
+	t.Run("Snapshots expire with the counter", func(t *testing.T) {
+		ctx := context.Background()
+		assert.InDelta(t, utils.BaseTTLPeriod.Seconds(), Client.TTL(ctx, utils.CreateSnapshotsKey(utils.BuildDBKey("snapshot_ns", "sales"))).Val().Seconds(), 5)
+
+		w := request("POST", "/create/snapshot_ns/sliding?ttl=3600&refresh_ttl=true", "")
+		assert.Equal(t, http.StatusCreated, w.Code)
+		var created map[string]interface{}
+		json.Unmarshal(w.Body.Bytes(), &created)
+		assert.Equal(t, http.StatusCreated, request("POST", "/snapshot/snapshot_ns/sliding?name=start", created["admin_key"].(string)).Code)
+		snapshotsKey := utils.CreateSnapshotsKey(utils.BuildDBKey("snapshot_ns", "sliding"))
+		assert.InDelta(t, 3600, Client.TTL(ctx, snapshotsKey).Val().Seconds(), 5)
+
+		// hits push the expiry of the counter forward, and that of its snapshots along with it
+		Client.Expire(ctx, snapshotsKey, time.Minute)
+		assert.Equal(t, http.StatusOK, request("GET", "/hit/snapshot_ns/sliding", "").Code)
+		assert.InDelta(t, 3600, Client.TTL(ctx, snapshotsKey).Val().Seconds(), 5)
+	})
+
+	t.Run("Deleting the counter deletes its snapshots", func(t *testing.T) {
+		assert.Equal(t, http.StatusOK, request("DELETE", "/delete/snapshot_ns/sales", adminKey).Code)
+		assert.Zero(t, Client.Exists(context.Background(), utils.CreateSnapshotsKey(utils.BuildDBKey("snapshot_ns", "sales"))).Val())
+	})
+}
+
+func TestMaxBodyBytes(t *testing.T) {
+	MaxBodyBytes = 64
+	defer func() { MaxBodyBytes = 10 << 20 }()
+	r := setupTestRouter()
+	large := `{"keys":[` + strings.Repeat(`{"namespace":"body_ns","key":"counter"},`, 10) + `{"namespace":"body_ns","key":"counter"}]}`
+
+	t.Run("Announced size", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/hit-batch", strings.NewReader(large))
+		req.Header.Set("Content-Type", "application/json")
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+	})
+
+	t.Run("Unannounced size", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/hit-batch", io.NopCloser(strings.NewReader(large)))
+		req.ContentLength = -1
+		req.Header.Set("Content-Type", "application/json")
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+		assert.Contains(t, w.Body.String(), "64 bytes")
+	})
+
+	t.Run("Small bodies", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/hit-batch", strings.NewReader(`{"keys":[{"namespace":"body_ns","key":"counter"}]}`))
+		req.Header.Set("Content-Type", "application/json")
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+}
+
+func TestResetAll(t *testing.T) {
+	r := setupTestRouter()
+	request := func(method, path, token string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, path, nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		r.ServeHTTP(w, req)
+		return w
+	}
+	w := request("POST", "/namespace/reset_all_ns/token", "")
+	assert.Equal(t, http.StatusCreated, w.Code)
+	var claimResponse map[string]interface{}
+	json.Unmarshal(w.Body.Bytes(), &claimResponse)
+	namespaceToken := claimResponse["admin_key"].(string)
+
+	for _, path := range []string{"/create/reset_all_ns/first?initializer=12", "/create/reset_all_ns/second?initializer=3.5&type=float&ttl=3600",
+		"/create/reset_all_ns/floored?initializer=20&min=10", "/create/reset_keep/other?initializer=7"} {
+		assert.Equal(t, http.StatusCreated, request("POST", path, "").Code)
+	}
+	value := func(path string) string {
+		return request("GET", "/get/"+path, "").Body.String()
+	}
+
+	t.Run("Without confirmation", func(t *testing.T) {
+		assert.Equal(t, http.StatusBadRequest, request("POST", "/reset-all/reset_all_ns", namespaceToken).Code)
+		assert.JSONEq(t, `{"value": 12}`, value("reset_all_ns/first"))
+	})
+
+	t.Run("Reset to zero", func(t *testing.T) {
+		w := request("POST", "/reset-all/reset_all_ns?confirm=true", namespaceToken)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.JSONEq(t, `{"namespace": "reset_all_ns", "reset": 2, "skipped": 1}`, w.Body.String())
+		assert.JSONEq(t, `{"value": 0}`, value("reset_all_ns/first"))
+		assert.JSONEq(t, `{"value": 0}`, value("reset_all_ns/second"))
+		assert.JSONEq(t, `{"value": 20}`, value("reset_all_ns/floored")) // 0 is below its min
+		assert.JSONEq(t, `{"value": 7}`, value("reset_keep/other"))
+		ttl := Client.TTL(context.Background(), utils.BuildDBKey("reset_all_ns", "second")).Val()
+		assert.True(t, ttl > 3500*time.Second && ttl <= time.Hour, "unexpected ttl %v", ttl)
+	})
+
+	t.Run("Reset to a value", func(t *testing.T) {
+		w := request("POST", "/reset-all/reset_all_ns?confirm=true&value=10", namespaceToken)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.JSONEq(t, `{"namespace": "reset_all_ns", "reset": 3, "skipped": 0}`, w.Body.String())
+		assert.JSONEq(t, `{"value": 10}`, value("reset_all_ns/floored"))
+		assert.Equal(t, http.StatusBadRequest, request("POST", "/reset-all/reset_all_ns?confirm=true&value=1.5", namespaceToken).Code)
+	})
+
+	t.Run("Requires the namespace token", func(t *testing.T) {
+		assert.Equal(t, http.StatusUnauthorized, request("POST", "/reset-all/reset_all_ns?confirm=true", "wrong").Code)
+	})
+}
+
+func TestEvents(t *testing.T) {
+	r := setupTestRouter()
+	utils.Events = utils.NewEventQueue(utils.RedisStreamPublisher{Client: Client, Stream: "test:events"}, 10)
+	defer func() { utils.Events = nil }()
+	request := func(method, path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, path, nil)
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	w := request("POST", "/create/events_ns/counted?initializer=5")
+	assert.Equal(t, http.StatusCreated, w.Code)
+	var created map[string]interface{}
+	json.Unmarshal(w.Body.Bytes(), &created)
+	request("GET", "/hit/events_ns/counted")
+	request("GET", "/dec/events_ns/counted")
+	request("GET", "/get/events_ns/counted") // reads aren't published
+	w = httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/delete/events_ns/counted", nil)
+	req.Header.Set("Authorization", "Bearer "+created["admin_key"].(string))
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	utils.Events.Close() // waits until the events are published
+
+	entries, err := Client.XRange(context.Background(), "test:events", "-", "+").Result()
+	assert.NoError(t, err)
+	ops := make([]string, len(entries))
+	for i, entry := range entries {
+		ops[i] = entry.Values["op"].(string)
+		assert.Equal(t, "events_ns", entry.Values["namespace"])
+		assert.Equal(t, "counted", entry.Values["key"])
+		assert.Contains(t, entry.Values, "timestamp")
+	}
+	assert.Equal(t, []string{"create", "hit", "dec", "delete"}, ops)
+	if len(entries) == 4 {
+		assert.Equal(t, "5", entries[0].Values["value"])
+		assert.Equal(t, "6", entries[1].Values["value"])
+		assert.NotContains(t, entries[3].Values, "value")
+	}
+
+	t.Run("Drops events when the buffer is full", func(t *testing.T) {
+		blocked := make(chan struct{})
+		utils.Events = utils.NewEventQueue(blockingPublisher(blocked), 1)
+		dropped := utils.EventsDropped.Load()
+		for i := 0; i < 5; i++ { // the first is being published, the second is buffered
+			utils.Events.Emit(utils.BuildDBKey("events_ns", "dropped"), "hit", i)
+		}
+		assert.GreaterOrEqual(t, utils.EventsDropped.Load()-dropped, int64(3))
+		close(blocked)
+		utils.Events.Close()
+	})
+
+	t.Run("Drops events emitted after closing", func(t *testing.T) {
+		utils.Events = utils.NewEventQueue(utils.RedisStreamPublisher{Client: Client, Stream: "test:closed_events"}, 10)
+		var wg sync.WaitGroup
+		for i := 0; i < 20; i++ { // racing Close, which mustn't panic either
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				utils.Events.Emit(utils.BuildDBKey("events_ns", "closed"), "hit", 1)
+			}()
+		}
+		utils.Events.Close()
+		wg.Wait()
+		dropped := utils.EventsDropped.Load()
+		utils.Events.Emit(utils.BuildDBKey("events_ns", "closed"), "hit", 2)
+		assert.Equal(t, dropped+1, utils.EventsDropped.Load())
+		utils.Events.Close()
+	})
+}
+
+// blockingPublisher is an EventPublisher that doesn't return until unblocked is closed.
+type blockingPublisher chan struct{}
+
+func (p blockingPublisher) Publish(_ context.Context, _ utils.Event) error {
+	<-p
+	return nil
+}
+
+func TestStatsPagination(t *testing.T) {
+	r := setupTestRouter()
+	for _, namespace := range []string{"stats_page_b", "stats_page_a", "stats_page_c"} {
+		Client.Set(context.Background(), utils.BuildDBKey(namespace, "counted"), 1, 0)
+	}
+	stats := func(query string) (int, map[string]interface{}) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/stats?"+query, nil)
+		r.ServeHTTP(w, req)
+		var response map[string]interface{}
+		json.Unmarshal(w.Body.Bytes(), &response)
+		counters, _ := response["counters"].(map[string]interface{})
+		return w.Code, counters
+	}
+	names := func(counters map[string]interface{}) []string {
+		var names []string
+		for _, namespace := range counters["top_namespaces"].([]interface{}) {
+			names = append(names, namespace.(map[string]interface{})["namespace"].(string))
+		}
+		return names
+	}
+
+	code, counters := stats("sort=name&limit=1000")
+	assert.Equal(t, http.StatusOK, code)
+	all := names(counters)
+	assert.Equal(t, float64(len(all)), counters["namespaces"])
+	assert.True(t, sort.StringsAreSorted(all))
+	assert.Subset(t, all, []string{"stats_page_a", "stats_page_b", "stats_page_c"})
+
+	t.Run("Paging", func(t *testing.T) {
+		_, counters := stats("sort=name&limit=2&offset=1")
+		assert.Equal(t, all[1:3], names(counters))
+		_, counters = stats("limit=1")
+		assert.Len(t, names(counters), 1)
+		_, counters = stats("offset=100000")
+		assert.Empty(t, names(counters))
+	})
+
+	t.Run("Invalid parameters", func(t *testing.T) {
+		for _, query := range []string{"sort=size", "limit=0", "limit=1001", "limit=ten", "offset=-1"} {
+			code, _ := stats(query)
+			assert.Equal(t, http.StatusBadRequest, code, query)
+		}
+	})
+}
+
+func TestShardedGet(t *testing.T) {
+	r := setupTestRouter()
+	shard := miniredis.RunT(t)
+	ShardClients = []*redis.Client{redis.NewClient(&redis.Options{Addr: shard.Addr()})}
+	defer func() { ShardClients = nil }()
+	get := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", path, nil)
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	Client.Set(context.Background(), utils.BuildDBKey("sharded_ns", "split"), 40, 0)
+	shard.Set(utils.BuildDBKey("sharded_ns", "split"), "2")
+	shard.Set(utils.BuildDBKey("sharded_ns", "remote"), "7")
+
+	t.Run("Sums every shard", func(t *testing.T) {
+		w := get("/get/sharded_ns/split?shards=true")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.JSONEq(t, `{"value": 42, "shards": 2}`, w.Body.String())
+		assert.JSONEq(t, `{"value": 40}`, get("/get/sharded_ns/split").Body.String()) // only this shard
+		assert.Equal(t, "42", get("/get/sharded_ns/split?shards=true&format=text").Body.String())
+	})
+
+	t.Run("Missing on this shard", func(t *testing.T) {
+		assert.JSONEq(t, `{"value": 7, "shards": 1}`, get("/get/sharded_ns/remote?shards=true").Body.String())
+		assert.Equal(t, http.StatusNotFound, get("/get/sharded_ns/nowhere?shards=true").Code)
+	})
+
+	t.Run("Floats", func(t *testing.T) {
+		shard.Set(utils.BuildDBKey("sharded_ns", "split"), "2.5")
+		assert.JSONEq(t, `{"value": 42.5, "shards": 2}`, get("/get/sharded_ns/split?shards=true").Body.String())
+	})
+
+	t.Run("A shard is down", func(t *testing.T) {
+		shard.Close()
+		assert.Equal(t, http.StatusInternalServerError, get("/get/sharded_ns/split?shards=true").Code)
+	})
+}
+
+func TestDocsRedirect(t *testing.T) {
+	DocsUrl = "https://abacus.example.com/docs/"
+	defer func() { DocsUrl = DefaultDocsUrl }()
+	r := setupTestRouter()
+
+	for _, path := range []string{"/docs", "/no/such/route"} {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", path, nil)
+		req.Header.Set("Accept", "text/html,application/xhtml+xml")
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusPermanentRedirect, w.Code, path)
+		assert.Equal(t, "https://abacus.example.com/docs/", w.Header().Get("Location"), path)
+	}
+}
+
+func TestNoRoute(t *testing.T) {
+	r := setupTestRouter()
+	request := func(method, path, accept string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, path, nil)
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("API clients get a 404", func(t *testing.T) {
+		for _, path := range []string{"/get", "/hits/no_route_ns/key", "/nothing/here"} {
+			w := request("GET", path, "")
+			assert.Equal(t, http.StatusNotFound, w.Code, path)
+			var response map[string]interface{}
+			assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+			assert.Contains(t, response["error"], "GET "+path)
+		}
+		assert.Equal(t, http.StatusNotFound, request("PUT", "/create/no_route_ns/key", "").Code)
+	})
+
+	t.Run("Browsers on API paths get a 404", func(t *testing.T) {
+		assert.Equal(t, http.StatusNotFound, request("GET", "/get", "text/html").Code)
+		assert.Equal(t, http.StatusNotFound, request("GET", "/info", "text/html").Code)
+	})
+
+	t.Run("Browsers and the root are redirected", func(t *testing.T) {
+		assert.Equal(t, http.StatusPermanentRedirect, request("GET", "/nothing/here", "text/html").Code)
+		assert.Equal(t, http.StatusPermanentRedirect, request("GET", "/", "").Code)
+	})
+}
+
+func TestExpiredStream(t *testing.T) {
+	ExpiryEvents = true
+	defer func() { ExpiryEvents, utils.Expirations = false, nil }()
+	utils.Expirations = utils.NewExpiryWatcher()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go utils.Expirations.Watch(ctx, Client, 0)
+	r := setupTestRouter()
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/namespace/expiry_ns/token", nil)
+	r.ServeHTTP(w, req)
+	var claimResponse map[string]interface{}
+	json.Unmarshal(w.Body.Bytes(), &claimResponse)
+	namespaceToken := claimResponse["admin_key"].(string)
+
+	t.Run("Requires the namespace token", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/expired/expiry_ns", nil)
+		req.Header.Set("Authorization", "Bearer wrong")
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+
+	t.Run("Streams the expired counters of the namespace", func(t *testing.T) {
+		stream := newMockResponseWriter()
+		streamCtx, stopStream := context.WithCancel(context.Background())
+		req, _ := http.NewRequestWithContext(streamCtx, "GET", "/expired/expiry_ns", nil)
+		req.Header.Set("Authorization", "Bearer "+namespaceToken)
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			r.ServeHTTP(stream, req)
+		}()
+		time.Sleep(100 * time.Millisecond) // lets the watcher subscribe and the stream start
+
+		for _, key := range []string{"K:expiry_ns:gone", "M:expiry_ns:gone", "K:other_expiry_ns:gone", "K:expiry_ns:also_gone"} {
+			Client.Publish(context.Background(), utils.ExpiredChannel(0), key)
+		}
+		time.Sleep(100 * time.Millisecond)
+		stopStream()
+		<-done
+
+		assert.Equal(t, "no", stream.Header().Get("X-Accel-Buffering"))
+		assert.Equal(t, "event: expired\ndata: {\"namespace\":\"expiry_ns\",\"key\":\"gone\"}\n\n"+
+			"event: expired\ndata: {\"namespace\":\"expiry_ns\",\"key\":\"also_gone\"}\n\n", stream.Body.String())
+	})
+}
+
+func TestKeyPathNormalization(t *testing.T) {
+	r := setupTestRouter()
+	for i, path := range []string{"/hit/normalize_ns/visits", "/hit/normalize_ns//visits", "/hit/normalize_ns/visits/",
+		"/hit//normalize_ns/visits", "/hit/normalize_ns///visits//"} {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", path, nil)
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code, path)
+		assert.JSONEq(t, `{"value": `+strconv.Itoa(i+1)+`}`, w.Body.String(), path)
+	}
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/hit/normalize_ns/a//b", nil)
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/get/normalize_ns/"+strings.Repeat("a/", utils.MaxKeyPathLength), nil)
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "at most")
+}
+
+func TestRollingCounters(t *testing.T) {
+	r := setupTestRouter()
+	today := time.Now().UTC().Format("2006-01-02")
+	for i := 1; i <= 2; i++ {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/hit/roll_ns/visits?roll=daily", nil)
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.JSONEq(t, `{"value": `+strconv.Itoa(i)+`, "key": "visits_`+today+`"}`, w.Body.String())
+	}
+	ttl := Client.TTL(context.Background(), "K:roll_ns:visits_"+today).Val()
+	assert.Greater(t, ttl, time.Duration(utils.RollKeep)*24*time.Hour)
+	assert.LessOrEqual(t, ttl, time.Duration(utils.RollKeep+1)*24*time.Hour)
+	assert.Equal(t, int64(0), Client.Exists(context.Background(), "K:roll_ns:visits").Val())
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/hit/roll_ns/visits?roll=monthly", nil)
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"value": 1, "key": "visits_`+time.Now().UTC().Format("2006-01")+`"}`, w.Body.String())
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/hit/roll_ns/visits?roll=weekly", nil)
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestInfoBatch(t *testing.T) {
+	r := setupTestRouter()
+	for _, path := range []string{"/create/info_batch_ns/first?initializer=5", "/create/info_batch_ns/second?type=float&initializer=1.5",
+		"/create/info_batch_ns/hidden?visibility=private"} {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", path, nil)
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusCreated, w.Code)
+	}
+
+	w := httptest.NewRecorder()
+	body := `{"keys": [{"namespace": "info_batch_ns", "key": "first"}, {"namespace": "info_batch_ns", "key": "second"},
+		{"namespace": "info_batch_ns", "key": "missing"}, {"namespace": "info_batch_ns", "key": "hidden"}, {"namespace": "info_batch_ns", "key": "$$"}]}`
+	req, _ := http.NewRequest("POST", "/info-batch", strings.NewReader(body))
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	var results []map[string]interface{}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &results))
+	assert.Len(t, results, 5)
+
+	assert.Equal(t, "first", results[0]["key"])
+	assert.Equal(t, float64(5), results[0]["value"])
+	assert.Equal(t, true, results[0]["exists"])
+	assert.Equal(t, false, results[0]["is_genuine"])
+	assert.Greater(t, results[0]["expires_in"], float64(0))
+	assert.Equal(t, 1.5, results[1]["value"])
+	assert.Equal(t, "float", results[1]["type"])
+	assert.Equal(t, false, results[2]["exists"])
+	assert.Equal(t, float64(-1), results[2]["value"])
+	assert.Equal(t, "Counter is private", results[3]["error"])
+	assert.NotContains(t, results[3], "value")
+	assert.Contains(t, results[4], "error")
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("POST", "/info-batch", strings.NewReader(`{"keys": []}`))
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestShareTokens(t *testing.T) {
+	r := setupTestRouter()
+	request := func(method, path, token string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, path, nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		r.ServeHTTP(w, req)
+		return w
+	}
+	w := request("POST", "/namespace/share_ns/token", "")
+	assert.Equal(t, http.StatusCreated, w.Code)
+	var claimResponse map[string]interface{}
+	json.Unmarshal(w.Body.Bytes(), &claimResponse)
+	namespaceToken := claimResponse["admin_key"].(string)
+
+	w = request("POST", "/create/share_ns/secret?initializer=7&visibility=private", "")
+	assert.Equal(t, http.StatusCreated, w.Code)
+	var createResponse map[string]interface{}
+	json.Unmarshal(w.Body.Bytes(), &createResponse)
+	adminKey := createResponse["admin_key"].(string)
+	assert.Equal(t, http.StatusCreated, request("POST", "/create/share_ns/other?visibility=private", "").Code)
+
+	assert.Equal(t, http.StatusBadRequest, request("POST", "/share/share_ns/secret?ttl=0", adminKey).Code)
+	assert.Equal(t, http.StatusNotFound, request("POST", "/share/share_ns/missing", namespaceToken).Code)
+	w = request("POST", "/share/share_ns/secret?ttl=60", adminKey)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	var shareResponse map[string]interface{}
+	json.Unmarshal(w.Body.Bytes(), &shareResponse)
+	token := shareResponse["token"].(string)
+	assert.InDelta(t, time.Now().Add(time.Minute).Unix(), shareResponse["expires_at"], 2)
+	assert.Contains(t, shareResponse["url"], "/get/share_ns/secret?token="+token)
+
+	t.Run("Reads the counter", func(t *testing.T) {
+		w := request("GET", "/get/share_ns/secret?token="+token, "")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.JSONEq(t, `{"value": 7}`, w.Body.String())
+		assert.Equal(t, http.StatusOK, request("GET", "/info/share_ns/secret", token).Code)
+	})
+
+	t.Run("Only reads the counter it was minted for", func(t *testing.T) {
+		assert.Equal(t, http.StatusUnauthorized, request("GET", "/get/share_ns/other", token).Code)
+		assert.Equal(t, http.StatusUnauthorized, request("POST", "/set/share_ns/secret?value=1", token).Code)
+		assert.Equal(t, http.StatusUnauthorized, request("POST", "/share/share_ns/secret", token).Code)
+	})
+
+	t.Run("Doesn't change the counter", func(t *testing.T) {
+		assert.Equal(t, http.StatusForbidden, request("GET", "/hit/share_ns/secret", token).Code)
+		assert.Equal(t, http.StatusForbidden, request("GET", "/dec/share_ns/secret", token).Code)
+		assert.Equal(t, http.StatusForbidden, request("GET", "/upsert/share_ns/secret", token).Code)
+		assert.Equal(t, http.StatusForbidden, request("POST", "/reserve/share_ns/secret?count=2", token).Code)
+		assert.Equal(t, http.StatusForbidden, request("GET", "/history/share_ns/secret", token).Code)
+		w := request("GET", "/get/share_ns/secret", adminKey)
+		assert.JSONEq(t, `{"value": 7}`, w.Body.String())
+	})
+
+	t.Run("Expires", func(t *testing.T) {
+		secret, err := utils.GetShareSecret(context.Background(), Client, "share_ns")
+		assert.NoError(t, err)
+		expired := utils.SignShareToken(secret, "K:share_ns:secret", time.Now().Add(-time.Second))
+		assert.Equal(t, http.StatusUnauthorized, request("GET", "/get/share_ns/secret", expired).Code)
+		assert.Equal(t, http.StatusUnauthorized, request("GET", "/get/share_ns/secret", token[:len(token)-1]+"x").Code)
+	})
+
+	t.Run("Revoked by rotating the secret", func(t *testing.T) {
+		assert.Equal(t, http.StatusUnauthorized, request("DELETE", "/share/share_ns", adminKey).Code)
+		assert.Equal(t, http.StatusOK, request("DELETE", "/share/share_ns", namespaceToken).Code)
+		assert.Equal(t, http.StatusUnauthorized, request("GET", "/get/share_ns/secret", token).Code)
+		w := request("POST", "/share/share_ns/secret", adminKey)
+		assert.Equal(t, http.StatusCreated, w.Code)
+		var response map[string]interface{}
+		json.Unmarshal(w.Body.Bytes(), &response)
+		assert.Equal(t, http.StatusOK, request("GET", "/get/share_ns/secret", response["token"].(string)).Code)
+	})
+}
+
+func TestValueBase(t *testing.T) {
+	r := setupTestRouter()
+	request := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", path, nil)
+		r.ServeHTTP(w, req)
+		return w
+	}
+	assert.Equal(t, http.StatusCreated, request("/create/base_ns/counter?initializer=254").Code)
+
+	w := request("/hit/base_ns/counter?base=16")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"value": 255, "value_in_base": "ff"}`, w.Body.String())
+
+	w = request("/get/base_ns/counter?base=2")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"value": 255, "value_in_base": "11111111"}`, w.Body.String())
+
+	w = request("/get/base_ns/counter?base=36&format=text")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "73", w.Body.String())
+
+	for _, base := range []string{"1", "37", "hex"} {
+		assert.Equal(t, http.StatusBadRequest, request("/get/base_ns/counter?base="+base).Code, base)
+		assert.Equal(t, http.StatusBadRequest, request("/hit/base_ns/counter?base="+base).Code, base)
+	}
+	assert.JSONEq(t, `{"value": 255}`, request("/get/base_ns/counter").Body.String())
+
+	assert.Equal(t, http.StatusCreated, request("/create/base_ns/float?type=float&initializer=1.5").Code)
+	assert.Equal(t, http.StatusConflict, request("/get/base_ns/float?base=16").Code)
+}
+
+func TestDescriptions(t *testing.T) {
+	r := setupTestRouter()
+	request := func(method, path, token string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, path, nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		r.ServeHTTP(w, req)
+		return w
+	}
+	info := func(path string) map[string]interface{} {
+		var body map[string]interface{}
+		json.Unmarshal(request("GET", path, "").Body.Bytes(), &body)
+		return body
+	}
+
+	w := request("POST", "/create/describe_ns/visits?description=Visits+of+the+landing+page", "")
+	assert.Equal(t, http.StatusCreated, w.Code)
+	var created map[string]interface{}
+	json.Unmarshal(w.Body.Bytes(), &created)
+	adminKey := created["admin_key"].(string)
+	assert.Equal(t, "Visits of the landing page", info("/info/describe_ns/visits")["description"])
+	assert.Equal(t, http.StatusBadRequest, request("POST", "/create/describe_ns/long?description="+strings.Repeat("a", utils.MaxDescriptionLength+1), "").Code)
+	assert.Equal(t, http.StatusCreated, request("POST", "/create/describe_ns/plain", "").Code)
+
+	t.Run("Listed", func(t *testing.T) {
+		var list map[string]interface{}
+		json.Unmarshal(request("GET", "/list/describe_ns", "").Body.Bytes(), &list)
+		descriptions := map[string]interface{}{}
+		for _, counter := range list["keys"].([]interface{}) {
+			counter := counter.(map[string]interface{})
+			descriptions[counter["key"].(string)] = counter["description"]
+		}
+		assert.Equal(t, map[string]interface{}{"visits": "Visits of the landing page", "plain": nil}, descriptions)
+	})
+
+	t.Run("Updated", func(t *testing.T) {
+		assert.Equal(t, http.StatusUnauthorized, request("POST", "/description/describe_ns/visits?description=mine", "wrong").Code)
+		w := request("POST", "/description/describe_ns/visits?description=Unique+visits", adminKey)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.JSONEq(t, `{"namespace": "describe_ns", "key": "visits", "description": "Unique visits"}`, w.Body.String())
+		assert.Equal(t, "Unique visits", info("/info/describe_ns/visits")["description"])
+		assert.Equal(t, http.StatusBadRequest, request("POST", "/description/describe_ns/visits?description=%0A", adminKey).Code)
+
+		assert.Equal(t, http.StatusOK, request("POST", "/description/describe_ns/visits?description=", adminKey).Code)
+		assert.Equal(t, "", info("/info/describe_ns/visits")["description"])
+	})
+}
+
+func TestVersionView(t *testing.T) {
+	r := setupTestRouter()
+	GitCommit, BuildTime = "4f2c1e9", "2024-05-01T12:00:00Z"
+	defer func() { GitCommit, BuildTime = "", "" }()
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/version", nil)
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"version": "`+Version+`", "git_commit": "4f2c1e9", "build_time": "2024-05-01T12:00:00Z", "go_version": "`+runtime.Version()+`"}`, w.Body.String())
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/stats", nil)
+	r.ServeHTTP(w, req)
+	var stats map[string]interface{}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &stats))
+	assert.Equal(t, "4f2c1e9", stats["build"].(map[string]interface{})["git_commit"])
+}
+
+func TestUpsert(t *testing.T) {
+	r := setupTestRouter()
+	request := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", path, nil)
+		r.ServeHTTP(w, req)
+		return w
+	}
+	body := func(w *httptest.ResponseRecorder) map[string]interface{} {
+		var response map[string]interface{}
+		json.Unmarshal(w.Body.Bytes(), &response)
+		return response
+	}
+
+	t.Run("Creates and then increments", func(t *testing.T) {
+		w := request("/upsert/upsert_ns/visits?initializer=10&step=2&max=13&ttl=3600")
+		assert.Equal(t, http.StatusCreated, w.Code)
+		created := body(w)
+		assert.Equal(t, true, created["created"])
+		assert.Equal(t, float64(12), created["value"])
+		assert.NotEmpty(t, created["admin_key"])
+		ttl := Client.TTL(context.Background(), "K:upsert_ns:visits").Val()
+		assert.Greater(t, ttl, 59*time.Minute)
+		assert.LessOrEqual(t, ttl, time.Hour)
+
+		// the settings given on later calls don't change the counter
+		w = request("/upsert/upsert_ns/visits?initializer=100&max=1000")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.JSONEq(t, `{"value": 13, "created": false}`, w.Body.String())
+		w = request("/upsert/upsert_ns/visits")
+		assert.Equal(t, http.StatusConflict, w.Code)
+		assert.Contains(t, w.Body.String(), "max value of 13")
+	})
+
+	t.Run("Floats", func(t *testing.T) {
+		w := request("/upsert/upsert_ns/float?type=float&initializer=1.5&step=0.25")
+		assert.Equal(t, http.StatusCreated, w.Code)
+		assert.Equal(t, 1.75, body(w)["value"])
+		w = request("/upsert/upsert_ns/float?step=0.25")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.JSONEq(t, `{"value": 2, "created": false}`, w.Body.String())
+	})
+
+	t.Run("Concurrent upserts create the counter once", func(t *testing.T) {
+		var wg sync.WaitGroup
+		var mutex sync.Mutex
+		creations := 0
+		for i := 0; i < 20; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if request("/upsert/upsert_ns/concurrent").Code == http.StatusCreated {
+					mutex.Lock()
+					creations++
+					mutex.Unlock()
+				}
+			}()
+		}
+		wg.Wait()
+		assert.Equal(t, 1, creations)
+		assert.JSONEq(t, `{"value": 20}`, request("/get/upsert_ns/concurrent").Body.String())
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		assert.Equal(t, http.StatusBadRequest, request("/upsert/upsert_ns/invalid?step=0").Code)
+		assert.Equal(t, http.StatusBadRequest, request("/upsert/upsert_ns/invalid?max=5&initializer=5").Code)
+		assert.Equal(t, http.StatusBadRequest, request("/upsert/upsert_ns/invalid?type=huge").Code)
+		assert.Equal(t, http.StatusNotFound, request("/get/upsert_ns/invalid").Code)
+	})
+
+	t.Run("Initializer plus step can't overflow", func(t *testing.T) {
+		w := request("/upsert/upsert_ns/overflow?initializer=9223372036854775807")
+		assert.Equal(t, http.StatusConflict, w.Code)
+		assert.Contains(t, w.Body.String(), overflowError)
+		w = request("/upsert/upsert_ns/overflow?initializer=-9223372036854775808&step=-1")
+		assert.Equal(t, http.StatusConflict, w.Code)
+		assert.Equal(t, http.StatusNotFound, request("/get/upsert_ns/overflow").Code)
+	})
+}
+
+func TestCreateOnHit(t *testing.T) {
+	r := setupTestRouter()
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/hit/create_hit_ns/typo?create=false", nil)
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Equal(t, int64(0), Client.Exists(context.Background(), "K:create_hit_ns:typo").Val())
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/hit/create_hit_ns/typo?create=maybe", nil)
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("POST", "/create/create_hit_ns/visits", nil)
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/hit/create_hit_ns/visits?create=false", nil)
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"value": 1}`, w.Body.String())
+
+	t.Run("CREATE_ON_HIT=false", func(t *testing.T) {
+		CreateOnHit = false
+		defer func() { CreateOnHit = true }()
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/hit/create_hit_ns/other", nil)
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+
+		w = httptest.NewRecorder()
+		body := `{"keys":[{"namespace":"create_hit_ns","key":"visits"},{"namespace":"create_hit_ns","key":"other"}]}`
+		req, _ = http.NewRequest("POST", "/hit-batch", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.JSONEq(t, `[{"namespace": "create_hit_ns", "key": "visits", "value": 2},
+			{"namespace": "create_hit_ns", "key": "other", "error": "Key not found"}]`, w.Body.String())
+
+		w = httptest.NewRecorder()
+		req, _ = http.NewRequest("GET", "/hit/create_hit_ns/other?create=true", nil)
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.JSONEq(t, `{"value": 1}`, w.Body.String())
+	})
+}
+
+func TestHashTaggedKeys(t *testing.T) {
+	utils.HashTags = true
+	defer func() { utils.HashTags = false }()
+	r := setupTestRouter()
+	request := func(method, path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, path, nil)
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	assert.Equal(t, http.StatusCreated, request("POST", "/create/cluster_ns/visits?initializer=5").Code)
+	assert.Equal(t, http.StatusOK, request("GET", "/hit/cluster_ns/visits").Code)
+	assert.Equal(t, "6", Client.Get(context.Background(), "K:{cluster_ns}:visits").Val())
+	assert.Equal(t, int64(1), Client.Exists(context.Background(), "M:{cluster_ns}:visits").Val())
+
+	w := request("GET", "/list/cluster_ns")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"key":"visits"`)
+
+	w = request("GET", "/sum/cluster_ns")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"count": 1, "namespace": "cluster_ns", "total": 6}`, w.Body.String())
+
+	t.Run("Transactions stay within a namespace", func(t *testing.T) {
+		RedisCluster = true
+		defer func() { RedisCluster = false }()
+		transaction := func(body string) *httptest.ResponseRecorder {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("POST", "/transaction", strings.NewReader(body))
+			r.ServeHTTP(w, req)
+			return w
+		}
+		w := transaction(`[{"op":"decr","namespace":"cluster_ns","key":"visits"},{"op":"incr","namespace":"cluster_other","key":"visits"}]`)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), "operations[1]: ")
+		assert.Equal(t, "6", Client.Get(context.Background(), "K:{cluster_ns}:visits").Val())
+
+		w = transaction(`[{"op":"decr","namespace":"cluster_ns","key":"visits"},{"op":"incr","namespace":"cluster_ns","key":"moved"}]`)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "5", Client.Get(context.Background(), "K:{cluster_ns}:visits").Val())
+	})
+}
+
+func TestHitRate(t *testing.T) {
+	r := setupTestRouter()
+	request := func(method, path string, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, path, strings.NewReader(body))
+		r.ServeHTTP(w, req)
+		return w
+	}
+	assert.Equal(t, http.StatusCreated, request("POST", "/create/rate_ns/busy", "").Code)
+	assert.Equal(t, http.StatusCreated, request("POST", "/create/rate_ns/idle", "").Code)
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, http.StatusOK, request("GET", "/hit/rate_ns/busy", "").Code)
+	}
+	assert.Equal(t, http.StatusOK, request("GET", "/hit/rate_ns/busy?dry_run=true", "").Code)
+	assert.Equal(t, http.StatusOK, request("POST", "/hit-batch", `{"keys":[{"namespace":"rate_ns","key":"busy"}]}`).Code)
+
+	var info map[string]interface{}
+	w := request("GET", "/info/rate_ns/busy", "")
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &info))
+	assert.GreaterOrEqual(t, info["hits_last_minute"], float64(4)) // the previous minute's buckets may count too
+	assert.LessOrEqual(t, info["hits_last_minute"], float64(5))
+
+	var results []map[string]interface{}
+	w = request("POST", "/info-batch", `{"keys":[{"namespace":"rate_ns","key":"busy"},{"namespace":"rate_ns","key":"idle"}]}`)
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &results))
+	assert.Equal(t, info["hits_last_minute"], results[0]["hits_last_minute"])
+	assert.Equal(t, float64(0), results[1]["hits_last_minute"])
+
+	buckets := Client.Keys(context.Background(), "R:rate_ns:busy:*").Val()
+	assert.NotEmpty(t, buckets)
+	assert.LessOrEqual(t, len(buckets), 2)
+	for _, bucket := range buckets {
+		assert.LessOrEqual(t, Client.TTL(context.Background(), bucket).Val(), 2*time.Minute)
+	}
+}
+
+func TestCounterOverflow(t *testing.T) {
+	r := setupTestRouter()
+	request := func(method, path string, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, path, strings.NewReader(body))
+		r.ServeHTTP(w, req)
+		return w
+	}
+	w := request("POST", "/create/overflow_ns/huge?initializer="+strconv.FormatInt(math.MaxInt64-1, 10), "")
+	assert.Equal(t, http.StatusCreated, w.Code)
+	w = request("GET", "/hit/overflow_ns/huge", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"value": 9223372036854775807}`, w.Body.String())
+
+	w = request("GET", "/hit/overflow_ns/huge", "")
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Contains(t, w.Body.String(), "9223372036854775807")
+	assert.Equal(t, strconv.FormatInt(math.MaxInt64, 10), Client.Get(context.Background(), "K:overflow_ns:huge").Val())
+
+	w = request("POST", "/reserve/overflow_ns/huge?count=2", "")
+	assert.Equal(t, http.StatusConflict, w.Code)
+
+	w = request("POST", "/hit-batch", `{"keys":[{"namespace":"overflow_ns","key":"huge"},{"namespace":"overflow_ns","key":"small"}]}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `[{"namespace": "overflow_ns", "key": "huge", "error": "`+overflowError+`"},
+		{"namespace": "overflow_ns", "key": "small", "value": 1}]`, w.Body.String())
+}
+
+func TestOverflowRecordsNothing(t *testing.T) {
+	r := setupTestRouter()
+	request := func(method, path string, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, path, strings.NewReader(body))
+		r.ServeHTTP(w, req)
+		return w
+	}
+	ctx := context.Background()
+	w := request("POST", "/create/overflow_record_ns/full?history=true&initializer="+strconv.FormatInt(math.MaxInt64, 10), "")
+	assert.Equal(t, http.StatusCreated, w.Code)
+	lastUpdated := Client.HGet(ctx, "M:overflow_record_ns:full", "last_updated").Val()
+
+	w = request("GET", "/hit/overflow_record_ns/full", "")
+	assert.Equal(t, http.StatusConflict, w.Code)
+	w = request("POST", "/reserve/overflow_record_ns/full?count=2", "")
+	assert.Equal(t, http.StatusConflict, w.Code)
+	w = request("POST", "/hit-batch", `{"keys":[{"namespace":"overflow_record_ns","key":"full"}]}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), overflowError)
+
+	assert.Equal(t, strconv.FormatInt(math.MaxInt64, 10), Client.Get(ctx, "K:overflow_record_ns:full").Val())
+	assert.Equal(t, int64(0), Client.XLen(ctx, "H:overflow_record_ns:full").Val())
+	assert.Equal(t, lastUpdated, Client.HGet(ctx, "M:overflow_record_ns:full", "last_updated").Val())
+	assert.Empty(t, Client.Keys(ctx, "R:overflow_record_ns:full:*").Val())
+}
+
+func TestNamespaceFromSubdomain(t *testing.T) {
+	SubdomainBase = "counters.example.com"
+	r := setupTestRouter()
+	SubdomainBase = ""
+	request := func(method, host, path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, path, nil)
+		req.Host = host
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	w := request("GET", "tenant.counters.example.com:8080", "/hit/visits/")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"value": 1}`, w.Body.String())
+	w = request("GET", "Tenant.Counters.Example.com", "/hit/visits/")
+	assert.JSONEq(t, `{"value": 2}`, w.Body.String())
+	assert.Equal(t, "2", Client.Get(context.Background(), "K:tenant:visits").Val())
+
+	// a namespace in the path still wins, and hosts that aren't subdomains fall back to the path
+	w = request("GET", "tenant.counters.example.com", "/hit/other_ns/visits")
+	assert.JSONEq(t, `{"value": 1}`, w.Body.String())
+	w = request("GET", "counters.example.com", "/get/tenant/visits")
+	assert.JSONEq(t, `{"value": 2}`, w.Body.String())
+	w = request("GET", "a.b.counters.example.com", "/hit/visits/")
+	assert.JSONEq(t, `{"value": 1}`, w.Body.String())
+	assert.Equal(t, "1", Client.Get(context.Background(), "K:default:visits").Val())
+
+	w = request("GET", "tenant.counters.example.com", "/info/visits/")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"full_key":"K:tenant:visits"`)
+}
+
+func TestCORSCredentials(t *testing.T) {
+	CORSOrigins, CORSCredentials, CORSMaxAge = []string{"https://embed.example.com"}, true, 10*time.Minute
+	defer func() { CORSOrigins, CORSCredentials, CORSMaxAge = nil, false, 12*time.Hour }()
+	r := setupTestRouter()
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("OPTIONS", "/get/test/cors_credentials", nil)
+	req.Header.Set("Origin", "https://embed.example.com")
+	req.Header.Set("Access-Control-Request-Method", "GET")
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.Equal(t, "https://embed.example.com", w.Header().Get("Access-Control-Allow-Origin"))
+	assert.Equal(t, "true", w.Header().Get("Access-Control-Allow-Credentials"))
+	assert.Equal(t, "600", w.Header().Get("Access-Control-Max-Age"))
+
+	// without them, the defaults neither allow credentials nor change the cache time
+	CORSOrigins, CORSCredentials, CORSMaxAge = nil, false, 12*time.Hour
+	r = setupTestRouter()
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, "*", w.Header().Get("Access-Control-Allow-Origin"))
+	assert.Empty(t, w.Header().Get("Access-Control-Allow-Credentials"))
+	assert.Equal(t, "43200", w.Header().Get("Access-Control-Max-Age"))
+}
+
+func TestExistsView(t *testing.T) {
+	r := setupTestRouter()
+	request := func(method, path, token string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, path, nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	w := request("GET", "/exists/exists_ns/missing", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"exists": false}`, w.Body.String())
+	assert.Zero(t, Client.Exists(context.Background(), "K:exists_ns:missing").Val())
+
+	request("POST", "/create/exists_ns/present", "")
+	w = request("GET", "/exists/exists_ns/present", "")
+	assert.JSONEq(t, `{"exists": true}`, w.Body.String())
+
+	w = request("POST", "/create/exists_ns/secret?visibility=private", "")
+	var created map[string]interface{}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &created))
+	w = request("GET", "/exists/exists_ns/secret", "")
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	w = request("GET", "/exists/exists_ns/secret", created["admin_key"].(string))
+	assert.JSONEq(t, `{"exists": true}`, w.Body.String())
+
+	w = request("GET", "/exists/exists_ns/in/valid", "")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestMaxMinView(t *testing.T) {
+	r := setupTestRouter()
+	request := func(path, token string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", path, nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		r.ServeHTTP(w, req)
+		return w
+	}
+	w := request("/create/extreme_ns/peak?initializer=95", "")
+	var created map[string]interface{}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &created))
+	token := created["admin_key"].(string)
+
+	w = request("/max/extreme_ns/peak?value=120", token)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"value": 120, "changed": true}`, w.Body.String())
+	w = request("/max/extreme_ns/peak?value=80", token)
+	assert.JSONEq(t, `{"value": 120, "changed": false}`, w.Body.String())
+	w = request("/max/extreme_ns/peak?value=120", token)
+	assert.JSONEq(t, `{"value": 120, "changed": false}`, w.Body.String())
+
+	w = request("/min/extreme_ns/peak?value=130", token)
+	assert.JSONEq(t, `{"value": 120, "changed": false}`, w.Body.String())
+	w = request("/min/extreme_ns/peak?value=-5", token)
+	assert.JSONEq(t, `{"value": -5, "changed": true}`, w.Body.String())
+	assert.Equal(t, "-5", Client.Get(context.Background(), "K:extreme_ns:peak").Val())
+
+	w = request("/max/extreme_ns/peak?value=high", token)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	w = request("/max/extreme_ns/peak?value=200", "")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	w = request("/max/extreme_ns/peak?value=200", "wrong-token")
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Equal(t, "-5", Client.Get(context.Background(), "K:extreme_ns:peak").Val())
+
+	t.Run("Concurrent reports keep the highest", func(t *testing.T) {
+		var wg sync.WaitGroup
+		for i := 1; i <= 50; i++ {
+			wg.Add(1)
+			go func(value int) {
+				defer wg.Done()
+				request("/max/extreme_ns/peak?value="+strconv.Itoa(value), token)
+			}(i)
+		}
+		wg.Wait()
+		assert.Equal(t, "50", Client.Get(context.Background(), "K:extreme_ns:peak").Val())
+	})
+
+	t.Run("Compared exactly", func(t *testing.T) {
+		Client.Set(context.Background(), "K:extreme_ns:peak", "9007199254740992", redis.KeepTTL)
+		// 2^53 + 1 is 2^53 as a double
+		w := request("/max/extreme_ns/peak?value=9007199254740993", token)
+		assert.JSONEq(t, `{"value": 9007199254740993, "changed": true}`, w.Body.String())
+		assert.Equal(t, "9007199254740993", Client.Get(context.Background(), "K:extreme_ns:peak").Val())
+	})
+
+	t.Run("Bounds of the counter", func(t *testing.T) {
+		w := request("/create/extreme_ns/bounded?initializer=5&max=10&min=0", "")
+		var created map[string]interface{}
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &created))
+		token := created["admin_key"].(string)
+
+		w = request("/max/extreme_ns/bounded?value=11", token)
+		assert.Equal(t, http.StatusConflict, w.Code)
+		assert.Contains(t, w.Body.String(), "max value of 10")
+		w = request("/max/extreme_ns/bounded?value=10", token)
+		assert.JSONEq(t, `{"value": 10, "changed": true}`, w.Body.String())
+
+		// like decrements, new mins past the min are clamped to it
+		w = request("/min/extreme_ns/bounded?value=-3", token)
+		assert.JSONEq(t, `{"value": 0, "changed": true, "clamped": true}`, w.Body.String())
+		w = request("/min/extreme_ns/bounded?value=-3", token)
+		assert.JSONEq(t, `{"value": 0, "changed": false, "clamped": true}`, w.Body.String())
+		assert.Equal(t, "0", Client.Get(context.Background(), "K:extreme_ns:bounded").Val())
+	})
+}
+
+func TestUnknownTokenIsRejected(t *testing.T) {
+	r := setupTestRouter()
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/hit/auth_ns/genuine", nil)
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	for _, path := range []string{"/set/auth_ns/genuine?value=1000", "/reset/auth_ns/genuine", "/delete/auth_ns/genuine"} {
+		w = httptest.NewRecorder()
+		req, _ = http.NewRequest("POST", path, nil)
+		req.Header.Set("Authorization", "Bearer junk")
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code, path)
+		assert.NotContains(t, w.Body.String(), "value", path)
+	}
+	assert.Equal(t, "1", Client.Get(context.Background(), "K:auth_ns:genuine").Val())
 }
@@ -0,0 +1,70 @@
+package utils
+
+import (
+	"fmt"
+	"html"
+	"regexp"
+	"strings"
+)
+
+const (
+	BadgeStyleFlat    = "flat"
+	BadgeStylePlastic = "plastic"
+
+	badgeCharWidth = 7  // rough width of a character in 11px Verdana
+	badgePadding   = 10 // horizontal padding on each side of a badge half
+)
+
+var (
+	badgeColors = map[string]string{
+		"brightgreen": "#4c1",
+		"green":       "#97ca00",
+		"yellow":      "#dfb317",
+		"orange":      "#fe7d37",
+		"red":         "#e05d44",
+		"blue":        "#007ec6",
+		"lightgrey":   "#9f9f9f",
+		"gray":        "#555",
+	}
+	hexColorPattern = regexp.MustCompile(`^[0-9a-fA-F]{3}([0-9a-fA-F]{3})?$`)
+)
+
+// BadgeColor resolves a named color (e.g. "blue") or a hex color without the leading # (e.g. "ff69b4").
+func BadgeColor(color string) (string, error) {
+	if named, ok := badgeColors[strings.ToLower(color)]; ok {
+		return named, nil
+	}
+	if hexColorPattern.MatchString(color) {
+		return "#" + color, nil
+	}
+	return "", fmt.Errorf("must be a named color or a hex color such as ff69b4")
+}
+
+// RenderBadge renders a shields.io style SVG badge with the label on the left and the value on the right.
+// color must already be resolved through BadgeColor.
+func RenderBadge(label, value, color, style string) string {
+	labelWidth := len(label)*badgeCharWidth + 2*badgePadding
+	valueWidth := len(value)*badgeCharWidth + 2*badgePadding
+	width := labelWidth + valueWidth
+
+	radius, gradient := 3, `<stop offset="0" stop-color="#bbb" stop-opacity=".1"/><stop offset="1" stop-opacity=".1"/>`
+	if style == BadgeStylePlastic {
+		radius, gradient = 4, `<stop offset="0" stop-color="#fff" stop-opacity=".7"/><stop offset=".1" stop-color="#aaa" stop-opacity=".1"/><stop offset=".9" stop-opacity=".3"/><stop offset="1" stop-opacity=".5"/>`
+	}
+
+	label, value = html.EscapeString(label), html.EscapeString(value)
+	return fmt.Sprintf(`<svg xmlns="http://www.w3.org/2000/svg" width="%[1]d" height="20" role="img" aria-label="%[4]s: %[5]s">`+
+		`<title>%[4]s: %[5]s</title>`+
+		`<linearGradient id="s" x2="0" y2="100%%">%[9]s</linearGradient>`+
+		`<clipPath id="r"><rect width="%[1]d" height="20" rx="%[8]d" fill="#fff"/></clipPath>`+
+		`<g clip-path="url(#r)">`+
+		`<rect width="%[2]d" height="20" fill="#555"/>`+
+		`<rect x="%[2]d" width="%[3]d" height="20" fill="%[6]s"/>`+
+		`<rect width="%[1]d" height="20" fill="url(#s)"/>`+
+		`</g>`+
+		`<g fill="#fff" text-anchor="middle" font-family="Verdana,Geneva,DejaVu Sans,sans-serif" font-size="11">`+
+		`<text x="%[7]d" y="14">%[4]s</text>`+
+		`<text x="%[10]d" y="14">%[5]s</text>`+
+		`</g></svg>`,
+		width, labelWidth, valueWidth, label, value, color, labelWidth/2, radius, gradient, labelWidth+valueWidth/2)
+}
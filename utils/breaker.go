@@ -0,0 +1,150 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// ErrCircuitOpen is returned in place of running a redis command while the circuit breaker is open.
+var ErrCircuitOpen = errors.New("redis is unavailable, the circuit breaker is open")
+
+// States of a Breaker.
+const (
+	BreakerClosed   = "closed"    // commands run as usual
+	BreakerOpen     = "open"      // commands fail right away until the cool-down is over
+	BreakerHalfOpen = "half_open" // a single command probes whether redis recovered
+)
+
+// Breaker is a redis hook that stops sending commands to redis once threshold of them failed in a row, so an outage
+// fails requests fast instead of piling them up waiting on timeouts. After cooldown a single command is let through
+// as a probe, closing the breaker again if it succeeds and reopening it if it fails.
+type Breaker struct {
+	threshold int
+	cooldown  time.Duration
+
+	mu       sync.Mutex
+	state    string
+	failures int // consecutive failures
+	openedAt time.Time
+	probing  bool
+}
+
+// BreakerStatus is the state of a Breaker, as reported by /healthcheck.
+type BreakerStatus struct {
+	State    string `json:"state"`
+	Failures int    `json:"consecutive_failures"`
+	RetryIn  int64  `json:"retry_in,omitempty"` // seconds until a probe is let through, only set while open
+}
+
+func NewBreaker(threshold int, cooldown time.Duration) *Breaker {
+	return &Breaker{threshold: threshold, cooldown: cooldown, state: BreakerClosed}
+}
+
+// Open reports whether commands are currently being refused.
+func (b *Breaker) Open() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state == BreakerOpen && time.Since(b.openedAt) < b.cooldown
+}
+
+func (b *Breaker) Status() BreakerStatus {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	status := BreakerStatus{State: b.state, Failures: b.failures}
+	if b.state == BreakerOpen {
+		if remaining := b.cooldown - time.Since(b.openedAt); remaining > 0 {
+			status.RetryIn = int64(math.Ceil(remaining.Seconds()))
+		} else {
+			status.State = BreakerHalfOpen // the next command probes
+		}
+	}
+	return status
+}
+
+// allow reports whether a command may be sent, starting a probe if the cool-down is over.
+func (b *Breaker) allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state == BreakerOpen {
+		if time.Since(b.openedAt) < b.cooldown {
+			return ErrCircuitOpen
+		}
+		b.state = BreakerHalfOpen
+	}
+	if b.state == BreakerHalfOpen {
+		if b.probing {
+			return ErrCircuitOpen
+		}
+		b.probing = true
+	}
+	return nil
+}
+
+// record counts the outcome of a command that was sent.
+func (b *Breaker) record(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.probing = false
+	if !unreachable(err) {
+		b.failures = 0
+		b.state = BreakerClosed
+		return
+	}
+	b.failures++
+	if b.state == BreakerHalfOpen || b.failures >= b.threshold {
+		b.state = BreakerOpen
+		b.openedAt = time.Now()
+	}
+}
+
+// unreachable reports whether err means that redis couldn't be reached or didn't answer in time. Errors redis replied
+// with, such as redis.Nil, mean that it is up, and a request the client gave up on says nothing about redis.
+func unreachable(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) {
+		return false
+	}
+	var redisErr redis.Error
+	if errors.As(err, &redisErr) {
+		return false
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) || errors.Is(err, context.DeadlineExceeded) || errors.Is(err, io.EOF) ||
+		errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+func (b *Breaker) DialHook(next redis.DialHook) redis.DialHook {
+	return next
+}
+
+func (b *Breaker) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		if err := b.allow(); err != nil {
+			cmd.SetErr(err)
+			return err
+		}
+		err := next(ctx, cmd)
+		b.record(err)
+		return err
+	}
+}
+
+func (b *Breaker) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		if err := b.allow(); err != nil {
+			for _, cmd := range cmds {
+				cmd.SetErr(err)
+			}
+			return err
+		}
+		err := next(ctx, cmds)
+		b.record(err)
+		return err
+	}
+}
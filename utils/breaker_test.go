@@ -0,0 +1,72 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBreaker(t *testing.T) {
+	outage := &net.OpError{Op: "dial", Err: errors.New("connection refused")}
+
+	t.Run("Open after consecutive failures", func(t *testing.T) {
+		breaker := NewBreaker(3, time.Minute)
+		for i := 0; i < 2; i++ {
+			assert.NoError(t, breaker.allow())
+			breaker.record(outage)
+		}
+		assert.NoError(t, breaker.allow())
+		breaker.record(nil) // a success resets the count
+		for i := 0; i < 3; i++ {
+			assert.NoError(t, breaker.allow())
+			breaker.record(outage)
+		}
+		assert.True(t, breaker.Open())
+		assert.ErrorIs(t, breaker.allow(), ErrCircuitOpen)
+		status := breaker.Status()
+		assert.Equal(t, BreakerOpen, status.State)
+		assert.Equal(t, 3, status.Failures)
+		assert.Equal(t, int64(60), status.RetryIn)
+	})
+
+	t.Run("Probe after the cool-down", func(t *testing.T) {
+		breaker := NewBreaker(1, time.Millisecond)
+		breaker.allow()
+		breaker.record(outage)
+		time.Sleep(2 * time.Millisecond)
+		assert.Equal(t, BreakerHalfOpen, breaker.Status().State)
+
+		assert.NoError(t, breaker.allow()) // the probe
+		assert.ErrorIs(t, breaker.allow(), ErrCircuitOpen, "only one probe at a time")
+		breaker.record(outage)
+		assert.True(t, breaker.Open(), "a failed probe reopens the breaker")
+
+		time.Sleep(2 * time.Millisecond)
+		assert.NoError(t, breaker.allow())
+		breaker.record(nil)
+		assert.Equal(t, BreakerClosed, breaker.Status().State)
+		assert.NoError(t, breaker.allow())
+	})
+
+	t.Run("Only outages count", func(t *testing.T) {
+		tests := []struct {
+			err  error
+			want bool
+		}{
+			{nil, false},
+			{redis.Nil, false},
+			{context.Canceled, false},
+			{errors.New("WRONGTYPE Operation against a key holding the wrong kind of value"), false},
+			{outage, true},
+			{context.DeadlineExceeded, true},
+		}
+		for _, tt := range tests {
+			assert.Equal(t, tt.want, unreachable(tt.err), "%v", tt.err)
+		}
+	})
+}
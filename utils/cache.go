@@ -0,0 +1,88 @@
+package utils
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// Counters caches the values of recently read counters in memory, nil unless caching was enabled. Every write to a
+// counter invalidates its entry on this instance, other instances may serve the old value until their entry expires.
+var Counters *CounterCache
+
+// CounterCache is a least recently used cache of counter values and their metadata, whose entries expire after ttl.
+// All methods are safe to call on a nil cache, which caches nothing.
+type CounterCache struct {
+	mutex   sync.Mutex
+	size    int
+	ttl     time.Duration
+	entries map[string]*list.Element
+	order   *list.List // of *cacheEntry, most recently used first
+}
+
+type cacheEntry struct {
+	dbKey     string
+	value     interface{}
+	meta      Metadata
+	expiresAt time.Time
+}
+
+func NewCounterCache(size int, ttl time.Duration) *CounterCache {
+	return &CounterCache{size: size, ttl: ttl, entries: make(map[string]*list.Element), order: list.New()}
+}
+
+// Get returns the cached value and metadata of the counter dbKey, ok being false if it isn't cached.
+func (cc *CounterCache) Get(dbKey string) (value interface{}, meta Metadata, ok bool) {
+	if cc == nil {
+		return nil, Metadata{}, false
+	}
+	cc.mutex.Lock()
+	defer cc.mutex.Unlock()
+	element, ok := cc.entries[dbKey]
+	if !ok {
+		return nil, Metadata{}, false
+	}
+	entry := element.Value.(*cacheEntry)
+	if time.Now().After(entry.expiresAt) {
+		cc.remove(element)
+		return nil, Metadata{}, false
+	}
+	cc.order.MoveToFront(element)
+	return entry.value, entry.meta, true
+}
+
+// Set caches the value and metadata of the counter dbKey, evicting the least recently used entry if the cache is full.
+func (cc *CounterCache) Set(dbKey string, value interface{}, meta Metadata) {
+	if cc == nil {
+		return
+	}
+	cc.mutex.Lock()
+	defer cc.mutex.Unlock()
+	entry := &cacheEntry{dbKey: dbKey, value: value, meta: meta, expiresAt: time.Now().Add(cc.ttl)}
+	if element, ok := cc.entries[dbKey]; ok {
+		element.Value = entry
+		cc.order.MoveToFront(element)
+		return
+	}
+	cc.entries[dbKey] = cc.order.PushFront(entry)
+	if cc.order.Len() > cc.size {
+		cc.remove(cc.order.Back())
+	}
+}
+
+// Invalidate drops the cached value of the counter dbKey, to be called whenever it (or its metadata) changes.
+func (cc *CounterCache) Invalidate(dbKey string) {
+	if cc == nil {
+		return
+	}
+	cc.mutex.Lock()
+	defer cc.mutex.Unlock()
+	if element, ok := cc.entries[dbKey]; ok {
+		cc.remove(element)
+	}
+}
+
+func (cc *CounterCache) remove(element *list.Element) {
+	cc.order.Remove(element)
+	delete(cc.entries, element.Value.(*cacheEntry).dbKey)
+}
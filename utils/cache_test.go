@@ -0,0 +1,61 @@
+package utils
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCounterCache(t *testing.T) {
+	t.Run("Get what was set", func(t *testing.T) {
+		cache := NewCounterCache(2, time.Minute)
+		cache.Set("K:ns:a", int64(1), Metadata{Type: IntCounter})
+		value, meta, ok := cache.Get("K:ns:a")
+		assert.True(t, ok)
+		assert.Equal(t, int64(1), value)
+		assert.Equal(t, IntCounter, meta.Type)
+
+		_, _, ok = cache.Get("K:ns:b")
+		assert.False(t, ok)
+	})
+
+	t.Run("Evict the least recently used", func(t *testing.T) {
+		cache := NewCounterCache(2, time.Minute)
+		cache.Set("K:ns:a", int64(1), Metadata{})
+		cache.Set("K:ns:b", int64(2), Metadata{})
+		cache.Get("K:ns:a")
+		cache.Set("K:ns:c", int64(3), Metadata{})
+
+		_, _, ok := cache.Get("K:ns:b")
+		assert.False(t, ok)
+		_, _, ok = cache.Get("K:ns:a")
+		assert.True(t, ok)
+		_, _, ok = cache.Get("K:ns:c")
+		assert.True(t, ok)
+	})
+
+	t.Run("Expire", func(t *testing.T) {
+		cache := NewCounterCache(2, time.Millisecond)
+		cache.Set("K:ns:a", int64(1), Metadata{})
+		time.Sleep(2 * time.Millisecond)
+		_, _, ok := cache.Get("K:ns:a")
+		assert.False(t, ok)
+	})
+
+	t.Run("Invalidate", func(t *testing.T) {
+		cache := NewCounterCache(2, time.Minute)
+		cache.Set("K:ns:a", int64(1), Metadata{})
+		cache.Invalidate("K:ns:a")
+		_, _, ok := cache.Get("K:ns:a")
+		assert.False(t, ok)
+	})
+
+	t.Run("Nil cache", func(t *testing.T) {
+		var cache *CounterCache
+		cache.Set("K:ns:a", int64(1), Metadata{})
+		cache.Invalidate("K:ns:a")
+		_, _, ok := cache.Get("K:ns:a")
+		assert.False(t, ok)
+	})
+}
@@ -0,0 +1,95 @@
+package utils
+
+import (
+	"reflect"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/goccy/go-json"
+)
+
+// WantsCamelCase reports whether the client asked for camelCase field names, via ?case=camel or a case=camel
+// parameter of the Accept header (e.g. Accept: application/json; case=camel). snake_case is the default.
+func WantsCamelCase(c *gin.Context) bool {
+	if value, ok := c.GetQuery("case"); ok {
+		return strings.EqualFold(value, "camel")
+	}
+	for _, accept := range strings.Split(c.GetHeader("Accept"), ",") {
+		_, params, _ := strings.Cut(accept, ";")
+		for _, param := range strings.Split(params, ";") {
+			name, value, _ := strings.Cut(strings.TrimSpace(param), "=")
+			if strings.EqualFold(name, "case") && strings.EqualFold(strings.Trim(value, `"`), "camel") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// CamelCase turns a snake_case field name into camelCase, e.g. last_updated into lastUpdated. Repeated underscores
+// count as one, so expired_keys__since_restart becomes expiredKeysSinceRestart.
+func CamelCase(name string) string {
+	var builder strings.Builder
+	builder.Grow(len(name))
+	upper := false
+	for _, char := range name {
+		switch {
+		case char == '_' && builder.Len() > 0:
+			upper = true
+		case upper:
+			builder.WriteString(strings.ToUpper(string(char)))
+			upper = false
+		default:
+			builder.WriteRune(char)
+		}
+	}
+	return builder.String()
+}
+
+// CamelKeys returns body with the field names of its gin.H objects in camelCase, recursing into nested objects and
+// lists. Other maps hold data (tags, namespace names, path counts...) and are kept as is. Structs are converted by
+// their json field names, one level deep.
+func CamelKeys(body interface{}) interface{} {
+	switch body := body.(type) {
+	case nil, string, bool, int, int64, float64, json.Number:
+		return body
+	case gin.H:
+		converted := make(gin.H, len(body))
+		for name, value := range body {
+			converted[CamelCase(name)] = CamelKeys(value)
+		}
+		return converted
+	case []gin.H:
+		converted := make([]gin.H, len(body))
+		for i, value := range body {
+			converted[i] = CamelKeys(value).(gin.H)
+		}
+		return converted
+	case []interface{}:
+		converted := make([]interface{}, len(body))
+		for i, value := range body {
+			converted[i] = CamelKeys(value)
+		}
+		return converted
+	}
+	value := reflect.ValueOf(body)
+	if value.Kind() == reflect.Pointer && !value.IsNil() {
+		value = value.Elem()
+	}
+	if value.Kind() != reflect.Struct {
+		return body
+	}
+	encoded, err := json.Marshal(body)
+	if err != nil {
+		return body // let the renderer report it
+	}
+	var fields gin.H
+	if err := json.Unmarshal(encoded, &fields); err != nil {
+		return body
+	}
+	converted := make(gin.H, len(fields))
+	for name, field := range fields {
+		converted[CamelCase(name)] = field
+	}
+	return converted
+}
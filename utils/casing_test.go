@@ -0,0 +1,45 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCamelCase(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected string
+	}{
+		{"value", "value"},
+		{"last_updated", "lastUpdated"},
+		{"is_genuine", "isGenuine"},
+		{"expired_keys__since_restart", "expiredKeysSinceRestart"},
+		{"_private", "_private"},
+		{"trailing_", "trailing"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.input, func(t *testing.T) {
+			assert.Equal(t, tc.expected, CamelCase(tc.input))
+		})
+	}
+}
+
+func TestCamelKeys(t *testing.T) {
+	body := gin.H{
+		"last_updated": 1,
+		"tags":         map[string]string{"build_id": "42"},
+		"counters":     gin.H{"top_namespaces": []gin.H{{"namespace": "my_ns", "key_count": 2}}},
+		"breaker":      BreakerStatus{State: "closed", Failures: 3},
+		"keys":         []interface{}{gin.H{"expires_in": -1}},
+	}
+	assert.Equal(t, gin.H{
+		"lastUpdated": 1,
+		"tags":        map[string]string{"build_id": "42"},
+		"counters":    gin.H{"topNamespaces": []gin.H{{"namespace": "my_ns", "keyCount": 2}}},
+		"breaker":     gin.H{"state": "closed", "consecutiveFailures": float64(3)},
+		"keys":        []interface{}{gin.H{"expiresIn": -1}},
+	}, CamelKeys(body))
+}
@@ -0,0 +1,117 @@
+package utils
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	censusScanCount     = 1000 // keys fetched per SCAN round trip when counting the counters
+	CensusTopNamespaces = 10   // number of namespaces a page of the census lists by default
+	CensusMaxPage       = 1000 // most namespaces a page of the census can list
+)
+
+// NamespaceCount is the number of counters in a namespace.
+type NamespaceCount struct {
+	Namespace string `json:"namespace"`
+	Counters  int64  `json:"counters"`
+}
+
+// Census is a count of the counters in the database at TakenAt.
+type Census struct {
+	Total      int64
+	Namespaces []NamespaceCount // every namespace, largest first
+	TakenAt    time.Time
+	byName     []NamespaceCount // the same namespaces in alphabetical order
+}
+
+// Page returns limit namespaces of the census starting at offset, ordered by "count" (largest first) or "name".
+func (c Census) Page(order string, offset, limit int) []NamespaceCount {
+	namespaces := c.Namespaces
+	if order == "name" {
+		namespaces = c.byName
+	}
+	if offset >= len(namespaces) {
+		return []NamespaceCount{}
+	}
+	end := offset + limit
+	if end > len(namespaces) {
+		end = len(namespaces)
+	}
+	return namespaces[offset:end]
+}
+
+// TakeCensus counts the counters of every namespace. It scans the whole keyspace, so use a CensusCache instead of
+// calling it per request.
+func TakeCensus(ctx context.Context, client redis.UniversalClient) (Census, error) {
+	counts := make(map[string]int64)
+	census := Census{TakenAt: time.Now()}
+	err := ForEachNode(ctx, client, func(ctx context.Context, node redis.UniversalClient) error {
+		var cursor uint64
+		for {
+			keys, next, err := node.Scan(ctx, cursor, "K"+KeySeparator+"*", censusScanCount).Result()
+			if err != nil {
+				return err
+			}
+			for _, key := range keys {
+				if namespace, _, ok := SplitDBKey(key); ok {
+					counts[namespace]++
+					census.Total++
+				}
+			}
+			if next == 0 {
+				return nil
+			}
+			cursor = next
+		}
+	})
+	if err != nil {
+		return Census{}, err
+	}
+
+	census.Namespaces = make([]NamespaceCount, 0, len(counts))
+	for namespace, count := range counts {
+		census.Namespaces = append(census.Namespaces, NamespaceCount{Namespace: namespace, Counters: count})
+	}
+	sort.Slice(census.Namespaces, func(i, j int) bool {
+		a, b := census.Namespaces[i], census.Namespaces[j]
+		return a.Counters > b.Counters || (a.Counters == b.Counters && a.Namespace < b.Namespace)
+	})
+	census.byName = append([]NamespaceCount(nil), census.Namespaces...)
+	sort.Slice(census.byName, func(i, j int) bool {
+		return census.byName[i].Namespace < census.byName[j].Namespace
+	})
+	return census, nil
+}
+
+// CensusCache takes a census at most once per interval. Requests that come in while it is being taken wait for it
+// rather than starting scans of their own.
+type CensusCache struct {
+	client   redis.UniversalClient
+	interval time.Duration
+	mutex    sync.Mutex
+	census   *Census
+}
+
+func NewCensusCache(client redis.UniversalClient, interval time.Duration) *CensusCache {
+	return &CensusCache{client: client, interval: interval}
+}
+
+// Get returns the cached census, taking a new one if it is missing or older than the interval.
+func (cc *CensusCache) Get(ctx context.Context) (Census, error) {
+	cc.mutex.Lock()
+	defer cc.mutex.Unlock()
+	if cc.census != nil && time.Since(cc.census.TakenAt) < cc.interval {
+		return *cc.census, nil
+	}
+	census, err := TakeCensus(ctx, cc.client)
+	if err != nil {
+		return Census{}, err
+	}
+	cc.census = &census
+	return census, nil
+}
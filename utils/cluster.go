@@ -0,0 +1,70 @@
+package utils
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// HashTags wraps the namespace in the db keys in braces, e.g. K:{namespace}:key, so that Redis Cluster keeps all the
+// keys of a namespace in one hash slot. It is enabled by REDIS_CLUSTER, and changing it orphans the existing counters.
+var HashTags = false
+
+// dbNamespace returns namespace the way it appears in db keys.
+func dbNamespace(namespace string) string {
+	if HashTags {
+		return "{" + namespace + "}"
+	}
+	return namespace
+}
+
+// SplitDBKey returns the namespace and key of the counter dbKey, reporting false if it isn't one.
+func SplitDBKey(dbKey string) (string, string, bool) {
+	parts := strings.SplitN(dbKey, KeySeparator, 3)
+	if len(parts) != 3 {
+		return "", "", false
+	}
+	namespace := parts[1]
+	if HashTags {
+		namespace = strings.TrimSuffix(strings.TrimPrefix(namespace, "{"), "}")
+	}
+	return namespace, parts[2], true
+}
+
+// NamespaceNode returns the client to scan the keys of namespace with. On a cluster, a SCAN only covers the node it is
+// sent to, which for a namespace is the master holding its hash slot. Any other client is returned as is.
+func NamespaceNode(ctx context.Context, client redis.UniversalClient, namespace string) (redis.UniversalClient, error) {
+	cluster, ok := client.(*redis.ClusterClient)
+	if !ok {
+		return client, nil
+	}
+	return cluster.MasterForKey(ctx, dbNamespace(namespace))
+}
+
+// ForEachNode calls fn with every master of a cluster, one at a time, or just with client if it isn't one. Scans of
+// the whole keyspace have to go through it.
+func ForEachNode(ctx context.Context, client redis.UniversalClient, fn func(ctx context.Context, node redis.UniversalClient) error) error {
+	cluster, ok := client.(*redis.ClusterClient)
+	if !ok {
+		return fn(ctx, client)
+	}
+	var mutex sync.Mutex
+	var masters []*redis.Client
+	err := cluster.ForEachMaster(ctx, func(ctx context.Context, master *redis.Client) error {
+		mutex.Lock() // ForEachMaster calls this concurrently, collecting the masters spares fn from having to cope with that
+		masters = append(masters, master)
+		mutex.Unlock()
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for _, master := range masters {
+		if err := fn(ctx, master); err != nil {
+			return err
+		}
+	}
+	return nil
+}
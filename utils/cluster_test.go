@@ -0,0 +1,44 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHashTags(t *testing.T) {
+	HashTags = true
+	defer func() { HashTags = false }()
+	dbKey := BuildDBKey("mysite.com", "visits")
+	assert.Equal(t, "K:{mysite.com}:visits", dbKey)
+	assert.Equal(t, "M:{mysite.com}:visits", CreateMetaKey(dbKey))
+	assert.Equal(t, "N:{mysite.com}", CreateNamespaceKey("mysite.com"))
+	assert.Equal(t, "mysite.com", NamespaceOf(dbKey))
+}
+
+func TestSplitDBKey(t *testing.T) {
+	testCases := []struct {
+		dbKey             string
+		hashTags          bool
+		expectedNamespace string
+		expectedKey       string
+		expectedOk        bool
+	}{
+		{"K:mysite.com:visits", false, "mysite.com", "visits", true},
+		{"K:{mysite.com}:visits", true, "mysite.com", "visits", true},
+		{"M:{mysite.com}:visits", true, "mysite.com", "visits", true},
+		{"K:mysite.com", false, "", "", false},
+		{"K:{mysite.com}", true, "", "", false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.dbKey, func(t *testing.T) {
+			HashTags = tc.hashTags
+			defer func() { HashTags = false }()
+			namespace, key, ok := SplitDBKey(tc.dbKey)
+			assert.Equal(t, tc.expectedNamespace, namespace)
+			assert.Equal(t, tc.expectedKey, key)
+			assert.Equal(t, tc.expectedOk, ok)
+		})
+	}
+}
@@ -2,7 +2,75 @@ package utils
 
 import "time"
 
-const BaseTTLPeriod = time.Hour * 365 * 10 // 10 years
+const DefaultBaseTTLPeriod = time.Hour * 365 * 10 // 10 years
+
+// BaseTTLPeriod is the expiry of counters without a custom ttl, pushed back whenever they are used. It defaults to
+// DefaultBaseTTLPeriod and can be changed with DEFAULT_TTL.
+var BaseTTLPeriod = DefaultBaseTTLPeriod
+
+// Location is the timezone date-based operations, such as the periods of rolling counters, use. It is UTC unless
+// DEFAULT_TIMEZONE (or TZ) says otherwise.
+var Location = time.UTC
+
+// FormatTime formats t as RFC3339 in Location, for the times the API reports as text rather than unix time.
+func FormatTime(t time.Time) string {
+	return t.In(Location).Format(time.RFC3339)
+}
+
+// localTimeLayouts are the layouts ParseTime accepts besides RFC3339, read as times in Location.
+var localTimeLayouts = []string{"2006-01-02T15:04:05", "2006-01-02T15:04", "2006-01-02"}
+
+// ParseTime parses raw as RFC3339 or, lacking a UTC offset, as a date (and time of day) in Location, so a day starts
+// at the same midnight rolling counters do.
+func ParseTime(raw string) (time.Time, error) {
+	parsed, err := time.Parse(time.RFC3339, raw)
+	if err == nil {
+		return parsed, nil
+	}
+	for _, layout := range localTimeLayouts {
+		if local, localErr := time.ParseInLocation(layout, raw, Location); localErr == nil {
+			return local, nil
+		}
+	}
+	return time.Time{}, err
+}
 
 const MinLength = 3
 const MaxLength = 64
+
+const DefaultMaxKeyDepth = 2
+
+// MaxKeyDepth is the max number of segments in the path of a counter, its namespace and key. Keys can't contain
+// slashes, so it can only be lowered, to 1 to only allow counters of the default namespace in paths, with
+// MAX_KEY_DEPTH.
+var MaxKeyDepth = DefaultMaxKeyDepth
+
+const DefaultMaxKeyPathLength = 256
+
+// MaxKeyPathLength is the max length of the path of a counter, namespace, key and slashes, past which requests are
+// rejected before the path is even split. It defaults to DefaultMaxKeyPathLength and can be changed with
+// MAX_KEY_PATH_LENGTH.
+var MaxKeyPathLength = DefaultMaxKeyPathLength
+
+const MaxBatchSize = 50 // max number of keys in a single batch request
+
+const ListPageSize = 100 // number of keys scanned per page of /list
+
+// KeySeparator joins the parts of db keys, e.g. K:{namespace}:{key}. Changing it orphans the existing counters.
+var KeySeparator = ":"
+
+const MaxTags = 10 // max number of tags a counter can carry
+
+const MaxDescriptionLength = 280 // max number of characters in the description of a counter
+
+const HistoryRetention = 30 * 24 * time.Hour // how long the changes of counters with history are kept for
+
+const HistoryMaxEntries = 100000 // most changes the history of a single counter holds, the oldest are dropped first
+
+const MaxHistoryBuckets = 1000 // max number of buckets a single /history request can return
+
+const MaxSnapshots = 100 // max number of snapshots a single counter can have
+
+const DefaultUniqueWindow = 24 * time.Hour // how long unique counters count each visitor once for, unless set
+
+const VisitorCookie = "abacus_visitor" // cookie identifying a visitor to unique counters, the IP is used without it
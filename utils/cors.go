@@ -0,0 +1,44 @@
+package utils
+
+import (
+	"errors"
+	"strings"
+)
+
+// ParseOrigins parses a comma separated list of allowed origins, such as "https://example.com,*.example.com". Each
+// origin may contain a single * wildcard.
+func ParseOrigins(raw string) ([]string, error) {
+	var origins []string
+	for _, origin := range strings.Split(raw, ",") {
+		origin = strings.TrimSpace(origin)
+		if origin == "" {
+			continue
+		}
+		if strings.Count(origin, "*") > 1 {
+			return nil, errors.New("origin " + origin + " contains more than one *")
+		}
+		origins = append(origins, origin)
+	}
+	if len(origins) == 0 {
+		return nil, errors.New("no origins given")
+	}
+	return origins, nil
+}
+
+// OriginAllowed reports whether origin matches one of the allowed origins, where * matches any part of it (so
+// *.example.com matches every subdomain of example.com, over any scheme).
+func OriginAllowed(allowed []string, origin string) bool {
+	for _, pattern := range allowed {
+		before, after, wildcard := strings.Cut(pattern, "*")
+		if !wildcard {
+			if origin == pattern {
+				return true
+			}
+			continue
+		}
+		if len(origin) >= len(before)+len(after) && strings.HasPrefix(origin, before) && strings.HasSuffix(origin, after) {
+			return true
+		}
+	}
+	return false
+}
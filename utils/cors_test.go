@@ -0,0 +1,37 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOriginAllowed(t *testing.T) {
+	allowed, err := ParseOrigins("https://example.com, *.example.org,https://*.example.net")
+	assert.NoError(t, err)
+	testCases := []struct {
+		origin   string
+		expected bool
+	}{
+		{"https://example.com", true},
+		{"http://example.com", false},
+		{"https://www.example.com", false},
+		{"https://blog.example.org", true},
+		{"http://a.b.example.org", true},
+		{"https://example.org", false},
+		{"https://evilexample.org", false},
+		{"https://www.example.net", true},
+		{"http://www.example.net", false},
+		{"", false},
+	}
+	for _, tc := range testCases {
+		assert.Equal(t, tc.expected, OriginAllowed(allowed, tc.origin), tc.origin)
+	}
+}
+
+func TestParseOrigins(t *testing.T) {
+	_, err := ParseOrigins("*.*.example.com")
+	assert.Error(t, err)
+	_, err = ParseOrigins(" , ")
+	assert.Error(t, err)
+}
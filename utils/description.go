@@ -0,0 +1,65 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// descriptionField is the field of the metadata hash (M:) holding the description of a counter.
+const descriptionField = "description"
+
+// ValidateDescription checks that a description is at most MaxDescriptionLength characters of printable text.
+func ValidateDescription(description string) error {
+	if !utf8.ValidString(description) {
+		return errors.New("description must be valid UTF-8")
+	}
+	if utf8.RuneCountInString(description) > MaxDescriptionLength {
+		return fmt.Errorf("description can't be longer than %d characters", MaxDescriptionLength)
+	}
+	for _, r := range description {
+		if unicode.IsControl(r) {
+			return errors.New("description must not contain control characters")
+		}
+	}
+	return nil
+}
+
+// SetDescription replaces the description of the counter dbKey, "" removing it. ttl is the remaining one of the
+// counter, which its metadata expires alongside.
+func SetDescription(ctx context.Context, client redis.UniversalClient, dbKey, description string, ttl time.Duration) error {
+	Counters.Invalidate(dbKey)
+	metaKey := CreateMetaKey(dbKey)
+	if description == "" {
+		return client.HDel(ctx, metaKey, descriptionField).Err()
+	}
+	pipe := client.Pipeline()
+	pipe.HSet(ctx, metaKey, descriptionField, description)
+	if ttl > 0 {
+		pipe.Expire(ctx, metaKey, ttl)
+	}
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// Descriptions returns the descriptions of the counters dbKeys, "" for those without one.
+func Descriptions(ctx context.Context, client redis.UniversalClient, dbKeys []string) ([]string, error) {
+	pipe := client.Pipeline()
+	cmds := make([]*redis.StringCmd, len(dbKeys))
+	for i, dbKey := range dbKeys {
+		cmds[i] = pipe.HGet(ctx, CreateMetaKey(dbKey), descriptionField)
+	}
+	if _, err := pipe.Exec(ctx); err != nil && !errors.Is(err, redis.Nil) {
+		return nil, err
+	}
+	descriptions := make([]string, len(dbKeys))
+	for i, cmd := range cmds {
+		descriptions[i] = cmd.Val()
+	}
+	return descriptions, nil
+}
@@ -0,0 +1,30 @@
+package utils
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateDescription(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected error
+	}{
+		{"", nil},
+		{"Visits of the landing page", nil},
+		{"Besuche der Startseite – ohne Bots 🐶", nil},
+		{strings.Repeat("ä", MaxDescriptionLength), nil},
+		{strings.Repeat("a", MaxDescriptionLength+1), fmt.Errorf("description can't be longer than %d characters", MaxDescriptionLength)},
+		{"first line\nsecond line", fmt.Errorf("description must not contain control characters")},
+		{"\xff", fmt.Errorf("description must be valid UTF-8")},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.input, func(t *testing.T) {
+			assert.Equal(t, tc.expected, ValidateDescription(tc.input))
+		})
+	}
+}
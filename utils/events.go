@@ -0,0 +1,126 @@
+package utils
+
+import (
+	"context"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	DefaultEventStream  = "abacus:events"
+	DefaultEventBuffer  = 1000
+	eventPublishTimeout = 5 * time.Second
+	// MaxEventStreamLength is about how many events the redis stream keeps, consumers lagging further behind miss some.
+	MaxEventStreamLength = 100000
+)
+
+// Event describes a successful write to a counter. Value is its value after the write, nil if it was deleted (or, for
+// renames, moved without being read).
+type Event struct {
+	Namespace string      `json:"namespace"`
+	Key       string      `json:"key"`
+	Op        string      `json:"op"`
+	Value     interface{} `json:"value"`
+	Timestamp int64       `json:"timestamp"` // unix millis
+}
+
+// EventPublisher sends events to a message queue.
+type EventPublisher interface {
+	Publish(ctx context.Context, event Event) error
+}
+
+// RedisStreamPublisher appends every event to a redis stream, trimmed to about MaxLen entries if MaxLen is positive.
+type RedisStreamPublisher struct {
+	Client redis.UniversalClient
+	Stream string
+	MaxLen int64
+}
+
+func (p RedisStreamPublisher) Publish(ctx context.Context, event Event) error {
+	values := map[string]interface{}{
+		"namespace": event.Namespace,
+		"key":       event.Key,
+		"op":        event.Op,
+		"timestamp": event.Timestamp,
+	}
+	if event.Value != nil {
+		values["value"] = event.Value
+	}
+	return p.Client.XAdd(ctx, &redis.XAddArgs{Stream: p.Stream, MaxLen: p.MaxLen, Approx: true, Values: values}).Err()
+}
+
+// EventQueue publishes events in the background so that writes never wait on the message queue. Events emitted while
+// its buffer is full are dropped and counted in EventsDropped rather than slowing the write down.
+type EventQueue struct {
+	publisher EventPublisher
+	events    chan Event
+	done      chan struct{}
+	mutex     sync.RWMutex // held for reading while sending to events, so Close doesn't close it in between
+	closed    bool
+}
+
+// Events publishes the events of all writes, nil if no event backend is configured.
+var Events *EventQueue
+
+// EventsDropped is the number of events dropped because the buffer of the EventQueue was full.
+var EventsDropped atomic.Int64
+
+// NewEventQueue starts publishing events to publisher, buffering up to size of them.
+func NewEventQueue(publisher EventPublisher, size int) *EventQueue {
+	q := &EventQueue{publisher: publisher, events: make(chan Event, size), done: make(chan struct{})}
+	go q.run()
+	return q
+}
+
+func (q *EventQueue) run() {
+	defer close(q.done)
+	for event := range q.events {
+		ctx, cancel := context.WithTimeout(context.Background(), eventPublishTimeout)
+		if err := q.publisher.Publish(ctx, event); err != nil {
+			log.Printf("Error publishing %s event for %s/%s: %v", event.Op, event.Namespace, event.Key, err)
+		}
+		cancel()
+	}
+}
+
+// Emit queues an event for the counter dbKey without blocking. It is a no-op on a nil queue, so callers don't have to
+// check whether events are enabled.
+func (q *EventQueue) Emit(dbKey, op string, value interface{}) {
+	if q == nil {
+		return
+	}
+	namespace, key, ok := SplitDBKey(dbKey)
+	if !ok {
+		return
+	}
+	event := Event{Namespace: namespace, Key: key, Op: op, Value: value, Timestamp: time.Now().UnixMilli()}
+	q.mutex.RLock()
+	defer q.mutex.RUnlock()
+	if q.closed { // e.g. writes still being answered while shutting down
+		EventsDropped.Add(1)
+		return
+	}
+	select {
+	case q.events <- event:
+	default:
+		EventsDropped.Add(1)
+	}
+}
+
+// Close stops accepting events and waits until the buffered ones are published. Events emitted afterwards are dropped.
+func (q *EventQueue) Close() {
+	if q == nil {
+		return
+	}
+	q.mutex.Lock()
+	if !q.closed {
+		q.closed = true
+		close(q.events)
+	}
+	q.mutex.Unlock()
+	<-q.done
+}
@@ -0,0 +1,96 @@
+package utils
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const expiryListenerBuffer = 16 // expirations a slow listener can fall behind by before they are dropped for it
+
+// ExpiredChannel is the channel redis publishes the keys that expired in database db on, given that
+// notify-keyspace-events includes "Ex".
+func ExpiredChannel(db int) string {
+	return "__keyevent@" + strconv.Itoa(db) + "__:expired"
+}
+
+// Expiration is a counter that expired.
+type Expiration struct {
+	Namespace string `json:"namespace"`
+	Key       string `json:"key"`
+}
+
+// ExpiryWatcher passes the expirations of counters on to the listeners of their namespace.
+type ExpiryWatcher struct {
+	mutex     sync.RWMutex
+	listeners map[string]map[chan Expiration]struct{}
+}
+
+// Expirations watches for expired counters, nil unless EXPIRY_EVENTS is enabled.
+var Expirations *ExpiryWatcher
+
+func NewExpiryWatcher() *ExpiryWatcher {
+	return &ExpiryWatcher{listeners: make(map[string]map[chan Expiration]struct{})}
+}
+
+// Watch subscribes to the expired events of database db until ctx is done. Redis only sends them if
+// notify-keyspace-events is set up accordingly, see ExpiredChannel.
+func (w *ExpiryWatcher) Watch(ctx context.Context, client redis.UniversalClient, db int) {
+	pubsub := client.Subscribe(ctx, ExpiredChannel(db))
+	defer pubsub.Close()
+	messages := pubsub.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case message, ok := <-messages:
+			if !ok {
+				return
+			}
+			w.expired(message.Payload)
+		}
+	}
+}
+
+// expired passes dbKey on to the listeners of its namespace if it is a counter, skipping those that are behind.
+func (w *ExpiryWatcher) expired(dbKey string) {
+	namespace, key, ok := SplitDBKey(dbKey)
+	if !ok || !strings.HasPrefix(dbKey, "K"+KeySeparator) {
+		return // the metadata, history, ... of counters expire too
+	}
+	Counters.Invalidate(dbKey)
+	CloseStream(dbKey)
+	Events.Emit(dbKey, "expire", nil)
+	expiration := Expiration{Namespace: namespace, Key: key}
+	w.mutex.RLock()
+	defer w.mutex.RUnlock()
+	for listener := range w.listeners[expiration.Namespace] {
+		select {
+		case listener <- expiration:
+		default:
+		}
+	}
+}
+
+// Listen returns a channel receiving the expirations of the counters of namespace, along with a function to stop
+// listening.
+func (w *ExpiryWatcher) Listen(namespace string) (<-chan Expiration, func()) {
+	listener := make(chan Expiration, expiryListenerBuffer)
+	w.mutex.Lock()
+	if w.listeners[namespace] == nil {
+		w.listeners[namespace] = make(map[chan Expiration]struct{})
+	}
+	w.listeners[namespace][listener] = struct{}{}
+	w.mutex.Unlock()
+	return listener, func() {
+		w.mutex.Lock()
+		delete(w.listeners[namespace], listener)
+		if len(w.listeners[namespace]) == 0 {
+			delete(w.listeners, namespace)
+		}
+		w.mutex.Unlock()
+	}
+}
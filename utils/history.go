@@ -0,0 +1,65 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// QueueHistory queues appending a change of the counter dbKey by delta to its history on pipe, if it keeps one. The
+// history is a stream trimmed to the changes of the last HistoryRetention (and at most HistoryMaxEntries of them).
+// As nothing older is kept anyway, it expires HistoryRetention after the last change.
+func QueueHistory(ctx context.Context, pipe redis.Pipeliner, dbKey string, meta Metadata, delta float64) {
+	if !meta.History || delta == 0 {
+		return
+	}
+	historyKey := CreateHistoryKey(dbKey)
+	pipe.XAdd(ctx, &redis.XAddArgs{Stream: historyKey, MaxLen: HistoryMaxEntries, Approx: true, Values: []interface{}{"delta", delta}})
+	pipe.XTrimMinIDApprox(ctx, historyKey, strconv.FormatInt(time.Now().Add(-HistoryRetention).UnixMilli(), 10), 0)
+	pipe.Expire(ctx, historyKey, HistoryRetention)
+}
+
+// RecordHistory appends a change of the counter dbKey by delta to its history, see QueueHistory.
+func RecordHistory(ctx context.Context, client redis.UniversalClient, dbKey string, meta Metadata, delta float64) error {
+	if !meta.History {
+		return nil
+	}
+	pipe := client.Pipeline()
+	QueueHistory(ctx, pipe, dbKey, meta, delta)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// ReadHistory sums up the changes of the counter dbKey from from until to (inclusive, so a change made just now is
+// included) into buckets of the given size, the first of which starts at from. Buckets without changes are 0.
+func ReadHistory(ctx context.Context, client redis.UniversalClient, dbKey string, from, to time.Time, bucket time.Duration) ([]float64, error) {
+	count := int((to.Sub(from) + bucket - 1) / bucket)
+	sums := make([]float64, count)
+	start, end := from.UnixMilli(), to.UnixMilli()
+	entries, err := client.XRange(ctx, CreateHistoryKey(dbKey), strconv.FormatInt(start, 10), strconv.FormatInt(end, 10)).Result()
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		millis, err := strconv.ParseInt(strings.SplitN(entry.ID, "-", 2)[0], 10, 64)
+		if err != nil {
+			continue
+		}
+		delta, err := strconv.ParseFloat(fmt.Sprint(entry.Values["delta"]), 64)
+		if err != nil {
+			continue
+		}
+		index := int((millis - start) / bucket.Milliseconds())
+		if index == count { // made at exactly to, which ends the last bucket
+			index--
+		}
+		if index >= 0 && index < count {
+			sums[index] += delta
+		}
+	}
+	return sums, nil
+}
@@ -0,0 +1,27 @@
+package utils
+
+import "net/netip"
+
+// IPPrefix returns the network of ip with the given prefix lengths, ipv4Bits for IPv4 and ipv6Bits for IPv6 addresses,
+// e.g. 203.0.113.0/24 for 203.0.113.7 with 24 bits. Clients are often handed whole networks, notably a /64 for IPv6,
+// so grouping them by one keeps them from getting a fresh rate limit budget from every address they rotate to.
+// Full lengths (32/128) return the address itself, as do addresses that can't be parsed.
+func IPPrefix(ip string, ipv4Bits, ipv6Bits int) string {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return ip
+	}
+	addr = addr.Unmap().WithZone("") // IPv4-mapped IPv6 addresses are IPv4 clients
+	bits := ipv6Bits
+	if addr.Is4() {
+		bits = ipv4Bits
+	}
+	if bits >= addr.BitLen() {
+		return addr.String()
+	}
+	prefix, err := addr.Prefix(bits)
+	if err != nil {
+		return ip
+	}
+	return prefix.String()
+}
@@ -0,0 +1,32 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIPPrefix(t *testing.T) {
+	testCases := []struct {
+		ip       string
+		ipv4Bits int
+		ipv6Bits int
+		expected string
+	}{
+		{"203.0.113.7", 32, 64, "203.0.113.7"},
+		{"203.0.113.7", 24, 64, "203.0.113.0/24"},
+		{"203.0.113.200", 24, 64, "203.0.113.0/24"},
+		{"203.0.113.7", 16, 128, "203.0.0.0/16"},
+		{"::ffff:203.0.113.7", 24, 64, "203.0.113.0/24"},
+		{"2001:db8:1:2:3:4:5:6", 32, 64, "2001:db8:1:2::/64"},
+		{"2001:db8:1:2:ffff:ffff:ffff:ffff", 32, 64, "2001:db8:1:2::/64"},
+		{"2001:db8:1:2:3:4:5:6", 32, 48, "2001:db8:1::/48"},
+		{"2001:db8:1:2:3:4:5:6", 32, 128, "2001:db8:1:2:3:4:5:6"},
+		{"fe80::1%eth0", 32, 64, "fe80::/64"},
+		{"not an ip", 24, 64, "not an ip"},
+		{"", 24, 64, ""},
+	}
+	for _, tc := range testCases {
+		assert.Equal(t, tc.expected, IPPrefix(tc.ip, tc.ipv4Bits, tc.ipv6Bits), tc.ip)
+	}
+}
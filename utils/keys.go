@@ -9,6 +9,8 @@ import (
 	"os"
 	"regexp"
 	"strings"
+	"unicode"
+	"unicode/utf8"
 
 	"github.com/joho/godotenv"
 
@@ -53,14 +55,24 @@ func convertReserved(c *gin.Context, input string) string {
 	return input
 }
 
-func CreateRawAdminKey(c *gin.Context) string {
+// ResolveNamespaceKey is GetNamespaceKey with the reserved names resolved. Both are empty if that fails, with the
+// error already written to the response.
+func ResolveNamespaceKey(c *gin.Context) (string, string) {
 	namespace, key := GetNamespaceKey(c)
 	namespace = convertReserved(c, namespace)
 	key = convertReserved(c, key)
+	if key == "" || namespace == "" {
+		return "", ""
+	}
+	return namespace, key
+}
+
+func CreateRawAdminKey(c *gin.Context) string {
+	namespace, key := ResolveNamespaceKey(c)
 	if key == "" || namespace == "" {
 		return ""
 	}
-	return "A:" + namespace + ":" + key
+	return CreateAdminKey(BuildDBKey(namespace, key))
 
 }
 func CreateKey(c *gin.Context, namespace, key string, skipValidation bool) string {
@@ -72,23 +84,68 @@ func CreateKey(c *gin.Context, namespace, key string, skipValidation bool) strin
 	if key == "" {
 		return ""
 	}
-	if skipValidation == false {
-		if err := validate(namespace); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid namespace: " + err.Error()})
-			return ""
-		}
-		if err := validate(key); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid key: " + err.Error()})
-			return ""
-		}
+	check := validate
+	if skipValidation { // legacy keys may not follow the naming rules, but still mustn't collide with other keys
+		check = checkCharacters
+	}
+	if err := check(namespace); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid namespace: " + err.Error()})
+		return ""
+	}
+	if err := check(key); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid key: " + err.Error()})
+		return ""
 	}
-	return "K:" + namespace + ":" + key
+	return BuildDBKey(namespace, key)
+}
+
+// BuildDBKey joins an already validated namespace and key into the db key of their counter.
+func BuildDBKey(namespace, key string) string {
+	return "K" + KeySeparator + dbNamespace(namespace) + KeySeparator + key
+}
+
+// ValidateKey builds the db key for a namespace/key pair given in a request body. Unlike CreateKey, it doesn't
+// resolve reserved names or write to the response, making it suitable for validating many keys at once.
+func ValidateKey(namespace, key string) (string, error) {
+	if namespace == "" {
+		namespace = "default"
+	}
+	if err := validate(namespace); err != nil {
+		return "", fmt.Errorf("invalid namespace: %w", err)
+	}
+	if err := validate(key); err != nil {
+		return "", fmt.Errorf("invalid key: %w", err)
+	}
+	return BuildDBKey(namespace, key), nil
+}
+
+// CreateListPattern builds the SCAN pattern matching the counters of a namespace whose keys start with prefix.
+func CreateListPattern(namespace, prefix string) (string, error) {
+	if err := ValidateNamespace(namespace); err != nil {
+		return "", err
+	}
+	// keys can't contain any glob characters, so a valid prefix doesn't need escaping
+	if match, _ := regexp.MatchString(`^[A-Za-z0-9_\-.]{0,64}$`, prefix); !match {
+		return "", fmt.Errorf("invalid prefix: must match the pattern ^[A-Za-z0-9_\\-.]{0,64}$")
+	}
+	return BuildDBKey(namespace, prefix) + "*", nil
+}
+
+// ValidateNamespace checks that a namespace given on its own, without a key, is valid.
+func ValidateNamespace(namespace string) error {
+	if err := validate(namespace); err != nil {
+		return fmt.Errorf("invalid namespace: %w", err)
+	}
+	return nil
 }
 
 // validate checks if the namespace/key meet the validation criteria.
 func validate(input string) error {
-	if len(input) < 3 || len(input) > 64 {
-		return fmt.Errorf("length must be between 3 and 64 characters inclusive")
+	if len(input) < MinLength || len(input) > MaxLength {
+		return fmt.Errorf("length must be between %d and %d characters inclusive", MinLength, MaxLength)
+	}
+	if err := checkCharacters(input); err != nil {
+		return err
 	}
 	match, err := regexp.MatchString(`^[A-Za-z0-9_\-.]{3,64}$`, input)
 	if err != nil {
@@ -100,26 +157,143 @@ func validate(input string) error {
 	return nil
 }
 
+// checkCharacters rejects the characters that would let a namespace/key run into the next part of its db key, or
+// that can't be told apart when they're displayed.
+func checkCharacters(input string) error {
+	for _, r := range input {
+		switch {
+		case r == ':' || strings.ContainsRune(KeySeparator, r):
+			return fmt.Errorf("must not contain %q", r)
+		case unicode.IsSpace(r):
+			return fmt.Errorf("must not contain whitespace")
+		case unicode.IsControl(r):
+			return fmt.Errorf("must not contain control characters")
+		}
+	}
+	return nil
+}
+
+// ParseKeySeparator validates the separator the parts of db keys are joined with. It has to be a single character
+// that valid namespaces/keys can't contain and that has no special meaning in SCAN patterns.
+func ParseKeySeparator(raw string) (string, error) {
+	if utf8.RuneCountInString(raw) != 1 {
+		return "", fmt.Errorf("must be a single character")
+	}
+	if match, _ := regexp.MatchString(`^[A-Za-z0-9_\-.*?\[\]\\^]$`, raw); match {
+		return "", fmt.Errorf("must not be a character that's allowed in keys or special in patterns")
+	}
+	if r, _ := utf8.DecodeRuneInString(raw); unicode.IsSpace(r) || unicode.IsControl(r) {
+		return "", fmt.Errorf("must not be whitespace or a control character")
+	}
+	return raw, nil
+}
+
+// GetNamespaceKey returns the namespace and key a /:namespace/*key route was called with, see NormalizeKeyPath. Both
+// are empty if the path doesn't name a counter, with the error already written to the response: a 400 if it is longer
+// than MaxKeyPathLength or nested deeper than MaxKeyDepth, a 404 otherwise.
 func GetNamespaceKey(c *gin.Context) (string, string) {
-	var namespace, key string
-	key = strings.Trim(c.Param("key"), "/")
-	if strings.Contains(key, "/") {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Route not found. Use /create/:namespace/:key or /hit/:key instead."})
+	rawNamespace, rawKey := c.Param("namespace"), c.Param("key")
+	if len(rawNamespace)+len(rawKey) > MaxKeyPathLength {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("The path of the key must be at most %d characters long", MaxKeyPathLength)})
 		return "", ""
 	}
-	if !(len(key) > 0) {
-		namespace = "default"
-		key = c.Param("namespace")
-	} else {
-		namespace = c.Param("namespace")
+	namespace, key, ok := NormalizeKeyPath(rawNamespace, rawKey)
+	if !ok && KeyPathDepth(rawNamespace, rawKey) > MaxKeyDepth {
+		if MaxKeyDepth == 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Paths can't name a namespace on this server, use /:key with no slashes"})
+		} else {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Keys can't be nested, use /:namespace/:key with no slashes in either"})
+		}
+		return "", ""
+	}
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Route not found. Use /create/:namespace/:key or /hit/:key instead."})
+		return "", ""
 	}
 	return namespace, key
 }
 
+// NormalizeKeyPath turns the :namespace and *key of a route into the namespace and key of a counter. Leading, trailing
+// and repeated slashes are ignored, so /hit/ns/key, /hit/ns//key and /hit//ns/key/ all count the same counter, and a
+// path with a single segment names a key of the default namespace. It reports false if the path has no segments or
+// more than MaxKeyDepth, as keys can't contain slashes.
+func NormalizeKeyPath(namespace, key string) (string, string, bool) {
+	segments := make([]string, 0, MaxKeyDepth)
+	for _, segment := range strings.Split(namespace+"/"+key, "/") {
+		if segment != "" {
+			segments = append(segments, segment)
+		}
+	}
+	if len(segments) > MaxKeyDepth {
+		return "", "", false
+	}
+	switch len(segments) {
+	case 1:
+		return "default", segments[0], true
+	case 2:
+		return segments[0], segments[1], true
+	}
+	return "", "", false
+}
+
+// KeyPathDepth returns the number of segments in the path of a counter, ignoring empty ones like NormalizeKeyPath.
+func KeyPathDepth(namespace, key string) int {
+	depth := 0
+	for _, segment := range strings.Split(namespace+"/"+key, "/") {
+		if segment != "" {
+			depth++
+		}
+	}
+	return depth
+}
+
 func CreateAdminKey(key string) string {
 	// remove the K: prefix
-	key = strings.TrimPrefix(key, "K:")
-	return "A:" + key
+	key = strings.TrimPrefix(key, "K"+KeySeparator)
+	return "A" + KeySeparator + key
+}
+
+func CreateMetaKey(key string) string {
+	// remove the K: prefix
+	key = strings.TrimPrefix(key, "K"+KeySeparator)
+	return "M" + KeySeparator + key
+}
+
+func CreateHistoryKey(key string) string {
+	// remove the K: prefix
+	key = strings.TrimPrefix(key, "K"+KeySeparator)
+	return "H" + KeySeparator + key
+}
+
+func CreateVisitorsKey(key string) string {
+	// remove the K: prefix
+	key = strings.TrimPrefix(key, "K"+KeySeparator)
+	return "U" + KeySeparator + key
+}
+
+func CreateSnapshotsKey(key string) string {
+	// remove the K: prefix
+	key = strings.TrimPrefix(key, "K"+KeySeparator)
+	return "S" + KeySeparator + key
+}
+
+// NamespaceOf returns the namespace of the counter dbKey. Namespaces never contain the separator, so it is whatever
+// comes between the first two.
+func NamespaceOf(dbKey string) string {
+	namespace, _, _ := SplitDBKey(dbKey)
+	return namespace
+}
+
+func CreateNamespaceKey(namespace string) string {
+	return "N" + KeySeparator + dbNamespace(namespace)
+}
+
+// GetAuthToken returns the admin token of a request, given either as a Bearer token or via ?token.
+func GetAuthToken(c *gin.Context) string {
+	if header := c.Request.Header.Get("Authorization"); strings.HasPrefix(header, "Bearer ") {
+		return strings.TrimPrefix(header, "Bearer ")
+	}
+	return c.Query("token")
 }
 
 func LoadEnv() {
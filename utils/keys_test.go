@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 
 	"github.com/gin-gonic/gin"
@@ -26,6 +27,9 @@ func TestValidate(t *testing.T) {
 		{"abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ12345678901234567890", fmt.Errorf("length must be between 3 and 64 characters inclusive")},
 		{"abc$", fmt.Errorf("must match the pattern ^[A-Za-z0-9_\\-.]{3,64}$")},
 		{"abc-123$", fmt.Errorf("must match the pattern ^[A-Za-z0-9_\\-.]{3,64}$")},
+		{"abc:def", fmt.Errorf("must not contain ':'")},
+		{"abc def", fmt.Errorf("must not contain whitespace")},
+		{"abc\x00def", fmt.Errorf("must not contain control characters")},
 	}
 
 	for _, tc := range testCases {
@@ -36,6 +40,69 @@ func TestValidate(t *testing.T) {
 	}
 }
 
+func TestCheckCharacters(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected error
+	}{
+		{"legacy$key", nil},
+		{"https//example.com", nil},
+		{"ns:key", fmt.Errorf("must not contain ':'")},
+		{"tab\tkey", fmt.Errorf("must not contain whitespace")},
+		{"new\nline", fmt.Errorf("must not contain whitespace")},
+		{"bell\akey", fmt.Errorf("must not contain control characters")},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.input, func(t *testing.T) {
+			assert.Equal(t, tc.expected, checkCharacters(tc.input))
+		})
+	}
+}
+
+func TestParseKeySeparator(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected string
+		valid    bool
+	}{
+		{":", ":", true},
+		{"|", "|", true},
+		{"§", "§", true},
+		{"", "", false},
+		{"::", "", false},
+		{"a", "", false},
+		{"-", "", false},
+		{"*", "", false},
+		{"[", "", false},
+		{" ", "", false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.input, func(t *testing.T) {
+			separator, err := ParseKeySeparator(tc.input)
+			assert.Equal(t, tc.expected, separator)
+			assert.Equal(t, tc.valid, err == nil)
+		})
+	}
+}
+
+func TestBuildDBKey(t *testing.T) {
+	assert.Equal(t, "K:ns:key", BuildDBKey("ns", "key"))
+	assert.Equal(t, "A:ns:key", CreateAdminKey(BuildDBKey("ns", "key")))
+
+	KeySeparator = "|"
+	defer func() { KeySeparator = ":" }()
+	assert.Equal(t, "K|ns|key", BuildDBKey("ns", "key"))
+	assert.Equal(t, "M|ns|key", CreateMetaKey(BuildDBKey("ns", "key")))
+	assert.Equal(t, "N|ns", CreateNamespaceKey("ns"))
+	assert.Equal(t, "H|ns|key", CreateHistoryKey(BuildDBKey("ns", "key")))
+	assert.Equal(t, "U|ns|key", CreateVisitorsKey(BuildDBKey("ns", "key")))
+	assert.Equal(t, "S|ns|key", CreateSnapshotsKey(BuildDBKey("ns", "key")))
+	assert.Equal(t, "ns", NamespaceOf(BuildDBKey("ns", "key")))
+	assert.Error(t, validate("ns|key"))
+}
+
 func TestTruncateString(t *testing.T) {
 	assert.Equal(t, MinLength, 3)  // tests assume MIN_LENGTH of 3
 	assert.Equal(t, MaxLength, 64) // tests assume MAX_LENGTH of 64
@@ -67,8 +134,11 @@ func TestGetNamespaceKey(t *testing.T) {
 		expectedCode      int
 	}{
 		{"jasoncameron.dev", "test", "jasoncameron.dev", "test", http.StatusOK},
-		{"jasoncameron.dev", "test/test", "", "", http.StatusNotFound},
+		{"jasoncameron.dev", "test/test", "", "", http.StatusBadRequest},
+		{"jasoncameron.dev", "/" + strings.Repeat("a", MaxKeyPathLength), "", "", http.StatusBadRequest},
 		{"jasoncameron.dev", "", "default", "jasoncameron.dev", http.StatusOK},
+		{"jasoncameron.dev", "/test/", "jasoncameron.dev", "test", http.StatusOK},
+		{"", "/", "", "", http.StatusNotFound},
 	}
 
 	for _, tc := range testCases {
@@ -84,3 +154,91 @@ func TestGetNamespaceKey(t *testing.T) {
 		})
 	}
 }
+
+func TestKeyPathLimits(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	get := func(namespace, key string) (string, string, int) {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Params = []gin.Param{{Key: "namespace", Value: namespace}, {Key: "key", Value: key}}
+		c.Request, _ = http.NewRequest(http.MethodGet, "/", nil)
+		namespace, key = GetNamespaceKey(c)
+		return namespace, key, w.Code
+	}
+
+	t.Run("Path lengths below the segment lengths", func(t *testing.T) {
+		MaxKeyPathLength = 20
+		defer func() { MaxKeyPathLength = DefaultMaxKeyPathLength }()
+		_, _, code := get("namespace", "/"+strings.Repeat("a", 10))
+		assert.Equal(t, http.StatusOK, code)
+		_, _, code = get("namespace", "/"+strings.Repeat("a", 11))
+		assert.Equal(t, http.StatusBadRequest, code)
+	})
+
+	t.Run("A depth of 1", func(t *testing.T) {
+		MaxKeyDepth = 1
+		defer func() { MaxKeyDepth = DefaultMaxKeyDepth }()
+		namespace, key, code := get("visits", "")
+		assert.Equal(t, "default", namespace)
+		assert.Equal(t, "visits", key)
+		assert.Equal(t, http.StatusOK, code)
+		_, _, code = get("mysite.com", "/visits")
+		assert.Equal(t, http.StatusBadRequest, code)
+	})
+}
+
+func TestNormalizeKeyPath(t *testing.T) {
+	testCases := []struct {
+		namespace         string
+		key               string
+		expectedNamespace string
+		expectedKey       string
+		expectedOk        bool
+	}{
+		{"ns", "/key", "ns", "key", true},
+		{"ns", "//key", "ns", "key", true},
+		{"ns", "/key/", "ns", "key", true},
+		{"ns", "///key//", "ns", "key", true},
+		{"", "/ns/key", "ns", "key", true}, // /hit//ns/key
+		{"", "/ns//key/", "ns", "key", true},
+		{"key", "", "default", "key", true},
+		{"key", "/", "default", "key", true},
+		{"", "/key", "default", "key", true}, // /hit//key
+		{"", "//", "", "", false},
+		{"", "", "", "", false},
+		{"ns", "/a/b", "", "", false},
+		{"ns", "/a//b", "", "", false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.namespace+tc.key, func(t *testing.T) {
+			namespace, key, ok := NormalizeKeyPath(tc.namespace, tc.key)
+			assert.Equal(t, tc.expectedNamespace, namespace)
+			assert.Equal(t, tc.expectedKey, key)
+			assert.Equal(t, tc.expectedOk, ok)
+		})
+	}
+}
+
+func TestKeyPathDepth(t *testing.T) {
+	testCases := []struct {
+		namespace string
+		key       string
+		expected  int
+	}{
+		{"", "", 0},
+		{"", "//", 0},
+		{"key", "", 1},
+		{"", "/key", 1},
+		{"ns", "/key", 2},
+		{"ns", "//key/", 2},
+		{"ns", "/a/b", 3},
+		{"ns", "/a//b/c/", 4},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.namespace+tc.key, func(t *testing.T) {
+			assert.Equal(t, tc.expected, KeyPathDepth(tc.namespace, tc.key))
+		})
+	}
+}
@@ -0,0 +1,23 @@
+package utils
+
+import (
+	"errors"
+	"log/slog"
+	"os"
+)
+
+// SetupLogging makes the default slog logger, and with it the log package, write in format: "text" for key=value
+// pairs or "json" for one JSON object per line.
+func SetupLogging(format string) error {
+	var handler slog.Handler
+	switch format {
+	case "text":
+		handler = slog.NewTextHandler(os.Stderr, nil)
+	case "json":
+		handler = slog.NewJSONHandler(os.Stderr, nil)
+	default:
+		return errors.New("format must be either text or json")
+	}
+	slog.SetDefault(slog.New(handler))
+	return nil
+}
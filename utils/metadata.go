@@ -0,0 +1,257 @@
+package utils
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	IntCounter   = "int"
+	FloatCounter = "float"
+)
+
+const lastUpdatedField = "last_updated"
+
+// Metadata holds the per-counter settings that are stored in the counter's M: hash.
+type Metadata struct {
+	Type string
+	// TTL is the expiry the counter was created with, 0 meaning it never expires. Counters created without a
+	// custom TTL use BaseTTLPeriod, which is refreshed every time they are accessed.
+	TTL       time.Duration
+	CustomTTL bool
+	// RefreshTTL makes a counter with a custom TTL push its expiry forward by TTL on every hit.
+	RefreshTTL bool
+	// Max is the value the counter can't be incremented past, only enforced if HasMax is set.
+	Max    float64
+	HasMax bool
+	// Min is the value the counter can't be decremented past, only enforced if HasMin is set. Decrements past it are
+	// clamped to it, unless RejectBelowMin is set.
+	Min            float64
+	HasMin         bool
+	RejectBelowMin bool
+	// DeleteAtZero makes a decrement that takes the counter to zero or less (or past its min) delete it.
+	DeleteAtZero bool
+	// WebhookURL is notified every time the counter crosses a multiple of WebhookEvery.
+	WebhookURL   string
+	WebhookEvery int64
+	// Private counters can only be read with a token that may modify them.
+	Private bool
+	// CreatedAt is when the counter was created, zero for counters that predate recording it.
+	CreatedAt time.Time
+	// CreatorIP is the HashIP of the address the counter was created from, only recorded if the operator opted in.
+	CreatorIP string
+	// History makes the counter record its changes, so they can be read back as a time series.
+	History bool
+	// UniqueWindow makes hits count each visitor only once per window of this length, 0 for regular counters.
+	UniqueWindow time.Duration
+	// Tags are the labels the counter was created with, by name. Nil if it has none.
+	Tags map[string]string
+	// Description tells people what the counter tracks, "" if it has none.
+	Description string
+	// LastUpdated is when the value of the counter last changed, zero if that predates tracking it. It is recorded
+	// with QueueUpdated rather than stored along with the settings.
+	LastUpdated time.Time
+}
+
+func (m Metadata) IsFloat() bool {
+	return m.Type == FloatCounter
+}
+
+// Bounded reports whether the counter has to be changed with BoundedIncr, as it has a max or a min to enforce, or
+// deletes itself at zero.
+func (m Metadata) Bounded() bool {
+	return m.HasMax || m.HasMin || m.DeleteAtZero
+}
+
+// Refreshes reports whether the expiry of the counter is pushed forward when it is used.
+func (m Metadata) Refreshes() bool {
+	return !m.CustomTTL || (m.RefreshTTL && m.TTL > 0)
+}
+
+// fields returns the non-default metadata values in the form they are stored in redis.
+func (m Metadata) fields() map[string]interface{} {
+	fields := make(map[string]interface{})
+	if m.Type != "" && m.Type != IntCounter {
+		fields["type"] = m.Type
+	}
+	if m.CustomTTL {
+		fields["ttl"] = int64(m.TTL.Seconds())
+	}
+	if m.RefreshTTL {
+		fields["refresh_ttl"] = true
+	}
+	if m.HasMax {
+		fields["max"] = m.Max
+	}
+	if m.HasMin {
+		fields["min"] = m.Min
+	}
+	if m.RejectBelowMin {
+		fields["min_reject"] = true
+	}
+	if m.DeleteAtZero {
+		fields["delete_at_zero"] = true
+	}
+	if m.WebhookURL != "" {
+		fields["webhook_url"] = m.WebhookURL
+		fields["webhook_every"] = m.WebhookEvery
+	}
+	if m.Private {
+		fields["private"] = true
+	}
+	if !m.CreatedAt.IsZero() {
+		fields["created_at"] = m.CreatedAt.UnixMilli()
+	}
+	if m.CreatorIP != "" {
+		fields["created_ip"] = m.CreatorIP
+	}
+	if m.History {
+		fields["history"] = true
+	}
+	if m.UniqueWindow > 0 {
+		fields["unique_window"] = int64(m.UniqueWindow.Seconds())
+	}
+	for name, value := range m.Tags {
+		fields[tagFieldPrefix+name] = value
+	}
+	if m.Description != "" {
+		fields[descriptionField] = m.Description
+	}
+	return fields
+}
+
+// ParseMetadata converts the fields of a metadata hash (as returned by HGETALL) into Metadata.
+func ParseMetadata(fields map[string]string) Metadata {
+	meta := Metadata{Type: IntCounter, TTL: BaseTTLPeriod}
+	if t, ok := fields["type"]; ok {
+		meta.Type = t
+	}
+	if ttl, err := strconv.ParseInt(fields["ttl"], 10, 64); err == nil {
+		meta.TTL = time.Duration(ttl) * time.Second
+		meta.CustomTTL = true
+	}
+	meta.RefreshTTL, _ = strconv.ParseBool(fields["refresh_ttl"])
+	if maxValue, err := strconv.ParseFloat(fields["max"], 64); err == nil {
+		meta.Max, meta.HasMax = maxValue, true
+	}
+	if minValue, err := strconv.ParseFloat(fields["min"], 64); err == nil {
+		meta.Min, meta.HasMin = minValue, true
+	}
+	meta.RejectBelowMin, _ = strconv.ParseBool(fields["min_reject"])
+	meta.DeleteAtZero, _ = strconv.ParseBool(fields["delete_at_zero"])
+	meta.WebhookURL = fields["webhook_url"]
+	meta.WebhookEvery, _ = strconv.ParseInt(fields["webhook_every"], 10, 64)
+	meta.Private, _ = strconv.ParseBool(fields["private"])
+	if createdAt, err := strconv.ParseInt(fields["created_at"], 10, 64); err == nil {
+		meta.CreatedAt = time.UnixMilli(createdAt)
+	}
+	meta.CreatorIP = fields["created_ip"]
+	meta.History, _ = strconv.ParseBool(fields["history"])
+	if window, err := strconv.ParseInt(fields["unique_window"], 10, 64); err == nil {
+		meta.UniqueWindow = time.Duration(window) * time.Second
+	}
+	meta.Description = fields[descriptionField]
+	for field, value := range fields {
+		if name, ok := strings.CutPrefix(field, tagFieldPrefix); ok {
+			if meta.Tags == nil {
+				meta.Tags = make(map[string]string)
+			}
+			meta.Tags[name] = value
+		}
+	}
+	if lastUpdated, err := strconv.ParseInt(fields[lastUpdatedField], 10, 64); err == nil {
+		meta.LastUpdated = time.UnixMilli(lastUpdated)
+	}
+	return meta
+}
+
+// GetMetadata loads the metadata of a counter. Counters without a metadata hash get the defaults.
+func GetMetadata(ctx context.Context, client redis.UniversalClient, dbKey string) (Metadata, error) {
+	fields, err := client.HGetAll(ctx, CreateMetaKey(dbKey)).Result()
+	if err != nil {
+		return Metadata{}, err
+	}
+	return ParseMetadata(fields), nil
+}
+
+// SetMetadata stores the metadata of a counter, expiring it alongside the counter itself.
+func SetMetadata(ctx context.Context, client redis.UniversalClient, dbKey string, meta Metadata, ttl time.Duration) error {
+	if len(meta.fields()) == 0 {
+		return nil // nothing differs from the defaults, no need to store anything
+	}
+	pipe := client.Pipeline()
+	QueueMetadata(ctx, pipe, dbKey, meta, ttl)
+	_, err := pipe.Exec(ctx)
+	Counters.Invalidate(dbKey)
+	return err
+}
+
+// QueueMetadata queues storing the metadata of a counter on pipe, like SetMetadata does.
+func QueueMetadata(ctx context.Context, pipe redis.Pipeliner, dbKey string, meta Metadata, ttl time.Duration) {
+	fields := meta.fields()
+	if len(fields) == 0 {
+		return
+	}
+	Counters.Invalidate(dbKey) // the cached metadata is outdated
+	metaKey := CreateMetaKey(dbKey)
+	pipe.HSet(ctx, metaKey, fields)
+	if ttl > 0 {
+		pipe.Expire(ctx, metaKey, ttl)
+	}
+}
+
+// HashIP hashes the address a counter was created from with a secret salt, so counters created from the same address
+// can be matched up without the address being recoverable from the database.
+func HashIP(salt, ip string) string {
+	mac := hmac.New(sha256.New, []byte(salt))
+	mac.Write([]byte(ip))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// QueueUpdated queues recording on pipe that the value of the counter dbKey changed just now. The metadata hash may
+// only be created by this, so it is given the default expiry unless the counter has a custom TTL, whose hash expires
+// alongside it already. Its snapshots are given the default expiry along with it, as the change pushed that of the
+// counter forward. Once pipe was executed, the caller has to drop the cached value with Counters.Invalidate:
+// dropping it any earlier lets a read in between cache the old value again.
+func QueueUpdated(ctx context.Context, pipe redis.Pipeliner, dbKey string, meta Metadata) {
+	metaKey := CreateMetaKey(dbKey)
+	pipe.HSet(ctx, metaKey, lastUpdatedField, time.Now().UnixMilli())
+	if !meta.CustomTTL {
+		pipe.Expire(ctx, metaKey, BaseTTLPeriod)
+		QueueSnapshotsExpiry(ctx, pipe, dbKey, BaseTTLPeriod)
+	}
+}
+
+// SetUpdated records that the value of the counter dbKey changed just now, see QueueUpdated.
+func SetUpdated(ctx context.Context, client redis.UniversalClient, dbKey string, meta Metadata) error {
+	pipe := client.Pipeline()
+	QueueUpdated(ctx, pipe, dbKey, meta)
+	_, err := pipe.Exec(ctx)
+	Counters.Invalidate(dbKey) // every write records an update, so this is where the cached value is dropped
+	return err
+}
+
+// PrivateCounters reports which of the counters dbKeys are private, for listings that have to leave them out.
+func PrivateCounters(ctx context.Context, client redis.UniversalClient, dbKeys []string) ([]bool, error) {
+	pipe := client.Pipeline()
+	cmds := make([]*redis.StringCmd, len(dbKeys))
+	for i, dbKey := range dbKeys {
+		cmds[i] = pipe.HGet(ctx, CreateMetaKey(dbKey), "private")
+	}
+	if _, err := pipe.Exec(ctx); err != nil && !errors.Is(err, redis.Nil) {
+		return nil, err
+	}
+	private := make([]bool, len(dbKeys))
+	for i, cmd := range cmds {
+		private[i], _ = strconv.ParseBool(cmd.Val())
+	}
+	return private, nil
+}
@@ -0,0 +1,124 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/redis/go-redis/v9"
+)
+
+const metricsScanCount = 1000 // keys fetched per SCAN/GET round trip when collecting counter values
+
+// RedisErrorHook counts failed redis commands for the redis errors metric. redis.Nil isn't counted as it only means
+// that a key doesn't exist.
+type RedisErrorHook struct{}
+
+func (RedisErrorHook) DialHook(next redis.DialHook) redis.DialHook {
+	return next
+}
+
+func (RedisErrorHook) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		err := next(ctx, cmd)
+		if err != nil && !errors.Is(err, redis.Nil) {
+			RedisErrors.Add(1)
+		}
+		return err
+	}
+}
+
+func (RedisErrorHook) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		err := next(ctx, cmds)
+		for _, cmd := range cmds {
+			if cmdErr := cmd.Err(); cmdErr != nil && !errors.Is(cmdErr, redis.Nil) {
+				RedisErrors.Add(1)
+			}
+		}
+		return err
+	}
+}
+
+// counterCollector exposes the value of every counter as a gauge, reading them from redis on every scrape.
+type counterCollector struct {
+	client redis.UniversalClient
+	desc   *prometheus.Desc
+}
+
+func (cc *counterCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- cc.desc
+}
+
+func (cc *counterCollector) Collect(ch chan<- prometheus.Metric) {
+	_ = ForEachNode(context.Background(), cc.client, func(ctx context.Context, node redis.UniversalClient) error {
+		var cursor uint64
+		for {
+			keys, next, err := node.Scan(ctx, cursor, "K"+KeySeparator+"*", metricsScanCount).Result()
+			if err != nil {
+				return err
+			}
+			if len(keys) > 0 {
+				// GETs rather than a MGET, as the keys of a page span several hash slots on a cluster
+				pipe := node.Pipeline()
+				values := make([]*redis.StringCmd, len(keys))
+				for i, key := range keys {
+					values[i] = pipe.Get(ctx, key)
+				}
+				if _, err := pipe.Exec(ctx); err != nil && !errors.Is(err, redis.Nil) {
+					return err
+				}
+				for i, value := range values {
+					raw, err := value.Result()
+					if err != nil { // expired in between the SCAN and GET
+						continue
+					}
+					parsed, err := strconv.ParseFloat(raw, 64)
+					namespace, key, ok := SplitDBKey(keys[i])
+					if err != nil || !ok {
+						continue
+					}
+					ch <- prometheus.MustNewConstMetric(cc.desc, prometheus.GaugeValue, parsed, namespace, key)
+				}
+			}
+			if next == 0 {
+				return nil
+			}
+			cursor = next
+		}
+	})
+}
+
+// MetricsHandler serves the counter values along with the internal metrics in the prometheus exposition format.
+func MetricsHandler(client redis.UniversalClient) http.Handler {
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		&counterCollector{
+			client: client,
+			desc:   prometheus.NewDesc("abacus_counter", "Current value of a counter.", []string{"namespace", "key"}, nil),
+		},
+		prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Name: "abacus_requests_total",
+			Help: "Total number of requests served since the server started.",
+		}, func() float64 { return float64(RequestsServed.Load()) }),
+		prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Name: "abacus_rate_limited_total",
+			Help: "Total number of requests rejected by the rate limiter since the server started.",
+		}, func() float64 { return float64(RateLimited.Load()) }),
+		prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Name: "abacus_redis_errors_total",
+			Help: "Total number of failed redis commands since the server started.",
+		}, func() float64 { return float64(RedisErrors.Load()) }),
+		prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Name: "abacus_events_dropped_total",
+			Help: "Total number of write events dropped because the event buffer was full since the server started.",
+		}, func() float64 { return float64(EventsDropped.Load()) }),
+	)
+	return promhttp.HandlerFor(registry, promhttp.HandlerOpts{})
+}
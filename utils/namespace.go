@@ -0,0 +1,165 @@
+package utils
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// namespaceTokenField is the field of the namespace hash (N:) holding the hashed admin token of the namespace.
+const namespaceTokenField = "admin_token"
+
+// namespaceSecretField is the field of the namespace hash holding the secret its write requests are signed with. It has
+// to be stored as is, as the server computes the same signatures with it.
+const namespaceSecretField = "signing_secret"
+
+// HashToken hashes an admin token for storage, so a leaked database doesn't leak the tokens with it.
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// HasNamespaceToken reports whether an admin token was set up for namespace.
+func HasNamespaceToken(ctx context.Context, client redis.UniversalClient, namespace string) (bool, error) {
+	return client.HExists(ctx, CreateNamespaceKey(namespace), namespaceTokenField).Result()
+}
+
+// CheckNamespaceToken reports whether token is the admin token of namespace.
+func CheckNamespaceToken(ctx context.Context, client redis.UniversalClient, namespace, token string) (bool, error) {
+	if token == "" {
+		return false, nil
+	}
+	hash, err := client.HGet(ctx, CreateNamespaceKey(namespace), namespaceTokenField).Result()
+	if errors.Is(err, redis.Nil) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	return subtle.ConstantTimeCompare([]byte(hash), []byte(HashToken(token))) == 1, nil
+}
+
+// ClaimNamespaceToken sets the first admin token of namespace, reporting false if another one was set in the meantime.
+func ClaimNamespaceToken(ctx context.Context, client redis.UniversalClient, namespace, token string) (bool, error) {
+	return client.HSetNX(ctx, CreateNamespaceKey(namespace), namespaceTokenField, HashToken(token)).Result()
+}
+
+// SetNamespaceToken replaces the admin token of namespace.
+func SetNamespaceToken(ctx context.Context, client redis.UniversalClient, namespace, token string) error {
+	return client.HSet(ctx, CreateNamespaceKey(namespace), namespaceTokenField, HashToken(token)).Err()
+}
+
+// GetSigningSecret returns the secret the write requests of namespace are signed with, "" if it doesn't sign them.
+func GetSigningSecret(ctx context.Context, client redis.UniversalClient, namespace string) (string, error) {
+	secret, err := client.HGet(ctx, CreateNamespaceKey(namespace), namespaceSecretField).Result()
+	if errors.Is(err, redis.Nil) {
+		return "", nil
+	}
+	return secret, err
+}
+
+// SetSigningSecret replaces the signing secret of namespace, "" turning signing off.
+func SetSigningSecret(ctx context.Context, client redis.UniversalClient, namespace, secret string) error {
+	if secret == "" {
+		return client.HDel(ctx, CreateNamespaceKey(namespace), namespaceSecretField).Err()
+	}
+	return client.HSet(ctx, CreateNamespaceKey(namespace), namespaceSecretField, secret).Err()
+}
+
+// SignRequest returns the hex encoded HMAC-SHA256 signature of a request, keyed with secret. It covers the timestamp
+// the request was signed at, its method, its path along with the query and its body, one per line.
+func SignRequest(secret, timestamp, method, uri string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "\n" + method + "\n" + uri + "\n"))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// NamespaceHasCounters reports whether namespace contains any counters. This has to scan the whole keyspace in the
+// worst case, so it is only meant for rare operations such as claiming a namespace.
+func NamespaceHasCounters(ctx context.Context, client redis.UniversalClient, namespace string) (bool, error) {
+	node, err := NamespaceNode(ctx, client, namespace)
+	if err != nil {
+		return false, err
+	}
+	var cursor uint64
+	for {
+		keys, next, err := node.Scan(ctx, cursor, BuildDBKey(namespace, "")+"*", 1000).Result()
+		if err != nil {
+			return false, err
+		}
+		if len(keys) > 0 {
+			return true, nil
+		}
+		if next == 0 {
+			return false, nil
+		}
+		cursor = next
+	}
+}
+
+// PurgeNamespace deletes every counter of namespace along with its metadata, admin key, history, visitors and
+// snapshots, scanning the keyspace page by page and unlinking each page at once. The namespace hash keeps its admin
+// token and limits so the namespace stays claimed, only its counter count is reset. Returns the number of counters
+// deleted.
+func PurgeNamespace(ctx context.Context, client redis.UniversalClient, namespace string) (int64, error) {
+	node, err := NamespaceNode(ctx, client, namespace)
+	if err != nil {
+		return 0, err
+	}
+	var deleted int64
+	var cursor uint64
+	for {
+		dbKeys, next, err := node.Scan(ctx, cursor, BuildDBKey(namespace, "")+"*", ListPageSize).Result()
+		if err != nil {
+			return deleted, err
+		}
+		if len(dbKeys) > 0 {
+			keys := make([]string, 0, len(dbKeys)*5)
+			for _, dbKey := range dbKeys {
+				keys = append(keys, CreateMetaKey(dbKey), CreateAdminKey(dbKey), CreateHistoryKey(dbKey), CreateVisitorsKey(dbKey),
+					CreateSnapshotsKey(dbKey))
+			}
+			pipe := client.Pipeline()
+			counters := pipe.Unlink(ctx, dbKeys...)
+			pipe.Unlink(ctx, keys...)
+			if _, err := pipe.Exec(ctx); err != nil {
+				return deleted, err
+			}
+			deleted += counters.Val()
+			for _, dbKey := range dbKeys {
+				Counters.Invalidate(dbKey)
+				CloseStream(dbKey)
+				Events.Emit(dbKey, "delete", nil)
+			}
+		}
+		if next == 0 {
+			break
+		}
+		cursor = next
+	}
+	// leftovers of counters that expired on their own, their metadata outlives them until it expires too
+	for _, prefix := range []string{"M", "A", "H", "U", "S"} {
+		cursor = 0
+		for {
+			keys, next, err := node.Scan(ctx, cursor, prefix+KeySeparator+dbNamespace(namespace)+KeySeparator+"*", ListPageSize).Result()
+			if err != nil {
+				return deleted, err
+			}
+			if len(keys) > 0 {
+				if err := client.Unlink(ctx, keys...).Err(); err != nil {
+					return deleted, err
+				}
+			}
+			if next == 0 {
+				break
+			}
+			cursor = next
+		}
+	}
+	return deleted, client.HSet(ctx, CreateNamespaceKey(namespace), "counters", 0).Err()
+}
@@ -0,0 +1,22 @@
+package utils
+
+import "strings"
+
+// IsOverflow reports whether err is redis refusing to change a counter because its value would leave the range of an
+// int64 (INCRBY) or stop being a finite number (INCRBYFLOAT), also when raised by a script.
+func IsOverflow(err error) bool {
+	if err == nil {
+		return false
+	}
+	message := err.Error()
+	return strings.Contains(message, "increment or decrement would overflow") || strings.Contains(message, "would produce NaN or Infinity")
+}
+
+// AddInt64 returns a + b and whether the sum fits in an int64, refusing the same sums INCRBY does.
+func AddInt64(a, b int64) (int64, bool) {
+	sum := a + b
+	if (b > 0 && sum < a) || (b < 0 && sum > a) {
+		return 0, false
+	}
+	return sum, true
+}
@@ -0,0 +1,45 @@
+package utils
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestIsOverflow(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{nil, false},
+		{errors.New("ERR increment or decrement would overflow"), true},
+		{errors.New("ERR increment would produce NaN or Infinity"), true},
+		{errors.New("ERR Error running script: @user_script:3: ERR increment or decrement would overflow"), true},
+		{errors.New("ERR value is not an integer or out of range"), false},
+	}
+	for _, tt := range tests {
+		if got := IsOverflow(tt.err); got != tt.want {
+			t.Errorf("IsOverflow(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestAddInt64(t *testing.T) {
+	tests := []struct {
+		a, b int64
+		want int64
+		ok   bool
+	}{
+		{1, 2, 3, true},
+		{math.MaxInt64 - 1, 1, math.MaxInt64, true},
+		{math.MaxInt64, 1, 0, false},
+		{math.MinInt64, -1, 0, false},
+		{math.MinInt64, math.MaxInt64, -1, true},
+		{-5, 3, -2, true},
+	}
+	for _, tt := range tests {
+		if got, ok := AddInt64(tt.a, tt.b); got != tt.want || ok != tt.ok {
+			t.Errorf("AddInt64(%d, %d) = %d, %v, want %d, %v", tt.a, tt.b, got, ok, tt.want, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package utils
+
+import (
+	"context"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// hits are counted in a bucket per minute, of which only the current and the previous one are ever read, so every
+// counter has at most two of them at a time
+const rateBucket = time.Minute
+
+// CreateRateKey returns the key of the bucket counting the hits of the counter dbKey in the minute t is in, e.g.
+// R:{namespace}:{key}:{unix minute}. Keys can't contain the separator, so the minute can't be mistaken for a part of it.
+func CreateRateKey(dbKey string, t time.Time) string {
+	key := strings.TrimPrefix(dbKey, "K"+KeySeparator)
+	return "R" + KeySeparator + key + KeySeparator + strconv.FormatInt(t.Unix()/int64(rateBucket.Seconds()), 10)
+}
+
+// QueueHit queues counting a hit of the counter dbKey in the bucket of the current minute on pipe. The bucket expires
+// once the minute after it is over, when it can't be read anymore.
+func QueueHit(ctx context.Context, pipe redis.Pipeliner, dbKey string) {
+	rateKey := CreateRateKey(dbKey, time.Now())
+	pipe.Incr(ctx, rateKey)
+	pipe.Expire(ctx, rateKey, 2*rateBucket)
+}
+
+// HitRate holds the buckets of the hits of a counter in the last minute, see QueueHitRate.
+type HitRate struct {
+	current, previous *redis.StringCmd
+	at                time.Time
+}
+
+// QueueHitRate queues reading the buckets the hits of the counter dbKey in the last minute are counted in on pipe,
+// they can be estimated with LastMinute once it was executed.
+func QueueHitRate(ctx context.Context, pipe redis.Pipeliner, dbKey string) HitRate {
+	now := time.Now()
+	return HitRate{
+		current:  pipe.Get(ctx, CreateRateKey(dbKey, now)),
+		previous: pipe.Get(ctx, CreateRateKey(dbKey, now.Add(-rateBucket))),
+		at:       now,
+	}
+}
+
+// LastMinute estimates how often the counter was hit in the minute before it was read: all of the hits of the current
+// minute plus the share of those of the previous one that fell within the last 60 seconds, assuming they were spread
+// evenly across it.
+func (r HitRate) LastMinute() int64 {
+	current, _ := r.current.Int64() // missing buckets had no hits
+	previous, _ := r.previous.Int64()
+	elapsed := float64(r.at.UnixNano()%int64(rateBucket)) / float64(rateBucket)
+	return current + int64(math.Round(float64(previous)*(1-elapsed)))
+}
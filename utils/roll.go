@@ -0,0 +1,67 @@
+package utils
+
+import (
+	"fmt"
+	"time"
+)
+
+// RollLocation is the timezone the periods of rolling counters start and end in, Location unless ROLL_TIMEZONE says
+// otherwise.
+var RollLocation = time.UTC
+
+// DefaultRollKeep is how many periods a rolling counter is kept for after its own period is over, unless
+// ROLL_KEEP_PERIODS says otherwise.
+const DefaultRollKeep = 30
+
+// RollKeep is how many periods rolling counters are kept for after their own period is over.
+var RollKeep = DefaultRollKeep
+
+// RollPeriod is a period a rolling counter (?roll=) counts for, after which hits go to the counter of the next one.
+type RollPeriod struct {
+	layout string                         // suffix appended to the key, as a time layout
+	start  func(time.Time) time.Time      // start of the period t is in
+	add    func(time.Time, int) time.Time // start of the period n periods after the one starting at t
+}
+
+var rollPeriods = map[string]RollPeriod{
+	"hourly": {
+		layout: "2006-01-02-15",
+		start: func(t time.Time) time.Time {
+			return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), 0, 0, 0, t.Location())
+		},
+		add: func(t time.Time, n int) time.Time { return t.Add(time.Duration(n) * time.Hour) },
+	},
+	"daily": {
+		layout: "2006-01-02",
+		start: func(t time.Time) time.Time {
+			return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+		},
+		add: func(t time.Time, n int) time.Time { return t.AddDate(0, 0, n) },
+	},
+	"monthly": {
+		layout: "2006-01",
+		start: func(t time.Time) time.Time {
+			return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, t.Location())
+		},
+		add: func(t time.Time, n int) time.Time { return t.AddDate(0, n, 0) },
+	},
+}
+
+// ParseRollPeriod returns the period called name: hourly, daily or monthly.
+func ParseRollPeriod(name string) (RollPeriod, error) {
+	period, ok := rollPeriods[name]
+	if !ok {
+		return RollPeriod{}, fmt.Errorf("roll must be either hourly, daily or monthly")
+	}
+	return period, nil
+}
+
+// Key returns the key of the counter key counts in for the period now is in, e.g. visits_2024-12-31 for daily.
+func (p RollPeriod) Key(key string, now time.Time) string {
+	return key + "_" + now.In(RollLocation).Format(p.layout)
+}
+
+// Expiry returns when the counter of the period now is in expires, RollKeep periods after that period is over.
+func (p RollPeriod) Expiry(now time.Time) time.Time {
+	return p.add(p.start(now.In(RollLocation)), 1+RollKeep)
+}
@@ -0,0 +1,64 @@
+package utils
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRollPeriod(t *testing.T) {
+	now := time.Date(2024, time.December, 31, 22, 30, 0, 0, time.UTC)
+	testCases := []struct {
+		period string
+		key    string
+		expiry time.Time
+	}{
+		{"hourly", "visits_2024-12-31-22", time.Date(2025, time.January, 2, 5, 0, 0, 0, time.UTC)},
+		{"daily", "visits_2024-12-31", time.Date(2025, time.January, 31, 0, 0, 0, 0, time.UTC)},
+		{"monthly", "visits_2024-12", time.Date(2027, time.July, 1, 0, 0, 0, 0, time.UTC)},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.period, func(t *testing.T) {
+			period, err := ParseRollPeriod(tc.period)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.key, period.Key("visits", now))
+			assert.True(t, tc.expiry.Equal(period.Expiry(now)), period.Expiry(now))
+		})
+	}
+
+	t.Run("Timezone", func(t *testing.T) {
+		tokyo, err := time.LoadLocation("Asia/Tokyo")
+		if err != nil {
+			t.Skip("no timezone database")
+		}
+		RollLocation = tokyo
+		defer func() { RollLocation = time.UTC }()
+		period, _ := ParseRollPeriod("daily")
+		assert.Equal(t, "visits_2025-01-01", period.Key("visits", now))
+	})
+
+	_, err := ParseRollPeriod("weekly")
+	assert.Error(t, err)
+}
+
+func TestLocalTime(t *testing.T) {
+	tokyo, err := time.LoadLocation("Asia/Tokyo")
+	if err != nil {
+		t.Skip("no timezone database")
+	}
+	Location = tokyo
+	defer func() { Location = time.UTC }()
+
+	midnight := time.Date(2025, time.January, 1, 0, 0, 0, 0, tokyo)
+	for _, raw := range []string{"2025-01-01", "2025-01-01T00:00", "2025-01-01T00:00:00", "2024-12-31T15:00:00Z"} {
+		parsed, err := ParseTime(raw)
+		assert.NoError(t, err, raw)
+		assert.True(t, midnight.Equal(parsed), raw)
+	}
+	_, err = ParseTime("yesterday")
+	assert.Error(t, err)
+
+	assert.Equal(t, "2025-01-01T00:00:00+09:00", FormatTime(midnight.UTC()))
+}
@@ -0,0 +1,541 @@
+package utils
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// exactIntegers defines the functions the scripts that change integer counters do their arithmetic with. Lua numbers
+// are doubles, which can't tell integers apart above 2^53, so these work on the decimal strings redis stores instead:
+// intCmp(a, b) returns -1, 0 or 1 as a is less than, equal to or greater than b, intAdd(a, b) returns their sum
+// and intInRange(a) reports whether a fits in an int64, as INCRBY requires.
+const exactIntegers = `
+local function splitInt(s)
+	local sign, digits = string.match(s, '^(-?)0*(%d+)$')
+	if digits == '0' then
+		sign = ''
+	end
+	return sign, digits
+end
+local function cmpDigits(a, b)
+	if #a ~= #b then
+		return #a < #b and -1 or 1
+	end
+	if a == b then
+		return 0
+	end
+	return a < b and -1 or 1
+end
+local function addDigits(a, b)
+	local digits, carry, i, j = {}, 0, #a, #b
+	while i > 0 or j > 0 or carry > 0 do
+		local sum = carry + (i > 0 and tonumber(string.sub(a, i, i)) or 0) + (j > 0 and tonumber(string.sub(b, j, j)) or 0)
+		table.insert(digits, 1, sum % 10)
+		carry = math.floor(sum / 10)
+		i, j = i - 1, j - 1
+	end
+	return table.concat(digits)
+end
+local function subDigits(a, b)
+	local digits, borrow, i, j = {}, 0, #a, #b
+	while i > 0 do
+		local difference = tonumber(string.sub(a, i, i)) - borrow - (j > 0 and tonumber(string.sub(b, j, j)) or 0)
+		borrow = 0
+		if difference < 0 then
+			difference, borrow = difference + 10, 1
+		end
+		table.insert(digits, 1, difference)
+		i, j = i - 1, j - 1
+	end
+	local result = string.gsub(table.concat(digits), '^0+', '')
+	return result == '' and '0' or result
+end
+local function intCmp(a, b)
+	local signA, digitsA = splitInt(a)
+	local signB, digitsB = splitInt(b)
+	if signA ~= signB then
+		return signA == '-' and -1 or 1
+	end
+	local order = cmpDigits(digitsA, digitsB)
+	return signA == '-' and -order or order
+end
+local function intAdd(a, b)
+	local signA, digitsA = splitInt(a)
+	local signB, digitsB = splitInt(b)
+	if signA == signB then
+		local sum = addDigits(digitsA, digitsB)
+		return sum == '0' and '0' or signA .. sum
+	end
+	local order = cmpDigits(digitsA, digitsB)
+	if order == 0 then
+		return '0'
+	elseif order > 0 then
+		return signA .. subDigits(digitsA, digitsB)
+	end
+	return signB .. subDigits(digitsB, digitsA)
+end
+local function intInRange(a)
+	return intCmp(a, '-9223372036854775808') >= 0 and intCmp(a, '9223372036854775807') <= 0
+end
+`
+
+// Results of CompareAndSet.
+const (
+	CASMissing  = 0
+	CASMismatch = 1
+	CASSet      = 2
+)
+
+// CompareAndSet sets KEYS[1] to ARGV[2] only if its current value equals ARGV[1] (compared as numbers). ARGV[3] is
+// the expiry in seconds, or "keep" to keep the current one. Returns {CASMissing} if the key doesn't exist,
+// {CASMismatch, current} if it holds a different value and {CASSet, new} if it was set.
+var CompareAndSet = redis.NewScript(`
+local current = redis.call('GET', KEYS[1])
+if not current then
+	return {0}
+end
+if tonumber(current) ~= tonumber(ARGV[1]) then
+	return {1, current}
+end
+if ARGV[3] == 'keep' then
+	redis.call('SET', KEYS[1], ARGV[2], 'KEEPTTL')
+else
+	redis.call('SET', KEYS[1], ARGV[2], 'EX', ARGV[3])
+end
+return {2, ARGV[2]}
+`)
+
+// Results of SetExtreme.
+const (
+	ExtremeMissing  = 0
+	ExtremeKept     = 1
+	ExtremeSet      = 2
+	ExtremeAboveMax = 3
+	ExtremeBelowMin = 4
+)
+
+// SetExtreme sets KEYS[1] to ARGV[1] only if that is greater than its current value when ARGV[2] is "max", or less
+// when it is "min", as high and low-water marks do. ARGV[3] is the expiry like for CompareAndSet and ARGV[4] the
+// counter type, integer counters being compared exactly (see exactIntegers). The bounds of the counter apply like
+// they do to BoundedIncr: a new max past the counter's max in ARGV[5] is refused, and a new min past its min in ARGV[5]
+// is clamped to it, or refused if ARGV[6] is "reject". Returns {ExtremeMissing} if the key doesn't exist,
+// {ExtremeKept, current} if its value was kept, {ExtremeSet, new} if it was set, or just the bound that refused it
+// (ExtremeAboveMax or ExtremeBelowMin) along with the current value. A third element of 1 flags a clamped value.
+var SetExtreme = redis.NewScript(exactIntegers + `
+local current = redis.call('GET', KEYS[1])
+if not current then
+	return {0}
+end
+local float = ARGV[4] == 'float'
+local function cmp(a, b)
+	if not float then
+		return intCmp(a, b)
+	end
+	a, b = tonumber(a), tonumber(b)
+	if a == b then
+		return 0
+	end
+	return a < b and -1 or 1
+end
+if not float and not string.match(current, '^-?%d+$') then
+	return redis.error_reply('ERR value is not an integer or out of range')
+end
+local candidate, bound, clamped = ARGV[1], ARGV[5], 0
+if ARGV[2] == 'max' then
+	if cmp(candidate, current) <= 0 then
+		return {1, current}
+	end
+	if bound ~= '' and cmp(candidate, bound) > 0 then
+		return {3, current}
+	end
+else
+	if cmp(candidate, current) >= 0 then
+		return {1, current}
+	end
+	if bound ~= '' and cmp(candidate, bound) < 0 then
+		if ARGV[6] == 'reject' then
+			return {4, current}
+		end
+		if cmp(current, bound) <= 0 then
+			return {1, current, 1}
+		end
+		candidate, clamped = bound, 1
+	end
+end
+if ARGV[3] == 'keep' then
+	redis.call('SET', KEYS[1], candidate, 'KEEPTTL')
+else
+	redis.call('SET', KEYS[1], candidate, 'EX', ARGV[3])
+end
+return {2, candidate, clamped}
+`)
+
+// Results of BoundedIncr.
+const (
+	IncrApplied  = 0
+	IncrAboveMax = 1
+	IncrBelowMin = 2
+	IncrClamped  = 3
+	IncrDeleted  = 4
+	IncrRepeated = 5
+)
+
+// BoundedIncr changes the counter KEYS[1] by ARGV[1], keeping it between the max in ARGV[2] and the min in ARGV[3]
+// (either may be empty). ARGV[4] is the counter type and ARGV[5] is "reject" to refuse decrements past the min
+// instead of clamping them to it. Returns {status, value, previous}, value being the unchanged value unless the
+// status is IncrApplied, IncrClamped or IncrDeleted. Only changes towards a bound are checked, so a counter that was
+// set outside its bounds can still be brought back. The bounds of integer counters are checked exactly, see
+// exactIntegers. A counter created by the change expires after ARGV[7] seconds, unless that is 0.
+//
+// If ARGV[6] is "delete", a decrement that takes the counter to zero or less (or past its min) deletes it instead,
+// along with its metadata (KEYS[2]), admin key (KEYS[3]), history (KEYS[4]), visitors (KEYS[6]) and snapshots
+// (KEYS[7]), no longer counting it in the namespace hash KEYS[5]. The status is IncrDeleted then, value being the value it reached.
+//
+// If ARGV[8] isn't empty, the change is a hit to a unique counter by that visitor, which is added to the visitors
+// KEYS[6] like RecordVisitor does with the window ARGV[9] once the change was made. The status is IncrRepeated, with
+// nothing changed, if they were counted already. A change that is refused or fails doesn't count the visitor, so the
+// hit can be retried.
+var BoundedIncr = redis.NewScript(exactIntegers + `
+local raw = redis.call('GET', KEYS[1])
+local created = not raw
+raw = raw or '0'
+local float = ARGV[4] == 'float'
+if not float and not string.match(raw, '^-?%d+$') then
+	return {0, redis.call('INCRBY', KEYS[1], ARGV[1]), raw} -- fails like INCRBY does on a value that isn't an integer
+end
+local function add(a, b)
+	if float then
+		return tonumber(a) + tonumber(b)
+	end
+	return intAdd(a, b)
+end
+local function cmp(a, b)
+	if not float then
+		return intCmp(a, b)
+	end
+	a, b = tonumber(a), tonumber(b)
+	if a == b then
+		return 0
+	end
+	return a < b and -1 or 1
+end
+local function expireCreated()
+	if created and tonumber(ARGV[7]) > 0 then
+		redis.call('EXPIRE', KEYS[1], ARGV[7])
+	end
+end
+local function countVisitor()
+	if ARGV[8] ~= '' and redis.call('SADD', KEYS[6], ARGV[8]) == 1 and redis.call('TTL', KEYS[6]) == -1 then
+		redis.call('EXPIRE', KEYS[6], ARGV[9])
+	end
+end
+if ARGV[8] ~= '' and redis.call('SISMEMBER', KEYS[6], ARGV[8]) == 1 then
+	return {5, raw, raw}
+end
+local amount, max, min = ARGV[1], ARGV[2], ARGV[3]
+local increment = cmp(amount, '0') > 0
+local value = add(raw, amount)
+if max ~= '' and increment and cmp(value, max) > 0 then
+	return {1, raw, raw}
+end
+if ARGV[6] == 'delete' and not increment and (cmp(value, '0') <= 0 or (min ~= '' and cmp(value, min) < 0)) then
+	if min ~= '' and cmp(value, min) < 0 then
+		value = min
+	end
+	redis.call('DEL', KEYS[1], KEYS[2], KEYS[3], KEYS[4], KEYS[6], KEYS[7])
+	if (tonumber(redis.call('HGET', KEYS[5], 'counters')) or 0) > 0 then
+		redis.call('HINCRBY', KEYS[5], 'counters', -1)
+	end
+	if float then
+		return {4, tostring(tonumber(value)), raw}
+	end
+	return {4, value, raw}
+end
+if min ~= '' and not increment and cmp(value, min) < 0 then
+	if ARGV[5] == 'reject' then
+		return {2, raw, raw}
+	end
+	countVisitor()
+	if cmp(raw, min) <= 0 then
+		return {3, raw, raw}
+	end
+	redis.call('SET', KEYS[1], min, 'KEEPTTL')
+	expireCreated()
+	return {3, min, raw}
+end
+local result
+if float then
+	result = redis.call('INCRBYFLOAT', KEYS[1], amount)
+else
+	result = redis.call('INCRBY', KEYS[1], amount)
+end
+countVisitor()
+expireCreated()
+return {0, result, raw}
+`)
+
+// IncrBounded queues running BoundedIncr with the bounds of a counter on pipe. A counter it creates gets the expiry
+// a new one would: the default TTL, or its custom one. visitor is the visitor of a hit to a unique counter, empty for
+// other changes.
+func IncrBounded(ctx context.Context, pipe redis.Pipeliner, dbKey string, meta Metadata, amount, visitor string) *redis.Cmd {
+	var maxValue, minValue, minMode string
+	if meta.HasMax {
+		maxValue = strconv.FormatFloat(meta.Max, 'f', -1, 64)
+	}
+	if meta.HasMin {
+		minValue = strconv.FormatFloat(meta.Min, 'f', -1, 64)
+	}
+	if meta.RejectBelowMin {
+		minMode = "reject"
+	}
+	keys, deleteMode := []string{dbKey}, ""
+	if meta.DeleteAtZero || visitor != "" {
+		keys = append(keys, CreateMetaKey(dbKey), CreateAdminKey(dbKey), CreateHistoryKey(dbKey), CreateNamespaceKey(NamespaceOf(dbKey)),
+			CreateVisitorsKey(dbKey), CreateSnapshotsKey(dbKey))
+	}
+	if meta.DeleteAtZero {
+		deleteMode = "delete"
+	}
+	ttl := BaseTTLPeriod
+	if meta.CustomTTL {
+		ttl = meta.TTL
+	}
+	return BoundedIncr.Eval(ctx, pipe, keys, amount, maxValue, minValue, meta.Type, minMode, deleteMode, int64(ttl.Seconds()),
+		visitor, int64(meta.UniqueWindow.Seconds()))
+}
+
+// UniqueIncr changes the counter KEYS[1] by ARGV[1] (with INCRBYFLOAT if ARGV[4] is "float") for a hit to a unique
+// counter that has no bounds, only if the visitor ARGV[2] is new to its visitors KEYS[2]. They are added to them like
+// RecordVisitor does with the window ARGV[3] once the change was made, so a hit that fails can be retried. Returns
+// {status, value, previous} like BoundedIncr, the status being IncrApplied or IncrRepeated.
+var UniqueIncr = redis.NewScript(`
+local raw = redis.call('GET', KEYS[1]) or '0'
+if redis.call('SISMEMBER', KEYS[2], ARGV[2]) == 1 then
+	return {5, raw, raw}
+end
+local result
+if ARGV[4] == 'float' then
+	result = redis.call('INCRBYFLOAT', KEYS[1], ARGV[1])
+else
+	result = redis.call('INCRBY', KEYS[1], ARGV[1])
+end
+redis.call('SADD', KEYS[2], ARGV[2])
+if redis.call('TTL', KEYS[2]) == -1 then
+	redis.call('EXPIRE', KEYS[2], ARGV[3])
+end
+return {0, result, raw}
+`)
+
+// IncrVisit queues the change a hit by visitor makes to a unique counter on pipe: with BoundedIncr if it has bounds,
+// with UniqueIncr otherwise.
+func IncrVisit(ctx context.Context, pipe redis.Pipeliner, dbKey string, meta Metadata, amount, visitor string) *redis.Cmd {
+	if meta.Bounded() {
+		return IncrBounded(ctx, pipe, dbKey, meta, amount, visitor)
+	}
+	return UniqueIncr.Eval(ctx, pipe, []string{dbKey, CreateVisitorsKey(dbKey)}, amount, visitor, int64(meta.UniqueWindow.Seconds()), meta.Type)
+}
+
+// Results of RenameCounter.
+const (
+	RenameMissing = 0
+	RenameExists  = 1
+	RenameDone    = 2
+)
+
+// RenameCounter renames the counter KEYS[1] to KEYS[7] with RENAMENX, moving its metadata (KEYS[2]), admin key
+// (KEYS[3]), history (KEYS[4]), visitors (KEYS[5]) and snapshots (KEYS[6]) along with it to KEYS[8] through KEYS[12].
+// Leftovers of an earlier counter at the target, which would otherwise be mistaken for the renamed counter's own, are
+// removed. Returns RenameMissing if there is no counter to rename, RenameExists if the target already exists and
+// RenameDone once it was renamed.
+var RenameCounter = redis.NewScript(`
+if redis.call('EXISTS', KEYS[1]) == 0 then
+	return 0
+end
+if redis.call('RENAMENX', KEYS[1], KEYS[7]) == 0 then
+	return 1
+end
+for i = 2, 6 do
+	if redis.call('EXISTS', KEYS[i]) == 1 then
+		redis.call('RENAME', KEYS[i], KEYS[i + 6])
+	else
+		redis.call('DEL', KEYS[i + 6])
+	end
+end
+return 2
+`)
+
+// RenameCounterKeys returns the keys RenameCounter needs to rename the counter dbKey to newDBKey.
+func RenameCounterKeys(dbKey, newDBKey string) []string {
+	return []string{
+		dbKey, CreateMetaKey(dbKey), CreateAdminKey(dbKey), CreateHistoryKey(dbKey), CreateVisitorsKey(dbKey),
+		CreateSnapshotsKey(dbKey),
+		newDBKey, CreateMetaKey(newDBKey), CreateAdminKey(newDBKey), CreateHistoryKey(newDBKey), CreateVisitorsKey(newDBKey),
+		CreateSnapshotsKey(newDBKey),
+	}
+}
+
+// Results of MergeCounters.
+const (
+	MergeSourceMissing = 0
+	MergeTargetMissing = 1
+	MergeNotInteger    = 2
+	MergeDone          = 3
+)
+
+// MergeCounters adds the value of the counter KEYS[2] to the counter KEYS[1], deleting the source along with its
+// metadata (KEYS[3]), admin key (KEYS[4]), history (KEYS[6]), visitors (KEYS[7]) and snapshots (KEYS[8]) if ARGV[2]
+// is "delete", which no longer counts it in the namespace hash KEYS[5]. ARGV[1] is the type of the target. Returns
+// {MergeDone, total} once merged, or just the reason it wasn't: MergeSourceMissing, MergeTargetMissing, or
+// MergeNotInteger if the source holds a decimal value that an integer target can't take.
+var MergeCounters = redis.NewScript(`
+local source = redis.call('GET', KEYS[2])
+if not source then
+	return {0}
+end
+if redis.call('EXISTS', KEYS[1]) == 0 then
+	return {1}
+end
+local total
+if ARGV[1] == 'float' then
+	total = redis.call('INCRBYFLOAT', KEYS[1], source)
+elseif string.match(source, '^-?%d+$') then
+	total = redis.call('INCRBY', KEYS[1], source)
+else
+	return {2}
+end
+if ARGV[2] == 'delete' then
+	redis.call('DEL', KEYS[2], KEYS[3], KEYS[4], KEYS[6], KEYS[7], KEYS[8])
+	if (tonumber(redis.call('HGET', KEYS[5], 'counters')) or 0) > 0 then
+		redis.call('HINCRBY', KEYS[5], 'counters', -1)
+	end
+end
+return {3, total}
+`)
+
+// Results of CreateCounter.
+const (
+	CreateExists = 0
+	CreateFull   = 1
+	CreateDone   = 2
+)
+
+// CreateCounter creates the counter KEYS[1] with the value ARGV[1], expiring after ARGV[2] seconds unless that is 0,
+// and counts it in the namespace hash KEYS[2]. The namespace may hold at most the max_counters field of its hash, or
+// ARGV[3] if that isn't set, a limit of 0 meaning no limit. Returns {CreateExists} if the counter already exists,
+// {CreateFull, limit} if the namespace is at its limit and {CreateDone} once it was created.
+var CreateCounter = redis.NewScript(`
+if redis.call('EXISTS', KEYS[1]) == 1 then
+	return {0}
+end
+local limit = tonumber(redis.call('HGET', KEYS[2], 'max_counters') or ARGV[3]) or 0
+local count = tonumber(redis.call('HGET', KEYS[2], 'counters') or '0') or 0
+if limit > 0 and count >= limit then
+	return {1, limit}
+end
+if tonumber(ARGV[2]) > 0 then
+	redis.call('SET', KEYS[1], ARGV[1], 'EX', ARGV[2])
+else
+	redis.call('SET', KEYS[1], ARGV[1])
+end
+redis.call('HINCRBY', KEYS[2], 'counters', 1)
+return {2}
+`)
+
+// DeleteCounter deletes the counter KEYS[1] along with its metadata (KEYS[2]), admin key (KEYS[3]), history (KEYS[5]),
+// visitors (KEYS[6]) and snapshots (KEYS[7]), no longer counting it in the namespace hash KEYS[4]. Returns 1 if the
+// counter existed, 0 otherwise.
+var DeleteCounter = redis.NewScript(`
+local deleted = redis.call('DEL', KEYS[1])
+redis.call('DEL', KEYS[2], KEYS[3], KEYS[5], KEYS[6], KEYS[7])
+if deleted == 1 and (tonumber(redis.call('HGET', KEYS[4], 'counters')) or 0) > 0 then
+	redis.call('HINCRBY', KEYS[4], 'counters', -1)
+end
+return deleted
+`)
+
+// DeleteCounterKeys returns the keys DeleteCounter needs to delete the counter dbKey of namespace.
+func DeleteCounterKeys(namespace, dbKey string) []string {
+	return []string{dbKey, CreateMetaKey(dbKey), CreateAdminKey(dbKey), CreateNamespaceKey(namespace), CreateHistoryKey(dbKey),
+		CreateVisitorsKey(dbKey), CreateSnapshotsKey(dbKey)}
+}
+
+// RecordVisitor adds the visitor ARGV[1] to the set KEYS[1] of the visitors of a unique counter, which expires ARGV[2]
+// seconds after its first visitor, starting the next window. Returns 1 if the visitor is new to the window, 0 if they
+// were counted already.
+var RecordVisitor = redis.NewScript(`
+local added = redis.call('SADD', KEYS[1], ARGV[1])
+if added == 1 and redis.call('TTL', KEYS[1]) == -1 then
+	redis.call('EXPIRE', KEYS[1], ARGV[2])
+end
+return added
+`)
+
+// Results of ApplyTransaction.
+const (
+	TransactionDone       = 0
+	TransactionAboveMax   = 1
+	TransactionBelowMin   = 2
+	TransactionNotInteger = 3
+	TransactionOverflow   = 4
+)
+
+// TransactionArgs is the number of ARGV entries ApplyTransaction takes per counter.
+const TransactionArgs = 4
+
+// ApplyTransaction changes all the counters in KEYS, or none of them. Each counter takes TransactionArgs entries of
+// ARGV: the amount to change it by, its max and min (either may be empty) and its type. Counters may appear more
+// than once, each change then applies to the result of the previous one. Redis doesn't roll back scripts that fail
+// halfway, so every change is checked before any of them is made: a change towards a bound that would pass it
+// returns {TransactionAboveMax or TransactionBelowMin, index, value}, one that would take the counter past what it
+// can hold (an int64, or a finite float) returns {TransactionOverflow, index, value}, and an integer counter holding
+// a decimal value returns {TransactionNotInteger, index, value}, index starting at 1. Otherwise it returns
+// {TransactionDone, values}, values being the value after each change.
+var ApplyTransaction = redis.NewScript(exactIntegers + `
+local values = {}
+for i, key in ipairs(KEYS) do
+	local base = (i - 1) * 4
+	local amount, max, min = ARGV[base + 1], ARGV[base + 2], ARGV[base + 3]
+	local increment = string.sub(amount, 1, 1) ~= '-'
+	local raw = values[key] or redis.call('GET', key) or '0'
+	if ARGV[base + 4] == 'float' then
+		local value = tonumber(raw) + tonumber(amount)
+		if value ~= value or value == math.huge or value == -math.huge then
+			return {4, i, raw}
+		end
+		if max ~= '' and increment and value > tonumber(max) then
+			return {1, i, raw}
+		end
+		if min ~= '' and not increment and value < tonumber(min) then
+			return {2, i, raw}
+		end
+		values[key] = tostring(value)
+	else
+		if not string.match(raw, '^-?%d+$') then
+			return {3, i, raw}
+		end
+		local value = intAdd(raw, amount)
+		if not intInRange(value) then
+			return {4, i, raw}
+		end
+		if max ~= '' and increment and intCmp(value, max) > 0 then
+			return {1, i, raw}
+		end
+		if min ~= '' and not increment and intCmp(value, min) < 0 then
+			return {2, i, raw}
+		end
+		values[key] = value
+	end
+end
+local results = {}
+for i, key in ipairs(KEYS) do
+	local base = (i - 1) * 4
+	if ARGV[base + 4] == 'float' then
+		results[i] = redis.call('INCRBYFLOAT', key, ARGV[base + 1])
+	else
+		results[i] = redis.call('INCRBY', key, ARGV[base + 1])
+	end
+end
+return {0, results}
+`)
@@ -0,0 +1,85 @@
+package utils
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	DefaultShareTTL = time.Hour
+	MaxShareTTL     = 30 * 24 * time.Hour
+	// sharePrefix starts every share token, so they can be told apart from admin tokens and JWTs.
+	sharePrefix = "share_"
+)
+
+// namespaceShareField is the field of the namespace hash holding the secret the share tokens of its counters are signed
+// with. Deleting it revokes all of them at once.
+const namespaceShareField = "share_secret"
+
+// IsShareToken reports whether token looks like a share token, see SignShareToken.
+func IsShareToken(token string) bool {
+	return strings.HasPrefix(token, sharePrefix)
+}
+
+// SignShareToken returns a token granting read access to the counter dbKey until expires, in the form of
+// share_{expires in unix seconds}_{hex encoded HMAC-SHA256 of the db key and expiry, keyed with secret}.
+func SignShareToken(secret, dbKey string, expires time.Time) string {
+	unix := strconv.FormatInt(expires.Unix(), 10)
+	return sharePrefix + unix + "_" + shareSignature(secret, dbKey, unix)
+}
+
+func shareSignature(secret, dbKey, expires string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(dbKey + "\n" + expires))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// GetShareSecret returns the secret the share tokens of namespace are signed with, creating one if it has none yet.
+func GetShareSecret(ctx context.Context, client redis.UniversalClient, namespace string) (string, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	namespaceKey := CreateNamespaceKey(namespace)
+	pipe := client.TxPipeline()
+	pipe.HSetNX(ctx, namespaceKey, namespaceShareField, hex.EncodeToString(raw))
+	secret := pipe.HGet(ctx, namespaceKey, namespaceShareField)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return "", err
+	}
+	return secret.Val(), nil
+}
+
+// RevokeShareTokens invalidates every share token of namespace by dropping their secret, the next one gets a new one.
+func RevokeShareTokens(ctx context.Context, client redis.UniversalClient, namespace string) error {
+	return client.HDel(ctx, CreateNamespaceKey(namespace), namespaceShareField).Err()
+}
+
+// CheckShareToken reports whether token is a share token of the counter dbKey in namespace that hasn't expired or been
+// revoked yet.
+func CheckShareToken(ctx context.Context, client redis.UniversalClient, namespace, dbKey, token string) (bool, error) {
+	parts := strings.Split(strings.TrimPrefix(token, sharePrefix), "_")
+	if !IsShareToken(token) || len(parts) != 2 {
+		return false, nil
+	}
+	expires, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil || time.Now().Unix() >= expires {
+		return false, nil
+	}
+	secret, err := client.HGet(ctx, CreateNamespaceKey(namespace), namespaceShareField).Result()
+	if errors.Is(err, redis.Nil) {
+		return false, nil // revoked, or never shared
+	} else if err != nil {
+		return false, err
+	}
+	return hmac.Equal([]byte(parts[1]), []byte(shareSignature(secret, dbKey, parts[0]))), nil
+}
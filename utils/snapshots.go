@@ -0,0 +1,95 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// snapshotTimeFieldPrefix starts the fields of the snapshot hash (S:) holding when each snapshot was taken, e.g.
+// at:eoy2024 = 1735689600000, next to the field holding the value it froze, e.g. eoy2024 = 1042. Snapshot names
+// can't contain the ":", so the two can't be mixed up.
+const snapshotTimeFieldPrefix = "at:"
+
+// Snapshot is the value of a counter frozen at the time it was taken.
+type Snapshot struct {
+	Name    string
+	Value   string // as stored, the caller parses it by the type of the counter
+	TakenAt time.Time
+}
+
+// ValidateSnapshotName checks that a snapshot name is short and plain enough to be used in urls without encoding.
+func ValidateSnapshotName(name string) error {
+	if !tagPattern.MatchString(name) {
+		return fmt.Errorf("invalid snapshot name %q: must match ^[A-Za-z0-9_\\-.]{1,64}$", name)
+	}
+	return nil
+}
+
+// Results of TakeSnapshot.
+const (
+	SnapshotMissing = 0
+	SnapshotExists  = 1
+	SnapshotFull    = 2
+	SnapshotTaken   = 3
+)
+
+// TakeSnapshot copies the value of the counter KEYS[1] into the snapshot ARGV[1] of its snapshot hash KEYS[2], taken
+// at ARGV[2] (unix millis). Snapshots never change once taken, and a counter can have at most ARGV[3] of them. The
+// hash is given the expiry of the counter, see QueueSnapshotsExpiry. Returns {SnapshotTaken, value} once taken, or just the reason it wasn't: SnapshotMissing if there is no counter,
+// SnapshotExists if the name is taken already or SnapshotFull.
+var TakeSnapshot = redis.NewScript(`
+local value = redis.call('GET', KEYS[1])
+if not value then
+	return {0}
+end
+if redis.call('HEXISTS', KEYS[2], ARGV[1]) == 1 then
+	return {1}
+end
+if redis.call('HLEN', KEYS[2]) / 2 >= tonumber(ARGV[3]) then
+	return {2}
+end
+redis.call('HSET', KEYS[2], ARGV[1], value, 'at:' .. ARGV[1], ARGV[2])
+local ttl = redis.call('PTTL', KEYS[1])
+if ttl > 0 then
+	redis.call('PEXPIRE', KEYS[2], ttl)
+end
+return {3, value}
+`)
+
+// QueueSnapshotsExpiry queues giving the snapshots of the counter dbKey the expiry ttl on pipe. The snapshots are kept
+// for as long as the counter is, so this is queued whenever its expiry is pushed forward.
+func QueueSnapshotsExpiry(ctx context.Context, pipe redis.Pipeliner, dbKey string, ttl time.Duration) {
+	pipe.Expire(ctx, CreateSnapshotsKey(dbKey), ttl)
+}
+
+// GetSnapshots returns the snapshots of the counter dbKey, oldest first.
+func GetSnapshots(ctx context.Context, client redis.UniversalClient, dbKey string) ([]Snapshot, error) {
+	fields, err := client.HGetAll(ctx, CreateSnapshotsKey(dbKey)).Result()
+	if err != nil {
+		return nil, err
+	}
+	snapshots := make([]Snapshot, 0, len(fields)/2)
+	for name, value := range fields {
+		if strings.HasPrefix(name, snapshotTimeFieldPrefix) {
+			continue
+		}
+		snapshot := Snapshot{Name: name, Value: value}
+		if takenAt, err := strconv.ParseInt(fields[snapshotTimeFieldPrefix+name], 10, 64); err == nil {
+			snapshot.TakenAt = time.UnixMilli(takenAt)
+		}
+		snapshots = append(snapshots, snapshot)
+	}
+	sort.Slice(snapshots, func(i, j int) bool {
+		if !snapshots[i].TakenAt.Equal(snapshots[j].TakenAt) {
+			return snapshots[i].TakenAt.Before(snapshots[j].TakenAt)
+		}
+		return snapshots[i].Name < snapshots[j].Name
+	})
+	return snapshots, nil
+}
@@ -28,11 +28,18 @@ var (
 	StatsManager *StatManager
 )
 
+// Counters exposed via /metrics. Unlike Total, these are never reset while the server is running.
+var (
+	RequestsServed atomic.Int64
+	RateLimited    atomic.Int64
+	RedisErrors    atomic.Int64
+)
+
 type StatManager struct {
 	stats     *sync.Map
 	buffer    chan statsEntry
 	pathCount atomic.Int64
-	client    *redis.Client
+	client    redis.UniversalClient
 	saveMutex sync.Mutex
 }
 
@@ -50,7 +57,7 @@ type StatsSnapshot struct {
 	PathStats  map[string]int64 `json:"path_stats"`
 }
 
-func NewStatsManager(client *redis.Client) *StatManager {
+func NewStatsManager(client redis.UniversalClient) *StatManager {
 	sm := &StatManager{
 		stats:  &sync.Map{},
 		buffer: make(chan statsEntry, batchSize),
@@ -65,7 +72,7 @@ func NewStatsManager(client *redis.Client) *StatManager {
 
 func (sm *StatManager) getStatsSnapshot() *StatsSnapshot {
 	snapshot := &StatsSnapshot{
-		Timestamp:  time.Now(),
+		Timestamp:  time.Now().In(Location),
 		Total:      atomic.LoadInt64(&Total),
 		PathCount:  sm.pathCount.Load(),
 		BufferSize: len(sm.buffer),
@@ -185,7 +192,7 @@ func (sm *StatManager) RecordStat(path string, count int64) {
 	sm.buffer <- entry
 }
 
-func InitializeStatsManager(client *redis.Client) *StatManager {
+func InitializeStatsManager(client redis.UniversalClient) *StatManager {
 	sm := NewStatsManager(client)
 	StatsManager = sm
 
@@ -0,0 +1,73 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// tagFieldPrefix starts the fields of the metadata hash (M:) holding the tags of a counter, e.g. tag:env = prod.
+const tagFieldPrefix = "tag:"
+
+var tagPattern = regexp.MustCompile(`^[A-Za-z0-9_\-.]{1,64}$`)
+
+// ParseTags parses tags given as NAME:VALUE, such as those of ?tag=env:prod&tag=team:web.
+func ParseTags(raw []string) (map[string]string, error) {
+	tags := make(map[string]string, len(raw))
+	for _, tag := range raw {
+		name, value, ok := strings.Cut(tag, ":")
+		if !ok {
+			return nil, fmt.Errorf("invalid tag %q: must be in the fmt of NAME:VALUE", tag)
+		}
+		if _, duplicate := tags[name]; duplicate {
+			return nil, fmt.Errorf("invalid tag %q: %s is given more than once", tag, name)
+		}
+		tags[name] = value
+	}
+	return tags, ValidateTags(tags)
+}
+
+// ValidateTags checks that there aren't too many tags, and that their names and values are short and plain enough to
+// be used in urls without encoding.
+func ValidateTags(tags map[string]string) error {
+	if len(tags) > MaxTags {
+		return fmt.Errorf("a counter can't have more than %d tags", MaxTags)
+	}
+	for name, value := range tags {
+		if !tagPattern.MatchString(name) || !tagPattern.MatchString(value) {
+			return fmt.Errorf("invalid tag %q: name and value must match the pattern %s", name+":"+value, tagPattern)
+		}
+	}
+	return nil
+}
+
+// MatchTags reports which of the counters dbKeys carry all of tags.
+func MatchTags(ctx context.Context, client redis.UniversalClient, dbKeys []string, tags map[string]string) ([]bool, error) {
+	fields := make([]string, 0, len(tags))
+	for name := range tags {
+		fields = append(fields, tagFieldPrefix+name)
+	}
+	pipe := client.Pipeline()
+	cmds := make([]*redis.SliceCmd, len(dbKeys))
+	for i, dbKey := range dbKeys {
+		cmds[i] = pipe.HMGet(ctx, CreateMetaKey(dbKey), fields...)
+	}
+	if _, err := pipe.Exec(ctx); err != nil && !errors.Is(err, redis.Nil) {
+		return nil, err
+	}
+	matches := make([]bool, len(dbKeys))
+	for i, cmd := range cmds {
+		matches[i] = true
+		for j, value := range cmd.Val() {
+			if value != tags[strings.TrimPrefix(fields[j], tagFieldPrefix)] { // nil if the counter lacks the tag
+				matches[i] = false
+				break
+			}
+		}
+	}
+	return matches, nil
+}
@@ -0,0 +1,51 @@
+package utils
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseTags(t *testing.T) {
+	tooMany := make([]string, MaxTags+1)
+	for i := range tooMany {
+		tooMany[i] = "tag" + strconv.Itoa(i) + ":value"
+	}
+	testCases := []struct {
+		name     string
+		input    []string
+		expected map[string]string
+		valid    bool
+	}{
+		{"none", nil, map[string]string{}, true},
+		{"several", []string{"env:prod", "team:web"}, map[string]string{"env": "prod", "team": "web"}, true},
+		{"missing value", []string{"env"}, nil, false},
+		{"empty value", []string{"env:"}, nil, false},
+		{"empty name", []string{":prod"}, nil, false},
+		{"colon in value", []string{"env:prod:eu"}, nil, false},
+		{"whitespace", []string{"env:pr od"}, nil, false},
+		{"too long", []string{"env:" + strings.Repeat("a", 65)}, nil, false},
+		{"duplicate", []string{"env:prod", "env:dev"}, nil, false},
+		{"too many", tooMany, nil, false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tags, err := ParseTags(tc.input)
+			assert.Equal(t, tc.valid, err == nil, err)
+			if tc.valid {
+				assert.Equal(t, tc.expected, tags)
+			}
+		})
+	}
+}
+
+func TestTagMetadata(t *testing.T) {
+	fields := Metadata{Tags: map[string]string{"env": "prod"}}.fields()
+	assert.Equal(t, map[string]interface{}{"tag:env": "prod"}, fields)
+	meta := ParseMetadata(map[string]string{"type": "int", "tag:env": "prod"})
+	assert.Equal(t, map[string]string{"env": "prod"}, meta.Tags)
+	assert.Nil(t, ParseMetadata(map[string]string{"type": "int"}).Tags)
+}
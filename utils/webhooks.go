@@ -0,0 +1,137 @@
+package utils
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net"
+	"net/http"
+	"net/url"
+	"syscall"
+	"time"
+
+	"github.com/goccy/go-json"
+)
+
+const (
+	webhookAttempts = 3
+	webhookTimeout  = 5 * time.Second
+)
+
+// AllowPrivateWebhooks lets webhooks be sent to loopback, private and link-local addresses, for self-hosted servers
+// whose webhooks are meant for their own network. Otherwise anyone could have the server POST to its internals.
+var AllowPrivateWebhooks = false
+
+var errPrivateWebhook = errors.New("host must not resolve to a loopback, private, link-local or multicast address")
+
+// webhookClient checks every address it connects to once more, as the DNS of a host checked by ValidateWebhookURL
+// may resolve to another address by the time the webhook is sent, and redirects may go anywhere. It ignores the
+// proxy settings of the environment, whose address would be checked instead.
+var webhookClient = &http.Client{
+	Timeout: webhookTimeout,
+	Transport: &http.Transport{
+		DialContext: (&net.Dialer{
+			Timeout: webhookTimeout,
+			Control: func(network, address string, _ syscall.RawConn) error {
+				host, _, err := net.SplitHostPort(address)
+				if err != nil {
+					return err
+				}
+				if ip := net.ParseIP(host); ip == nil || !webhookAddressAllowed(ip) {
+					return errPrivateWebhook
+				}
+				return nil
+			},
+		}).DialContext,
+		TLSHandshakeTimeout: webhookTimeout,
+	},
+}
+
+// WebhookPayload is the body POSTed to a counter's webhook when it crosses a threshold.
+type WebhookPayload struct {
+	Namespace string      `json:"namespace"`
+	Key       string      `json:"key"`
+	Value     interface{} `json:"value"`
+	Timestamp string      `json:"timestamp"` // when the threshold was crossed, see FormatTime
+}
+
+// ValidateWebhookURL checks that a webhook url is an absolute http(s) url whose host only resolves to public
+// addresses, unless AllowPrivateWebhooks is set.
+func ValidateWebhookURL(rawURL string) error {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return err
+	}
+	if parsed.Scheme != "http" && parsed.Scheme != "https" {
+		return fmt.Errorf("scheme must be either http or https")
+	}
+	if parsed.Host == "" {
+		return fmt.Errorf("host is required")
+	}
+	if AllowPrivateWebhooks {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), webhookTimeout)
+	defer cancel()
+	addresses, err := net.DefaultResolver.LookupIPAddr(ctx, parsed.Hostname())
+	if err != nil {
+		return fmt.Errorf("host can't be resolved")
+	}
+	for _, address := range addresses {
+		if !webhookAddressAllowed(address.IP) {
+			return errPrivateWebhook
+		}
+	}
+	return nil
+}
+
+// webhookAddressAllowed reports whether webhooks may be sent to ip.
+func webhookAddressAllowed(ip net.IP) bool {
+	if AllowPrivateWebhooks {
+		return true
+	}
+	return !(ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsUnspecified() || ip.IsMulticast())
+}
+
+// CrossedThreshold reports whether going from previous to current passed (or reached) a multiple of every.
+func CrossedThreshold(previous, current float64, every int64) bool {
+	if every <= 0 {
+		return false
+	}
+	step := float64(every)
+	return math.Floor(current/step) != math.Floor(previous/step)
+}
+
+// SendWebhook POSTs payload to webhookURL, retrying with a backoff if it fails. It blocks until the webhook was
+// delivered or all attempts failed, so it should be run in its own goroutine.
+func SendWebhook(webhookURL string, payload WebhookPayload) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		log.Printf("Error encoding webhook payload: %v", err)
+		return
+	}
+	for attempt := 1; attempt <= webhookAttempts; attempt++ {
+		if err = postWebhook(webhookURL, body); err == nil {
+			return
+		}
+		if attempt < webhookAttempts {
+			time.Sleep(time.Duration(attempt) * time.Second)
+		}
+	}
+	log.Printf("Giving up on webhook %s after %d attempts: %v", webhookURL, webhookAttempts, err)
+}
+
+func postWebhook(webhookURL string, body []byte) error {
+	resp, err := webhookClient.Post(webhookURL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return nil
+}
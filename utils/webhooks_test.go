@@ -0,0 +1,67 @@
+package utils
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCrossedThreshold(t *testing.T) {
+	testCases := []struct {
+		previous, current float64
+		every             int64
+		expected          bool
+	}{
+		{9, 10, 10, true},
+		{10, 11, 10, false},
+		{995, 1005, 1000, true},
+		{0.5, 1.5, 1, true},
+		{1, 2, 0, false},
+	}
+	for _, tc := range testCases {
+		assert.Equal(t, tc.expected, CrossedThreshold(tc.previous, tc.current, tc.every), "%v -> %v every %d", tc.previous, tc.current, tc.every)
+	}
+}
+
+func TestValidateWebhookURL(t *testing.T) {
+	testCases := map[string]bool{
+		"https://93.184.215.14/hook":              true,
+		"http://[2606:4700::1111]/hook":           true,
+		"ftp://93.184.215.14/hook":                false,
+		"http:///hook":                            false,
+		"http://127.0.0.1:6379/":                  false,
+		"http://localhost/hook":                   false,
+		"http://[::1]/hook":                       false,
+		"http://10.0.0.1/hook":                    false,
+		"http://172.16.5.4/hook":                  false,
+		"http://192.168.1.1/hook":                 false,
+		"http://169.254.169.254/latest/meta-data": false,
+		"http://[fe80::1]/hook":                   false,
+		"http://[fd00::1]/hook":                   false,
+		"http://0.0.0.0/hook":                     false,
+		"http://224.0.0.1/hook":                   false,
+		"http://[::ffff:127.0.0.1]/hook":          false,
+	}
+	for rawURL, valid := range testCases {
+		err := ValidateWebhookURL(rawURL)
+		assert.Equal(t, valid, err == nil, "%s: %v", rawURL, err)
+	}
+}
+
+func TestWebhookClientRefusesPrivateAddresses(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("webhook reached a loopback address")
+	}))
+	defer server.Close()
+
+	// the url was validated when its host resolved elsewhere, the connection still mustn't be made
+	err := postWebhook(server.URL, []byte(`{}`))
+	assert.ErrorIs(t, err, errPrivateWebhook)
+
+	AllowPrivateWebhooks = true
+	defer func() { AllowPrivateWebhooks = false }()
+	server.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	assert.NoError(t, postWebhook(server.URL, []byte(`{}`)))
+}